@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/diademnetwork/go-diadem/util"
@@ -154,6 +155,19 @@ func (s *StoreState) FeatureEnabled(name string, val bool) bool {
 	return false
 }
 
+// Features returns the values of all the features that have been explicitly enabled or disabled
+// in the given state, keyed by feature name.
+func Features(state ReadOnlyState) map[string]bool {
+	features := map[string]bool{}
+	for _, entry := range state.Range([]byte(featurePrefix)) {
+		if len(entry.Key) == 0 {
+			continue
+		}
+		features[string(entry.Key)] = bytes.Equal(entry.Value, []byte{1})
+	}
+	return features
+}
+
 func (s *StoreState) SetFeature(name string, val bool) {
 	data := []byte{0}
 	if val {
@@ -217,6 +231,21 @@ func (s *StoreStateSnapshot) Release() {
 	}
 }
 
+// NewCachedStoreState returns a state that reads from the given state, and buffers all writes in
+// memory without ever applying them to the given state. This makes it possible to re-execute txs
+// against a read-only snapshot of the app state.
+func NewCachedStoreState(state State) State {
+	return &StoreState{
+		ctx:        state.Context(),
+		store:      store.WrapAtomic(state).BeginTx(),
+		block:      state.Block(),
+		validators: diadem.NewValidatorSet(),
+		getValidatorSet: func(_ State) (diadem.ValidatorSet, error) {
+			return diadem.NewValidatorSet(state.Validators()...), nil
+		},
+	}
+}
+
 // For all the times you need a read-only store.KVStore but you only have a store.KVReader.
 type readOnlyKVStoreAdapter struct {
 	store.KVReader
@@ -254,7 +283,7 @@ type QueryHandler interface {
 }
 
 type OriginHandler interface {
-	ValidateOrigin(input []byte, chainId string, currentBlockHeight int64) error
+	ValidateOrigin(state State, input []byte) error
 	Reset(currentBlockHeight int64)
 }
 
@@ -279,10 +308,14 @@ type ChainConfigManagerFactoryFunc func(state State) (ChainConfigManager, error)
 
 type Application struct {
 	lastBlockHeader abci.Header
+	lastBlockHash   []byte
 	curBlockHeader  abci.Header
 	curBlockHash    []byte
-	Store           store.VersionedKVStore
-	Init            func(State) error
+	// Held for writing while a block is being committed, and for reading while a read-only
+	// snapshot of the last committed block is being created.
+	commitMutex sync.RWMutex
+	Store       store.VersionedKVStore
+	Init        func(State) error
 	TxHandler
 	QueryHandler
 	EventHandler
@@ -560,7 +593,7 @@ func (a *Application) processTx(txBytes []byte, isCheckTx bool) (TxHandlerResult
 	)
 
 	if isCheckTx {
-		err := a.OriginHandler.ValidateOrigin(txBytes, state.Block().ChainID, state.Block().Height)
+		err := a.OriginHandler.ValidateOrigin(state, txBytes)
 		if err != nil {
 			storeTx.Rollback()
 			return TxHandlerResult{}, err
@@ -574,8 +607,12 @@ func (a *Application) processTx(txBytes []byte, isCheckTx bool) (TxHandlerResult
 	r, err := a.TxHandler.ProcessTx(state, txBytes, isCheckTx)
 	if err != nil {
 		storeTx.Rollback()
-		// TODO: save receipt & hash of failed EVM tx to node-local persistent cache (not app state)
-		receiptHandler.DiscardCurrentReceipt()
+		if isCheckTx {
+			receiptHandler.DiscardCurrentReceipt()
+		} else {
+			// the receipt of a failed EVM tx is kept in the node-local receipts DB (not app state)
+			receiptHandler.CommitFailedReceipt(txBytes)
+		}
 		return r, err
 	}
 
@@ -611,6 +648,9 @@ func (a *Application) Commit() abci.ResponseCommit {
 		commitBlockLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
+	a.commitMutex.Lock()
+	defer a.commitMutex.Unlock()
+
 	appHash, _, err := a.Store.SaveVersion()
 	if err != nil {
 		// Rollback evm.db by setting Patricia tree root to previous block
@@ -632,6 +672,7 @@ func (a *Application) Commit() abci.ResponseCommit {
 		}
 	}(height, a.curBlockHeader)
 	a.lastBlockHeader = a.curBlockHeader
+	a.lastBlockHash = a.curBlockHash
 
 	if err := a.Store.Prune(); err != nil {
 		log.Error("failed to prune app.db", "err", err)
@@ -647,7 +688,10 @@ func (a *Application) Query(req abci.RequestQuery) abci.ResponseQuery {
 		return abci.ResponseQuery{Code: 1, Log: "not implemented"}
 	}
 
-	result, err := a.QueryHandler.Handle(a.ReadOnlyState(), req.Path, req.Data)
+	snapshot := a.ReadOnlyState()
+	defer snapshot.Release()
+
+	result, err := a.QueryHandler.Handle(snapshot, req.Path, req.Data)
 	if err != nil {
 		return abci.ResponseQuery{Code: 1, Log: err.Error()}
 	}
@@ -659,23 +703,77 @@ func (a *Application) height() int64 {
 	return a.Store.Version() + 1
 }
 
+// StoreProof returns the value of the given key in the last committed app state, along with a
+// Merkle proof for the value (or its absence). The proof can be verified against the AppHash in
+// the header of the block following the one the proof was generated for.
+func (a *Application) StoreProof(key []byte) (*store.ValueProof, error) {
+	// Proofs are generated from the last saved version of the store, so make sure a new version
+	// isn't being saved (or old ones pruned) while the proof is being generated.
+	a.commitMutex.RLock()
+	defer a.commitMutex.RUnlock()
+
+	ps, ok := a.Store.(store.ProvableStore)
+	if !ok {
+		return nil, fmt.Errorf("app store doesn't support proofs")
+	}
+	return ps.GetWithProof(key)
+}
+
+// PruningStatus returns the progress of the background pruning of the app store.
+func (a *Application) PruningStatus() (*store.PruningStatus, error) {
+	pr, ok := a.Store.(store.PruningStatusReporter)
+	if !ok {
+		return nil, fmt.Errorf("app store doesn't prune old versions in the background")
+	}
+	return pr.PruningStatus()
+}
+
+// ReadOnlyStateAt returns a read-only snapshot of the app state as it was right after the block
+// with the given header & hash was committed. The app doesn't keep track of previous block headers,
+// so the caller must supply the header of the block at the height of interest.
+func (a *Application) ReadOnlyStateAt(block abci.Header, blockHash []byte) (State, error) {
+	// Prevent the store from saving or pruning versions while the snapshot is being created.
+	a.commitMutex.RLock()
+	defer a.commitMutex.RUnlock()
+
+	hs, ok := a.Store.(store.HistoricalStore)
+	if !ok {
+		return nil, fmt.Errorf("app store doesn't support historical state")
+	}
+	snapshot, err := hs.GetSnapshotAt(block.Height)
+	if err != nil {
+		return nil, err
+	}
+	return NewStoreStateSnapshot(nil, snapshot, block, blockHash, a.GetValidatorSet), nil
+}
+
+// ReadOnlyState returns a read-only snapshot of the app state as it was right after the last block
+// was committed. The block header, block hash, validator set, and store contents of the snapshot
+// are guaranteed to be from the same block, even if a new block is being committed concurrently.
 func (a *Application) ReadOnlyState() State {
-	// FIXME: Figure out a less ugly way to do this
-	var readOnlyStore store.Snapshot
-	// TODO: Caching store needs to be updated to handle real snapshots from MultiReaderIAVLStore
-	if cachingStore, ok := (a.Store.(*store.CachingStore)); ok {
-		readOnlyStore = store.NewReadOnlyCachingStore(cachingStore)
+	a.commitMutex.RLock()
+	defer a.commitMutex.RUnlock()
+
+	block := a.lastBlockHeader
+	blockHash := a.lastBlockHash
+	version := a.Store.Version()
+	// The last block header isn't persisted, so it won't be available until the first block is
+	// committed after the node restarts.
+	if block.Height != version {
+		block = abci.Header{ChainID: block.ChainID, Height: version}
+		blockHash = nil
+	}
+
+	var snapshot store.Snapshot
+	if hs, ok := a.Store.(store.HistoricalStore); ok && version > 0 {
+		var err error
+		if snapshot, err = hs.GetSnapshotAt(version); err != nil {
+			log.Error("failed to create snapshot of last committed state", "height", version, "err", err)
+			snapshot = a.Store.GetSnapshot()
+		}
 	} else {
-		readOnlyStore = a.Store.GetSnapshot()
+		snapshot = a.Store.GetSnapshot()
 	}
 
-	// TODO: the store snapshot should be created atomically, otherwise the block header might
-	//       not match the state... need to figure out why this hasn't spectacularly failed already
-	return NewStoreStateSnapshot(
-		nil,
-		readOnlyStore,
-		a.lastBlockHeader,
-		nil, // TODO: last block hash!
-		a.GetValidatorSet,
-	)
+	return NewStoreStateSnapshot(nil, snapshot, block, blockHash, a.GetValidatorSet)
 }
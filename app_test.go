@@ -0,0 +1,124 @@
+package diademchain
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/diademnetwork/diademchain/store"
+	diadem "github.com/diademnetwork/go-diadem"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+type nullEventDispatcher struct{}
+
+func (d *nullEventDispatcher) Send(blockHeight uint64, eventIndex int, msg []byte) error {
+	return nil
+}
+
+func (d *nullEventDispatcher) Flush() {}
+
+var testHeightKey = []byte("height")
+
+func testBlockHash(height int64) []byte {
+	return []byte(fmt.Sprintf("hash%d", height))
+}
+
+// Test that the block header, block hash, validator set, and store contents of read-only snapshots
+// always match each other, even while blocks are being committed.
+func TestReadOnlyStateConsistency(t *testing.T) {
+	iavlStore, err := store.NewIAVLStore(dbm.NewMemDB(), 0, 0)
+	require.NoError(t, err)
+	cachingStore, err := store.NewCachingStore(iavlStore, store.DefaultCachingStoreConfig())
+	require.NoError(t, err)
+
+	app := &Application{
+		Store:        cachingStore,
+		EventHandler: NewDefaultEventHandler(&nullEventDispatcher{}),
+		// the power of the only validator is the height at which the validator set was loaded
+		GetValidatorSet: func(state State) (diadem.ValidatorSet, error) {
+			var power int64
+			if buf := state.Get(testHeightKey); buf != nil {
+				power = int64(binary.BigEndian.Uint64(buf))
+			}
+			return diadem.NewValidatorSet(&diadem.Validator{PubKey: []byte("validator"), Power: power}), nil
+		},
+	}
+
+	const numBlocks = 500
+	const numReaders = 8
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	errs := make(chan error, numReaders)
+
+	for i := 0; i < numReaders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if err := checkReadOnlyState(app.ReadOnlyState()); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	for height := int64(1); height <= numBlocks; height++ {
+		app.curBlockHeader = abci.Header{Height: height}
+		app.curBlockHash = testBlockHash(height)
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, uint64(height))
+		app.Store.Set(testHeightKey, buf)
+		// some unrelated writes to keep the cache busy
+		app.Store.Set([]byte(fmt.Sprintf("key%d", height%10)), buf)
+		app.Commit()
+	}
+	close(done)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	state := app.ReadOnlyState()
+	require.NoError(t, checkReadOnlyState(state))
+	require.Equal(t, int64(numBlocks), state.Block().Height)
+}
+
+func checkReadOnlyState(state State) error {
+	defer state.Release()
+
+	height := state.Block().Height
+	if height == 0 {
+		if state.Has(testHeightKey) {
+			return fmt.Errorf("state at height 0 shouldn't contain any data")
+		}
+		return nil
+	}
+	if string(state.Block().CurrentHash) != string(testBlockHash(height)) {
+		return fmt.Errorf("block hash %s doesn't match height %d", state.Block().CurrentHash, height)
+	}
+	buf := state.Get(testHeightKey)
+	if buf == nil {
+		return fmt.Errorf("state at height %d is missing data", height)
+	}
+	if storedHeight := int64(binary.BigEndian.Uint64(buf)); storedHeight != height {
+		return fmt.Errorf("state written at height %d returned for height %d", storedHeight, height)
+	}
+	validators := state.Validators()
+	if len(validators) != 1 || validators[0].Power != height {
+		return fmt.Errorf("validator set doesn't match height %d", height)
+	}
+	return nil
+}
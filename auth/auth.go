@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/go-kit/kit/metrics"
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
@@ -14,6 +15,7 @@ import (
 	diadem "github.com/diademnetwork/go-diadem"
 	"github.com/diademnetwork/go-diadem/util"
 	"github.com/diademnetwork/diademchain"
+	genesiscfg "github.com/diademnetwork/diademchain/config/genesis"
 )
 
 var (
@@ -58,7 +60,7 @@ var SignatureTxMiddleware = diademchain.TxMiddlewareFunc(func(
 		return r, err
 	}
 
-	origin, err := GetOrigin(tx, state.Block().ChainID)
+	origin, err := ResolveOrigin(state, tx)
 	if err != nil {
 		return r, err
 	}
@@ -86,8 +88,49 @@ func GetOrigin(tx SignedTx, chainId string) (diadem.Address, error) {
 	}, nil
 }
 
+// ResolveOrigin verifies the signature of the given tx, and returns the address of the account
+// controlled by the key that signed it. The account address will differ from the address derived
+// from the key if the key of the account has been rotated.
+func ResolveOrigin(state diademchain.ReadOnlyState, tx SignedTx) (diadem.Address, error) {
+	origin, err := GetOrigin(tx, state.Block().ChainID)
+	if err != nil {
+		return diadem.Address{}, err
+	}
+	origin.Local, err = ResolveAccountKey(state, tx.PublicKey)
+	if err != nil {
+		return diadem.Address{}, err
+	}
+	return origin, nil
+}
+
+const noncePrefix = "nonce"
+
 func nonceKey(addr diadem.Address) []byte {
-	return util.PrefixKey([]byte("nonce"), addr.Bytes())
+	return util.PrefixKey([]byte(noncePrefix), addr.Bytes())
+}
+
+// ExportNonces returns the raw nonces of all the accounts in the given state, keyed by the raw
+// account address bytes.
+func ExportNonces(state diademchain.ReadOnlyState) []genesiscfg.StateEntry {
+	var nonces []genesiscfg.StateEntry
+	for _, entry := range state.Range([]byte(noncePrefix)) {
+		// keys that merely start with the prefix can't be unprefixed
+		if len(entry.Key) == 0 {
+			continue
+		}
+		nonces = append(nonces, genesiscfg.StateEntry{
+			Key:   entry.Key,
+			Value: entry.Value,
+		})
+	}
+	return nonces
+}
+
+// ImportNonces writes nonces previously obtained via ExportNonces to the given state.
+func ImportNonces(state diademchain.State, nonces []genesiscfg.StateEntry) {
+	for _, entry := range nonces {
+		state.Set(util.PrefixKey([]byte(noncePrefix), entry.Key), entry.Value)
+	}
 }
 
 func Nonce(state diademchain.ReadOnlyState, addr diadem.Address) uint64 {
@@ -97,6 +140,35 @@ func Nonce(state diademchain.ReadOnlyState, addr diadem.Address) uint64 {
 type NonceHandler struct {
 	nonceCache map[string]uint64
 	lastHeight int64
+
+	// Txs with sequence numbers higher than expected, waiting for the txs with the lower sequence
+	// numbers to arrive, nil unless the queue is enabled.
+	queue *nonceQueue
+	mutex sync.Mutex
+}
+
+// EnableNonceQueue allows txs with sequence numbers higher than the next expected one to wait in
+// a queue until the txs with the lower sequence numbers are received. Queued txs are sent back to
+// the mempool via the resubmit func once the gap is filled.
+func (n *NonceHandler) EnableNonceQueue(cfg *NonceQueueConfig, resubmit func(txBytes []byte)) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.queue = newNonceQueue(cfg, resubmit)
+}
+
+// PendingNonce returns the sequence number of the last tx sent by the given account that was
+// accepted into the mempool, the second return value will be false if there are no txs from the
+// account in the mempool.
+func (n *NonceHandler) PendingNonce(addr diadem.Address) (uint64, bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	nextSeq := n.nonceCache[addr.String()]
+	if nextSeq == 0 {
+		return 0, false
+	}
+	return nextSeq - 1, true
 }
 
 func (n *NonceHandler) Nonce(
@@ -110,12 +182,6 @@ func (n *NonceHandler) Nonce(
 	if origin.IsEmpty() {
 		return r, errors.New("transaction has no origin [nonce]")
 	}
-	if n.lastHeight != state.Block().Height {
-		n.lastHeight = state.Block().Height
-		n.nonceCache = make(map[string]uint64)
-		//clear the cache for each block
-	}
-	seq := diademchain.NewSequence(nonceKey(origin)).Next(state)
 
 	var tx NonceTx
 	err := proto.Unmarshal(txBytes, &tx)
@@ -123,6 +189,27 @@ func (n *NonceHandler) Nonce(
 		return r, err
 	}
 
+	if err := n.checkSequence(state, origin, &tx, isCheckTx); err != nil {
+		return r, err
+	}
+
+	return next(state, tx.Inner, isCheckTx)
+}
+
+func (n *NonceHandler) checkSequence(state diademchain.State, origin diadem.Address, tx *NonceTx, isCheckTx bool) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.lastHeight != state.Block().Height {
+		n.lastHeight = state.Block().Height
+		n.nonceCache = make(map[string]uint64)
+		//clear the cache for each block
+		if n.queue != nil {
+			n.queue.prune(state)
+		}
+	}
+	seq := diademchain.NewSequence(nonceKey(origin)).Next(state)
+
 	//TODO nonce cache is temporary until we have a separate atomic state for the entire checktx flow
 	cacheSeq := n.nonceCache[origin.String()]
 	//If we have a client send multiple transactions in a single block we can run into this problem
@@ -133,11 +220,17 @@ func (n *NonceHandler) Nonce(
 	}
 
 	if tx.Sequence != seq {
+		if tx.Sequence > seq && isCheckTx && n.queue != nil {
+			if err := n.queue.add(state, origin, tx.Sequence, seq); err != nil {
+				nonceErrorCount.Add(1)
+				return err
+			}
+			return fmt.Errorf("tx with sequence number %d queued until txs with sequence numbers from %d are received", tx.Sequence, seq)
+		}
 		nonceErrorCount.Add(1)
-		return r, fmt.Errorf("sequence number does not match expected %d got %d", seq, tx.Sequence)
+		return fmt.Errorf("sequence number does not match expected %d got %d", seq, tx.Sequence)
 	}
-
-	return next(state, tx.Inner, isCheckTx)
+	return nil
 }
 
 func (n *NonceHandler) IncNonce(state diademchain.State,
@@ -150,9 +243,16 @@ func (n *NonceHandler) IncNonce(state diademchain.State,
 		return errors.New("transaction has no origin [IncNonce]")
 	}
 
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
 	//We only increment the nonce if the transaction is successful
 	//There are situations in checktx where we may not have committed the transaction to the statestore yet
 	n.nonceCache[origin.String()] = n.nonceCache[origin.String()] + 1
+	// the tx with the next sequence number may be waiting in the queue
+	if n.queue != nil {
+		n.queue.release(origin, n.nonceCache[origin.String()])
+	}
 	return nil
 }
 
@@ -160,3 +260,9 @@ var NonceTxHandler = NonceHandler{nonceCache: make(map[string]uint64), lastHeigh
 
 var NonceTxPostNonceMiddleware = diademchain.PostCommitMiddlewareFunc(NonceTxHandler.IncNonce)
 var NonceTxMiddleware = diademchain.TxMiddlewareFunc(NonceTxHandler.Nonce)
+
+// PendingNonce returns the sequence number of the last tx sent by the given account that was
+// accepted into the mempool.
+func PendingNonce(addr diadem.Address) (uint64, bool) {
+	return NonceTxHandler.PendingNonce(addr)
+}
@@ -3,6 +3,9 @@ package auth
 type Config struct {
 	// Per-chain tx signing config, indexed by chain ID
 	Chains map[string]ChainConfig
+	// Max number of blocks a session key can remain valid for after it's granted, zero means
+	// session keys can remain valid indefinitely.
+	MaxSessionKeyLifetime int64
 }
 
 type ChainConfig struct {
@@ -14,9 +17,11 @@ func DefaultConfig() *Config {
 	chains := make(map[string]ChainConfig)
 	chains["default"] = ChainConfig{TxType: "diadem"}
 	chains["eth"] = ChainConfig{TxType: "eth", AccountType: 1}
+	chains["multisig"] = ChainConfig{TxType: "multisig", AccountType: 0}
 
 	return &Config{
-		Chains: chains,
+		Chains:                chains,
+		MaxSessionKeyLifetime: 86400, // roughly a day with 1 second blocks
 	}
 }
 
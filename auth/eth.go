@@ -3,8 +3,16 @@
 package auth
 
 import (
+	"math/big"
+
+	etypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/gogo/protobuf/proto"
+	"github.com/diademnetwork/go-diadem"
 	"github.com/diademnetwork/go-diadem/common/evmcompat"
+	"github.com/diademnetwork/go-diadem/types"
+	"github.com/diademnetwork/go-diadem/vm"
 	sha3 "github.com/miguelmota/go-solidity-sha3"
 	"github.com/pkg/errors"
 )
@@ -29,3 +37,87 @@ func verifyTron(tx SignedTx) ([]byte, error) {
 	}
 	return crypto.PubkeyToAddress(*publicKey).Bytes(), nil
 }
+
+// ethRLPTxInfo returns the hash & gas parameters of an RLP encoded Ethereum tx. The EVM doesn't
+// charge intrinsic gas, so the gas limit only has to cover the gas used by the contract code.
+func ethRLPTxInfo(txBytes []byte) (*EthTxInfo, error) {
+	var ethTx etypes.Transaction
+	if err := rlp.DecodeBytes(txBytes, &ethTx); err != nil {
+		return nil, errors.Wrap(err, "failed to decode Ethereum tx")
+	}
+	if ethTx.Gas() == 0 {
+		return nil, errors.New("Ethereum tx gas limit must be greater than zero")
+	}
+	return &EthTxInfo{
+		Hash:     ethTx.Hash().Bytes(),
+		GasLimit: ethTx.Gas(),
+		GasPrice: ethTx.GasPrice(),
+	}, nil
+}
+
+// ethRLPTxToNonceTx recovers the sender of an EIP-155 signed Ethereum tx, and converts the tx to
+// the equivalent NonceTx. The sender address will have the given sender chain ID, and the contract
+// address will have the chain ID of this chain.
+func ethRLPTxToNonceTx(
+	txBytes []byte, ethChainID *big.Int, senderChainID, chainID string,
+) ([]byte, error) {
+	var ethTx etypes.Transaction
+	if err := rlp.DecodeBytes(txBytes, &ethTx); err != nil {
+		return nil, errors.Wrap(err, "failed to decode Ethereum tx")
+	}
+
+	if !ethTx.Protected() {
+		return nil, errors.New("Ethereum tx isn't EIP-155 replay protected")
+	}
+
+	sender, err := etypes.Sender(etypes.NewEIP155Signer(ethChainID), &ethTx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to recover Ethereum tx sender")
+	}
+
+	var value *types.BigUInt
+	if ethTx.Value() != nil && ethTx.Value().Sign() > 0 {
+		value = &types.BigUInt{Value: *diadem.NewBigUInt(ethTx.Value())}
+	}
+
+	from := diadem.Address{ChainID: senderChainID, Local: sender.Bytes()}
+	msg := vm.MessageTx{From: from.MarshalPB()}
+	tx := types.Transaction{}
+	if ethTx.To() == nil {
+		tx.Id = 1 // deploy
+		msg.Data, err = proto.Marshal(&vm.DeployTx{
+			VmType: vm.VMType_EVM,
+			Code:   ethTx.Data(),
+			Value:  value,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal DeployTx")
+		}
+	} else {
+		tx.Id = 2 // call
+		to := diadem.Address{ChainID: chainID, Local: ethTx.To().Bytes()}
+		msg.To = to.MarshalPB()
+		msg.Data, err = proto.Marshal(&vm.CallTx{
+			VmType: vm.VMType_EVM,
+			Input:  ethTx.Data(),
+			Value:  value,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal CallTx")
+		}
+	}
+
+	tx.Data, err = proto.Marshal(&msg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal MessageTx")
+	}
+	txBytes, err = proto.Marshal(&tx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal Transaction")
+	}
+	// Ethereum nonces start at zero, while the first NonceTx of an account has sequence one.
+	return proto.Marshal(&NonceTx{
+		Inner:    txBytes,
+		Sequence: ethTx.Nonce() + 1,
+	})
+}
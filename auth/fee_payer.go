@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+
+	"github.com/gogo/protobuf/proto"
+	"golang.org/x/crypto/ed25519"
+
+	diadem "github.com/diademnetwork/go-diadem"
+	lauth "github.com/diademnetwork/go-diadem/auth"
+	"github.com/diademnetwork/go-diadem/util"
+	"github.com/diademnetwork/diademchain"
+)
+
+var (
+	ContextKeyFeePayer       = contextKey("FeePayer")
+	ContextKeyFeePayerMaxFee = contextKey("FeePayerMaxFee")
+)
+
+// FeePayerMessage returns the message the fee payer must sign to agree to pay up to maxFee for
+// the given tx. The message includes the public key of the tx signer, so the fee payer signature
+// can't be attached to an identical tx from another account.
+func FeePayerMessage(signedTx *lauth.SignedTx, maxFee uint64) []byte {
+	maxFeeBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(maxFeeBytes, maxFee)
+	return util.PrefixKey([]byte("fee-payer"), signedTx.PublicKey, signedTx.Inner, maxFeeBytes)
+}
+
+// SignFeePayer adds the signature of the fee payer to the given signed tx, the fee payer agrees to
+// pay a fee of up to maxFee for the tx.
+func SignFeePayer(feePayer lauth.Signer, signedTx *lauth.SignedTx, maxFee uint64) *FeePayerSignedTx {
+	return &FeePayerSignedTx{
+		Inner:             signedTx.Inner,
+		Signature:         signedTx.Signature,
+		PublicKey:         signedTx.PublicKey,
+		FeePayerPublicKey: feePayer.PublicKey(),
+		FeePayerSignature: feePayer.Sign(FeePayerMessage(signedTx, maxFee)),
+		MaxFee:            maxFee,
+	}
+}
+
+// FeePayer returns the account that agreed to pay the fee for the tx being processed, or an empty
+// address if the fee should be paid by the origin of the tx.
+func FeePayer(ctx context.Context) diadem.Address {
+	if feePayer, ok := ctx.Value(ContextKeyFeePayer).(diadem.Address); ok {
+		return feePayer
+	}
+	return diadem.Address{}
+}
+
+// FeePayerMaxFee returns the max fee the fee payer agreed to pay for the tx being processed, the
+// second return value is false if the tx has no fee payer.
+func FeePayerMaxFee(ctx context.Context) (uint64, bool) {
+	maxFee, ok := ctx.Value(ContextKeyFeePayerMaxFee).(uint64)
+	return maxFee, ok
+}
+
+// FeePayerTxMiddleware verifies the fee payer signature of a tx (if it has one), and makes the
+// fee payer available to the tx fee middleware via FeePayer & FeePayerMaxFee. This middleware must
+// run before the tx is unwrapped by the signature verification middleware. RLP encoded Ethereum txs
+// can't have a fee payer, so they're passed through as is.
+var FeePayerTxMiddleware = diademchain.TxMiddlewareFunc(func(
+	state diademchain.State,
+	txBytes []byte,
+	next diademchain.TxHandlerFunc,
+	isCheckTx bool,
+) (diademchain.TxHandlerResult, error) {
+	var r diademchain.TxHandlerResult
+
+	if IsEthRLPTx(txBytes) {
+		return next(state, txBytes, isCheckTx)
+	}
+
+	var tx FeePayerSignedTx
+	if err := proto.Unmarshal(txBytes, &tx); err != nil {
+		return r, err
+	}
+
+	if len(tx.FeePayerPublicKey) == 0 && len(tx.FeePayerSignature) == 0 {
+		return next(state, txBytes, isCheckTx)
+	}
+
+	if len(tx.FeePayerPublicKey) != ed25519.PublicKeySize {
+		return r, errors.New("invalid fee payer public key length")
+	}
+
+	if len(tx.FeePayerSignature) != ed25519.SignatureSize {
+		return r, errors.New("invalid fee payer signature length")
+	}
+
+	msg := FeePayerMessage(&lauth.SignedTx{
+		Inner:     tx.Inner,
+		Signature: tx.Signature,
+		PublicKey: tx.PublicKey,
+	}, tx.MaxFee)
+	if !ed25519.Verify(tx.FeePayerPublicKey, msg, tx.FeePayerSignature) {
+		return r, errors.New("invalid fee payer signature")
+	}
+
+	local, err := ResolveAccountKey(state, tx.FeePayerPublicKey)
+	if err != nil {
+		return r, err
+	}
+	feePayer := diadem.Address{
+		ChainID: state.Block().ChainID,
+		Local:   local,
+	}
+
+	ctx := context.WithValue(state.Context(), ContextKeyFeePayer, feePayer)
+	ctx = context.WithValue(ctx, ContextKeyFeePayerMaxFee, tx.MaxFee)
+	return next(state.WithContext(ctx), txBytes, isCheckTx)
+})
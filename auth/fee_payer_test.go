@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	proto "github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"golang.org/x/crypto/ed25519"
+
+	diadem "github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/go-diadem/auth"
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/store"
+)
+
+func TestFeePayerTxMiddleware(t *testing.T) {
+	origBytes := []byte("hello")
+	_, privKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	feePayerPubKey, feePayerPrivKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	signedTx := auth.SignTx(auth.NewEd25519Signer([]byte(privKey)), origBytes)
+	feePayerTx := SignFeePayer(auth.NewEd25519Signer([]byte(feePayerPrivKey)), signedTx, 1000)
+	feePayerTxBytes, err := proto.Marshal(feePayerTx)
+	require.NoError(t, err)
+
+	state := diademchain.NewStoreState(context.Background(), store.NewMemStore(), abci.Header{ChainID: "default"}, nil, nil)
+
+	// The fee payer should be available to the middleware that follows, and the tx should still
+	// be accepted by the signature verification middleware.
+	var feePayer diadem.Address
+	var maxFee uint64
+	_, err = FeePayerTxMiddleware.ProcessTx(state, feePayerTxBytes,
+		func(state diademchain.State, txBytes []byte, isCheckTx bool) (diademchain.TxHandlerResult, error) {
+			feePayer = FeePayer(state.Context())
+			maxFee, _ = FeePayerMaxFee(state.Context())
+			return SignatureTxMiddleware.ProcessTx(state, txBytes,
+				func(state diademchain.State, txBytes []byte, isCheckTx bool) (diademchain.TxHandlerResult, error) {
+					require.Equal(t, origBytes, txBytes)
+					return diademchain.TxHandlerResult{}, nil
+				}, isCheckTx,
+			)
+		}, false,
+	)
+	require.NoError(t, err)
+	require.Equal(t, "default", feePayer.ChainID)
+	require.Equal(t, diadem.LocalAddressFromPublicKey(feePayerPubKey), feePayer.Local)
+	require.Equal(t, uint64(1000), maxFee)
+
+	// The max fee is covered by the fee payer signature
+	feePayerTx.MaxFee = 2000
+	tamperedTxBytes, err := proto.Marshal(feePayerTx)
+	require.NoError(t, err)
+	_, err = FeePayerTxMiddleware.ProcessTx(state, tamperedTxBytes,
+		func(state diademchain.State, txBytes []byte, isCheckTx bool) (diademchain.TxHandlerResult, error) {
+			return diademchain.TxHandlerResult{}, nil
+		}, false,
+	)
+	require.Error(t, err)
+	feePayerTx.MaxFee = 1000
+
+	// Txs without a fee payer should pass through
+	signedTxBytes, err := proto.Marshal(signedTx)
+	require.NoError(t, err)
+	_, err = FeePayerTxMiddleware.ProcessTx(state, signedTxBytes,
+		func(state diademchain.State, txBytes []byte, isCheckTx bool) (diademchain.TxHandlerResult, error) {
+			require.True(t, FeePayer(state.Context()).IsEmpty())
+			_, ok := FeePayerMaxFee(state.Context())
+			require.False(t, ok)
+			return diademchain.TxHandlerResult{}, nil
+		}, false,
+	)
+	require.NoError(t, err)
+
+	// The fee payer signature can't be reused for a different tx
+	otherTx := auth.SignTx(auth.NewEd25519Signer([]byte(privKey)), []byte("bye"))
+	feePayerTx.Inner = otherTx.Inner
+	feePayerTx.Signature = otherTx.Signature
+	feePayerTxBytes, err = proto.Marshal(feePayerTx)
+	require.NoError(t, err)
+	_, err = FeePayerTxMiddleware.ProcessTx(state, feePayerTxBytes,
+		func(state diademchain.State, txBytes []byte, isCheckTx bool) (diademchain.TxHandlerResult, error) {
+			return diademchain.TxHandlerResult{}, nil
+		}, false,
+	)
+	require.Error(t, err)
+
+	// RLP encoded Ethereum txs should pass through untouched
+	ethTxBytes := []byte{0xf8, 0x6b, 0x80}
+	called := false
+	_, err = FeePayerTxMiddleware.ProcessTx(state, ethTxBytes,
+		func(state diademchain.State, txBytes []byte, isCheckTx bool) (diademchain.TxHandlerResult, error) {
+			called = true
+			require.Equal(t, ethTxBytes, txBytes)
+			require.True(t, FeePayer(state.Context()).IsEmpty())
+			return diademchain.TxHandlerResult{}, nil
+		}, false,
+	)
+	require.NoError(t, err)
+	require.True(t, called)
+}
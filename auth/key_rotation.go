@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gogo/protobuf/proto"
+	"golang.org/x/crypto/ed25519"
+
+	diadem "github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/go-diadem/util"
+	"github.com/diademnetwork/diademchain"
+)
+
+// Accounts are initially controlled by the ed25519 key their address is derived from, once the
+// key of an account is rotated the account is controlled by the new key, and any txs signed by
+// the old key are rejected.
+//
+// The state of each account with a rotated key consists of:
+// - acctkey<account address> -> the public key currently controlling the account
+// - keyacct<key address> -> the address of the account the key controls
+// - retiredkey<key address> -> marker for keys that have been rotated out and can't be used again
+// Key addresses are derived from public keys the same way account addresses are.
+var (
+	accountKeyPrefix = []byte("acctkey")
+	keyAccountPrefix = []byte("keyacct")
+	retiredKeyPrefix = []byte("retiredkey")
+)
+
+// RotateKeyTx replaces the key that controls the account that sent the tx, the tx must be signed
+// by the current key of the account.
+type RotateKeyTx struct {
+	// ed25519 public key that will control the account
+	NewPublicKey []byte `protobuf:"bytes,1,opt,name=newPublicKey,proto3" json:"newPublicKey,omitempty"`
+	// Signature of RotateKeyMessage(account) by the new key, proves the account owner controls
+	// the new key.
+	NewKeySignature []byte `protobuf:"bytes,2,opt,name=newKeySignature,proto3" json:"newKeySignature,omitempty"`
+}
+
+func (m *RotateKeyTx) Reset()         { *m = RotateKeyTx{} }
+func (m *RotateKeyTx) String() string { return proto.CompactTextString(m) }
+func (*RotateKeyTx) ProtoMessage()    {}
+
+// HasBalanceFunc reports whether the given account holds a balance of any of the coins that live
+// outside the auth state (e.g. DiademCoin or ETH).
+type HasBalanceFunc func(state diademchain.State, account diadem.Address) (bool, error)
+
+// RotateKeyMessage returns the message that must be signed by the new key of the given account.
+func RotateKeyMessage(account diadem.Address) []byte {
+	return util.PrefixKey([]byte("rotate-key"), account.Bytes())
+}
+
+// AccountKey returns the public key currently controlling the given account, or nil if the
+// account is still controlled by the key it was created with.
+func AccountKey(state diademchain.ReadOnlyState, account diadem.Address) []byte {
+	return state.Get(util.PrefixKey(accountKeyPrefix, account.Local))
+}
+
+// ResolveAccountKey returns the local address of the account controlled by the given ed25519
+// public key, an error is returned if the key has been rotated out of the account it controlled.
+func ResolveAccountKey(state diademchain.ReadOnlyState, pubKey []byte) (diadem.LocalAddress, error) {
+	keyAddr := diadem.LocalAddressFromPublicKey(pubKey)
+	if !state.FeatureEnabled(diademchain.AuthKeyRotationFeature, false) {
+		return keyAddr, nil
+	}
+	if account := state.Get(util.PrefixKey(keyAccountPrefix, keyAddr)); account != nil {
+		return diadem.LocalAddress(account), nil
+	}
+	if state.Has(util.PrefixKey(retiredKeyPrefix, keyAddr)) {
+		return nil, errors.New("key has been rotated out")
+	}
+	return keyAddr, nil
+}
+
+// RotateKey replaces the key that controls the given account.
+//
+// The new key must not have been used before, otherwise the txs signed by the new key would
+// suddenly be attributed to the given account instead of the account they were sent from, and any
+// coins sent to the address of the new key would be lost. If hasBalance is nil only the auth state
+// is checked.
+func RotateKey(state diademchain.State, account diadem.Address, tx *RotateKeyTx, hasBalance HasBalanceFunc) error {
+	if !state.FeatureEnabled(diademchain.AuthKeyRotationFeature, false) {
+		return errors.New("key rotation hasn't been enabled")
+	}
+	if account.ChainID != state.Block().ChainID {
+		return fmt.Errorf("key of account %s can't be rotated", account.String())
+	}
+	if len(tx.NewPublicKey) != ed25519.PublicKeySize {
+		return errors.New("invalid public key length")
+	}
+	if len(tx.NewKeySignature) != ed25519.SignatureSize ||
+		!ed25519.Verify(tx.NewPublicKey, RotateKeyMessage(account), tx.NewKeySignature) {
+		return errors.New("invalid new key signature")
+	}
+
+	newKeyAddr := diadem.LocalAddressFromPublicKey(tx.NewPublicKey)
+	newKeyAccount := diadem.Address{ChainID: account.ChainID, Local: newKeyAddr}
+	if state.Has(util.PrefixKey(keyAccountPrefix, newKeyAddr)) ||
+		state.Has(util.PrefixKey(retiredKeyPrefix, newKeyAddr)) ||
+		state.Has(util.PrefixKey(sessionKeyPrefix, newKeyAddr)) ||
+		Nonce(state, newKeyAccount) != 0 {
+		return errors.New("new key is already in use")
+	}
+	if hasBalance != nil {
+		funded, err := hasBalance(state, newKeyAccount)
+		if err != nil {
+			return err
+		}
+		if funded {
+			return errors.New("new key is already in use")
+		}
+	}
+
+	curKeyAddr := account.Local
+	if curKey := AccountKey(state, account); curKey != nil {
+		curKeyAddr = diadem.LocalAddressFromPublicKey(curKey)
+		state.Delete(util.PrefixKey(keyAccountPrefix, curKeyAddr))
+	}
+	state.Set(util.PrefixKey(retiredKeyPrefix, curKeyAddr), []byte{1})
+	state.Set(util.PrefixKey(accountKeyPrefix, account.Local), tx.NewPublicKey)
+	state.Set(util.PrefixKey(keyAccountPrefix, newKeyAddr), account.Local)
+	return nil
+}
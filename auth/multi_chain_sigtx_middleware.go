@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"math/big"
+	"sort"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/diademnetwork/go-diadem"
@@ -13,6 +15,7 @@ import (
 	"github.com/diademnetwork/go-diadem/vm"
 	"github.com/diademnetwork/diademchain"
 	"github.com/diademnetwork/diademchain/builtin/plugins/address_mapper"
+	sha3 "github.com/miguelmota/go-solidity-sha3"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/ed25519"
 )
@@ -23,6 +26,8 @@ const (
 	DiademSignedTxType     SignedTxType = "diadem"
 	EthereumSignedTxType SignedTxType = "eth"
 	TronSignedTxType     SignedTxType = "tron"
+	// Txs sent from multisig accounts, signed by M of N ed25519 keys
+	MultisigSignedTxType SignedTxType = "multisig"
 )
 
 // AccountType is used to specify which address should be used on-chain to identify a tx sender.
@@ -41,6 +46,7 @@ var originRecoveryFuncs = map[SignedTxType]originRecoveryFunc{
 	DiademSignedTxType:     verifyEd25519,
 	EthereumSignedTxType: verifySolidity66Byte,
 	TronSignedTxType:     verifyTron,
+	MultisigSignedTxType: verifyMultisig,
 }
 
 type originRecoveryFunc func(tx SignedTx) ([]byte, error)
@@ -60,7 +66,27 @@ func NewMultiChainSignatureTxMiddleware(
 		var r diademchain.TxHandlerResult
 
 		var signedTx SignedTx
-		if err := proto.Unmarshal(txBytes, &signedTx); err != nil {
+		isEthTx := IsEthRLPTx(txBytes)
+		if isEthTx {
+			if !state.FeatureEnabled(diademchain.AuthSigTxEthRLPFeature, false) {
+				return r, errors.New("Ethereum txs are not enabled")
+			}
+			senderChainID, err := EthSenderChainID(chains)
+			if err != nil {
+				return r, err
+			}
+			chainID := state.Block().ChainID
+			signedTx.Inner, err = ethRLPTxToNonceTx(txBytes, EthChainID(chainID), senderChainID, chainID)
+			if err != nil {
+				return r, err
+			}
+			ethTx, err := ethRLPTxInfo(txBytes)
+			if err != nil {
+				return r, err
+			}
+			// the EVM uses the gas limit of the tx, and the receipt of the tx is stored under its hash
+			state = state.WithContext(context.WithValue(state.Context(), ContextKeyEthTx, ethTx))
+		} else if err := proto.Unmarshal(txBytes, &signedTx); err != nil {
 			return r, err
 		}
 
@@ -90,16 +116,29 @@ func NewMultiChainSignatureTxMiddleware(
 			return r, fmt.Errorf("unknown chain ID %s", msgSender.ChainID)
 		}
 
-		recoverOrigin, found := originRecoveryFuncs[chain.TxType]
-		if !found {
-			return r, fmt.Errorf("recovery function for Tx type %v not found", chain.TxType)
-		}
+		var recoveredAddr []byte
+		if isEthTx {
+			// the message sender was recovered from the signature of the Ethereum tx
+			recoveredAddr = msgSender.Local
+		} else {
+			recoverOrigin, found := originRecoveryFuncs[chain.TxType]
+			if !found {
+				return r, fmt.Errorf("recovery function for Tx type %v not found", chain.TxType)
+			}
 
-		recoveredAddr, err := recoverOrigin(signedTx)
-		if err != nil {
-			return r, errors.Wrapf(err, "failed to recover origin (tx type %v, chain ID %s)",
-				chain.TxType, msgSender.ChainID,
-			)
+			var err error
+			recoveredAddr, err = recoverOrigin(signedTx)
+			if err != nil {
+				return r, errors.Wrapf(err, "failed to recover origin (tx type %v, chain ID %s)",
+					chain.TxType, msgSender.ChainID,
+				)
+			}
+			if chain.TxType == DiademSignedTxType {
+				recoveredAddr, err = resolveDiademSigner(state, signedTx.PublicKey, msgSender, &tx, &msg)
+				if err != nil {
+					return r, err
+				}
+			}
 		}
 
 		if !bytes.Equal(recoveredAddr, msgSender.Local) {
@@ -146,6 +185,32 @@ func NewMultiChainSignatureTxMiddleware(
 	})
 }
 
+// resolveDiademSigner returns the local address of the account the given ed25519 key signs txs for.
+func resolveDiademSigner(
+	state diademchain.State, pubKey []byte, msgSender diadem.Address,
+	tx *types.Transaction, msg *vm.MessageTx,
+) (diadem.LocalAddress, error) {
+	// the key may be a session key granted by the message sender
+	if state.FeatureEnabled(diademchain.AuthSessionKeysFeature, false) {
+		sessionKey, err := GetSessionKey(state, pubKey)
+		if err != nil {
+			return nil, err
+		}
+		if sessionKey != nil {
+			owner := diadem.UnmarshalAddressPB(sessionKey.Owner)
+			if owner.Compare(msgSender) != 0 {
+				return nil, fmt.Errorf("session key wasn't granted by %s", msgSender.String())
+			}
+			if err := useSessionKey(state, sessionKey, tx, msg); err != nil {
+				return nil, err
+			}
+			return owner.Local, nil
+		}
+	}
+	// the key may control an account whose key has been rotated
+	return ResolveAccountKey(state, pubKey)
+}
+
 func getMappedAccountAddress(
 	state diademchain.State,
 	addr diadem.Address,
@@ -188,3 +253,76 @@ func verifyEd25519(tx SignedTx) ([]byte, error) {
 
 	return diadem.LocalAddressFromPublicKey(tx.PublicKey), nil
 }
+
+// UnwrapTx returns the NonceTx wrapped by the given tx, which may either be a SignedTx or an RLP
+// encoded Ethereum tx. The signature of a SignedTx isn't verified.
+func UnwrapTx(txBytes []byte, chainID string, authCfg *Config) ([]byte, error) {
+	if IsEthRLPTx(txBytes) {
+		senderChainID, err := EthSenderChainID(authCfg.Chains)
+		if err != nil {
+			return nil, err
+		}
+		return ethRLPTxToNonceTx(txBytes, EthChainID(chainID), senderChainID, chainID)
+	}
+	var signedTx SignedTx
+	if err := proto.Unmarshal(txBytes, &signedTx); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal SignedTx")
+	}
+	return signedTx.Inner, nil
+}
+
+// EthTxInfo contains the details of an RLP encoded Ethereum tx that aren't carried over to the
+// NonceTx it's converted to.
+type EthTxInfo struct {
+	// Ethereum tx hash, i.e. keccak256 of the RLP encoded tx.
+	Hash     []byte
+	GasLimit uint64
+	GasPrice *big.Int
+}
+
+// ContextKeyEthTx is used to attach the EthTxInfo of an RLP encoded Ethereum tx to the context of
+// the state the tx is processed with.
+var ContextKeyEthTx = contextKey("EthTx")
+
+// EthTxFromContext returns the EthTxInfo attached to the given context, or nil if the tx that's
+// being processed isn't an RLP encoded Ethereum tx.
+func EthTxFromContext(ctx context.Context) *EthTxInfo {
+	if ctx == nil {
+		return nil
+	}
+	ethTx, _ := ctx.Value(ContextKeyEthTx).(*EthTxInfo)
+	return ethTx
+}
+
+// IsEthRLPTx checks if the given tx is an RLP encoded Ethereum tx rather than a SignedTx. An RLP
+// encoded tx is a list, so its first byte is always 0xc0 or greater, while the first byte of
+// a marshalled SignedTx is the tag of one of its fields.
+func IsEthRLPTx(txBytes []byte) bool {
+	return len(txBytes) > 0 && txBytes[0] >= 0xc0
+}
+
+// EthSenderChainID returns the ID of the chain whose accounts sign txs with Ethereum keys, the
+// senders of Ethereum txs are identified by addresses with this chain ID.
+func EthSenderChainID(chains map[string]ChainConfig) (string, error) {
+	chainIDs := []string{}
+	for chainID, chain := range chains {
+		if chain.TxType == EthereumSignedTxType {
+			chainIDs = append(chainIDs, chainID)
+		}
+	}
+	if len(chainIDs) == 0 {
+		return "", errors.New("no chain that accepts Ethereum signed txs is enabled")
+	}
+	// pick the same chain on every node if there's more than one
+	sort.Strings(chainIDs)
+	return chainIDs[0], nil
+}
+
+// EthChainID returns the EIP-155 chain ID Ethereum txs sent to the chain with the given ID must be
+// signed with, it matches the network ID returned by net_version.
+func EthChainID(chainID string) *big.Int {
+	hash := sha3.SoliditySHA3(sha3.String(chainID))
+	ethChainID := new(big.Int)
+	ethChainID.SetString(hex.EncodeToString(hash)[0:13], 16)
+	return ethChainID
+}
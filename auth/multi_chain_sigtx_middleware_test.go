@@ -7,10 +7,13 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"math/big"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	etypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/gogo/protobuf/proto"
 	"github.com/diademnetwork/go-diadem"
 	"github.com/diademnetwork/go-diadem/auth"
@@ -256,6 +259,82 @@ func TestChainIdVerification(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestEthRLPTx(t *testing.T) {
+	state := diademchain.NewStoreState(nil, store.NewMemStore(), abci.Header{ChainID: defaultDiademChainId}, nil, nil)
+	ctx := context.WithValue(state.Context(), ContextKeyOrigin, origin)
+
+	chains := map[string]ChainConfig{
+		"default": {
+			TxType:      DiademSignedTxType,
+			AccountType: NativeAccountType,
+		},
+		"eth": {
+			TxType:      EthereumSignedTxType,
+			AccountType: NativeAccountType,
+		},
+	}
+	tmx := NewMultiChainSignatureTxMiddleware(
+		chains,
+		func(state diademchain.State) (contractpb.Context, error) { return nil, nil },
+	)
+
+	ethKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	ethAddr := diadem.Address{ChainID: "eth", Local: crypto.PubkeyToAddress(ethKey.PublicKey).Bytes()}
+	to := common.BytesToAddress(contract.Local)
+	signer := etypes.NewEIP155Signer(EthChainID(defaultDiademChainId))
+	ethTx, err := etypes.SignTx(
+		etypes.NewTransaction(3, to, big.NewInt(10), 100000, big.NewInt(0), []byte("input")), signer, ethKey,
+	)
+	require.NoError(t, err)
+	txBytes, err := rlp.EncodeToBytes(ethTx)
+	require.NoError(t, err)
+
+	// Ethereum txs are rejected until the feature flag is enabled
+	_, err = throttleMiddlewareHandler(tmx, state, txBytes, ctx)
+	require.Error(t, err)
+
+	state.SetFeature(diademchain.AuthSigTxEthRLPFeature, true)
+	var nonceTx NonceTx
+	var msg vm.MessageTx
+	var callTx vm.CallTx
+	var ethTxInfo *EthTxInfo
+	_, err = tmx.ProcessTx(state.WithContext(ctx), txBytes,
+		func(state diademchain.State, txBytes []byte, isCheckTx bool) (res diademchain.TxHandlerResult, err error) {
+			require.Equal(t, ethAddr, Origin(state.Context()))
+			ethTxInfo = EthTxFromContext(state.Context())
+			require.NoError(t, proto.Unmarshal(txBytes, &nonceTx))
+			var tx diademchain.Transaction
+			require.NoError(t, proto.Unmarshal(nonceTx.Inner, &tx))
+			require.Equal(t, callId, tx.Id)
+			require.NoError(t, proto.Unmarshal(tx.Data, &msg))
+			require.NoError(t, proto.Unmarshal(msg.Data, &callTx))
+			return res, nil
+		}, false,
+	)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), nonceTx.Sequence)
+	require.Equal(t, ethAddr, diadem.UnmarshalAddressPB(msg.From))
+	require.Equal(t, contract, diadem.UnmarshalAddressPB(msg.To))
+	require.Equal(t, vm.VMType_EVM, callTx.VmType)
+	require.Equal(t, []byte("input"), callTx.Input)
+	require.Equal(t, int64(10), callTx.Value.Value.Int64())
+	require.NotNil(t, ethTxInfo)
+	require.Equal(t, ethTx.Hash().Bytes(), ethTxInfo.Hash)
+	require.Equal(t, uint64(100000), ethTxInfo.GasLimit)
+
+	// txs signed for another chain are rejected
+	ethTx, err = etypes.SignTx(
+		etypes.NewTransaction(3, to, big.NewInt(10), 100000, big.NewInt(0), []byte("input")),
+		etypes.NewEIP155Signer(EthChainID("otherchain")), ethKey,
+	)
+	require.NoError(t, err)
+	txBytes, err = rlp.EncodeToBytes(ethTx)
+	require.NoError(t, err)
+	_, err = throttleMiddlewareHandler(tmx, state, txBytes, ctx)
+	require.Error(t, err)
+}
+
 func throttleMiddlewareHandler(ttm diademchain.TxMiddlewareFunc, state diademchain.State, signedTx []byte, ctx context.Context) (diademchain.TxHandlerResult, error) {
 	return ttm.ProcessTx(state.WithContext(ctx), signedTx,
 		func(state diademchain.State, txBytes []byte, isCheckTx bool) (res diademchain.TxHandlerResult, err error) {
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/diademnetwork/go-diadem"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	// MaxMultisigKeys is the maximum number of keys that can control a multisig account.
+	MaxMultisigKeys = 32
+
+	multisigSigSize = 1 + ed25519.SignatureSize
+)
+
+// MultisigKey identifies an account that's controlled by a set of ed25519 keys, a tx sent from
+// the account must be signed by at least Threshold of the keys.
+//
+// The address of the account is derived from the encoded key, which contains the threshold and
+// the sorted set of public keys, so changing either of them yields a different account.
+type MultisigKey struct {
+	Threshold  int
+	PublicKeys [][]byte
+}
+
+// NewMultisigKey returns a multisig key for the given public keys & threshold, the order of the
+// public keys doesn't matter.
+func NewMultisigKey(threshold int, pubKeys [][]byte) (*MultisigKey, error) {
+	keys := make([][]byte, len(pubKeys))
+	copy(keys, pubKeys)
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	key := &MultisigKey{
+		Threshold:  threshold,
+		PublicKeys: keys,
+	}
+	if err := key.validate(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (k *MultisigKey) validate() error {
+	if len(k.PublicKeys) == 0 || len(k.PublicKeys) > MaxMultisigKeys {
+		return fmt.Errorf("multisig key must have between 1 and %d public keys", MaxMultisigKeys)
+	}
+	if k.Threshold < 1 || k.Threshold > len(k.PublicKeys) {
+		return fmt.Errorf("invalid multisig threshold %d for %d keys", k.Threshold, len(k.PublicKeys))
+	}
+	for i, pubKey := range k.PublicKeys {
+		if len(pubKey) != ed25519.PublicKeySize {
+			return errors.New("invalid public key length")
+		}
+		if i > 0 && bytes.Compare(k.PublicKeys[i-1], pubKey) >= 0 {
+			return errors.New("multisig public keys must be sorted & unique")
+		}
+	}
+	return nil
+}
+
+// Marshal encodes the key as a threshold byte, followed by a key count byte, followed by the
+// public keys.
+func (k *MultisigKey) Marshal() []byte {
+	data := make([]byte, 0, 2+len(k.PublicKeys)*ed25519.PublicKeySize)
+	data = append(data, byte(k.Threshold), byte(len(k.PublicKeys)))
+	for _, pubKey := range k.PublicKeys {
+		data = append(data, pubKey...)
+	}
+	return data
+}
+
+// UnmarshalMultisigKey decodes a key that was encoded with MultisigKey.Marshal.
+func UnmarshalMultisigKey(data []byte) (*MultisigKey, error) {
+	if len(data) < 2 || len(data) != 2+int(data[1])*ed25519.PublicKeySize {
+		return nil, errors.New("invalid multisig key length")
+	}
+	key := &MultisigKey{
+		Threshold:  int(data[0]),
+		PublicKeys: make([][]byte, data[1]),
+	}
+	for i := range key.PublicKeys {
+		offset := 2 + i*ed25519.PublicKeySize
+		key.PublicKeys[i] = data[offset : offset+ed25519.PublicKeySize]
+	}
+	if err := key.validate(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// LocalAddress returns the address of the account controlled by the key.
+func (k *MultisigKey) LocalAddress() diadem.LocalAddress {
+	return diadem.LocalAddressFromPublicKey(k.Marshal())
+}
+
+// PartialSignature is a signature of a tx by one of the keys that control a multisig account.
+type PartialSignature struct {
+	PublicKey []byte `json:"publicKey"`
+	Signature []byte `json:"signature"`
+}
+
+// SignMultisigTx creates a partial signature of a NonceTx that will be sent from a multisig account.
+func SignMultisigTx(privKey ed25519.PrivateKey, nonceTx []byte) *PartialSignature {
+	return &PartialSignature{
+		PublicKey: []byte(privKey.Public().(ed25519.PublicKey)),
+		Signature: ed25519.Sign(privKey, nonceTx),
+	}
+}
+
+// CombineMultisigSignatures combines partial signatures of the given NonceTx into a SignedTx that
+// can be sent from the multisig account identified by the given key. Signatures by keys that
+// aren't part of the multisig key are rejected, and at least the threshold number of partial
+// signatures must be provided.
+func CombineMultisigSignatures(key *MultisigKey, nonceTx []byte, sigs []*PartialSignature) (*SignedTx, error) {
+	sigsByIndex := map[int][]byte{}
+	for _, sig := range sigs {
+		idx := -1
+		for i, pubKey := range key.PublicKeys {
+			if bytes.Equal(pubKey, sig.PublicKey) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, fmt.Errorf("public key %s isn't part of the multisig key",
+				diadem.LocalAddressFromPublicKey(sig.PublicKey).String(),
+			)
+		}
+		if len(sig.Signature) != ed25519.SignatureSize || !ed25519.Verify(sig.PublicKey, nonceTx, sig.Signature) {
+			return nil, fmt.Errorf("invalid signature by %s",
+				diadem.LocalAddressFromPublicKey(sig.PublicKey).String(),
+			)
+		}
+		sigsByIndex[idx] = sig.Signature
+	}
+	if len(sigsByIndex) < key.Threshold {
+		return nil, fmt.Errorf("%d of %d required signatures provided", len(sigsByIndex), key.Threshold)
+	}
+
+	signature := make([]byte, 0, len(sigsByIndex)*multisigSigSize)
+	for i := range key.PublicKeys {
+		if sig, ok := sigsByIndex[i]; ok {
+			signature = append(signature, byte(i))
+			signature = append(signature, sig...)
+		}
+	}
+	return &SignedTx{
+		Inner:     nonceTx,
+		Signature: signature,
+		PublicKey: key.Marshal(),
+	}, nil
+}
+
+// verifyMultisig recovers the origin of a tx sent from a multisig account. The public key of the
+// tx must be an encoded MultisigKey, and the signature must be a sequence of key index byte &
+// ed25519 signature pairs, sorted by key index.
+func verifyMultisig(tx SignedTx) ([]byte, error) {
+	key, err := UnmarshalMultisigKey(tx.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tx.Signature) == 0 || len(tx.Signature)%multisigSigSize != 0 {
+		return nil, errors.New("invalid multisig signature length")
+	}
+
+	numSigs := 0
+	lastIdx := -1
+	for offset := 0; offset < len(tx.Signature); offset += multisigSigSize {
+		idx := int(tx.Signature[offset])
+		if idx <= lastIdx || idx >= len(key.PublicKeys) {
+			return nil, errors.New("invalid multisig signature key index")
+		}
+		lastIdx = idx
+		sig := tx.Signature[offset+1 : offset+multisigSigSize]
+		if !ed25519.Verify(key.PublicKeys[idx], tx.Inner, sig) {
+			return nil, errors.New("invalid signature ed25519 verify")
+		}
+		numSigs++
+	}
+
+	if numSigs < key.Threshold {
+		return nil, fmt.Errorf("%d of %d required signatures provided", numSigs, key.Threshold)
+	}
+	return key.LocalAddress(), nil
+}
@@ -0,0 +1,116 @@
+// +build evm
+
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/go-diadem/plugin/contractpb"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/store"
+)
+
+func TestMultisigKey(t *testing.T) {
+	pub1, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	pub2, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	key1, err := NewMultisigKey(2, [][]byte{pub1, pub2})
+	require.NoError(t, err)
+	key2, err := NewMultisigKey(2, [][]byte{pub2, pub1})
+	require.NoError(t, err)
+	require.Equal(t, key1.LocalAddress(), key2.LocalAddress())
+
+	// the threshold is part of the address
+	key3, err := NewMultisigKey(1, [][]byte{pub1, pub2})
+	require.NoError(t, err)
+	require.NotEqual(t, key1.LocalAddress(), key3.LocalAddress())
+
+	decoded, err := UnmarshalMultisigKey(key1.Marshal())
+	require.NoError(t, err)
+	require.Equal(t, key1, decoded)
+
+	_, err = NewMultisigKey(3, [][]byte{pub1, pub2})
+	require.Error(t, err)
+	_, err = NewMultisigKey(1, [][]byte{pub1, pub1})
+	require.Error(t, err)
+}
+
+func TestMultisigSigning(t *testing.T) {
+	state := diademchain.NewStoreState(nil, store.NewMemStore(), abci.Header{ChainID: defaultDiademChainId}, nil, nil)
+	ctx := context.WithValue(state.Context(), ContextKeyOrigin, origin)
+
+	var pubKeys [][]byte
+	var privKeys []ed25519.PrivateKey
+	for i := 0; i < 3; i++ {
+		pub, priv, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		pubKeys = append(pubKeys, pub)
+		privKeys = append(privKeys, priv)
+	}
+	key, err := NewMultisigKey(2, pubKeys)
+	require.NoError(t, err)
+
+	chains := map[string]ChainConfig{
+		"default": {
+			TxType:      DiademSignedTxType,
+			AccountType: NativeAccountType,
+		},
+		"multisig": {
+			TxType:      MultisigSignedTxType,
+			AccountType: NativeAccountType,
+		},
+	}
+	tmx := NewMultiChainSignatureTxMiddleware(
+		chains,
+		func(state diademchain.State) (contractpb.Context, error) { return nil, nil },
+	)
+
+	nonceTx := mockNonceTx(t, diadem.Address{ChainID: "multisig", Local: key.LocalAddress()}, sequence)
+	sig1 := SignMultisigTx(privKeys[0], nonceTx)
+	sig2 := SignMultisigTx(privKeys[2], nonceTx)
+
+	// not enough signatures
+	_, err = CombineMultisigSignatures(key, nonceTx, []*PartialSignature{sig1})
+	require.Error(t, err)
+	// a duplicate signature doesn't count towards the threshold
+	_, err = CombineMultisigSignatures(key, nonceTx, []*PartialSignature{sig1, sig1})
+	require.Error(t, err)
+	// signature of a different tx
+	_, err = CombineMultisigSignatures(key, nonceTx, []*PartialSignature{sig1, SignMultisigTx(privKeys[1], []byte("other"))})
+	require.Error(t, err)
+
+	signedTx, err := CombineMultisigSignatures(key, nonceTx, []*PartialSignature{sig2, sig1})
+	require.NoError(t, err)
+	txBytes, err := proto.Marshal(signedTx)
+	require.NoError(t, err)
+	_, err = throttleMiddlewareHandler(tmx, state, txBytes, ctx)
+	require.NoError(t, err)
+
+	// the middleware must reject txs that don't meet the threshold, even if the tx was put
+	// together without CombineMultisigSignatures
+	partialTx := *signedTx
+	partialTx.Signature = signedTx.Signature[:multisigSigSize]
+	txBytes, err = proto.Marshal(&partialTx)
+	require.NoError(t, err)
+	_, err = throttleMiddlewareHandler(tmx, state, txBytes, ctx)
+	require.Error(t, err)
+
+	// a tx signed by the multisig keys can't be sent from an account with a lower threshold
+	weakKey, err := NewMultisigKey(1, pubKeys)
+	require.NoError(t, err)
+	weakTx := *signedTx
+	weakTx.PublicKey = weakKey.Marshal()
+	txBytes, err = proto.Marshal(&weakTx)
+	require.NoError(t, err)
+	_, err = throttleMiddlewareHandler(tmx, state, txBytes, ctx)
+	require.Error(t, err)
+}
@@ -4,6 +4,7 @@ package auth
 
 import (
 	"fmt"
+	"math/big"
 )
 
 func verifySolidity66Byte(_ SignedTx) ([]byte, error) {
@@ -13,3 +14,11 @@ func verifySolidity66Byte(_ SignedTx) ([]byte, error) {
 func verifyTron(tx SignedTx) ([]byte, error) {
 	return nil, fmt.Errorf("tron support not implemented")
 }
+
+func ethRLPTxInfo(_ []byte) (*EthTxInfo, error) {
+	return nil, fmt.Errorf("Ethereum tx support not implemented")
+}
+
+func ethRLPTxToNonceTx(_ []byte, _ *big.Int, _, _ string) ([]byte, error) {
+	return nil, fmt.Errorf("Ethereum tx support not implemented")
+}
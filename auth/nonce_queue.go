@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	diadem "github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/diademchain"
+)
+
+var ContextKeyTxBytes = contextKey("TxBytes")
+
+type NonceQueueConfig struct {
+	// Allows txs with sequence numbers higher than the next expected one to wait until the txs with
+	// the lower sequence numbers are received, instead of being rejected.
+	Enabled bool
+	// Max number of txs from a single account that can wait in the queue, this also limits how
+	// far ahead of the expected sequence number a tx can be.
+	MaxTxsPerAccount int
+	// Max number of txs that can wait in the queue across all accounts, once the queue is full the
+	// newest txs of the account with the most queued txs are evicted to make room.
+	MaxQueuedTxs int
+	// Number of blocks a tx can wait in the queue before it's dropped.
+	MaxAge int64
+}
+
+func DefaultNonceQueueConfig() *NonceQueueConfig {
+	return &NonceQueueConfig{
+		Enabled:          false,
+		MaxTxsPerAccount: 16,
+		MaxQueuedTxs:     4096,
+		MaxAge:           100,
+	}
+}
+
+// Clone returns a deep clone of the config.
+func (c *NonceQueueConfig) Clone() *NonceQueueConfig {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	return &clone
+}
+
+// PendingTxMiddleware makes the signed tx available to NonceTxMiddleware, so that txs with
+// sequence numbers that are too high can be queued. This middleware must run before the tx is
+// unwrapped by the signature verification middleware.
+var PendingTxMiddleware = diademchain.TxMiddlewareFunc(func(
+	state diademchain.State,
+	txBytes []byte,
+	next diademchain.TxHandlerFunc,
+	isCheckTx bool,
+) (diademchain.TxHandlerResult, error) {
+	if isCheckTx {
+		ctx := context.WithValue(state.Context(), ContextKeyTxBytes, txBytes)
+		state = state.WithContext(ctx)
+	}
+	return next(state, txBytes, isCheckTx)
+})
+
+type queuedTx struct {
+	txBytes []byte
+	height  int64
+}
+
+type accountQueue struct {
+	addr diadem.Address
+	txs  map[uint64]queuedTx
+}
+
+// nonceQueue holds txs whose sequence numbers are higher than expected, until the txs with the
+// lower sequence numbers are received. The caller is responsible for synchronizing access.
+type nonceQueue struct {
+	cfg      *NonceQueueConfig
+	accounts map[string]*accountQueue
+	resubmit func(txBytes []byte)
+	// total number of queued txs
+	size int
+}
+
+func newNonceQueue(cfg *NonceQueueConfig, resubmit func(txBytes []byte)) *nonceQueue {
+	return &nonceQueue{
+		cfg:      cfg,
+		accounts: make(map[string]*accountQueue),
+		resubmit: resubmit,
+	}
+}
+
+func (q *nonceQueue) add(state diademchain.State, origin diadem.Address, seq, expectedSeq uint64) error {
+	txBytes, ok := state.Context().Value(ContextKeyTxBytes).([]byte)
+	if !ok {
+		return fmt.Errorf("sequence number does not match expected %d got %d", expectedSeq, seq)
+	}
+	if seq-expectedSeq > uint64(q.cfg.MaxTxsPerAccount) {
+		return fmt.Errorf("sequence number %d is too far ahead of expected %d", seq, expectedSeq)
+	}
+
+	acct := q.accounts[origin.String()]
+	// a tx that replaces a queued tx with the same sequence number doesn't count towards the limits
+	if acct == nil || !acct.has(seq) {
+		queued := 0
+		if acct != nil {
+			queued = len(acct.txs)
+		}
+		if queued >= q.cfg.MaxTxsPerAccount {
+			return fmt.Errorf("too many queued txs from %s", origin.String())
+		}
+		if q.size >= q.cfg.MaxQueuedTxs && !q.evict(queued+1) {
+			return errors.New("nonce queue is full")
+		}
+		q.size++
+	}
+
+	if acct == nil {
+		acct = &accountQueue{
+			addr: origin,
+			txs:  make(map[uint64]queuedTx),
+		}
+		q.accounts[origin.String()] = acct
+	}
+	acct.txs[seq] = queuedTx{
+		txBytes: txBytes,
+		height:  state.Block().Height,
+	}
+	return nil
+}
+
+// evict drops the queued tx with the highest sequence number from the account with the most queued
+// txs, so that a single account can't keep the txs of other accounts out of a full queue. Nothing
+// is evicted unless that account has more queued txs than the account the new tx is from would have
+// (queuedTxs) once the new tx is queued.
+func (q *nonceQueue) evict(queuedTxs int) bool {
+	var largest *accountQueue
+	for _, acct := range q.accounts {
+		if largest == nil || len(acct.txs) > len(largest.txs) {
+			largest = acct
+		}
+	}
+	if largest == nil || len(largest.txs) <= queuedTxs {
+		return false
+	}
+	var maxSeq uint64
+	for seq := range largest.txs {
+		if seq > maxSeq {
+			maxSeq = seq
+		}
+	}
+	q.remove(largest, maxSeq)
+	return true
+}
+
+// remove drops the queued tx with the given sequence number from the given account.
+func (q *nonceQueue) remove(acct *accountQueue, seq uint64) {
+	delete(acct.txs, seq)
+	q.size--
+	if len(acct.txs) == 0 {
+		delete(q.accounts, acct.addr.String())
+	}
+}
+
+func (a *accountQueue) has(seq uint64) bool {
+	_, ok := a.txs[seq]
+	return ok
+}
+
+// release sends the queued tx with the given sequence number (if any) back to the mempool.
+func (q *nonceQueue) release(addr diadem.Address, seq uint64) {
+	acct := q.accounts[addr.String()]
+	if acct == nil {
+		return
+	}
+	qtx, ok := acct.txs[seq]
+	if !ok {
+		return
+	}
+	q.remove(acct, seq)
+	// CheckTx may be holding the mempool lock, so the tx must be resubmitted asynchronously
+	go q.resubmit(qtx.txBytes)
+}
+
+// prune drops txs that have been queued for too long, or whose sequence numbers have already been
+// used, and releases txs whose predecessors have been committed.
+func (q *nonceQueue) prune(state diademchain.State) {
+	height := state.Block().Height
+	for _, acct := range q.accounts {
+		committed := Nonce(state, acct.addr)
+		for seq, qtx := range acct.txs {
+			if seq <= committed || height-qtx.height > q.cfg.MaxAge {
+				q.remove(acct, seq)
+			}
+		}
+		q.release(acct.addr, committed+1)
+	}
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	proto "github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"golang.org/x/crypto/ed25519"
+
+	diadem "github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/store"
+)
+
+func TestNonceQueue(t *testing.T) {
+	pubkey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	origin := diadem.Address{
+		ChainID: "default",
+		Local:   diadem.LocalAddressFromPublicKey(pubkey),
+	}
+
+	resubmitted := make(chan []byte, 1)
+	handler := NonceHandler{nonceCache: make(map[string]uint64), lastHeight: 0}
+	handler.EnableNonceQueue(DefaultNonceQueueConfig(), func(txBytes []byte) {
+		resubmitted <- txBytes
+	})
+
+	next := func(state diademchain.State, txBytes []byte, isCheckTx bool) (diademchain.TxHandlerResult, error) {
+		return diademchain.TxHandlerResult{}, nil
+	}
+	checkTx := func(seq uint64) ([]byte, error) {
+		nonceTxBytes, err := proto.Marshal(&NonceTx{
+			Inner:    []byte{},
+			Sequence: seq,
+		})
+		require.NoError(t, err)
+		ctx := context.WithValue(context.Background(), ContextKeyOrigin, origin)
+		ctx = context.WithValue(ctx, ContextKeyTxBytes, nonceTxBytes)
+		state := diademchain.NewStoreState(ctx, store.NewMemStore(), abci.Header{Height: 5}, nil, nil)
+		if _, err := handler.Nonce(state, nonceTxBytes, next, true); err != nil {
+			return nonceTxBytes, err
+		}
+		return nonceTxBytes, handler.IncNonce(state, nonceTxBytes, diademchain.TxHandlerResult{}, nil)
+	}
+
+	_, ok := handler.PendingNonce(origin)
+	require.False(t, ok)
+
+	// A tx with a sequence number that's too far ahead should be rejected outright
+	_, err = checkTx(uint64(DefaultNonceQueueConfig().MaxTxsPerAccount) + 2)
+	require.Error(t, err)
+
+	// A tx with a sequence number that's ahead of the expected one should be queued...
+	queuedTx, err := checkTx(2)
+	require.Error(t, err)
+	select {
+	case <-resubmitted:
+		t.Fatal("tx resubmitted before the gap was filled")
+	default:
+	}
+
+	// ...until the tx with the expected sequence number is received
+	_, err = checkTx(1)
+	require.NoError(t, err)
+	select {
+	case txBytes := <-resubmitted:
+		require.Equal(t, queuedTx, txBytes)
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued tx wasn't resubmitted")
+	}
+
+	pendingNonce, ok := handler.PendingNonce(origin)
+	require.True(t, ok)
+	require.Equal(t, uint64(1), pendingNonce)
+
+	// The resubmitted tx should now be accepted
+	_, err = checkTx(2)
+	require.NoError(t, err)
+	pendingNonce, _ = handler.PendingNonce(origin)
+	require.Equal(t, uint64(2), pendingNonce)
+}
+
+func TestNonceQueueMaxQueuedTxs(t *testing.T) {
+	cfg := DefaultNonceQueueConfig()
+	cfg.MaxQueuedTxs = 3
+	queue := newNonceQueue(cfg, func(txBytes []byte) {})
+
+	accounts := make([]diadem.Address, 3)
+	for i := range accounts {
+		pubkey, _, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		accounts[i] = diadem.Address{ChainID: "default", Local: diadem.LocalAddressFromPublicKey(pubkey)}
+	}
+	add := func(origin diadem.Address, seq uint64) error {
+		ctx := context.WithValue(context.Background(), ContextKeyTxBytes, []byte{byte(seq)})
+		state := diademchain.NewStoreState(ctx, store.NewMemStore(), abci.Header{Height: 5}, nil, nil)
+		return queue.add(state, origin, seq, 1)
+	}
+	queued := func(origin diadem.Address) []uint64 {
+		var seqs []uint64
+		if acct := queue.accounts[origin.String()]; acct != nil {
+			for seq := range acct.txs {
+				seqs = append(seqs, seq)
+			}
+		}
+		return seqs
+	}
+
+	require.NoError(t, add(accounts[0], 2))
+	require.NoError(t, add(accounts[0], 3))
+	require.NoError(t, add(accounts[0], 4))
+	// replacing a queued tx doesn't require any room in the queue
+	require.NoError(t, add(accounts[0], 4))
+	require.Equal(t, 3, queue.size)
+
+	// once the queue is full the newest tx of the account with the most queued txs is evicted...
+	require.NoError(t, add(accounts[1], 2))
+	require.ElementsMatch(t, []uint64{2, 3}, queued(accounts[0]))
+	require.Equal(t, 3, queue.size)
+
+	// ...unless the new tx is from that account, or would give its account as many queued txs
+	require.Error(t, add(accounts[0], 5))
+	require.Error(t, add(accounts[1], 3))
+	require.Equal(t, 3, queue.size)
+
+	require.NoError(t, add(accounts[2], 2))
+	require.ElementsMatch(t, []uint64{2}, queued(accounts[0]))
+	require.ElementsMatch(t, []uint64{2}, queued(accounts[1]))
+	require.ElementsMatch(t, []uint64{2}, queued(accounts[2]))
+	require.Equal(t, 3, queue.size)
+}
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/gogo/protobuf/proto"
+	"golang.org/x/crypto/ed25519"
+
+	diadem "github.com/diademnetwork/go-diadem"
+	lp "github.com/diademnetwork/go-diadem/plugin"
+	"github.com/diademnetwork/go-diadem/types"
+	"github.com/diademnetwork/go-diadem/util"
+	"github.com/diademnetwork/go-diadem/vm"
+	"github.com/diademnetwork/diademchain"
+)
+
+// An account can grant a session key to a client (e.g. a game client) so that the client can send
+// txs on behalf of the account without access to the main key of the account. The txs signed by a
+// session key are attributed to the account that granted it, but are limited to calling specific
+// contracts (and optionally specific contract methods), and the key can only be used to sign a
+// limited number of txs before it expires.
+//
+// The state of each session key consists of:
+// - sessionkey<key address> -> SessionKey
+// Key addresses are derived from public keys the same way account addresses are. Revoked session
+// keys are marked as retired (see key_rotation.go) so they can't be reused.
+//
+// The session key types are defined in session_keys.proto.
+var sessionKeyPrefix = []byte("sessionkey")
+
+// SessionKeyMessage returns the message that must be signed by a session key granted by the
+// given account.
+func SessionKeyMessage(account diadem.Address) []byte {
+	return util.PrefixKey([]byte("session-key"), account.Bytes())
+}
+
+func sessionKeyKey(pubKey []byte) []byte {
+	return util.PrefixKey(sessionKeyPrefix, diadem.LocalAddressFromPublicKey(pubKey))
+}
+
+// GetSessionKey returns the session key with the given ed25519 public key, or nil if there is no
+// such session key.
+func GetSessionKey(state diademchain.ReadOnlyState, pubKey []byte) (*SessionKey, error) {
+	data := state.Get(sessionKeyKey(pubKey))
+	if data == nil {
+		return nil, nil
+	}
+	var sessionKey SessionKey
+	if err := proto.Unmarshal(data, &sessionKey); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session key: %v", err)
+	}
+	return &sessionKey, nil
+}
+
+func saveSessionKey(state diademchain.State, sessionKey *SessionKey) error {
+	data, err := proto.Marshal(sessionKey)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session key: %v", err)
+	}
+	state.Set(sessionKeyKey(sessionKey.PublicKey), data)
+	return nil
+}
+
+// GrantSessionKey grants a session key to the given account, the session key can't remain valid for
+// more than maxLifetime blocks (unless maxLifetime is zero).
+//
+// The session key must not have been used before, otherwise the txs signed by the session key
+// would suddenly be attributed to the given account instead of the account they were sent from.
+func GrantSessionKey(
+	state diademchain.State, account diadem.Address, tx *GrantSessionKeyTx, maxLifetime int64,
+) error {
+	if !state.FeatureEnabled(diademchain.AuthSessionKeysFeature, false) {
+		return errors.New("session keys haven't been enabled")
+	}
+	if len(tx.PublicKey) != ed25519.PublicKeySize {
+		return errors.New("invalid public key length")
+	}
+	if len(tx.KeySignature) != ed25519.SignatureSize ||
+		!ed25519.Verify(tx.PublicKey, SessionKeyMessage(account), tx.KeySignature) {
+		return errors.New("invalid session key signature")
+	}
+	if len(tx.Contracts) == 0 {
+		return errors.New("session key must be limited to at least one contract")
+	}
+	if tx.MaxTxCount == 0 {
+		return errors.New("session key max tx count must be greater than zero")
+	}
+	if tx.ExpiryHeight <= state.Block().Height {
+		return errors.New("session key expiry height must be in the future")
+	}
+	if maxLifetime > 0 && tx.ExpiryHeight-state.Block().Height > maxLifetime {
+		return fmt.Errorf("session key can't remain valid for more than %d blocks", maxLifetime)
+	}
+
+	keyAddr := diadem.LocalAddressFromPublicKey(tx.PublicKey)
+	if state.Has(util.PrefixKey(sessionKeyPrefix, keyAddr)) ||
+		state.Has(util.PrefixKey(keyAccountPrefix, keyAddr)) ||
+		state.Has(util.PrefixKey(retiredKeyPrefix, keyAddr)) ||
+		Nonce(state, diadem.Address{ChainID: account.ChainID, Local: keyAddr}) != 0 {
+		return errors.New("session key is already in use")
+	}
+
+	return saveSessionKey(state, &SessionKey{
+		Owner:        account.MarshalPB(),
+		PublicKey:    tx.PublicKey,
+		Contracts:    tx.Contracts,
+		Methods:      tx.Methods,
+		MaxTxCount:   tx.MaxTxCount,
+		ExpiryHeight: tx.ExpiryHeight,
+	})
+}
+
+// RevokeSessionKey revokes a session key granted by the given account.
+func RevokeSessionKey(state diademchain.State, account diadem.Address, tx *RevokeSessionKeyTx) error {
+	if !state.FeatureEnabled(diademchain.AuthSessionKeysFeature, false) {
+		return errors.New("session keys haven't been enabled")
+	}
+	sessionKey, err := GetSessionKey(state, tx.PublicKey)
+	if err != nil {
+		return err
+	}
+	if sessionKey == nil || diadem.UnmarshalAddressPB(sessionKey.Owner).Compare(account) != 0 {
+		return errors.New("session key not found")
+	}
+	keyAddr := diadem.LocalAddressFromPublicKey(tx.PublicKey)
+	state.Delete(util.PrefixKey(sessionKeyPrefix, keyAddr))
+	state.Set(util.PrefixKey(retiredKeyPrefix, keyAddr), []byte{1})
+	return nil
+}
+
+// useSessionKey checks that the given session key can be used to sign the given tx, and counts
+// the tx towards the max tx count of the session key.
+func useSessionKey(state diademchain.State, sessionKey *SessionKey, tx *types.Transaction, msg *vm.MessageTx) error {
+	if state.Block().Height >= sessionKey.ExpiryHeight {
+		return errors.New("session key has expired")
+	}
+	if sessionKey.TxCount >= sessionKey.MaxTxCount {
+		return errors.New("session key has reached the max tx count")
+	}
+	if tx.Id != 2 { // call
+		return errors.New("session keys can only be used to sign call txs")
+	}
+
+	contract := diadem.UnmarshalAddressPB(msg.To)
+	allowed := false
+	for _, addr := range sessionKey.Contracts {
+		if diadem.UnmarshalAddressPB(addr).Compare(contract) == 0 {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("session key can't be used to call contract %s", contract.String())
+	}
+
+	if len(sessionKey.Methods) > 0 {
+		method, err := contractMethod(msg)
+		if err != nil {
+			return err
+		}
+		allowed = false
+		for _, m := range sessionKey.Methods {
+			if m == method {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("session key can't be used to call method %s of contract %s",
+				method, contract.String(),
+			)
+		}
+	}
+
+	sessionKey.TxCount++
+	return saveSessionKey(state, sessionKey)
+}
+
+// contractMethod returns the name of the Go contract method, or the 4-byte selector of the EVM
+// contract method, called by the given tx.
+func contractMethod(msg *vm.MessageTx) (string, error) {
+	var callTx vm.CallTx
+	if err := proto.Unmarshal(msg.Data, &callTx); err != nil {
+		return "", fmt.Errorf("failed to unmarshal CallTx: %v", err)
+	}
+
+	switch callTx.VmType {
+	case vm.VMType_EVM:
+		if len(callTx.Input) < 4 {
+			return "", errors.New("EVM call input is too short")
+		}
+		return "0x" + hex.EncodeToString(callTx.Input[:4]), nil
+
+	case vm.VMType_PLUGIN:
+		var req lp.Request
+		if err := proto.Unmarshal(callTx.Input, &req); err != nil {
+			return "", fmt.Errorf("failed to unmarshal contract call request: %v", err)
+		}
+		if req.ContentType != lp.EncodingType_PROTOBUF3 {
+			return "", errors.New("session keys can only be used to sign protobuf encoded contract calls")
+		}
+		var methodCall lp.ContractMethodCall
+		if err := proto.Unmarshal(req.Body, &methodCall); err != nil {
+			return "", fmt.Errorf("failed to unmarshal contract method call: %v", err)
+		}
+		return methodCall.Method, nil
+
+	default:
+		return "", fmt.Errorf("unsupported VM type %v", callTx.VmType)
+	}
+}
@@ -0,0 +1,143 @@
+// +build evm
+
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/go-diadem/auth"
+	"github.com/diademnetwork/go-diadem/plugin/contractpb"
+	"github.com/diademnetwork/go-diadem/types"
+	"github.com/diademnetwork/go-diadem/vm"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/store"
+)
+
+func TestSessionKeys(t *testing.T) {
+	state := diademchain.NewStoreState(nil, store.NewMemStore(), abci.Header{ChainID: defaultDiademChainId, Height: 10}, nil, nil)
+	state.SetFeature(diademchain.AuthSigTxFeaturePrefix+defaultDiademChainId, true)
+
+	ownerPubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	sessionPubKey, sessionPrivKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	owner := diadem.Address{ChainID: defaultDiademChainId, Local: diadem.LocalAddressFromPublicKey(ownerPubKey)}
+	otherContract := diadem.MustParseAddress(defaultDiademChainId + ":0x1f4c684e297be3edf03b825e01c45cecd1f7261e")
+	selector := []byte{0xa9, 0x05, 0x9c, 0xbb}
+
+	grantTx := &GrantSessionKeyTx{
+		PublicKey:    sessionPubKey,
+		KeySignature: ed25519.Sign(sessionPrivKey, SessionKeyMessage(owner)),
+		Contracts:    []*types.Address{contract.MarshalPB()},
+		Methods:      []string{"0xa9059cbb"},
+		MaxTxCount:   2,
+		ExpiryHeight: 20,
+	}
+
+	// Expect an error if session keys are not enabled
+	require.Error(t, GrantSessionKey(state, owner, grantTx, 100))
+
+	state.SetFeature(diademchain.AuthSessionKeysFeature, true)
+
+	// The session key must sign the owner address
+	badGrantTx := *grantTx
+	badGrantTx.KeySignature = ed25519.Sign(sessionPrivKey, SessionKeyMessage(origin))
+	require.Error(t, GrantSessionKey(state, owner, &badGrantTx, 100))
+	// The session key can't remain valid for longer than the max lifetime
+	require.Error(t, GrantSessionKey(state, owner, grantTx, 5))
+
+	require.NoError(t, GrantSessionKey(state, owner, grantTx, 100))
+	// The same key can't be granted twice
+	require.Error(t, GrantSessionKey(state, owner, grantTx, 100))
+
+	tmx := NewMultiChainSignatureTxMiddleware(
+		map[string]ChainConfig{
+			defaultDiademChainId: {
+				TxType:      DiademSignedTxType,
+				AccountType: NativeAccountType,
+			},
+		},
+		func(state diademchain.State) (contractpb.Context, error) { return nil, nil },
+	)
+	ctx := context.Background()
+	sessionSigner := auth.NewEd25519Signer(sessionPrivKey)
+
+	// Txs signed by the session key are attributed to the owner
+	_, err = throttleMiddlewareHandler(tmx, state, mockSessionKeyTx(t, sessionSigner, owner, contract, selector), ctx)
+	require.NoError(t, err)
+
+	// The session key can only be used to call the allowed contracts & methods
+	_, err = throttleMiddlewareHandler(tmx, state, mockSessionKeyTx(t, sessionSigner, owner, otherContract, selector), ctx)
+	require.Error(t, err)
+	_, err = throttleMiddlewareHandler(tmx, state, mockSessionKeyTx(t, sessionSigner, owner, contract, []byte{1, 2, 3, 4}), ctx)
+	require.Error(t, err)
+
+	// The session key can't be used to send txs from any other account
+	sessionAddr := diadem.Address{ChainID: defaultDiademChainId, Local: diadem.LocalAddressFromPublicKey(sessionPubKey)}
+	_, err = throttleMiddlewareHandler(tmx, state, mockSessionKeyTx(t, sessionSigner, sessionAddr, contract, selector), ctx)
+	require.Error(t, err)
+
+	// The session key can only be used to sign a limited number of txs
+	_, err = throttleMiddlewareHandler(tmx, state, mockSessionKeyTx(t, sessionSigner, owner, contract, selector), ctx)
+	require.NoError(t, err)
+	_, err = throttleMiddlewareHandler(tmx, state, mockSessionKeyTx(t, sessionSigner, owner, contract, selector), ctx)
+	require.Error(t, err)
+
+	// The session key can't be used after it expires
+	sessionKey, err := GetSessionKey(state, sessionPubKey)
+	require.NoError(t, err)
+	sessionKey.TxCount = 0
+	require.NoError(t, saveSessionKey(state, sessionKey))
+	_, err = throttleMiddlewareHandler(tmx, state, mockSessionKeyTx(t, sessionSigner, owner, contract, selector), ctx)
+	require.NoError(t, err)
+	expiredState := diademchain.NewStoreState(nil, store.NewMemStore(), abci.Header{ChainID: defaultDiademChainId, Height: 20}, nil, nil)
+	expiredState.SetFeature(diademchain.AuthSigTxFeaturePrefix+defaultDiademChainId, true)
+	expiredState.SetFeature(diademchain.AuthSessionKeysFeature, true)
+	require.NoError(t, saveSessionKey(expiredState, sessionKey))
+	_, err = throttleMiddlewareHandler(tmx, expiredState, mockSessionKeyTx(t, sessionSigner, owner, contract, selector), ctx)
+	require.Error(t, err)
+
+	// Only the owner can revoke the session key
+	require.Error(t, RevokeSessionKey(state, origin, &RevokeSessionKeyTx{PublicKey: sessionPubKey}))
+	require.NoError(t, RevokeSessionKey(state, owner, &RevokeSessionKeyTx{PublicKey: sessionPubKey}))
+	_, err = throttleMiddlewareHandler(tmx, state, mockSessionKeyTx(t, sessionSigner, owner, contract, selector), ctx)
+	require.Error(t, err)
+
+	// Revoked session keys can't be granted again
+	require.Error(t, GrantSessionKey(state, owner, grantTx, 100))
+}
+
+func mockSessionKeyTx(t *testing.T, signer auth.Signer, from, to diadem.Address, input []byte) []byte {
+	callTx, err := proto.Marshal(&vm.CallTx{
+		VmType: vm.VMType_EVM,
+		Input:  input,
+	})
+	require.NoError(t, err)
+	messageTx, err := proto.Marshal(&vm.MessageTx{
+		Data: callTx,
+		To:   to.MarshalPB(),
+		From: from.MarshalPB(),
+	})
+	require.NoError(t, err)
+	tx, err := proto.Marshal(&diademchain.Transaction{
+		Id:   callId,
+		Data: messageTx,
+	})
+	require.NoError(t, err)
+	nonceTx, err := proto.Marshal(&auth.NonceTx{
+		Inner:    tx,
+		Sequence: sequence,
+	})
+	require.NoError(t, err)
+	signedTx, err := proto.Marshal(auth.SignTx(signer, nonceTx))
+	require.NoError(t, err)
+	return signedTx
+}
@@ -67,6 +67,13 @@ func (am *AddressMapper) Init(ctx contract.Context, req *InitRequest) error {
 	return nil
 }
 
+// ExportInitRequest builds an InitRequest that can be used to initialize the contract on a new
+// chain. The contract has no init state, so the request is always empty, the identity mappings of
+// an existing contract are not exported.
+func ExportInitRequest(ctx contract.StaticContext) (*InitRequest, error) {
+	return &InitRequest{}, nil
+}
+
 // AddIdentityMapping adds a mapping between a DAppChain account and a Mainnet account.
 // The caller must provide proof of ownership of the Mainnet account.
 func (am *AddressMapper) AddIdentityMapping(ctx contract.Context, req *AddIdentityMappingRequest) error {
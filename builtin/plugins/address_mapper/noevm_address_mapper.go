@@ -46,4 +46,8 @@ func SignIdentityMapping(_, _ diadem.Address, _ *ecdsa.PrivateKey) ([]byte, erro
 	return nil, nil
 }
 
+func ExportInitRequest(ctx contract.StaticContext) (*InitRequest, error) {
+	return &InitRequest{}, nil
+}
+
 var Contract plugin.Contract = contract.MakePluginContract(&AddressMapper{})
@@ -3,6 +3,7 @@ package coin
 import (
 	"errors"
 	"fmt"
+	"math/big"
 
 	diadem "github.com/diademnetwork/go-diadem"
 	ctypes "github.com/diademnetwork/go-diadem/builtin/types/coin"
@@ -10,6 +11,7 @@ import (
 	contract "github.com/diademnetwork/go-diadem/plugin/contractpb"
 	"github.com/diademnetwork/go-diadem/types"
 	"github.com/diademnetwork/go-diadem/util"
+	"github.com/gogo/protobuf/proto"
 
 	errUtil "github.com/pkg/errors"
 )
@@ -38,12 +40,13 @@ type (
 )
 
 var (
-	economyKey = []byte("economy")
-	decimals   = 18
+	economyKey       = []byte("economy")
+	accountKeyPrefix = []byte("account")
+	decimals         = 18
 )
 
 func accountKey(addr diadem.Address) []byte {
-	return util.PrefixKey([]byte("account"), addr.Bytes())
+	return util.PrefixKey(accountKeyPrefix, addr.Bytes())
 }
 
 func allowanceKey(owner, spender diadem.Address) []byte {
@@ -218,6 +221,15 @@ func (c *Coin) BalanceOf(
 	}, nil
 }
 
+// BalanceOf returns the DiademCoin balance of the given account.
+func BalanceOf(ctx contract.StaticContext, owner diadem.Address) (*diadem.BigUInt, error) {
+	acct, err := loadAccount(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	return &acct.Balance.Value, nil
+}
+
 func (c *Coin) Transfer(ctx contract.Context, req *TransferRequest) error {
 	from := ctx.Message().Sender
 	to := diadem.UnmarshalAddressPB(req.To)
@@ -256,6 +268,37 @@ func (c *Coin) Transfer(ctx contract.Context, req *TransferRequest) error {
 	return nil
 }
 
+// ChargeFee transfers a tx fee from the payer to the fee collector. This is only meant to be used
+// by the tx fee middleware, with a context that has direct access to the storage of the contract.
+func ChargeFee(ctx contract.Context, payer, collector diadem.Address, fee *diadem.BigUInt) error {
+	payerAccount, err := loadAccount(ctx, payer)
+	if err != nil {
+		return err
+	}
+
+	collectorAccount, err := loadAccount(ctx, collector)
+	if err != nil {
+		return err
+	}
+
+	payerBalance := payerAccount.Balance.Value
+	collectorBalance := collectorAccount.Balance.Value
+
+	if payerBalance.Cmp(fee) < 0 {
+		return fmt.Errorf("balance of %s is too low to pay fee %s", payer.String(), fee.String())
+	}
+
+	payerBalance.Sub(&payerBalance, fee)
+	collectorBalance.Add(&collectorBalance, fee)
+
+	payerAccount.Balance.Value = payerBalance
+	collectorAccount.Balance.Value = collectorBalance
+	if err := saveAccount(ctx, payerAccount); err != nil {
+		return err
+	}
+	return saveAccount(ctx, collectorAccount)
+}
+
 func (c *Coin) Approve(ctx contract.Context, req *ApproveRequest) error {
 	owner := ctx.Message().Sender
 	spender := diadem.UnmarshalAddressPB(req.Spender)
@@ -395,4 +438,39 @@ func saveAllowance(ctx contract.Context, allow *Allowance) error {
 	return ctx.Set(allowanceKey(owner, spender), allow)
 }
 
+// ExportInitRequest builds an InitRequest that can be used to initialize the contract on a new
+// chain with the account balances of an existing contract. InitRequest only supports whole coin
+// balances, so an error is returned if any account has a fractional balance, allowances are not
+// exported.
+func ExportInitRequest(ctx contract.StaticContext) (*InitRequest, error) {
+	div := diadem.NewBigUIntFromInt(10)
+	div.Exp(div, diadem.NewBigUIntFromInt(int64(decimals)), nil)
+
+	req := &InitRequest{}
+	for _, entry := range ctx.Range(accountKeyPrefix) {
+		var acct Account
+		if err := proto.Unmarshal(entry.Value, &acct); err != nil {
+			return nil, errUtil.Wrapf(err, "failed to unmarshal account %X", entry.Key)
+		}
+		if acct.Owner == nil || acct.Balance == nil {
+			continue
+		}
+		if new(big.Int).Mod(acct.Balance.Value.Int, div.Int).Sign() != 0 {
+			return nil, fmt.Errorf(
+				"balance of %v has a fractional part that can't be exported", diadem.UnmarshalAddressPB(acct.Owner),
+			)
+		}
+		balance := diadem.NewBigUIntFromInt(0)
+		balance.Div(&acct.Balance.Value, div)
+		if !balance.IsUint64() {
+			return nil, fmt.Errorf("balance of %v is too large to export", diadem.UnmarshalAddressPB(acct.Owner))
+		}
+		req.Accounts = append(req.Accounts, &InitialAccount{
+			Owner:   acct.Owner,
+			Balance: balance.Uint64(),
+		})
+	}
+	return req, nil
+}
+
 var Contract plugin.Contract = contract.MakePluginContract(&Coin{})
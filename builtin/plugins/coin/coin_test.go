@@ -74,6 +74,39 @@ func TestTransfer(t *testing.T) {
 	assert.Equal(t, 100, int(resp.Balance.Value.Int64()))
 }
 
+func TestChargeFee(t *testing.T) {
+	ctx := contractpb.WrapPluginContext(
+		plugin.CreateFakeContext(addr1, addr1),
+	)
+
+	err := saveAccount(ctx, &Account{
+		Owner: addr1.MarshalPB(),
+		Balance: &types.BigUInt{
+			Value: *diadem.NewBigUIntFromInt(100),
+		},
+	})
+	require.Nil(t, err)
+
+	err = ChargeFee(ctx, addr1, addr3, diadem.NewBigUIntFromInt(101))
+	assert.NotNil(t, err)
+
+	err = ChargeFee(ctx, addr1, addr3, diadem.NewBigUIntFromInt(30))
+	require.Nil(t, err)
+
+	contract := &Coin{}
+	resp, err := contract.BalanceOf(ctx, &BalanceOfRequest{
+		Owner: addr1.MarshalPB(),
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 70, int(resp.Balance.Value.Int64()))
+
+	resp, err = contract.BalanceOf(ctx, &BalanceOfRequest{
+		Owner: addr3.MarshalPB(),
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 30, int(resp.Balance.Value.Int64()))
+}
+
 func TestApprove(t *testing.T) {
 	contract := &Coin{}
 
@@ -375,3 +408,43 @@ func TestMintToGatewayAccess(t *testing.T) {
 	), "diademcoin gateway should be allowed to call MintToGateway")
 
 }
+
+func TestExportInitRequest(t *testing.T) {
+	contract := &Coin{}
+	ctx := contractpb.WrapPluginContext(
+		plugin.CreateFakeContext(addr1, addr1),
+	)
+
+	initReq := &InitRequest{
+		Accounts: []*InitialAccount{
+			&InitialAccount{
+				Owner:   addr1.MarshalPB(),
+				Balance: uint64(31),
+			},
+			&InitialAccount{
+				Owner:   addr2.MarshalPB(),
+				Balance: uint64(29),
+			},
+		},
+	}
+	require.NoError(t, contract.Init(ctx, initReq))
+
+	exported, err := ExportInitRequest(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []*InitialAccount{
+		&InitialAccount{Owner: addr1.MarshalPB(), Balance: uint64(31)},
+		&InitialAccount{Owner: addr2.MarshalPB(), Balance: uint64(29)},
+	}, exported.Accounts)
+
+	// fractional balances can't be exported
+	amount := big.NewInt(5)
+	amount.Exp(amount, big.NewInt(17), nil)
+	err = contract.Transfer(ctx, &TransferRequest{
+		To:     addr3.MarshalPB(),
+		Amount: &types.BigUInt{Value: *diadem.NewBigUInt(amount)},
+	})
+	require.NoError(t, err)
+
+	_, err = ExportInitRequest(ctx)
+	require.Error(t, err)
+}
@@ -183,6 +183,35 @@ func (c *DPOS) Init(ctx contract.Context, req *InitRequest) error {
 	return saveState(ctx, state)
 }
 
+// ExportInitRequest builds an InitRequest that can be used to initialize the contract on a new
+// chain with the params & validators of an existing contract. InitRequest can't carry candidates,
+// delegations, or the rewards distributed to them, so an error is returned if the contract has
+// any of those.
+func ExportInitRequest(ctx contract.StaticContext) (*InitRequest, error) {
+	state, err := loadState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := loadCandidateList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) > 0 {
+		return nil, fmt.Errorf("%d candidates can't be exported to an init request", len(candidates))
+	}
+	delegations, err := loadDelegationList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(delegations) > 0 {
+		return nil, fmt.Errorf("%d delegations can't be exported to an init request", len(delegations))
+	}
+	return &InitRequest{
+		Params:     state.Params,
+		Validators: state.Validators,
+	}, nil
+}
+
 // *********************
 // DELEGATION
 // *********************
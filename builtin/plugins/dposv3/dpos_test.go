@@ -1609,6 +1609,36 @@ func TestApplyPowerCap(t *testing.T) {
 	}
 }
 
+func TestExportInitRequest(t *testing.T) {
+	oraclePubKey, _ := hex.DecodeString(validatorPubKeyHex2)
+	oracleAddr := diadem.Address{
+		Local: diadem.LocalAddressFromPublicKey(oraclePubKey),
+	}
+	pctx := plugin.CreateFakeContext(addr1, addr1)
+
+	coinAddr := pctx.CreateContract(coin.Contract)
+	dpos, err := deployDPOSContract(pctx, &Params{
+		ValidatorCount:      21,
+		CoinContractAddress: coinAddr.MarshalPB(),
+		OracleAddress:       oracleAddr.MarshalPB(),
+	})
+	require.Nil(t, err)
+
+	dposCtx := contractpb.WrapPluginContext(pctx.WithAddress(dpos.Address))
+	exported, err := ExportInitRequest(dposCtx)
+	require.NoError(t, err)
+	require.EqualValues(t, 21, exported.Params.ValidatorCount)
+
+	// candidates can't be exported to an init request
+	err = dpos.WhitelistCandidate(pctx.WithSender(oracleAddr), addr1, big.NewInt(1000000000000), 0)
+	require.Nil(t, err)
+	err = dpos.RegisterCandidate(pctx.WithSender(addr1), pubKey1, nil, nil, nil, nil, nil, nil)
+	require.Nil(t, err)
+
+	_, err = ExportInitRequest(dposCtx)
+	require.Error(t, err)
+}
+
 // UTILITIES
 
 func makeAccount(owner diadem.Address, bal uint64) *coin.InitialAccount {
@@ -222,6 +222,31 @@ func (gw *Gateway) Init(ctx contract.Context, req *InitRequest) error {
 	})
 }
 
+// ExportInitRequest builds an InitRequest that can be used to initialize the contract on a new
+// chain with the owner & oracles of an existing contract. Token mappings, balances, and pending
+// withdrawals are not exported.
+func ExportInitRequest(ctx contract.StaticContext) (*InitRequest, error) {
+	state, err := loadState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if state.Owner == nil {
+		return nil, ErrOwnerNotSpecified
+	}
+	req := &InitRequest{
+		Owner:                state.Owner,
+		FirstMainnetBlockNum: state.LastMainnetBlockNum,
+	}
+	for _, entry := range ctx.Range(oracleStateKeyPrefix) {
+		var oracleState OracleState
+		if err := proto.Unmarshal(entry.Value, &oracleState); err != nil {
+			return nil, err
+		}
+		req.Oracles = append(req.Oracles, oracleState.Address)
+	}
+	return req, nil
+}
+
 func (gw *Gateway) AddOracle(ctx contract.Context, req *AddOracleRequest) error {
 	if req.Oracle == nil {
 		return ErrInvalidRequest
@@ -3,6 +3,8 @@
 package gateway
 
 import (
+	"errors"
+
 	tgtypes "github.com/diademnetwork/go-diadem/builtin/types/transfer_gateway"
 	"github.com/diademnetwork/go-diadem/plugin"
 	contract "github.com/diademnetwork/go-diadem/plugin/contractpb"
@@ -38,6 +40,10 @@ func (gw *Gateway) Init(ctx contract.Context, req *InitRequest) error {
 	return nil
 }
 
+func ExportInitRequest(ctx contract.StaticContext) (*InitRequest, error) {
+	return nil, errors.New("Transfer Gateway is not supported in non-EVM builds")
+}
+
 var Contract plugin.Contract = contract.MakePluginContract(&Gateway{})
 var UnsafeContract plugin.Contract = contract.MakePluginContract(&UnsafeGateway{Gateway{}})
 
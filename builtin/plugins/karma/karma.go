@@ -478,4 +478,58 @@ func modifyCountForUser(ctx contract.Context, user *types.Address, sourceName st
 	return nil
 }
 
+// ExportInitRequest builds a KarmaInitRequest that can be used to initialize the contract on a new
+// chain with the sources, oracle, config, upkeep params, and user karma of an existing contract.
+// Contracts that are being tracked for upkeep are not exported.
+func ExportInitRequest(ctx contract.StaticContext) (*ktypes.KarmaInitRequest, error) {
+	req := &ktypes.KarmaInitRequest{}
+
+	var sources ktypes.KarmaSources
+	if err := ctx.Get(SourcesKey, &sources); err != nil && err != contract.ErrNotFound {
+		return nil, errors.Wrap(err, "failed to load karma sources")
+	}
+	req.Sources = sources.Sources
+
+	oracle, err := GetOracleAddress(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load oracle")
+	}
+	if oracle != nil {
+		req.Oracle = oracle.MarshalPB()
+	}
+
+	var config ktypes.KarmaConfig
+	if err := ctx.Get(ConfigKey, &config); err == nil {
+		req.Config = &config
+	} else if err != contract.ErrNotFound {
+		return nil, errors.Wrap(err, "failed to load config")
+	}
+
+	var upkeep ktypes.KarmaUpkeepParams
+	if err := ctx.Get(UpkeepKey, &upkeep); err == nil {
+		req.Upkeep = &upkeep
+	} else if err != contract.ErrNotFound {
+		return nil, errors.Wrap(err, "failed to load upkeep params")
+	}
+
+	for _, entry := range ctx.Range([]byte(UserStateKeyPrefix)) {
+		var user types.Address
+		if err := proto.Unmarshal(entry.Key, &user); err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal user address %X", entry.Key)
+		}
+		var userState ktypes.KarmaState
+		if err := proto.Unmarshal(entry.Value, &userState); err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal karma state of user %v", user.String())
+		}
+		if len(userState.SourceStates) == 0 {
+			continue
+		}
+		req.Users = append(req.Users, &ktypes.KarmaAddressSource{
+			User:    &user,
+			Sources: userState.SourceStates,
+		})
+	}
+	return req, nil
+}
+
 var Contract plugin.Contract = contract.MakePluginContract(&Karma{})
@@ -113,6 +113,48 @@ func TestKarmaInit(t *testing.T) {
 	require.Equal(t, int64(85), config.MinKarmaToDeploy)
 }
 
+func TestKarmaExportInitRequest(t *testing.T) {
+	ctx := contractpb.WrapPluginContext(
+		plugin.CreateFakeContext(addr1, addr1),
+	)
+	contract := &Karma{}
+	require.NoError(t, contract.Init(ctx, &ktypes.KarmaInitRequest{
+		Oracle:  oracle,
+		Sources: sources,
+		Users:   users,
+		Config:  &ktypes.KarmaConfig{MinKarmaToDeploy: 73},
+	}))
+
+	exported, err := ExportInitRequest(ctx)
+	require.NoError(t, err)
+	require.Equal(t, oracle.String(), exported.Oracle.String())
+	require.Equal(t, int64(73), exported.Config.MinKarmaToDeploy)
+	require.NotNil(t, exported.Upkeep)
+	require.Equal(t, len(users), len(exported.Users))
+
+	// a contract initialized from the exported request should have the same state
+	newCtx := contractpb.WrapPluginContext(
+		plugin.CreateFakeContext(addr1, addr1),
+	)
+	require.NoError(t, contract.Init(newCtx, exported))
+	s, err := contract.GetSources(newCtx, &ktypes.GetSourceRequest{})
+	require.NoError(t, err)
+	require.Equal(t, len(sources), len(s.Sources))
+	for k := range sources {
+		require.Equal(t, sources[k].String(), s.Sources[k].String())
+	}
+	for _, u := range users {
+		oldState, err := contract.GetUserState(ctx, u.User)
+		require.NoError(t, err)
+		newState, err := contract.GetUserState(newCtx, u.User)
+		require.NoError(t, err)
+		require.Equal(t, oldState.String(), newState.String())
+	}
+	config, err := contract.GetConfig(newCtx, &ktypes.GetConfigRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int64(73), config.MinKarmaToDeploy)
+}
+
 func TestKarmaValidateOracle(t *testing.T) {
 	fakeContext := plugin.CreateFakeContext(addr1, addr1)
 	ctx := contractpb.WrapPluginContext(fakeContext)
@@ -0,0 +1,36 @@
+package common
+
+import (
+	"errors"
+
+	"github.com/diademnetwork/diademchain/config"
+	cdb "github.com/diademnetwork/diademchain/db"
+	"github.com/diademnetwork/diademchain/store"
+)
+
+// LoadAppStore loads the app store from the given node root dir, using the DB settings from the
+// given config.
+func LoadAppStore(cfg *config.Config, dir string) (store.VersionedKVStore, error) {
+	db, err := cdb.LoadDB(
+		cfg.DBBackend, cfg.DBName, dir, cfg.DBBackendConfig.CacheSizeMegs, false,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.AppStore.Version {
+	case 1:
+		return store.NewIAVLStore(db, 0, 0)
+	case 2:
+		valueDB, err := cdb.LoadDB(
+			cfg.AppStore.LatestStateDBBackend, cfg.AppStore.LatestStateDBName, dir,
+			cfg.DBBackendConfig.CacheSizeMegs, false,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return store.NewMultiReaderIAVLStore(db, valueDB, cfg.AppStore)
+	default:
+		return nil, errors.New("Invalid AppStore.Version config setting")
+	}
+}
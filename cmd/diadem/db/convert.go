@@ -0,0 +1,246 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/diademnetwork/diademchain/cmd/diadem/common"
+	cdb "github.com/diademnetwork/diademchain/db"
+	"github.com/diademnetwork/diademchain/receipts/leveldb"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	goleveldb "github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+// Number of key-value pairs written to the destination DB in a single batch.
+const convertDBBatchSize = 10000
+
+func newConvertDBCommand() *cobra.Command {
+	var srcBackend, destBackend, destDir, destName string
+	var skipVerify bool
+	cmd := &cobra.Command{
+		Use:   "convert <db-name>",
+		Short: "Copies a database (e.g. app, evm, receipts_db) to a different DB backend",
+		Example: "  diadem db convert app --dest-backend badgerdb --dest-dir ./converted\n" +
+			"  diadem db convert evm --dest-backend badgerdb --dest-name evm_badger",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := common.ParseConfig()
+			if err != nil {
+				return err
+			}
+
+			srcName := args[0]
+			if destDir == "" {
+				destDir = cfg.RootPath()
+			}
+			if destName == "" {
+				destName = srcName
+			}
+			srcPath, err := dbPath(srcBackend, srcName, cfg.RootPath())
+			if err != nil {
+				return err
+			}
+			destPath, err := dbPath(destBackend, destName, destDir)
+			if err != nil {
+				return err
+			}
+			if srcPath == destPath {
+				return errors.New("source and destination databases must not be the same, " +
+					"use --dest-dir or --dest-name to specify a different destination")
+			}
+			// Loading a DB that doesn't exist creates an empty one, which would then be happily
+			// copied to the destination.
+			if _, err := os.Stat(srcPath); err != nil {
+				return errors.Wrapf(err, "source DB %s not found", srcPath)
+			}
+
+			srcDB, err := loadSourceDB(srcBackend, srcName, srcPath, cfg.DBBackendConfig.CacheSizeMegs)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load source DB %s", srcName)
+			}
+			defer srcDB.Close()
+
+			if isEmptyDB(srcDB) {
+				return fmt.Errorf("source DB %s is empty", srcPath)
+			}
+
+			destDB, err := cdb.LoadDB(destBackend, destName, destDir, cfg.DBBackendConfig.CacheSizeMegs, false)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load destination DB %s", destName)
+			}
+			defer destDB.Close()
+
+			numKeys, err := copyDB(srcDB, destDB)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Copied %d keys from %s (%s) to %s (%s)\n", numKeys, srcPath, srcBackend, destPath, destBackend)
+
+			if skipVerify {
+				return nil
+			}
+			if err := verifyDBCopy(srcDB, destDB); err != nil {
+				return errors.Wrap(err, "verification failed")
+			}
+			fmt.Println("Verified destination DB matches the source DB")
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&srcBackend, "src-backend", cdb.GoLevelDBBackend, "Backend of the source DB")
+	flags.StringVar(&destBackend, "dest-backend", "", "Backend of the destination DB (goleveldb, cleveldb, badgerdb)")
+	flags.StringVar(&destDir, "dest-dir", "", "Directory to create the destination DB in (defaults to the node root dir)")
+	flags.StringVar(&destName, "dest-name", "", "Name of the destination DB (defaults to the source DB name)")
+	flags.BoolVar(&skipVerify, "skip-verify", false, "Don't compare the destination DB to the source DB after copying")
+	cmd.MarkFlagRequired("dest-backend")
+	return cmd
+}
+
+// sourceDB is the subset of the DB interface needed to read the DB being converted.
+type sourceDB interface {
+	Iterator(start, end []byte) dbm.Iterator
+	Close()
+}
+
+// dbPath returns the absolute path of the DB with the given name & backend in the given dir.
+func dbPath(backend, name, dir string) (string, error) {
+	if isLegacyReceiptsDB(backend, name) {
+		return filepath.Abs(filepath.Join(dir, name))
+	}
+	return filepath.Abs(filepath.Join(dir, name+".db"))
+}
+
+// isLegacyReceiptsDB returns true if the given DB is the receipts DB stored with the goleveldb
+// backend, which is opened directly by the receipts handler so its dir doesn't have the .db
+// suffix all the other DBs have (see leveldb.NewLevelDbReceipts).
+func isLegacyReceiptsDB(backend, name string) bool {
+	return name == leveldb.Db_Filename && (backend == cdb.GoLevelDBBackend || backend == "")
+}
+
+func loadSourceDB(backend, name, path string, cacheSizeMegs int) (sourceDB, error) {
+	if isLegacyReceiptsDB(backend, name) {
+		db, err := goleveldb.OpenFile(path, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &legacyLevelDB{db: db}, nil
+	}
+	return cdb.LoadDB(backend, name, filepath.Dir(path), cacheSizeMegs, false)
+}
+
+func isEmptyDB(db sourceDB) bool {
+	it := db.Iterator(nil, nil)
+	defer it.Close()
+	return !it.Valid()
+}
+
+// copyDB copies all the key-value pairs from the source DB to the destination DB, and returns
+// the number of keys copied. The node must not be running while the DB is being copied.
+func copyDB(src sourceDB, dest cdb.DBWrapper) (uint64, error) {
+	it := src.Iterator(nil, nil)
+	defer it.Close()
+
+	var numKeys uint64
+	batch := dest.NewBatch()
+	batchSize := 0
+	for ; it.Valid(); it.Next() {
+		batch.Set(it.Key(), it.Value())
+		batchSize++
+		numKeys++
+		if batchSize >= convertDBBatchSize {
+			batch.WriteSync()
+			batch = dest.NewBatch()
+			batchSize = 0
+			fmt.Printf("Copied %d keys...\n", numKeys)
+		}
+	}
+	if batchSize > 0 {
+		batch.WriteSync()
+	}
+	return numKeys, nil
+}
+
+// verifyDBCopy checks that the destination DB contains exactly the same key-value pairs as the
+// source DB.
+func verifyDBCopy(src sourceDB, dest cdb.DBWrapper) error {
+	srcIt := src.Iterator(nil, nil)
+	defer srcIt.Close()
+	destIt := dest.Iterator(nil, nil)
+	defer destIt.Close()
+
+	var numKeys uint64
+	for ; srcIt.Valid(); srcIt.Next() {
+		if !destIt.Valid() {
+			return fmt.Errorf("destination DB is missing keys, first missing key %X", srcIt.Key())
+		}
+		if !bytes.Equal(srcIt.Key(), destIt.Key()) {
+			return fmt.Errorf("key mismatch after %d keys, expected %X, got %X", numKeys, srcIt.Key(), destIt.Key())
+		}
+		if !bytes.Equal(srcIt.Value(), destIt.Value()) {
+			return fmt.Errorf("value mismatch for key %X", srcIt.Key())
+		}
+		destIt.Next()
+		numKeys++
+	}
+	if destIt.Valid() {
+		return fmt.Errorf("destination DB contains extra keys, first extra key %X", destIt.Key())
+	}
+	return nil
+}
+
+// legacyLevelDB provides read access to a goleveldb DB that wasn't created via db.LoadDB.
+type legacyLevelDB struct {
+	db *goleveldb.DB
+}
+
+func (l *legacyLevelDB) Iterator(start, end []byte) dbm.Iterator {
+	it := l.db.NewIterator(&util.Range{Start: start, Limit: end}, nil)
+	return &legacyLevelDBIterator{
+		Iterator: it,
+		start:    start,
+		end:      end,
+		valid:    it.First(),
+	}
+}
+
+func (l *legacyLevelDB) Close() {
+	l.db.Close()
+}
+
+type legacyLevelDBIterator struct {
+	iterator.Iterator
+	start, end []byte
+	valid      bool
+}
+
+func (it *legacyLevelDBIterator) Domain() ([]byte, []byte) {
+	return it.start, it.end
+}
+
+func (it *legacyLevelDBIterator) Valid() bool {
+	return it.valid
+}
+
+func (it *legacyLevelDBIterator) Next() {
+	it.valid = it.Iterator.Next()
+}
+
+// Key returns a copy of the current key, goleveldb reuses the underlying buffer.
+func (it *legacyLevelDBIterator) Key() []byte {
+	return append([]byte(nil), it.Iterator.Key()...)
+}
+
+// Value returns a copy of the current value, goleveldb reuses the underlying buffer.
+func (it *legacyLevelDBIterator) Value() []byte {
+	return append([]byte(nil), it.Iterator.Value()...)
+}
+
+func (it *legacyLevelDBIterator) Close() {
+	it.Iterator.Release()
+}
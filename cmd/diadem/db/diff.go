@@ -0,0 +1,230 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/cmd/diadem/common"
+	"github.com/diademnetwork/diademchain/config"
+	registry "github.com/diademnetwork/diademchain/registry/factory"
+	"github.com/diademnetwork/diademchain/store"
+	diadem "github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/go-diadem/util"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+// Values longer than this are truncated when printed, unless --full-values is specified.
+const diffMaxValueLen = 64
+
+func newDiffDBCommand() *cobra.Command {
+	var fromHeight, toHeight int64
+	var otherDir string
+	var fullValues bool
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Lists the keys that differ between two versions of the app state",
+		Long: "Compares two versions of the app state stored in app.db, or the same version of the app state " +
+			"stored in two different copies of app.db (e.g. from two nodes that disagree on the AppHash), " +
+			"and prints all the keys that were added, deleted, or changed.",
+		Example: "  diadem db diff --from 1000 --to 1001\n" +
+			"  diadem db diff --from 1000 --other-dir /path/to/other/node/chaindata",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := common.ParseConfig()
+			if err != nil {
+				return err
+			}
+
+			oldStore, err := common.LoadAppStore(cfg, cfg.RootPath())
+			if err != nil {
+				return err
+			}
+			newStore := oldStore
+			if otherDir != "" {
+				otherPath, err := filepath.Abs(otherDir)
+				if err != nil {
+					return err
+				}
+				rootPath, err := filepath.Abs(cfg.RootPath())
+				if err != nil {
+					return err
+				}
+				if otherPath == rootPath {
+					return errors.New("--other-dir must not be the same as the node root dir")
+				}
+				if newStore, err = common.LoadAppStore(cfg, otherDir); err != nil {
+					return err
+				}
+			}
+
+			if fromHeight == 0 {
+				fromHeight = oldStore.Version()
+			}
+			if toHeight == 0 {
+				if otherDir == "" {
+					return errors.New("--to must be specified when comparing versions in the same DB")
+				}
+				toHeight = fromHeight
+			}
+
+			oldSrc, err := store.NewStateDiffSource(oldStore, fromHeight)
+			if err != nil {
+				return err
+			}
+			newSrc, err := store.NewStateDiffSource(newStore, toHeight)
+			if err != nil {
+				return err
+			}
+
+			contracts, err := loadContractPrefixes(
+				cfg, []store.VersionedKVStore{oldStore, newStore}, []int64{fromHeight, toHeight},
+			)
+			if err != nil {
+				return err
+			}
+
+			counts := map[string]map[string]int{}
+			err = store.DiffStates(oldSrc, newSrc, func(entry store.StateDiffEntry) error {
+				change := "changed"
+				if entry.OldValue == nil {
+					change = "added"
+				} else if entry.NewValue == nil {
+					change = "deleted"
+				}
+				label, key := describeStateKey(entry.Key, contracts)
+				if counts[label] == nil {
+					counts[label] = map[string]int{}
+				}
+				counts[label][change]++
+
+				fmt.Printf("%-7s %s %s\n", change, label, key)
+				if entry.OldValue != nil {
+					fmt.Printf("        old: %s\n", formatStateValue(entry.OldValue, fullValues))
+				}
+				if entry.NewValue != nil {
+					fmt.Printf("        new: %s\n", formatStateValue(entry.NewValue, fullValues))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("\n--- summary (height %d -> %d) ---\n", fromHeight, toHeight)
+			if len(counts) == 0 {
+				fmt.Println("no differences")
+				return nil
+			}
+			labels := make([]string, 0, len(counts))
+			for label := range counts {
+				labels = append(labels, label)
+			}
+			sort.Strings(labels)
+			for _, label := range labels {
+				c := counts[label]
+				fmt.Printf("%s: %d added, %d deleted, %d changed\n", label, c["added"], c["deleted"], c["changed"])
+			}
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.Int64Var(&fromHeight, "from", 0, "Height of the old state (defaults to the latest height)")
+	flags.Int64Var(&toHeight, "to", 0, "Height of the new state (defaults to --from if --other-dir is specified)")
+	flags.StringVar(&otherDir, "other-dir", "", "Root dir of another node whose app.db should be compared to this one")
+	flags.BoolVar(&fullValues, "full-values", false, "Don't truncate long values")
+	return cmd
+}
+
+type contractPrefix struct {
+	prefix []byte
+	label  string
+}
+
+// loadContractPrefixes looks up all the contracts in the registry at each of the given store
+// versions, and returns the prefixes of the keys their state is stored under.
+func loadContractPrefixes(cfg *config.Config, stores []store.VersionedKVStore, versions []int64) ([]contractPrefix, error) {
+	regVer, err := registry.RegistryVersionFromInt(cfg.RegistryVersion)
+	if err != nil {
+		return nil, err
+	}
+	createRegistry, err := registry.NewRegistryFactory(regVer)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var contracts []contractPrefix
+	for i, s := range stores {
+		hs, ok := s.(store.HistoricalStore)
+		if !ok {
+			continue
+		}
+		snap, err := hs.GetSnapshotAt(versions[i])
+		if err != nil {
+			return nil, err
+		}
+		state := diademchain.NewStoreStateSnapshot(nil, snap, abci.Header{Height: versions[i]}, nil, nil)
+		records, err := createRegistry(state).GetRecords()
+		state.Release()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load contract records at height %d", versions[i])
+		}
+		for _, record := range records {
+			if record.Address == nil {
+				continue
+			}
+			addr := diadem.UnmarshalAddressPB(record.Address)
+			if seen[addr.String()] {
+				continue
+			}
+			seen[addr.String()] = true
+			label := addr.String()
+			if record.Name != "" {
+				label = fmt.Sprintf("%s (%s)", record.Name, addr.String())
+			}
+			contracts = append(contracts, contractPrefix{
+				prefix: diadem.DataPrefix(addr),
+				label:  label,
+			})
+		}
+	}
+	return contracts, nil
+}
+
+// describeStateKey returns a label for the given app store key, and the key with any prefix
+// covered by the label stripped off. Contract state keys are labeled with the name of the contract,
+// all other keys are labeled with their first prefix.
+func describeStateKey(key []byte, contracts []contractPrefix) (string, string) {
+	for _, c := range contracts {
+		if !bytes.HasPrefix(key, c.prefix) {
+			continue
+		}
+		if k, err := util.UnprefixKey(key, c.prefix); err == nil {
+			return "contract " + c.label, formatStateKey(k)
+		}
+	}
+	if i := bytes.IndexByte(key, 0); i > 0 {
+		return string(key[:i]), formatStateKey(key[i+1:])
+	}
+	return "-", formatStateKey(key)
+}
+
+func formatStateKey(key []byte) string {
+	for _, b := range key {
+		if b < 0x20 || b > 0x7e {
+			return fmt.Sprintf("0x%X", key)
+		}
+	}
+	return fmt.Sprintf("%q", key)
+}
+
+func formatStateValue(value []byte, full bool) string {
+	if !full && len(value) > diffMaxValueLen {
+		return fmt.Sprintf("0x%X... (%d bytes)", value[:diffMaxValueLen], len(value))
+	}
+	return fmt.Sprintf("0x%X", value)
+}
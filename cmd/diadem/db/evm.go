@@ -69,6 +69,7 @@ func newDumpEVMStateCommand() *cobra.Command {
 				}
 				return receiptVer, cfg.EVMPersistentTxReceiptsMax, nil
 			})
+			receiptHandlerProvider.DBBackend = cfg.ReceiptsDBBackend
 
 			// TODO: This should use snapshot obtained from appStore.ReadOnlyState()
 			storeTx := store.WrapAtomic(appStore).BeginTx()
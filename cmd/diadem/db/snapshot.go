@@ -0,0 +1,140 @@
+package db
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/diademnetwork/diademchain/cmd/diadem/common"
+	"github.com/diademnetwork/diademchain/config"
+	cdb "github.com/diademnetwork/diademchain/db"
+	"github.com/diademnetwork/diademchain/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func newSnapshotCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Export & import state snapshots of app.db",
+	}
+	cmd.AddCommand(
+		newExportSnapshotCommand(),
+		newImportSnapshotCommand(),
+	)
+	return cmd
+}
+
+func newExportSnapshotCommand() *cobra.Command {
+	var height int64
+	var chunkSize int
+	cmd := &cobra.Command{
+		Use:   "export <snapshot-dir>",
+		Short: "Exports a chunked state snapshot of app.db at the specified height",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := common.ParseConfig()
+			if err != nil {
+				return err
+			}
+
+			appStore, err := loadSnapshotExporter(cfg)
+			if err != nil {
+				return err
+			}
+			if height == 0 {
+				height = appStore.(store.VersionedKVStore).Version()
+			}
+
+			manifest, err := appStore.ExportSnapshot(height, args[0], chunkSize)
+			if err != nil {
+				return err
+			}
+			fmt.Printf(
+				"exported snapshot at height %d with root hash %s (%d chunks)\n",
+				manifest.Version, strings.ToUpper(manifest.RootHash), len(manifest.Chunks),
+			)
+			return nil
+		},
+	}
+	cmdFlags := cmd.Flags()
+	cmdFlags.Int64Var(&height, "height", 0, "Height at which to export the app state, defaults to the latest height")
+	cmdFlags.IntVar(&chunkSize, "chunk-size", store.DefaultStateSnapshotChunkSize, "Number of tree nodes per chunk")
+	return cmd
+}
+
+func newImportSnapshotCommand() *cobra.Command {
+	var appHashHex string
+	cmd := &cobra.Command{
+		Use:   "import <snapshot-dir>",
+		Short: "Imports a state snapshot into an empty app.db",
+		Long: "Imports a state snapshot into an empty app.db, the snapshot is verified against " +
+			"the given AppHash, which must be obtained from a trusted source (it's the AppHash in " +
+			"the header of the block following the snapshot height).",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := common.ParseConfig()
+			if err != nil {
+				return err
+			}
+
+			if cfg.AppStore.Version != 1 {
+				return errors.New("state snapshots can only be imported into the IAVL app store (AppStore.Version: 1)")
+			}
+
+			appHash, err := hex.DecodeString(appHashHex)
+			if err != nil {
+				return errors.Wrap(err, "invalid app hash")
+			}
+			if len(appHash) == 0 {
+				return errors.New("app hash not specified")
+			}
+
+			db, err := cdb.LoadDB(
+				cfg.DBBackend, cfg.DBName, cfg.RootPath(), cfg.DBBackendConfig.CacheSizeMegs, false,
+			)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			manifest, err := store.ImportStateSnapshot(db, args[0], appHash)
+			if err != nil {
+				return err
+			}
+
+			// Sanity check that the imported tree can actually be loaded
+			appStore, err := store.NewIAVLStore(db, 0, 0)
+			if err != nil {
+				return errors.Wrap(err, "failed to load imported app store")
+			}
+			if appStore.Version() != manifest.Version {
+				return fmt.Errorf(
+					"imported app store is at height %d, expected %d", appStore.Version(), manifest.Version,
+				)
+			}
+			if !bytes.Equal(appStore.Hash(), appHash) {
+				return fmt.Errorf("imported app store hash %X doesn't match app hash %X", appStore.Hash(), appHash)
+			}
+
+			fmt.Printf("imported snapshot at height %d\n", manifest.Version)
+			return nil
+		},
+	}
+	cmdFlags := cmd.Flags()
+	cmdFlags.StringVar(&appHashHex, "app-hash", "", "Hex-encoded AppHash the snapshot should be verified against")
+	return cmd
+}
+
+func loadSnapshotExporter(cfg *config.Config) (store.StateSnapshotExporter, error) {
+	appStore, err := common.LoadAppStore(cfg, cfg.RootPath())
+	if err != nil {
+		return nil, err
+	}
+	exporter, ok := appStore.(store.StateSnapshotExporter)
+	if !ok {
+		return nil, fmt.Errorf("%T doesn't support state snapshots", appStore)
+	}
+	return exporter, nil
+}
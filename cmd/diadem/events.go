@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/diademnetwork/diademchain/rpc"
+	"github.com/diademnetwork/go-diadem/cli"
+	"github.com/diademnetwork/go-diadem/client"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	amino "github.com/tendermint/go-amino"
+)
+
+type contractEventsFlags struct {
+	FromBlock uint64
+	ToBlock   uint64
+	Contract  string
+	Topic     string
+	Origin    string
+	TxHash    string
+	Limit     uint64
+	Cursor    string
+}
+
+func newContractEventsCommand() *cobra.Command {
+	var flags contractEventsFlags
+	cmd := &cobra.Command{
+		Use:   "contract-events",
+		Short: "Displays the events emitted by contracts within a range of blocks",
+		Example: "  diadem contract-events --from 100 --to 110 --contract coin --topic event:Transfer\n" +
+			"  diadem contract-events --from 100 --limit 10 --cursor 105.2",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return queryContractEvents(&flags)
+		},
+	}
+	cmdFlags := cmd.Flags()
+	cmdFlags.Uint64Var(&flags.FromBlock, "from", 0, "First block to fetch events from")
+	cmdFlags.Uint64Var(&flags.ToBlock, "to", 0, "Last block to fetch events from (defaults to the first block)")
+	cmdFlags.StringVar(&flags.Contract, "contract", "", "Only display events emitted by this contract (name or address)")
+	cmdFlags.StringVar(&flags.Topic, "topic", "", "Only display events with this topic")
+	cmdFlags.StringVar(&flags.Origin, "origin", "", "Only display events emitted in txs sent by this address")
+	cmdFlags.StringVar(&flags.TxHash, "tx-hash", "", "Only display events emitted by the tx with this hash (hex)")
+	cmdFlags.Uint64Var(&flags.Limit, "limit", 0, "Max number of events to display")
+	cmdFlags.StringVar(&flags.Cursor, "cursor", "", "Cursor returned by a previous query, used to fetch the next page of events")
+	setChainFlags(cmdFlags)
+	return cmd
+}
+
+func queryContractEvents(flags *contractEventsFlags) error {
+	params := map[string]interface{}{
+		"fromBlock": strconv.FormatUint(flags.FromBlock, 10),
+		"toBlock":   strconv.FormatUint(flags.ToBlock, 10),
+		"contract":  flags.Contract,
+		"topic":     flags.Topic,
+		"origin":    flags.Origin,
+		"limit":     strconv.FormatUint(flags.Limit, 10),
+		"cursor":    flags.Cursor,
+	}
+	if flags.TxHash != "" {
+		txHash, err := hex.DecodeString(strings.TrimPrefix(flags.TxHash, "0x"))
+		if err != nil {
+			return errors.Wrap(err, "invalid tx hash")
+		}
+		params["txHash"] = base64.StdEncoding.EncodeToString(txHash)
+	}
+
+	c := client.NewJSONRPCClient(cli.TxFlags.URI + "/query")
+	var rm json.RawMessage
+	if err := c.Call("contractevents", params, "1", &rm); err != nil {
+		return errors.Wrap(err, "failed to call contractevents")
+	}
+	var result rpc.ContractEventsResult
+	if err := amino.NewCodec().UnmarshalJSON(rm, &result); err != nil {
+		return errors.Wrap(err, "failed to unmarshal rpc response result")
+	}
+
+	for _, event := range result.Events {
+		eventJSON, err := formatJSON(event)
+		if err != nil {
+			return errors.Wrap(err, "formatting event")
+		}
+		fmt.Println(eventJSON)
+	}
+	fmt.Printf("fetched %d events from blocks %d to %d\n", len(result.Events), result.FromBlock, result.ToBlock)
+	if result.NextCursor != "" {
+		fmt.Printf("more events available, use --cursor %s to fetch the next page\n", result.NextCursor)
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/auth"
+	"github.com/diademnetwork/diademchain/builtin/plugins/address_mapper"
+	"github.com/diademnetwork/diademchain/builtin/plugins/coin"
+	"github.com/diademnetwork/diademchain/builtin/plugins/dposv3"
+	"github.com/diademnetwork/diademchain/builtin/plugins/gateway"
+	"github.com/diademnetwork/diademchain/builtin/plugins/karma"
+	"github.com/diademnetwork/diademchain/cmd/diadem/common"
+	"github.com/diademnetwork/diademchain/config"
+	"github.com/diademnetwork/diademchain/events"
+	"github.com/diademnetwork/diademchain/evm"
+	"github.com/diademnetwork/diademchain/log"
+	"github.com/diademnetwork/diademchain/plugin"
+	regcommon "github.com/diademnetwork/diademchain/registry"
+	registry "github.com/diademnetwork/diademchain/registry/factory"
+	"github.com/diademnetwork/diademchain/store"
+	diadem "github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/go-diadem/plugin/contractpb"
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	abci "github.com/tendermint/tendermint/abci/types"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+// initStateExporter builds the init request of a Go contract from the current state of the
+// contract.
+type initStateExporter func(ctx contractpb.StaticContext) (proto.Message, error)
+
+// Init state exporters of Go contracts, keyed by plugin name. Contracts that don't have an
+// exporter are exported with the init request from the current genesis file (if any).
+var initStateExporters = map[string]initStateExporter{
+	"coin": func(ctx contractpb.StaticContext) (proto.Message, error) {
+		return coin.ExportInitRequest(ctx)
+	},
+	"dposV3": func(ctx contractpb.StaticContext) (proto.Message, error) {
+		return dposv3.ExportInitRequest(ctx)
+	},
+	"gateway": func(ctx contractpb.StaticContext) (proto.Message, error) {
+		return gateway.ExportInitRequest(ctx)
+	},
+	"diademcoin-gateway": func(ctx contractpb.StaticContext) (proto.Message, error) {
+		return gateway.ExportInitRequest(ctx)
+	},
+	"addressmapper": func(ctx contractpb.StaticContext) (proto.Message, error) {
+		return address_mapper.ExportInitRequest(ctx)
+	},
+	"karma": func(ctx contractpb.StaticContext) (proto.Message, error) {
+		return karma.ExportInitRequest(ctx)
+	},
+}
+
+func newExportGenesisCommand() *cobra.Command {
+	var height int64
+	var outputPath string
+	var initOnly bool
+	cmd := &cobra.Command{
+		Use:   "export-genesis",
+		Short: "Exports the state of all the registered contracts to a genesis file",
+		Long: "Exports the state of all the registered contracts at the given height to a genesis file " +
+			"that can be used to start a new chain from the current state of this chain. " +
+			"The new chain must use the same chain ID, since contracts are restored at their original addresses. " +
+			"The node must not be running while the state is being exported.",
+		Example: "  diadem export-genesis --height 1000 --output genesis.exported.json\n" +
+			"  diadem export-genesis --init-only --output genesis.exported.json",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := common.ParseConfig()
+			if err != nil {
+				return err
+			}
+
+			appStore, err := common.LoadAppStore(cfg, cfg.RootPath())
+			if err != nil {
+				return err
+			}
+			if height == 0 {
+				height = appStore.Version()
+			}
+			hs, ok := appStore.(store.HistoricalStore)
+			if !ok {
+				return fmt.Errorf("app store doesn't support historical state")
+			}
+			snap, err := hs.GetSnapshotAt(height)
+			if err != nil {
+				return err
+			}
+			state := diademchain.NewStoreStateSnapshot(
+				nil, snap, abci.Header{ChainID: cfg.ChainID, Height: height}, nil, nil,
+			)
+			defer state.Release()
+
+			// The EVM state is read at the EVM root recorded in the app state at the given height,
+			// exporting fails if that root is no longer available in the EVM DB.
+			var evmDB dbm.DB
+			if evm.EVMEnabled {
+				evmDB, err = loadEvmDB(cfg, height)
+				if err != nil {
+					return err
+				}
+			}
+
+			gen, err := exportGenesis(cfg, state, evmDB, initOnly)
+			if err != nil {
+				return err
+			}
+
+			file, err := os.OpenFile(outputPath, os.O_EXCL|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			enc := json.NewEncoder(file)
+			enc.SetIndent("", "    ")
+			if err := enc.Encode(gen); err != nil {
+				return err
+			}
+			fmt.Printf("Exported %d contracts at height %d to %s\n", len(gen.Contracts), height, outputPath)
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.Int64Var(&height, "height", 0, "Height of the state to export (defaults to the latest height)")
+	flags.StringVar(&outputPath, "output", "genesis.exported.json", "Path of the genesis file to write, must not exist")
+	flags.BoolVar(&initOnly, "init-only", false,
+		"Only export the init requests of Go contracts, the contracts will be deployed & initialized "+
+			"from scratch on the new chain (EVM contracts are skipped)")
+	return cmd
+}
+
+// exportGenesis builds a genesis file containing all the contracts registered in the given state.
+// Unless initOnly is set, the exported contracts are restored at their original addresses with
+// their current state when a chain is started from the genesis file.
+func exportGenesis(cfg *config.Config, state diademchain.State, evmDB dbm.DB, initOnly bool) (*config.Genesis, error) {
+	regVer, err := registry.RegistryVersionFromInt(cfg.RegistryVersion)
+	if err != nil {
+		return nil, err
+	}
+	createRegistry, err := registry.NewRegistryFactory(regVer)
+	if err != nil {
+		return nil, err
+	}
+	reg := createRegistry(state)
+	records, err := reg.GetRecords()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load contract records")
+	}
+
+	// contracts that were deployed at genesis should be listed in the same order as before, since
+	// some contracts depend on others when they're initialized
+	var curGenesis *config.Genesis
+	if _, err := os.Stat(cfg.GenesisPath()); err == nil {
+		if curGenesis, err = config.ReadGenesis(cfg.GenesisPath()); err != nil {
+			return nil, err
+		}
+	} else {
+		curGenesis = &config.Genesis{}
+	}
+	genesisOrder := map[string]int{}
+	for i, contractCfg := range curGenesis.Contracts {
+		genesisOrder[contractCfg.Name] = i + 1
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		oi, oj := genesisOrder[records[i].Name], genesisOrder[records[j].Name]
+		if oi != oj {
+			return oi != 0 && (oj == 0 || oi < oj)
+		}
+		return records[i].Name < records[j].Name
+	})
+
+	pvm := plugin.NewPluginVM(
+		common.NewDefaultContractsLoader(cfg),
+		state,
+		evmDB,
+		reg,
+		diademchain.NewDefaultEventHandler(events.NewLogEventDispatcher()),
+		log.Default,
+		nil,
+		nil,
+		nil,
+	)
+
+	gen := &config.Genesis{}
+	for _, record := range records {
+		if record.Address == nil {
+			continue
+		}
+		contractCfg, err := exportContract(state, pvm, evmDB, record, curGenesis, initOnly)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to export contract %s", record.Name)
+		}
+		if contractCfg != nil {
+			gen.Contracts = append(gen.Contracts, *contractCfg)
+		}
+	}
+
+	if gen.AppState, err = exportAppState(state, evmDB); err != nil {
+		return nil, errors.Wrap(err, "failed to export app state")
+	}
+	return gen, nil
+}
+
+// exportAppState exports the state that doesn't belong to any contract, but is needed to keep
+// accepting txs from existing accounts, and to keep deploying contracts at the same addresses.
+func exportAppState(state diademchain.State, evmDB dbm.DB) (*config.AppState, error) {
+	appState := &config.AppState{
+		Nonces: auth.ExportNonces(state),
+	}
+	if evm.EVMEnabled && evmDB != nil {
+		var err error
+		if appState.EVMNonces, err = evm.ExportAccountNonces(state, evmDB); err != nil {
+			return nil, err
+		}
+	}
+	for name, enabled := range diademchain.Features(state) {
+		appState.Features = append(appState.Features, config.Feature{Name: name, Enabled: enabled})
+	}
+	sort.Slice(appState.Features, func(i, j int) bool {
+		return appState.Features[i].Name < appState.Features[j].Name
+	})
+	return appState, nil
+}
+
+func exportContract(
+	state diademchain.State,
+	pvm *plugin.PluginVM,
+	evmDB dbm.DB,
+	record *regcommon.Record,
+	curGenesis *config.Genesis,
+	initOnly bool,
+) (*config.ContractConfig, error) {
+	addr := diadem.UnmarshalAddressPB(record.Address)
+	contractState := &config.ContractState{
+		Address: addr.String(),
+	}
+	if record.Owner != nil {
+		contractState.Owner = diadem.UnmarshalAddressPB(record.Owner).String()
+	}
+
+	// only Go contracts have their code stored in the app store
+	codeBytes := state.Get(diadem.TextKey(addr))
+	if len(codeBytes) == 0 {
+		if initOnly {
+			fmt.Printf("Skipping EVM contract %s (%s)\n", record.Name, addr.String())
+			return nil, nil
+		}
+		evmState, err := evm.ExportContractState(state, evmDB, addr)
+		if err != nil {
+			return nil, err
+		}
+		evmState.Address = contractState.Address
+		evmState.Owner = contractState.Owner
+		return &config.ContractConfig{
+			VMTypeName: "EVM",
+			Name:       record.Name,
+			State:      evmState,
+		}, nil
+	}
+
+	var code plugin.PluginCode
+	if err := proto.Unmarshal(codeBytes, &code); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal plugin code")
+	}
+	contractCfg := &config.ContractConfig{
+		VMTypeName: "plugin",
+		Format:     "plugin",
+		Name:       record.Name,
+		Location:   code.Name,
+	}
+
+	// The storage of the contract is exported in full unless initOnly is set, in which case the
+	// init request is all that's left to carry the contract state over to the new chain.
+	pluginName := strings.Split(code.Name, ":")[0]
+	if exportInitState, ok := initStateExporters[pluginName]; ok && initOnly {
+		ctx := contractpb.WrapPluginContext(
+			pvm.CreateContractContext(diadem.RootAddress(addr.ChainID), addr, true),
+		)
+		initReq, err := exportInitState(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to export init state")
+		}
+		if contractCfg.Init, err = marshalInit(initReq); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, genContract := range curGenesis.Contracts {
+			if genContract.Name == record.Name && record.Name != "" {
+				contractCfg.Init = genContract.Init
+			}
+		}
+	}
+	if len(contractCfg.Init) == 0 {
+		contractCfg.Init = json.RawMessage("{}")
+	}
+
+	if !initOnly {
+		for _, entry := range state.Range(diadem.DataPrefix(addr)) {
+			contractState.Storage = append(contractState.Storage, config.StateEntry{
+				Key:   entry.Key,
+				Value: entry.Value,
+			})
+		}
+		contractCfg.State = contractState
+	}
+	return contractCfg, nil
+}
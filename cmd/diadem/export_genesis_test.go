@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/auth"
+	"github.com/diademnetwork/diademchain/builtin/plugins/coin"
+	"github.com/diademnetwork/diademchain/config"
+	"github.com/diademnetwork/diademchain/log"
+	"github.com/diademnetwork/diademchain/plugin"
+	registry "github.com/diademnetwork/diademchain/registry/factory"
+	"github.com/diademnetwork/diademchain/store"
+	diadem "github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/go-diadem/plugin/contractpb"
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+func TestExportGenesisRestoresPluginContracts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export-genesis")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := config.DefaultConfig()
+	cfg.RootDir = dir
+	cfg.RegistryVersion = int32(registry.LatestRegistryVersion)
+
+	owner := diadem.MustParseAddress("default:0xb16a379ec18d4093666f8f38b11a3071c920207d")
+	coinAddr := diadem.MustParseAddress("default:0x5cecd1f7261e1f4c684e297be3edf03b825e01c4")
+	createRegistry, err := registry.NewRegistryFactory(registry.LatestRegistryVersion)
+	require.NoError(t, err)
+
+	// deploy the coin contract on the old chain
+	oldState := diademchain.NewStoreState(nil, store.NewMemStore(), abci.Header{ChainID: "default"}, nil, nil)
+	code, err := proto.Marshal(&plugin.PluginCode{Name: "coin:1.0.0"})
+	require.NoError(t, err)
+	oldState.Set(diadem.TextKey(coinAddr), code)
+	require.NoError(t, createRegistry(oldState).Register("coin", coinAddr, owner))
+	pvm := plugin.NewPluginVM(nil, oldState, nil, createRegistry(oldState), nil, nil, nil, nil, nil)
+	ctx := contractpb.WrapPluginContext(pvm.CreateContractContext(owner, coinAddr, false))
+	require.NoError(t, (&coin.Coin{}).Init(ctx, &coin.InitRequest{
+		Accounts: []*coin.InitialAccount{
+			{Owner: owner.MarshalPB(), Balance: 100},
+		},
+	}))
+
+	gen, err := exportGenesis(cfg, oldState, nil, false)
+	require.NoError(t, err)
+	require.Len(t, gen.Contracts, 1)
+	contractCfg := gen.Contracts[0]
+	require.Equal(t, "coin", contractCfg.Name)
+	require.Equal(t, "coin:1.0.0", contractCfg.Location)
+	require.NotNil(t, contractCfg.State)
+	require.Equal(t, coinAddr.String(), contractCfg.State.Address)
+	require.Equal(t, owner.String(), contractCfg.State.Owner)
+
+	// the init request is only exported from the contract state if the storage isn't exported
+	initGen, err := exportGenesis(cfg, oldState, nil, true)
+	require.NoError(t, err)
+	require.Len(t, initGen.Contracts, 1)
+	require.Nil(t, initGen.Contracts[0].State)
+	var initReq coin.InitRequest
+	require.NoError(t, jsonpb.Unmarshal(bytes.NewReader(initGen.Contracts[0].Init), &initReq))
+	require.Len(t, initReq.Accounts, 1)
+	require.Equal(t, uint64(100), initReq.Accounts[0].Balance)
+
+	// restore the coin contract on the new chain
+	genBytes, err := json.Marshal(gen)
+	require.NoError(t, err)
+	var newGen config.Genesis
+	require.NoError(t, json.Unmarshal(genBytes, &newGen))
+
+	newState := diademchain.NewStoreState(nil, store.NewMemStore(), abci.Header{ChainID: "default"}, nil, nil)
+	newRegistry := createRegistry(newState)
+	require.NoError(t, restoreContract(newState, newGen.Contracts[0], nil, newRegistry, log.Root))
+
+	addr, err := newRegistry.Resolve("coin")
+	require.NoError(t, err)
+	require.Equal(t, coinAddr, addr)
+	require.Equal(t, code, newState.Get(diadem.TextKey(coinAddr)))
+	for _, entry := range oldState.Range(diadem.DataPrefix(coinAddr)) {
+		require.Equal(t, entry.Value, newState.WithPrefix(diadem.DataPrefix(coinAddr)).Get(entry.Key))
+	}
+	require.Equal(t,
+		len(oldState.Range(diadem.DataPrefix(coinAddr))),
+		len(newState.Range(diadem.DataPrefix(coinAddr))),
+	)
+
+	// restoring the same contract twice should fail
+	require.Error(t, restoreContract(newState, newGen.Contracts[0], nil, newRegistry, log.Root))
+}
+
+func TestExportGenesisRestoresAppState(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.RegistryVersion = int32(registry.LatestRegistryVersion)
+
+	account := diadem.MustParseAddress("default:0xb16a379ec18d4093666f8f38b11a3071c920207d")
+	oldState := diademchain.NewStoreState(nil, store.NewMemStore(), abci.Header{ChainID: "default"}, nil, nil)
+	auth.ImportNonces(oldState, []config.StateEntry{{Key: account.Bytes(), Value: []byte{0, 0, 0, 0, 0, 0, 0, 5}}})
+	oldState.SetFeature("test:enabled", true)
+	oldState.SetFeature("test:disabled", false)
+
+	gen, err := exportGenesis(cfg, oldState, nil, false)
+	require.NoError(t, err)
+	require.NotNil(t, gen.AppState)
+	require.Equal(t, []config.Feature{
+		{Name: "test:disabled", Enabled: false},
+		{Name: "test:enabled", Enabled: true},
+	}, gen.AppState.Features)
+
+	newState := diademchain.NewStoreState(nil, store.NewMemStore(), abci.Header{ChainID: "default"}, nil, nil)
+	require.NoError(t, restoreAppState(newState, gen.AppState, nil))
+	require.Equal(t, uint64(5), auth.Nonce(newState, account))
+	require.True(t, newState.FeatureEnabled("test:enabled", false))
+	require.False(t, newState.FeatureEnabled("test:disabled", true))
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"os/signal"
 	"path"
@@ -28,10 +29,12 @@ import (
 	"github.com/diademnetwork/diademchain"
 	"github.com/diademnetwork/diademchain/abci/backend"
 	"github.com/diademnetwork/diademchain/auth"
+	"github.com/diademnetwork/diademchain/builtin/plugins/coin"
 	"github.com/diademnetwork/diademchain/builtin/plugins/dposv2"
 	d2Oracle "github.com/diademnetwork/diademchain/builtin/plugins/dposv2/oracle"
 	d2OracleCfg "github.com/diademnetwork/diademchain/builtin/plugins/dposv2/oracle/config"
 	"github.com/diademnetwork/diademchain/builtin/plugins/dposv3"
+	"github.com/diademnetwork/diademchain/builtin/plugins/ethcoin"
 	plasmaConfig "github.com/diademnetwork/diademchain/builtin/plugins/plasma_cash/config"
 	plasmaOracle "github.com/diademnetwork/diademchain/builtin/plugins/plasma_cash/oracle"
 	"github.com/diademnetwork/diademchain/receipts/leveldb"
@@ -62,6 +65,7 @@ import (
 	regcommon "github.com/diademnetwork/diademchain/registry"
 	registry "github.com/diademnetwork/diademchain/registry/factory"
 	"github.com/diademnetwork/diademchain/rpc"
+	"github.com/diademnetwork/diademchain/rpc/graphql"
 	"github.com/diademnetwork/diademchain/store"
 	"github.com/diademnetwork/diademchain/throttle"
 	"github.com/diademnetwork/diademchain/tx_handler"
@@ -73,6 +77,7 @@ import (
 
 	"github.com/diademnetwork/diademchain/fnConsensus"
 	dbm "github.com/tendermint/tendermint/libs/db"
+	tmcore "github.com/tendermint/tendermint/rpc/core"
 )
 
 var RootCmd = &cobra.Command{
@@ -638,10 +643,12 @@ func loadAppStore(cfg *config.Config, logger *diadem.Logger, targetVersion int64
 		if cfg.AppStore.PruneInterval > int64(0) {
 			logger.Info("Loading Pruning IAVL Store")
 			appStore, err = store.NewPruningIAVLStore(db, store.PruningIAVLStoreConfig{
-				MaxVersions: cfg.AppStore.MaxVersions,
-				BatchSize:   cfg.AppStore.PruneBatchSize,
-				Interval:    time.Duration(cfg.AppStore.PruneInterval) * time.Second,
-				Logger:      logger,
+				MaxVersions:         cfg.AppStore.MaxVersions,
+				BatchSize:           cfg.AppStore.PruneBatchSize,
+				Interval:            time.Duration(cfg.AppStore.PruneInterval) * time.Second,
+				TargetCommitLatency: time.Duration(cfg.AppStore.PruneTargetCommitLatency) * time.Millisecond,
+				DBPath:              filepath.Join(cfg.RootPath(), cfg.DBName+".db"),
+				Logger:              logger,
 			})
 			if err != nil {
 				return nil, err
@@ -708,6 +715,62 @@ func loadEventStore(cfg *config.Config, logger *diadem.Logger) (store.EventStore
 	return eventStore, nil
 }
 
+func loadEthFilterStore(cfg *config.Config) (polls.FilterStore, error) {
+	filterStoreCfg := cfg.EthFilterStore
+	switch filterStoreCfg.Backend {
+	case polls.FilterStoreBackendDB:
+		db, err := cdb.LoadDB(
+			filterStoreCfg.DBBackend,
+			filterStoreCfg.DBName,
+			cfg.RootPath(),
+			20,
+			cfg.Metrics.Database,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return polls.NewDBFilterStore(db), nil
+	case polls.FilterStoreBackendRedis:
+		return polls.NewRedisFilterStore(filterStoreCfg.RedisURL), nil
+	}
+	return nil, fmt.Errorf("invalid eth filter store backend %s", filterStoreCfg.Backend)
+}
+
+func newSinkEventDispatcher(cfg *config.Config) (*events.SinkEventDispatcher, error) {
+	dispatcherCfg := cfg.EventDispatcher
+	var sink events.EventSink
+	var err error
+	switch dispatcherCfg.Dispatcher {
+	case events.DispatcherWebhook:
+		log.Info("Using webhook event dispatcher", "url", dispatcherCfg.Webhook.URL)
+		sink, err = events.NewWebhookEventSink(dispatcherCfg.Webhook)
+	case events.DispatcherNATS:
+		log.Info("Using NATS event dispatcher", "url", dispatcherCfg.NATS.URL, "subject", dispatcherCfg.NATS.Subject)
+		sink, err = events.NewNATSEventSink(dispatcherCfg.NATS)
+	case events.DispatcherKafka:
+		log.Info("Using Kafka event dispatcher", "brokers", dispatcherCfg.Kafka.Brokers, "topic", dispatcherCfg.Kafka.Topic)
+		sink, err = events.NewKafkaEventSink(dispatcherCfg.Kafka)
+	default:
+		return nil, fmt.Errorf("invalid event dispatcher %s", dispatcherCfg.Dispatcher)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create %s event dispatcher", dispatcherCfg.Dispatcher)
+	}
+
+	deliveryCfg := dispatcherCfg.Delivery
+	queueDB, err := cdb.LoadDB(
+		deliveryCfg.QueueDBBackend,
+		deliveryCfg.QueueDBName,
+		cfg.RootPath(),
+		20,
+		cfg.Metrics.Database,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return events.NewSinkEventDispatcher(sink, queueDB, deliveryCfg)
+}
+
 func loadEvmDB(cfg *config.Config, appHeight int64) (dbm.DB, error) {
 	evmDBCfg := cfg.EvmDB
 	db, err := cdb.LoadDB(
@@ -758,6 +821,11 @@ func loadApp(
 	case events.DispatcherLog:
 		logger.Info("Using simple log event dispatcher")
 		eventDispatcher = events.NewLogEventDispatcher()
+	case events.DispatcherWebhook, events.DispatcherNATS, events.DispatcherKafka:
+		eventDispatcher, err = newSinkEventDispatcher(cfg)
+		if err != nil {
+			return nil, err
+		}
 	default:
 		return nil, fmt.Errorf("invalid event dispatcher %s", cfg.EventDispatcher.Dispatcher)
 	}
@@ -792,6 +860,7 @@ func loadApp(
 		}
 		return receiptVer, cfg.EVMPersistentTxReceiptsMax, nil
 	})
+	receiptHandlerProvider.DBBackend = cfg.ReceiptsDBBackend
 
 	var newABMFactory plugin.NewAccountBalanceManagerFactoryFunc
 	if evm.EVMEnabled && cfg.EVMAccountsEnabled {
@@ -879,6 +948,39 @@ func loadApp(
 		},
 	}
 
+	rotateKeyTxHandler := &tx_handler.RotateKeyTxHandler{
+		HasBalance: func(state diademchain.State, account diadem.Address) (bool, error) {
+			// the address of the new key must not hold any DiademCoin or ETH
+			coins := []struct {
+				contractName string
+				balanceOf    func(contractpb.StaticContext, diadem.Address) (*diadem.BigUInt, error)
+			}{
+				{"coin", coin.BalanceOf},
+				{"ethcoin", ethcoin.BalanceOf},
+			}
+			for _, c := range coins {
+				ctx, err := getContractCtx(c.contractName, vmManager)(state)
+				if err == regcommon.ErrNotFound {
+					continue
+				} else if err != nil {
+					return false, err
+				}
+				balance, err := c.balanceOf(ctx, account)
+				if err != nil {
+					return false, err
+				}
+				if balance.Cmp(diadem.NewBigUIntFromInt(0)) > 0 {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+	}
+	grantSessionKeyTxHandler := &tx_handler.GrantSessionKeyTxHandler{
+		MaxLifetime: cfg.Auth.MaxSessionKeyLifetime,
+	}
+	revokeSessionKeyTxHandler := &tx_handler.RevokeSessionKeyTxHandler{}
+
 	gen, err := config.ReadGenesis(cfg.GenesisPath())
 	if err != nil {
 		return nil, err
@@ -889,6 +991,12 @@ func loadApp(
 		registry := createRegistry(state)
 		evm.AddDiademPrecompiles()
 		for i, contractCfg := range gen.Contracts {
+			if contractCfg.State != nil {
+				if err := restoreContract(state, contractCfg, evmDB, registry, logger); err != nil {
+					return errors.Wrapf(err, "restoring contract: %s", contractCfg.Name)
+				}
+				continue
+			}
 			err := deployContract(
 				state,
 				contractCfg,
@@ -902,6 +1010,13 @@ func loadApp(
 				return errors.Wrapf(err, "deploying contract: %s", contractCfg.Name)
 			}
 		}
+		if gen.AppState != nil {
+			// must be restored after the contracts are deployed, otherwise the exported nonce of the
+			// root account would change the addresses of the contracts deployed above
+			if err := restoreAppState(state, gen.AppState, evmDB); err != nil {
+				return errors.Wrap(err, "restoring app state")
+			}
+		}
 		return nil
 	}
 
@@ -943,17 +1058,31 @@ func loadApp(
 	router.HandleDeliverTx(1, diademchain.GeneratePassthroughRouteHandler(deployTxHandler))
 	router.HandleDeliverTx(2, diademchain.GeneratePassthroughRouteHandler(callTxHandler))
 	router.HandleDeliverTx(3, diademchain.GeneratePassthroughRouteHandler(migrationTxHandler))
+	router.HandleDeliverTx(4, diademchain.GeneratePassthroughRouteHandler(rotateKeyTxHandler))
+	router.HandleDeliverTx(5, diademchain.GeneratePassthroughRouteHandler(grantSessionKeyTxHandler))
+	router.HandleDeliverTx(6, diademchain.GeneratePassthroughRouteHandler(revokeSessionKeyTxHandler))
 
 	// TODO: Write this in more elegant way
 	router.HandleCheckTx(1, diademchain.GenerateConditionalRouteHandler(isEvmTx, diademchain.NoopTxHandler, deployTxHandler))
 	router.HandleCheckTx(2, diademchain.GenerateConditionalRouteHandler(isEvmTx, diademchain.NoopTxHandler, callTxHandler))
 	router.HandleCheckTx(3, diademchain.GenerateConditionalRouteHandler(isEvmTx, diademchain.NoopTxHandler, migrationTxHandler))
+	router.HandleCheckTx(4, diademchain.GenerateConditionalRouteHandler(isEvmTx, diademchain.NoopTxHandler, rotateKeyTxHandler))
+	router.HandleCheckTx(5, diademchain.GenerateConditionalRouteHandler(isEvmTx, diademchain.NoopTxHandler, grantSessionKeyTxHandler))
+	router.HandleCheckTx(6, diademchain.GenerateConditionalRouteHandler(isEvmTx, diademchain.NoopTxHandler, revokeSessionKeyTxHandler))
 
 	txMiddleWare := []diademchain.TxMiddleware{
 		diademchain.LogTxMiddleware,
 		diademchain.RecoveryTxMiddleware,
 	}
 
+	if cfg.NonceQueue.Enabled {
+		txMiddleWare = append(txMiddleWare, auth.PendingTxMiddleware)
+	}
+
+	if cfg.TxFees.Enabled {
+		txMiddleWare = append(txMiddleWare, auth.FeePayerTxMiddleware)
+	}
+
 	txMiddleWare = append(txMiddleWare, auth.NewChainConfigMiddleware(
 		cfg.Auth,
 		getContractCtx("addressmapper", vmManager),
@@ -979,6 +1108,24 @@ func loadApp(
 		txMiddleWare = append(txMiddleWare, dwMiddleware)
 	}
 
+	if cfg.TxFees.Enabled {
+		txMiddleWare = append(txMiddleWare, throttle.NewTxFeeMiddleware(
+			cfg.TxFees,
+			getContractCtx("coin", vmManager),
+			func(state diademchain.State) (diadem.Address, error) {
+				// TODO: Fees are only collected in the DPOS contract's balance for now, they should
+				//       be added to the DPOS reward pool once the contract supports it.
+				dposContractName := "dpos"
+				if cfg.DPOSVersion == 3 || state.FeatureEnabled(diademchain.DPOSVersion3Feature, false) {
+					dposContractName = "dposV3"
+				} else if cfg.DPOSVersion == 2 {
+					dposContractName = "dposV2"
+				}
+				return createRegistry(state).Resolve(dposContractName)
+			},
+		))
+	}
+
 	createContractUpkeepHandler := func(state diademchain.State) (diademchain.KarmaHandler, error) {
 		// TODO: This setting should be part of the config stored within the Karma contract itself,
 		//       that will allow us to switch the upkeep on & off via a tx.
@@ -1026,6 +1173,13 @@ func loadApp(
 		return diadem.NewValidatorSet(b.GenesisValidators()...), nil
 	}
 
+	if cfg.NonceQueue.Enabled {
+		auth.NonceTxHandler.EnableNonceQueue(cfg.NonceQueue, func(txBytes []byte) {
+			if _, err := tmcore.BroadcastTxAsync(txBytes); err != nil {
+				logger.Error("Failed to resubmit queued tx", "err", err)
+			}
+		})
+	}
 	txMiddleWare = append(txMiddleWare, auth.NonceTxMiddleware)
 
 	oracle, err := diadem.ParseAddress(cfg.Oracle)
@@ -1043,6 +1197,8 @@ func loadApp(
 		deployerAddressList,
 		cfg.TxLimiter.LimitDeploys,
 		cfg.TxLimiter.LimitCalls,
+		cfg.Auth,
+		getContractCtx("addressmapper", vmManager),
 	)
 
 	// Technically ThrottleTxMiddleWare has been replaced by OriginHandler but to replay a couple
@@ -1176,6 +1332,74 @@ func deployContract(
 	return nil
 }
 
+// restoreContract places a contract exported by the export-genesis command at its original
+// address, and restores the contract state, the contract is not initialized again.
+func restoreContract(
+	state diademchain.State,
+	contractCfg config.ContractConfig,
+	evmDB dbm.DB,
+	registry regcommon.Registry,
+	logger log.TMLogger,
+) error {
+	addr, err := diadem.ParseAddress(contractCfg.State.Address)
+	if err != nil {
+		return err
+	}
+	owner := addr
+	if contractCfg.State.Owner != "" {
+		if owner, err = diadem.ParseAddress(contractCfg.State.Owner); err != nil {
+			return err
+		}
+	}
+
+	switch contractCfg.VMType() {
+	case vm.VMType_PLUGIN:
+		if state.Has(diadem.TextKey(addr)) {
+			return fmt.Errorf("contract already exists at %v", addr)
+		}
+		code, err := proto.Marshal(&plugin.PluginCode{Name: contractCfg.Location})
+		if err != nil {
+			return err
+		}
+		state.Set(diadem.TextKey(addr), code)
+		contractState := state.WithPrefix(diadem.DataPrefix(addr))
+		for _, entry := range contractCfg.State.Storage {
+			contractState.Set(entry.Key, entry.Value)
+		}
+	case vm.VMType_EVM:
+		if err := evm.ImportContractState(state, evmDB, addr, contractCfg.State); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported VM type %s", contractCfg.VMTypeName)
+	}
+
+	if err := registry.Register(contractCfg.Name, addr, owner); err != nil {
+		return err
+	}
+
+	logger.Info("Restored contract",
+		"vm", contractCfg.VMTypeName,
+		"location", contractCfg.Location,
+		"name", contractCfg.Name,
+		"address", addr,
+	)
+	return nil
+}
+
+// restoreAppState writes the nonces & feature flags exported by the export-genesis command to the
+// given state.
+func restoreAppState(state diademchain.State, appState *config.AppState, evmDB dbm.DB) error {
+	auth.ImportNonces(state, appState.Nonces)
+	if err := evm.ImportAccountNonces(state, evmDB, appState.EVMNonces); err != nil {
+		return err
+	}
+	for _, feature := range appState.Features {
+		state.SetFeature(feature.Name, feature.Enabled)
+	}
+	return nil
+}
+
 type contextFactory func(state diademchain.State) (contractpb.Context, error)
 
 func getContractCtx(pluginName string, vmManager *vm.Manager) contextFactory {
@@ -1246,22 +1470,50 @@ func initQueryService(
 		return err
 	}
 
+	ethPolls := polls.NewEthSubscriptions()
+	if cfg.EthFilterStore.Enabled {
+		filterStore, err := loadEthFilterStore(cfg)
+		if err != nil {
+			return errors.Wrap(err, "failed to load eth filter store")
+		}
+		ethPolls = polls.NewEthSubscriptionsWithStore(
+			filterStore, time.Duration(cfg.EthFilterStore.TTL)*time.Second,
+		)
+	}
+
+	// AppStore v2 only keeps the values of the latest version, so there's no archive to read
+	// historical state from.
+	var historicalStateProvider rpc.HistoricalStateProvider
+	if cfg.AppStore.Version == 1 {
+		historicalStateProvider = app
+	}
+
 	qs := &rpc.QueryServer{
-		StateProvider:          app,
-		ChainID:                chainID,
-		Loader:                 loader,
-		Subscriptions:          app.EventHandler.SubscriptionSet(),
-		EthSubscriptions:       app.EventHandler.EthSubscriptionSet(),
-		EthLegacySubscriptions: app.EventHandler.LegacyEthSubscriptionSet(),
-		EthPolls:               *polls.NewEthSubscriptions(),
-		CreateRegistry:         createRegistry,
-		NewABMFactory:          newABMFactory,
-		ReceiptHandlerProvider: receiptHandlerProvider,
-		RPCListenAddress:       cfg.RPCListenAddress,
-		BlockStore:             blockstore,
-		EventStore:             app.EventStore,
-		EvmDB:                  app.EvmDB,
-		AuthCfg:                cfg.Auth,
+		StateProvider:           app,
+		HistoricalStateProvider: historicalStateProvider,
+		ProofProvider:           app,
+		PruningStatusProvider:   app,
+		ChainID:                 chainID,
+		Loader:                  loader,
+		Subscriptions:           app.EventHandler.SubscriptionSet(),
+		EthSubscriptions:        app.EventHandler.EthSubscriptionSet(),
+		EthLegacySubscriptions:  app.EventHandler.LegacyEthSubscriptionSet(),
+		EthPolls:                *ethPolls,
+		CreateRegistry:          createRegistry,
+		NewABMFactory:           newABMFactory,
+		ReceiptHandlerProvider:  receiptHandlerProvider,
+		RPCListenAddress:        cfg.RPCListenAddress,
+		BlockStore:              blockstore,
+		EventStore:              app.EventStore,
+		EvmDB:                   app.EvmDB,
+		AuthCfg:                 cfg.Auth,
+		BalanceContract:         cfg.EthBalanceContract,
+
+		EthGetLogsMaxBlockRange:     cfg.RPCLimits.EthGetLogsMaxBlockRange,
+		EthGetLogsMaxResults:        cfg.RPCLimits.EthGetLogsMaxResults,
+		ContractEventsMaxBlockRange: cfg.RPCLimits.ContractEventsMaxBlockRange,
+		ContractEventsMaxResults:    cfg.RPCLimits.ContractEventsMaxResults,
+		DebugTraceMaxSteps:          cfg.RPCLimits.DebugTraceMaxSteps,
 	}
 	bus := &rpc.QueryEventBus{
 		Subs:    *app.EventHandler.SubscriptionSet(),
@@ -1273,8 +1525,31 @@ func initQueryService(
 		qsvc = qs
 		qsvc = rpc.NewInstrumentingMiddleWare(requestCount, requestLatency, qsvc)
 	}
+	// the app doesn't see the mempool or the sync status, so eth_subscribe subscribers are kept
+	// up to date by polling the node instead
+	go rpc.NewEthSubscriptionPoller(chainID, cfg.Auth, qs.EthSubscriptions).Run()
+
+	var graphqlHandler http.Handler
+	if cfg.GraphQLEnabled {
+		graphqlHandler, err = graphql.NewHandler(&graphql.Backend{
+			StateProvider:          app,
+			ReceiptHandlerProvider: receiptHandlerProvider,
+			BlockStore:             blockstore,
+			MaxBlockRange:          cfg.RPCLimits.GraphQLMaxBlockRange,
+		}, graphql.Limits{
+			MaxDepth:       cfg.RPCLimits.GraphQLMaxDepth,
+			MaxParallelism: cfg.RPCLimits.GraphQLMaxParallelism,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	logger := log.Root.With("module", "query-server")
-	err = rpc.RPCServer(qsvc, logger, bus, cfg.RPCBindAddress, cfg.UnsafeRPCEnabled, cfg.UnsafeRPCBindAddress)
+	err = rpc.RPCServer(
+		qsvc, logger, bus, cfg.RPCBindAddress, cfg.UnsafeRPCEnabled, cfg.UnsafeRPCBindAddress, cfg.RPCLimits,
+		graphqlHandler, cfg.EVMDebugEnabled,
+	)
 	if err != nil {
 		return err
 	}
@@ -1313,12 +1588,17 @@ func main() {
 		newDeployCommand(),
 		newDeployGoCommand(),
 		newMigrationCommand(),
+		newExportGenesisCommand(),
 		callCommand,
 		newGenKeyCommand(),
+		newMultisigCommand(),
+		newRotateKeyCommand(),
+		newSessionKeyCommand(),
 		newYubiHsmCommand(),
 		newNodeKeyCommand(),
 		newStaticCallCommand(), //Depreciate
 		newGetBlocksByNumber(),
+		newContractEventsCommand(),
 		NewCoinCommand(),
 		NewDPOSV2Command(),
 		NewDPOSV3Command(),
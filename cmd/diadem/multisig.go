@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/diademnetwork/go-diadem"
+	lauth "github.com/diademnetwork/go-diadem/auth"
+	"github.com/diademnetwork/go-diadem/cli"
+	"github.com/diademnetwork/go-diadem/client"
+	"github.com/diademnetwork/go-diadem/types"
+	"github.com/diademnetwork/go-diadem/vm"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/diademnetwork/diademchain/auth"
+)
+
+// Txs sent from multisig accounts are put together in several steps, since the signers will
+// usually not have access to each other's keys:
+// 1. new-tx creates an unsigned tx.
+// 2. Each signer signs the unsigned tx with sign, producing a partial signature.
+// 3. combine aggregates the partial signatures into a signed tx.
+// 4. broadcast sends the signed tx to the DAppChain.
+func newMultisigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "multisig",
+		Short: "Create & sign txs sent from multisig accounts",
+	}
+	cmd.AddCommand(
+		newMultisigAddressCommand(),
+		newMultisigNewTxCommand(),
+		newMultisigSignCommand(),
+		newMultisigCombineCommand(),
+		newMultisigBroadcastCommand(),
+	)
+	return cmd
+}
+
+const multisigAddressCmdExample = `
+diadem multisig address 2 alice.pub bob.pub carol.pub --output treasury.multisig
+`
+
+func newMultisigAddressCommand() *cobra.Command {
+	var outFile string
+	var chainID string
+	cmd := &cobra.Command{
+		Use:     "address <threshold> <public key file 1> ... <public key file N>",
+		Short:   "Create a multisig key from a set of public keys, and print its address",
+		Example: multisigAddressCmdExample,
+		Args:    cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			threshold, err := strconv.Atoi(args[0])
+			if err != nil {
+				return errors.Wrap(err, "invalid threshold")
+			}
+			var pubKeys [][]byte
+			for _, pubFile := range args[1:] {
+				pubKey, err := readBase64File(pubFile)
+				if err != nil {
+					return err
+				}
+				pubKeys = append(pubKeys, pubKey)
+			}
+			key, err := auth.NewMultisigKey(threshold, pubKeys)
+			if err != nil {
+				return err
+			}
+			if outFile != "" {
+				if err := writeBase64File(outFile, key.Marshal()); err != nil {
+					return err
+				}
+			}
+			fmt.Printf("address: %s\n", diadem.Address{ChainID: chainID, Local: key.LocalAddress()}.String())
+			fmt.Printf("threshold: %d of %d\n", key.Threshold, len(key.PublicKeys))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&outFile, "output", "o", "", "file to write the multisig key to")
+	cmd.Flags().StringVar(&chainID, "caller-chain", "multisig", "chain ID of multisig accounts")
+	return cmd
+}
+
+type multisigNewTxFlags struct {
+	MultisigKeyFile string
+	ContractAddr    string
+	ContractName    string
+	Input           string
+	VMType          string
+	Sequence        uint64
+	OutFile         string
+}
+
+func newMultisigNewTxCommand() *cobra.Command {
+	var flags multisigNewTxFlags
+	cmd := &cobra.Command{
+		Use:   "new-tx",
+		Short: "Create an unsigned tx that calls a contract from a multisig account",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			callerChainID := cli.TxFlags.CallerChainID
+			if callerChainID == "" {
+				callerChainID = "multisig"
+			}
+			nonceTx, err := newMultisigCallTx(flags, callerChainID)
+			if err != nil {
+				return err
+			}
+			return writeBase64File(flags.OutFile, nonceTx)
+		},
+	}
+	cmd.Flags().StringVarP(&flags.MultisigKeyFile, "multisig-key", "m", "", "multisig key file")
+	cmd.Flags().StringVarP(&flags.ContractAddr, "contract-addr", "c", "", "contract address")
+	cmd.Flags().StringVarP(&flags.ContractName, "contract-name", "n", "", "contract name")
+	cmd.Flags().StringVarP(&flags.Input, "input", "i", "", "file with hex encoded call input")
+	cmd.Flags().StringVar(&flags.VMType, "vm", "evm", "VM type of the contract: evm or plugin")
+	cmd.Flags().Uint64Var(&flags.Sequence, "sequence", 0, "tx sequence number, i.e. the current nonce of the multisig account + 1")
+	cmd.Flags().StringVarP(&flags.OutFile, "output", "o", "", "file to write the unsigned tx to")
+	setChainFlags(cmd.Flags())
+	return cmd
+}
+
+func newMultisigCallTx(flags multisigNewTxFlags, callerChainID string) ([]byte, error) {
+	if flags.Sequence == 0 {
+		return nil, errors.New("tx sequence number not specified")
+	}
+	if flags.OutFile == "" {
+		return nil, errors.New("output file not specified")
+	}
+	keyBytes, err := readBase64File(flags.MultisigKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := auth.UnmarshalMultisigKey(keyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var vmType vm.VMType
+	switch flags.VMType {
+	case "evm":
+		vmType = vm.VMType_EVM
+	case "plugin":
+		vmType = vm.VMType_PLUGIN
+	default:
+		return nil, fmt.Errorf("unrecognised VM type %s", flags.VMType)
+	}
+
+	var contractAddr diadem.Address
+	if flags.ContractAddr != "" {
+		contractLocalAddr, err := diadem.LocalAddressFromHexString(flags.ContractAddr)
+		if err != nil {
+			return nil, err
+		}
+		contractAddr = diadem.Address{
+			ChainID: cli.TxFlags.ChainID,
+			Local:   contractLocalAddr,
+		}
+	} else {
+		rpcclient := client.NewDAppChainRPCClient(cli.TxFlags.ChainID, cli.TxFlags.URI+"/rpc", cli.TxFlags.URI+"/query")
+		contractAddr, err = rpcclient.Resolve(flags.ContractName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	intext, err := ioutil.ReadFile(flags.Input)
+	if err != nil {
+		return nil, err
+	}
+	input, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(intext)), "0x"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode call input")
+	}
+
+	callTxBytes, err := proto.Marshal(&vm.CallTx{
+		VmType: vmType,
+		Input:  input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	msgTxBytes, err := proto.Marshal(&vm.MessageTx{
+		From: diadem.Address{ChainID: callerChainID, Local: key.LocalAddress()}.MarshalPB(),
+		To:   contractAddr.MarshalPB(),
+		Data: callTxBytes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	txBytes, err := proto.Marshal(&types.Transaction{
+		Id:   2, // call tx
+		Data: msgTxBytes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&lauth.NonceTx{
+		Inner:    txBytes,
+		Sequence: flags.Sequence,
+	})
+}
+
+func newMultisigSignCommand() *cobra.Command {
+	var privFile, txFile, outFile string
+	cmd := &cobra.Command{
+		Use:   "sign",
+		Short: "Create a partial signature of an unsigned multisig tx",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			privKey, err := readBase64File(privFile)
+			if err != nil {
+				return err
+			}
+			if len(privKey) != ed25519.PrivateKeySize {
+				return errors.New("invalid private key length")
+			}
+			nonceTx, err := readBase64File(txFile)
+			if err != nil {
+				return err
+			}
+			sig, err := json.Marshal(auth.SignMultisigTx(ed25519.PrivateKey(privKey), nonceTx))
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(outFile, sig, 0664); err != nil {
+				return errors.Wrapf(err, "failed to write signature to %s", outFile)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&privFile, "key", "k", "", "private key file")
+	cmd.Flags().StringVar(&txFile, "tx", "", "unsigned tx file")
+	cmd.Flags().StringVarP(&outFile, "output", "o", "", "file to write the partial signature to")
+	return cmd
+}
+
+func newMultisigCombineCommand() *cobra.Command {
+	var keyFile, txFile, outFile string
+	cmd := &cobra.Command{
+		Use:   "combine <signature file 1> ... <signature file N>",
+		Short: "Combine partial signatures of a multisig tx into a signed tx",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keyBytes, err := readBase64File(keyFile)
+			if err != nil {
+				return err
+			}
+			key, err := auth.UnmarshalMultisigKey(keyBytes)
+			if err != nil {
+				return err
+			}
+			nonceTx, err := readBase64File(txFile)
+			if err != nil {
+				return err
+			}
+			var sigs []*auth.PartialSignature
+			for _, sigFile := range args {
+				data, err := ioutil.ReadFile(sigFile)
+				if err != nil {
+					return errors.Wrapf(err, "failed to read %s", sigFile)
+				}
+				var sig auth.PartialSignature
+				if err := json.Unmarshal(data, &sig); err != nil {
+					return errors.Wrapf(err, "failed to decode signature in %s", sigFile)
+				}
+				sigs = append(sigs, &sig)
+			}
+			signedTx, err := auth.CombineMultisigSignatures(key, nonceTx, sigs)
+			if err != nil {
+				return err
+			}
+			signedTxBytes, err := proto.Marshal(signedTx)
+			if err != nil {
+				return err
+			}
+			return writeBase64File(outFile, signedTxBytes)
+		},
+	}
+	cmd.Flags().StringVarP(&keyFile, "multisig-key", "m", "", "multisig key file")
+	cmd.Flags().StringVar(&txFile, "tx", "", "unsigned tx file")
+	cmd.Flags().StringVarP(&outFile, "output", "o", "", "file to write the signed tx to")
+	return cmd
+}
+
+func newMultisigBroadcastCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "broadcast <signed tx file>",
+		Short: "Send a signed multisig tx to the DAppChain",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			signedTx, err := readBase64File(args[0])
+			if err != nil {
+				return err
+			}
+			return broadcastTx(cli.TxFlags.URI+"/rpc", signedTx)
+		},
+	}
+	setChainFlags(cmd.Flags())
+	return cmd
+}
+
+type broadcastTxResult struct {
+	CheckTx struct {
+		Code uint32 `json:"code"`
+		Log  string `json:"log"`
+	} `json:"check_tx"`
+	DeliverTx struct {
+		Code uint32 `json:"code"`
+		Log  string `json:"log"`
+	} `json:"deliver_tx"`
+	Hash   string          `json:"hash"`
+	Height json.RawMessage `json:"height"`
+}
+
+func broadcastTx(rpcURL string, signedTx []byte) error {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      "multisig",
+		"method":  "broadcast_tx_commit",
+		"params": map[string]interface{}{
+			"tx": signedTx,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(rpcURL, "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		return errors.Wrap(err, "failed to send tx")
+	}
+	defer resp.Body.Close()
+
+	var rpcResp struct {
+		Result *broadcastTxResult `json:"result"`
+		Error  json.RawMessage    `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return errors.Wrap(err, "failed to decode response")
+	}
+	if rpcResp.Result == nil {
+		return fmt.Errorf("failed to send tx: %s", string(rpcResp.Error))
+	}
+	result := rpcResp.Result
+	if result.CheckTx.Code != 0 {
+		return fmt.Errorf("tx rejected: %s", result.CheckTx.Log)
+	}
+	if result.DeliverTx.Code != 0 {
+		return fmt.Errorf("tx failed: %s", result.DeliverTx.Log)
+	}
+	fmt.Printf("tx hash: %s\n", result.Hash)
+	fmt.Printf("block height: %s\n", strings.Trim(string(result.Height), `"`))
+	return nil
+}
+
+func readBase64File(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", filename)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s", filename)
+	}
+	return decoded, nil
+}
+
+func writeBase64File(filename string, data []byte) error {
+	if err := ioutil.WriteFile(filename, []byte(base64.StdEncoding.EncodeToString(data)), 0664); err != nil {
+		return errors.Wrapf(err, "failed to write %s", filename)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/diademnetwork/go-diadem"
+	lauth "github.com/diademnetwork/go-diadem/auth"
+	"github.com/diademnetwork/go-diadem/cli"
+	"github.com/diademnetwork/go-diadem/types"
+	"github.com/diademnetwork/go-diadem/vm"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/diademnetwork/diademchain/auth"
+)
+
+const rotateKeyTxID = 4
+
+const rotateKeyCmdExample = `
+diadem rotate-key -k current.priv --new-key new.priv
+`
+
+func newRotateKeyCommand() *cobra.Command {
+	var newPrivFile, account string
+	cmd := &cobra.Command{
+		Use:     "rotate-key",
+		Short:   "Replace the key that controls an account, without changing the account address",
+		Example: rotateKeyCmdExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rotateKey(cli.TxFlags.PrivFile, newPrivFile, account)
+		},
+	}
+	cmd.Flags().StringVarP(&cli.TxFlags.PrivFile, "key", "k", "", "current private key file")
+	cmd.Flags().StringVar(&newPrivFile, "new-key", "", "new private key file")
+	cmd.Flags().StringVar(&account, "account", "", "account address, only required if the key of the account has been rotated before")
+	setChainFlags(cmd.Flags())
+	return cmd
+}
+
+func rotateKey(privFile, newPrivFile, account string) error {
+	privKey, err := readBase64File(privFile)
+	if err != nil {
+		return err
+	}
+	newPrivKey, err := readBase64File(newPrivFile)
+	if err != nil {
+		return err
+	}
+	if len(privKey) != ed25519.PrivateKeySize || len(newPrivKey) != ed25519.PrivateKeySize {
+		return errors.New("invalid private key length")
+	}
+	signer := lauth.NewEd25519Signer(privKey)
+
+	accountAddr, err := accountAddress(signer, account)
+	if err != nil {
+		return err
+	}
+
+	newPubKey := ed25519.PrivateKey(newPrivKey).Public().(ed25519.PublicKey)
+	rotateKeyTxBytes, err := proto.Marshal(&auth.RotateKeyTx{
+		NewPublicKey:    newPubKey,
+		NewKeySignature: ed25519.Sign(ed25519.PrivateKey(newPrivKey), auth.RotateKeyMessage(accountAddr)),
+	})
+	if err != nil {
+		return err
+	}
+	if err := sendAccountTx(signer, accountAddr, rotateKeyTxID, rotateKeyTxBytes); err != nil {
+		return err
+	}
+	fmt.Printf("account %s is now controlled by key %s\n",
+		accountAddr.String(), diadem.LocalAddressFromPublicKey(newPubKey).String(),
+	)
+	return nil
+}
+
+// accountAddress returns the address of the given account, or the address derived from the key of
+// the given signer if no account is specified.
+func accountAddress(signer lauth.Signer, account string) (diadem.Address, error) {
+	if account != "" {
+		return diadem.ParseAddress(account)
+	}
+	return diadem.Address{
+		ChainID: cli.TxFlags.ChainID,
+		Local:   diadem.LocalAddressFromPublicKey(signer.PublicKey()),
+	}, nil
+}
+
+// sendAccountTx sends a tx with the given ID & payload from the given account to itself.
+func sendAccountTx(signer lauth.Signer, accountAddr diadem.Address, txID uint32, data []byte) error {
+	nonce, err := queryNonce(cli.TxFlags.URI+"/query", accountAddr)
+	if err != nil {
+		return err
+	}
+
+	msgTxBytes, err := proto.Marshal(&vm.MessageTx{
+		From: accountAddr.MarshalPB(),
+		To:   accountAddr.MarshalPB(),
+		Data: data,
+	})
+	if err != nil {
+		return err
+	}
+	txBytes, err := proto.Marshal(&types.Transaction{
+		Id:   txID,
+		Data: msgTxBytes,
+	})
+	if err != nil {
+		return err
+	}
+	nonceTxBytes, err := proto.Marshal(&lauth.NonceTx{
+		Inner:    txBytes,
+		Sequence: nonce + 1,
+	})
+	if err != nil {
+		return err
+	}
+	signedTxBytes, err := proto.Marshal(lauth.SignTx(signer, nonceTxBytes))
+	if err != nil {
+		return err
+	}
+	return broadcastTx(cli.TxFlags.URI+"/rpc", signedTxBytes)
+}
+
+// queryNonce returns the nonce of the last committed tx sent by the given account.
+func queryNonce(queryURL string, account diadem.Address) (uint64, error) {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      "nonce",
+		"method":  "nonce",
+		"params": map[string]interface{}{
+			"key":     "",
+			"account": account.String(),
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.Post(queryURL, "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to query nonce")
+	}
+	defer resp.Body.Close()
+
+	var rpcResp struct {
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return 0, errors.Wrap(err, "failed to decode response")
+	}
+	if len(rpcResp.Result) == 0 {
+		return 0, fmt.Errorf("failed to query nonce: %s", string(rpcResp.Error))
+	}
+	// the nonce may be encoded as either a number or a string
+	return strconv.ParseUint(strings.Trim(string(rpcResp.Result), `"`), 10, 64)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/diademnetwork/go-diadem"
+	lauth "github.com/diademnetwork/go-diadem/auth"
+	"github.com/diademnetwork/go-diadem/cli"
+	"github.com/diademnetwork/go-diadem/types"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/diademnetwork/diademchain/auth"
+)
+
+const (
+	grantSessionKeyTxID  = 5
+	revokeSessionKeyTxID = 6
+)
+
+func newSessionKeyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "session-key",
+		Short: "Grant & revoke session keys, which can sign a limited set of txs on behalf of an account",
+	}
+	cmd.AddCommand(
+		newGrantSessionKeyCommand(),
+		newRevokeSessionKeyCommand(),
+	)
+	return cmd
+}
+
+const grantSessionKeyCmdExample = `
+diadem session-key grant -k account.priv --session-key session.priv --contract MyGame --method 0xa9059cbb --max-txs 1000 --expiry-height 250000
+`
+
+func newGrantSessionKeyCommand() *cobra.Command {
+	var sessionPrivFile, account string
+	var contracts, methods []string
+	var maxTxCount uint64
+	var expiryHeight int64
+	cmd := &cobra.Command{
+		Use:     "grant",
+		Short:   "Grant a session key to an account",
+		Example: grantSessionKeyCmdExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			privKey, err := readBase64File(cli.TxFlags.PrivFile)
+			if err != nil {
+				return err
+			}
+			sessionPrivKey, err := readBase64File(sessionPrivFile)
+			if err != nil {
+				return err
+			}
+			if len(privKey) != ed25519.PrivateKeySize || len(sessionPrivKey) != ed25519.PrivateKeySize {
+				return errors.New("invalid private key length")
+			}
+			signer := lauth.NewEd25519Signer(privKey)
+			accountAddr, err := accountAddress(signer, account)
+			if err != nil {
+				return err
+			}
+
+			var contractAddrs []*types.Address
+			for _, contract := range contracts {
+				addr, err := cli.ResolveAddress(contract, cli.TxFlags.ChainID, cli.TxFlags.URI)
+				if err != nil {
+					return errors.Wrapf(err, "failed to resolve contract %s", contract)
+				}
+				contractAddrs = append(contractAddrs, addr.MarshalPB())
+			}
+
+			sessionPubKey := ed25519.PrivateKey(sessionPrivKey).Public().(ed25519.PublicKey)
+			txBytes, err := proto.Marshal(&auth.GrantSessionKeyTx{
+				PublicKey:    sessionPubKey,
+				KeySignature: ed25519.Sign(ed25519.PrivateKey(sessionPrivKey), auth.SessionKeyMessage(accountAddr)),
+				Contracts:    contractAddrs,
+				Methods:      methods,
+				MaxTxCount:   maxTxCount,
+				ExpiryHeight: expiryHeight,
+			})
+			if err != nil {
+				return err
+			}
+			if err := sendAccountTx(signer, accountAddr, grantSessionKeyTxID, txBytes); err != nil {
+				return err
+			}
+			fmt.Printf("session key %s granted to account %s\n",
+				diadem.LocalAddressFromPublicKey(sessionPubKey).String(), accountAddr.String(),
+			)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&cli.TxFlags.PrivFile, "key", "k", "", "private key file of the account")
+	cmd.Flags().StringVar(&sessionPrivFile, "session-key", "", "private key file of the session key")
+	cmd.Flags().StringVar(&account, "account", "", "account address, only required if the key of the account has been rotated")
+	cmd.Flags().StringSliceVar(&contracts, "contract", nil, "name or address of a contract the session key can call")
+	cmd.Flags().StringSliceVar(&methods, "method", nil, "Go contract method name, or EVM contract method selector, the session key can call (any method if omitted)")
+	cmd.Flags().Uint64Var(&maxTxCount, "max-txs", 100, "max number of txs the session key can sign")
+	cmd.Flags().Int64Var(&expiryHeight, "expiry-height", 0, "block height at which the session key expires, limited by the Auth.MaxSessionKeyLifetime setting of the node")
+	setChainFlags(cmd.Flags())
+	return cmd
+}
+
+const revokeSessionKeyCmdExample = `
+diadem session-key revoke -k account.priv --session-key session.pub
+`
+
+func newRevokeSessionKeyCommand() *cobra.Command {
+	var sessionPubFile, account string
+	cmd := &cobra.Command{
+		Use:     "revoke",
+		Short:   "Revoke a session key previously granted to an account",
+		Example: revokeSessionKeyCmdExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			privKey, err := readBase64File(cli.TxFlags.PrivFile)
+			if err != nil {
+				return err
+			}
+			sessionPubKey, err := readBase64File(sessionPubFile)
+			if err != nil {
+				return err
+			}
+			if len(privKey) != ed25519.PrivateKeySize {
+				return errors.New("invalid private key length")
+			}
+			if len(sessionPubKey) != ed25519.PublicKeySize {
+				return errors.New("invalid public key length")
+			}
+			signer := lauth.NewEd25519Signer(privKey)
+			accountAddr, err := accountAddress(signer, account)
+			if err != nil {
+				return err
+			}
+
+			txBytes, err := proto.Marshal(&auth.RevokeSessionKeyTx{
+				PublicKey: sessionPubKey,
+			})
+			if err != nil {
+				return err
+			}
+			if err := sendAccountTx(signer, accountAddr, revokeSessionKeyTxID, txBytes); err != nil {
+				return err
+			}
+			fmt.Printf("session key %s revoked\n", diadem.LocalAddressFromPublicKey(sessionPubKey).String())
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&cli.TxFlags.PrivFile, "key", "k", "", "private key file of the account")
+	cmd.Flags().StringVar(&sessionPubFile, "session-key", "", "public key file of the session key")
+	cmd.Flags().StringVar(&account, "account", "", "account address, only required if the key of the account has been rotated")
+	setChainFlags(cmd.Flags())
+	return cmd
+}
@@ -14,6 +14,7 @@ import (
 	dposv2OracleCfg "github.com/diademnetwork/diademchain/builtin/plugins/dposv2/oracle/config"
 	plasmacfg "github.com/diademnetwork/diademchain/builtin/plugins/plasma_cash/config"
 	genesiscfg "github.com/diademnetwork/diademchain/config/genesis"
+	"github.com/diademnetwork/diademchain/eth/polls"
 	"github.com/diademnetwork/diademchain/events"
 	"github.com/diademnetwork/diademchain/evm"
 	"github.com/diademnetwork/diademchain/gateway"
@@ -32,6 +33,11 @@ import (
 type (
 	Genesis        = genesiscfg.Genesis
 	ContractConfig = genesiscfg.ContractConfig
+	ContractState  = genesiscfg.ContractState
+	StateEntry     = genesiscfg.StateEntry
+	AppState       = genesiscfg.AppState
+	AccountNonce   = genesiscfg.AccountNonce
+	Feature        = genesiscfg.Feature
 )
 type Config struct {
 	// Cluster
@@ -45,6 +51,10 @@ type Config struct {
 	// Solidity contracts running on the Diadem EVM. This setting is disabled by default, which means
 	// all the EVM accounts always have a zero balance.
 	EVMAccountsEnabled bool
+	// Name of the builtin contract eth_getBalance reads account balances from, either "ethcoin"
+	// (the contract EVM accounts are hooked up to when EVMAccountsEnabled is set), or "coin"
+	// (DiademCoin). Defaults to "ethcoin".
+	EthBalanceContract string
 	DPOSVersion        int64
 	BootLegacyDPoS     bool
 
@@ -58,6 +68,8 @@ type Config struct {
 	RPCBindAddress       string
 	UnsafeRPCBindAddress string
 	UnsafeRPCEnabled     bool
+	RPCLimits            *RPCLimitsConfig
+	GraphQLEnabled       bool
 
 	Peers           string
 	PersistentPeers string
@@ -70,6 +82,7 @@ type Config struct {
 	Karma                       *KarmaConfig
 	GoContractDeployerWhitelist *throttle.GoContractDeployerWhitelistConfig
 	TxLimiter                   *throttle.TxLimiterConfig
+	TxFees                      *throttle.TxFeeConfig
 
 	// Logging
 	LogDestination     string
@@ -121,16 +134,25 @@ type Config struct {
 
 	DBBackendConfig *DBBackendConfig
 
+	// DB backend used to store EVM tx receipts when they're not stored in the app store
+	// (ReceiptsVersion: 2)
+	ReceiptsDBBackend string
+
 	// Event store
 	EventStore      *events.EventStoreConfig
 	EventDispatcher *events.EventDispatcherConfig
 
+	// Store used to persist filters created via the eth_newFilter family of Web3 JSON-RPC methods
+	EthFilterStore *polls.FilterStoreConfig
+
 	// EVM DB
 	EvmDB *evm.EvmDBConfig
 
 	FnConsensus *FnConsensusConfig
 
 	Auth *auth.Config
+	// Queue for txs with sequence numbers higher than expected
+	NonceQueue *auth.NonceQueueConfig
 
 	// Dragons
 	EVMDebugEnabled bool
@@ -195,6 +217,63 @@ type DeployerWhitelistConfig struct {
 	ContractEnabled bool
 }
 
+// RPCLimitsConfig controls the limits imposed on clients of the /query, /queryws, /eth & /graphql
+// endpoints.
+type RPCLimitsConfig struct {
+	// Enables per-client rate limiting & method filtering
+	Enabled bool
+	// Number of request units each client IP is allotted per second
+	IPRateLimit float64
+	// Max number of request units a client IP can use up in a burst
+	IPBurst int64
+	// Clients that pass one of these keys in the X-API-Key header (or the apikey URL param) are
+	// rate limited per key rather than per IP.
+	APIKeys         []string
+	APIKeyRateLimit float64
+	APIKeyBurst     int64
+	// Addresses (or CIDR ranges) of the reverse proxies in front of the node, clients connecting
+	// through one of these proxies are identified by the last address in the X-Forwarded-For
+	// header set by the proxy.
+	TrustedProxies []string
+	// Number of request units used up by each call to a method, methods that aren't listed use up
+	// a single unit. Queries sent to /graphql are treated as calls to the graphql method, and are
+	// charged once for each selection set in the query.
+	MethodCosts map[string]int64
+	// If not empty only the listed methods may be called.
+	AllowedMethods []string
+	// Methods that may not be called.
+	DeniedMethods []string
+	// Max size of a request body in bytes, zero means no limit
+	MaxRequestBytes int64
+
+	// Max number of blocks eth_getLogs can query in one go, zero means no limit
+	EthGetLogsMaxBlockRange uint64
+	// Max number of logs eth_getLogs can return, zero means no limit
+	EthGetLogsMaxResults uint64
+	// Max number of blocks contractevents can query in one go
+	ContractEventsMaxBlockRange uint64
+	// Max number of events contractevents can return in one page, zero means no limit
+	ContractEventsMaxResults uint64
+	// Max number of blocks a single GraphQL blocks or logs query can span, zero means no limit
+	GraphQLMaxBlockRange uint64
+	// Max depth of a GraphQL query, zero means no limit
+	GraphQLMaxDepth int
+	// Max number of resolvers that can run in parallel for a single GraphQL query
+	GraphQLMaxParallelism int
+
+	// Max number of requests in a batch sent to the /eth endpoint, zero means no limit
+	MaxBatchSize int
+	// Number of requests in a batch sent to the /eth endpoint that are served concurrently
+	BatchConcurrency int
+	// Max size of a response returned by the /eth endpoint in bytes, zero means no limit
+	MaxResponseBytes int
+	// Max number of seconds each request sent to the /eth endpoint can take, zero means no limit
+	CallTimeout int64
+	// Max number of steps debug_traceTransaction & debug_traceCall can capture, zero means the
+	// default limit of 10000 steps. These methods are only served when EVMDebugEnabled is set.
+	DebugTraceMaxSteps int
+}
+
 func DefaultDBBackendConfig() *DBBackendConfig {
 	return &DBBackendConfig{
 		CacheSizeMegs: 2042, //2 Gigabytes
@@ -246,6 +325,49 @@ func DefaultDeployerWhitelistConfig() *DeployerWhitelistConfig {
 	}
 }
 
+func DefaultRPCLimitsConfig() *RPCLimitsConfig {
+	return &RPCLimitsConfig{
+		Enabled:         false,
+		IPRateLimit:     50,
+		IPBurst:         100,
+		APIKeyRateLimit: 200,
+		APIKeyBurst:     400,
+		MethodCosts: map[string]int64{
+			"eth_getlogs":            10,
+			"getevmlogs":             10,
+			"contractevents":         5,
+			"debug_tracetransaction": 20,
+			"debug_tracecall":        20,
+			"graphql":                10,
+		},
+		MaxRequestBytes:             1000000,
+		ContractEventsMaxBlockRange: 20,
+		GraphQLMaxBlockRange:        100,
+		GraphQLMaxDepth:             10,
+		GraphQLMaxParallelism:       10,
+		BatchConcurrency:            1,
+	}
+}
+
+// Clone returns a deep clone of the config.
+func (c *RPCLimitsConfig) Clone() *RPCLimitsConfig {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	clone.APIKeys = append([]string(nil), c.APIKeys...)
+	clone.TrustedProxies = append([]string(nil), c.TrustedProxies...)
+	clone.AllowedMethods = append([]string(nil), c.AllowedMethods...)
+	clone.DeniedMethods = append([]string(nil), c.DeniedMethods...)
+	if c.MethodCosts != nil {
+		clone.MethodCosts = make(map[string]int64, len(c.MethodCosts))
+		for k, v := range c.MethodCosts {
+			clone.MethodCosts[k] = v
+		}
+	}
+	return &clone
+}
+
 //Structure for DIADEM ENV
 
 type Env struct {
@@ -335,6 +457,7 @@ func DefaultConfig() *Config {
 		RootDir:                    ".",
 		DBName:                     "app",
 		DBBackend:                  db.GoLevelDBBackend,
+		ReceiptsDBBackend:          db.GoLevelDBBackend,
 		GenesisFile:                "genesis.json",
 		PluginsDir:                 "contracts",
 		RPCListenAddress:           "tcp://127.0.0.1:46657", // TODO this is an ephemeral port in linux, we should move this
@@ -349,6 +472,7 @@ func DefaultConfig() *Config {
 		RPCBindAddress:             "tcp://0.0.0.0:46658",
 		UnsafeRPCEnabled:           false,
 		UnsafeRPCBindAddress:       "tcp://127.0.0.1:26680",
+		GraphQLEnabled:             false,
 		CreateEmptyBlocks:          true,
 		ContractLoaders:            []string{"static", "dynamic"},
 		LogStateDB:                 false,
@@ -358,6 +482,7 @@ func DefaultConfig() *Config {
 		EVMPersistentTxReceiptsMax: receipts.DefaultMaxReceipts,
 		SessionDuration:            600,
 		EVMAccountsEnabled:         false,
+		EthBalanceContract:         "ethcoin",
 		EVMDebugEnabled:            false,
 
 		Oracle:         "",
@@ -372,6 +497,7 @@ func DefaultConfig() *Config {
 	cfg.AppStore = store.DefaultConfig()
 	cfg.HsmConfig = hsmpv.DefaultConfig()
 	cfg.TxLimiter = throttle.DefaultTxLimiterConfig()
+	cfg.TxFees = throttle.DefaultTxFeeConfig()
 	cfg.GoContractDeployerWhitelist = throttle.DefaultGoContractDeployerWhitelistConfig()
 	cfg.DPOSv2OracleConfig = dposv2OracleCfg.DefaultConfig()
 	cfg.CachingStoreConfig = store.DefaultCachingStoreConfig()
@@ -380,15 +506,18 @@ func DefaultConfig() *Config {
 	cfg.Karma = DefaultKarmaConfig()
 	cfg.ChainConfig = DefaultChainConfigConfig(cfg.RPCProxyPort)
 	cfg.DeployerWhitelist = DefaultDeployerWhitelistConfig()
+	cfg.RPCLimits = DefaultRPCLimitsConfig()
 	cfg.DBBackendConfig = DefaultDBBackendConfig()
 	cfg.PrometheusPushGateway = DefaultPrometheusPushGatewayConfig()
 	cfg.EventDispatcher = events.DefaultEventDispatcherConfig()
 	cfg.EventStore = events.DefaultEventStoreConfig()
+	cfg.EthFilterStore = polls.DefaultFilterStoreConfig()
 	cfg.EvmDB = evm.DefaultEvmDBConfig()
 
 	cfg.FnConsensus = DefaultFnConsensusConfig()
 
 	cfg.Auth = auth.DefaultConfig()
+	cfg.NonceQueue = auth.DefaultNonceQueueConfig()
 	return cfg
 }
 
@@ -411,9 +540,13 @@ func (c *Config) Clone() *Config {
 	clone.AppStore = c.AppStore.Clone()
 	clone.HsmConfig = c.HsmConfig.Clone()
 	clone.TxLimiter = c.TxLimiter.Clone()
+	clone.TxFees = c.TxFees.Clone()
 	clone.EventStore = c.EventStore.Clone()
 	clone.EventDispatcher = c.EventDispatcher.Clone()
+	clone.EthFilterStore = c.EthFilterStore.Clone()
+	clone.RPCLimits = c.RPCLimits.Clone()
 	clone.Auth = c.Auth.Clone()
+	clone.NonceQueue = c.NonceQueue.Clone()
 	return &clone
 }
 
@@ -474,7 +607,10 @@ ChainID: "{{ .ChainID }}"
 RegistryVersion: {{ .RegistryVersion }}
 ReceiptsVersion: {{ .ReceiptsVersion }}
 EVMPersistentTxReceiptsMax: {{ .EVMPersistentTxReceiptsMax }}
+# DB backend used to store EVM tx receipts when ReceiptsVersion is 2 (goleveldb, cleveldb, badgerdb)
+ReceiptsDBBackend: "{{ .ReceiptsDBBackend }}"
 EVMAccountsEnabled: {{ .EVMAccountsEnabled }}
+EthBalanceContract: "{{ .EthBalanceContract }}"
 DPOSVersion: {{ .DPOSVersion }}
 BootLegacyDPoS: {{ .BootLegacyDPoS }}
 CreateEmptyBlocks: {{ .CreateEmptyBlocks }}
@@ -486,6 +622,49 @@ RPCProxyPort: {{ .RPCProxyPort }}
 RPCBindAddress: "{{ .RPCBindAddress }}"
 UnsafeRPCEnabled: {{ .UnsafeRPCEnabled }}
 UnsafeRPCBindAddress: "{{ .UnsafeRPCBindAddress }}"
+# Serve GraphQL queries for blocks, txs, receipts & logs at /graphql
+GraphQLEnabled: {{ .GraphQLEnabled }}
+{{- if .RPCLimits}}
+RPCLimits:
+  Enabled: {{ .RPCLimits.Enabled }}
+  IPRateLimit: {{ .RPCLimits.IPRateLimit }}
+  IPBurst: {{ .RPCLimits.IPBurst }}
+  APIKeys:
+  {{- range .RPCLimits.APIKeys}}
+  - "{{. -}}"
+  {{- end}}
+  APIKeyRateLimit: {{ .RPCLimits.APIKeyRateLimit }}
+  APIKeyBurst: {{ .RPCLimits.APIKeyBurst }}
+  TrustedProxies:
+  {{- range .RPCLimits.TrustedProxies}}
+  - "{{. -}}"
+  {{- end}}
+  MethodCosts:
+  {{- range $method, $cost := .RPCLimits.MethodCosts}}
+    {{ $method }}: {{ $cost }}
+  {{- end}}
+  AllowedMethods:
+  {{- range .RPCLimits.AllowedMethods}}
+  - "{{. -}}"
+  {{- end}}
+  DeniedMethods:
+  {{- range .RPCLimits.DeniedMethods}}
+  - "{{. -}}"
+  {{- end}}
+  MaxRequestBytes: {{ .RPCLimits.MaxRequestBytes }}
+  EthGetLogsMaxBlockRange: {{ .RPCLimits.EthGetLogsMaxBlockRange }}
+  EthGetLogsMaxResults: {{ .RPCLimits.EthGetLogsMaxResults }}
+  ContractEventsMaxBlockRange: {{ .RPCLimits.ContractEventsMaxBlockRange }}
+  ContractEventsMaxResults: {{ .RPCLimits.ContractEventsMaxResults }}
+  GraphQLMaxBlockRange: {{ .RPCLimits.GraphQLMaxBlockRange }}
+  GraphQLMaxDepth: {{ .RPCLimits.GraphQLMaxDepth }}
+  GraphQLMaxParallelism: {{ .RPCLimits.GraphQLMaxParallelism }}
+  MaxBatchSize: {{ .RPCLimits.MaxBatchSize }}
+  BatchConcurrency: {{ .RPCLimits.BatchConcurrency }}
+  MaxResponseBytes: {{ .RPCLimits.MaxResponseBytes }}
+  CallTimeout: {{ .RPCLimits.CallTimeout }}
+  DebugTraceMaxSteps: {{ .RPCLimits.DebugTraceMaxSteps }}
+{{- end}}
 Peers: "{{ .Peers }}"
 PersistentPeers: "{{ .PersistentPeers }}"
 #
@@ -505,7 +684,7 @@ GoContractDeployerWhitelist:
   Enabled: {{ .GoContractDeployerWhitelist.Enabled }}
   DeployerAddressList:
   {{- range .GoContractDeployerWhitelist.DeployerAddressList}}
-    - "{{. -}}"
+  - "{{. -}}"
   {{- end}}
 TxLimiter:
   LimitDeploys: {{ .TxLimiter.LimitDeploys }}
@@ -515,6 +694,20 @@ TxLimiter:
   {{- range .TxLimiter.DeployerAddressList}}
   - "{{. -}}" 
   {{- end}}
+{{- if .TxFees}}
+# Fees charged for txs in DiademCoin, denominated in the smallest unit of DiademCoin (10^-18 DIADEM).
+# Fees are only charged once the tx:fees feature is enabled, collected fees are held by the DPOS
+# contract (they're not distributed as rewards yet).
+TxFees:
+  Enabled: {{ .TxFees.Enabled }}
+  DeployTxFee: {{ .TxFees.DeployTxFee }}
+  CallTxFee: {{ .TxFees.CallTxFee }}
+  OtherTxFee: {{ .TxFees.OtherTxFee }}
+  # Fee per byte of the tx payload, EVM txs are charged for the gas they use instead
+  FeePerByte: {{ .TxFees.FeePerByte }}
+  # Fee per unit of gas used by EVM txs
+  GasPrice: {{ .TxFees.GasPrice }}
+{{- end}}
 #
 # ContractLoader
 #
@@ -750,6 +943,9 @@ AppStore:
   PruneInterval: {{ .AppStore.PruneInterval }}
   # Number of versions to prune at a time.
   PruneBatchSize: {{ .AppStore.PruneBatchSize }}
+  # If the average time it takes to commit a block exceeds this number of milliseconds the
+  # background pruning will slow down. If zero the background pruning won't be throttled.
+  PruneTargetCommitLatency: {{ .AppStore.PruneTargetCommitLatency }}
   # DB backend to use for storing a materialized view of the latest persistent app state
   # possible values are: "goleveldb". Only used by the MultiReaderIAVL store, ignored otherwise.
   LatestStateDBBackend: {{ .AppStore.LatestStateDBBackend }}
@@ -769,6 +965,23 @@ EventStore:
   DBName: {{.EventStore.DBName}}
   DBBackend: {{.EventStore.DBBackend}}
 {{end}}
+{{if .EthFilterStore -}}
+#
+# EthFilterStore
+#
+EthFilterStore:
+  # Set to true to persist filters created via eth_newFilter, eth_newBlockFilter, and
+  # eth_newPendingTransactionFilter, so their IDs remain valid across node restarts.
+  Enabled: {{.EthFilterStore.Enabled}}
+  # "db" - filters are stored in a DB in the node's data dir
+  # "redis" - filters are stored in a Redis server that may be shared by multiple nodes
+  Backend: "{{.EthFilterStore.Backend}}"
+  DBName: {{.EthFilterStore.DBName}}
+  DBBackend: {{.EthFilterStore.DBBackend}}
+  RedisURL: "{{.EthFilterStore.RedisURL}}"
+  # Number of seconds a filter remains valid after it was created or last polled
+  TTL: {{.EthFilterStore.TTL}}
+{{end}}
 {{if .EvmDB -}}
 #
 # EvmDB
@@ -777,7 +990,7 @@ EvmDB:
   # DBName defines evm database file name
   DBName: {{.EvmDB.DBName}}
   # DBBackend defines backend EVM store type
-  # available backend types are 'goleveldb', or 'cleveldb'
+  # available backend types are 'goleveldb', 'cleveldb', or 'badgerdb'
   DBBackend: {{.EvmDB.DBBackend}}
   # CacheSizeMegs defines cache size (in megabytes) of EVM store
   CacheSizeMegs: {{.EvmDB.CacheSizeMegs}}
@@ -802,13 +1015,44 @@ FnConsensus:
 # EventDispatcher
 #
 EventDispatcher:
-  # Available dispatcher: "db_indexer" | "log" | "redis"
+  # Available dispatcher: "db_indexer" | "log" | "redis" | "webhook" | "nats" | "kafka"
   Dispatcher: {{.EventDispatcher.Dispatcher}}
   {{if eq .EventDispatcher.Dispatcher "redis"}}
   # Redis will be use when Dispatcher is "redis"
   Redis:
     URI: "{{.EventDispatcher.Redis.URI}}"
   {{end}}
+  {{- if eq .EventDispatcher.Dispatcher "webhook"}}
+  # Webhook will be used when Dispatcher is "webhook"
+  Webhook:
+    URL: "{{.EventDispatcher.Webhook.URL}}"
+    Timeout: {{.EventDispatcher.Webhook.Timeout}}
+  {{end}}
+  {{- if eq .EventDispatcher.Dispatcher "nats"}}
+  # NATS will be used when Dispatcher is "nats"
+  NATS:
+    URL: "{{.EventDispatcher.NATS.URL}}"
+    Subject: "{{.EventDispatcher.NATS.Subject}}"
+    Timeout: {{.EventDispatcher.NATS.Timeout}}
+  {{end}}
+  {{- if eq .EventDispatcher.Dispatcher "kafka"}}
+  # Kafka will be used when Dispatcher is "kafka"
+  Kafka:
+    Brokers: "{{.EventDispatcher.Kafka.Brokers}}"
+    Topic: "{{.EventDispatcher.Kafka.Topic}}"
+    Timeout: {{.EventDispatcher.Kafka.Timeout}}
+  {{end}}
+  {{- if .EventDispatcher.Delivery}}
+  # Delivery settings for the "webhook", "nats", and "kafka" dispatchers, events are queued in
+  # a local DB until they're delivered, failed deliveries are retried with an exponential backoff.
+  Delivery:
+    BatchSize: {{.EventDispatcher.Delivery.BatchSize}}
+    # Retry backoff in milliseconds
+    MinRetryBackoff: {{.EventDispatcher.Delivery.MinRetryBackoff}}
+    MaxRetryBackoff: {{.EventDispatcher.Delivery.MaxRetryBackoff}}
+    QueueDBName: "{{.EventDispatcher.Delivery.QueueDBName}}"
+    QueueDBBackend: "{{.EventDispatcher.Delivery.QueueDBBackend}}"
+  {{end}}
 #
 # Tx signing & accounts
 #
@@ -819,6 +1063,20 @@ Auth:
       TxType: "{{.TxType -}}"
       AccountType: {{.AccountType -}}
     {{- end}}
+  # Max number of blocks a session key can remain valid for after it's granted, zero means there's
+  # no limit.
+  MaxSessionKeyLifetime: {{ .Auth.MaxSessionKeyLifetime }}
+{{- if .NonceQueue}}
+NonceQueue:
+  # Set to true to let txs with sequence numbers higher than expected wait until the txs with the
+  # lower sequence numbers are received, instead of rejecting them.
+  Enabled: {{ .NonceQueue.Enabled }}
+  MaxTxsPerAccount: {{ .NonceQueue.MaxTxsPerAccount }}
+  # Max number of txs that can be queued across all accounts
+  MaxQueuedTxs: {{ .NonceQueue.MaxQueuedTxs }}
+  # Number of blocks a tx can wait in the queue before it's dropped
+  MaxAge: {{ .NonceQueue.MaxAge }}
+{{- end}}
 # These should pretty much never be changed
 RootDir: "{{ .RootDir }}"
 DBName: "{{ .DBName }}"
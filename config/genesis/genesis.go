@@ -15,6 +15,28 @@ type ContractConfig struct {
 	Name       string          `json:"name,omitempty"`
 	Location   string          `json:"location"`
 	Init       json.RawMessage `json:"init"`
+	// State is only set for contracts exported from an existing chain by the export-genesis
+	// command, such contracts are restored at their original address with their exported state,
+	// instead of being deployed & initialized from scratch.
+	State *ContractState `json:"state,omitempty"`
+}
+
+// ContractState contains the state of a contract exported from an existing chain.
+type ContractState struct {
+	Address string `json:"address"`
+	Owner   string `json:"owner,omitempty"`
+	// Plugin contracts: the keys & values stored under the contract's data prefix.
+	// EVM contracts: the contract's storage slots.
+	Storage []StateEntry `json:"storage,omitempty"`
+	// The fields below are only set for EVM contracts.
+	Code    []byte `json:"code,omitempty"`
+	Nonce   uint64 `json:"nonce,omitempty"`
+	Balance string `json:"balance,omitempty"`
+}
+
+type StateEntry struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
 }
 
 func (c ContractConfig) VMType() lvm.VMType {
@@ -23,4 +45,30 @@ func (c ContractConfig) VMType() lvm.VMType {
 
 type Genesis struct {
 	Contracts []ContractConfig `json:"contracts"`
+	// AppState is only set for genesis files exported from an existing chain by the export-genesis
+	// command, it's restored after all the contracts have been deployed or restored.
+	AppState *AppState `json:"appState,omitempty"`
+}
+
+// AppState contains the state that doesn't belong to any contract, exported from an existing chain.
+type AppState struct {
+	// Auth nonces of all the accounts that have sent txs, these are also used to derive the
+	// addresses of the Go contracts deployed by each account. The keys are the raw account
+	// address bytes, and the values the raw nonce bytes.
+	Nonces []StateEntry `json:"nonces,omitempty"`
+	// EVM nonces of the accounts that aren't contracts, these are used to derive the addresses of
+	// the EVM contracts deployed by each account.
+	EVMNonces []AccountNonce `json:"evmNonces,omitempty"`
+	Features  []Feature      `json:"features,omitempty"`
+}
+
+type AccountNonce struct {
+	// Hex-encoded local address of the account, prefixed by 0x.
+	Address string `json:"address"`
+	Nonce   uint64 `json:"nonce"`
+}
+
+type Feature struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
 }
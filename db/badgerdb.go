@@ -0,0 +1,376 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/diademnetwork/diademchain/log"
+	"github.com/pkg/errors"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+const (
+	// How often stale data should be garbage collected from the BadgerDB value log
+	badgerGCInterval = 5 * time.Minute
+	// Value log files are rewritten if at least this fraction of the file can be discarded
+	badgerGCDiscardRatio = 0.5
+)
+
+// BadgerDB is a DBWrapper implementation backed by BadgerDB, a pure Go key-value store that
+// doesn't suffer from the compaction stalls LevelDB is prone to under heavy write loads.
+type BadgerDB struct {
+	db   *badger.DB
+	quit chan struct{}
+}
+
+var _ DBWrapper = &BadgerDB{}
+
+// LoadBadgerDB opens (or creates) a BadgerDB database in <dir>/<name>.db
+func LoadBadgerDB(name, dir string) (*BadgerDB, error) {
+	dbPath := filepath.Join(dir, name+".db")
+	if err := os.MkdirAll(dbPath, 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create BadgerDB dir %s", dbPath)
+	}
+	db, err := badger.Open(badger.DefaultOptions(dbPath))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open BadgerDB %s", dbPath)
+	}
+	b := &BadgerDB{
+		db:   db,
+		quit: make(chan struct{}),
+	}
+	go b.gcLoop()
+	return b, nil
+}
+
+// Badger doesn't reclaim space in the value log automatically, so the GC has to be triggered
+// periodically.
+func (b *BadgerDB) gcLoop() {
+	ticker := time.NewTicker(badgerGCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.runValueLogGC()
+		case <-b.quit:
+			return
+		}
+	}
+}
+
+func (b *BadgerDB) runValueLogGC() {
+	// each call rewrites at most one log file, so keep going until there's nothing left to do
+	for {
+		if err := b.db.RunValueLogGC(badgerGCDiscardRatio); err != nil {
+			if err != badger.ErrNoRewrite {
+				log.Error("BadgerDB value log GC failed", "err", err)
+			}
+			return
+		}
+	}
+}
+
+func (b *BadgerDB) Get(key []byte) []byte {
+	var val []byte
+	err := b.db.View(func(txn *badger.Txn) error {
+		var err error
+		val, err = badgerGet(txn, key)
+		return err
+	})
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (b *BadgerDB) Has(key []byte) bool {
+	return b.Get(key) != nil
+}
+
+func (b *BadgerDB) Set(key, value []byte) {
+	err := b.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (b *BadgerDB) SetSync(key, value []byte) {
+	b.Set(key, value)
+}
+
+func (b *BadgerDB) Delete(key []byte) {
+	err := b.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (b *BadgerDB) DeleteSync(key []byte) {
+	b.Delete(key)
+}
+
+func (b *BadgerDB) Iterator(start, end []byte) dbm.Iterator {
+	return newBadgerDBIterator(b.db.NewTransaction(false), true, start, end, false)
+}
+
+func (b *BadgerDB) ReverseIterator(start, end []byte) dbm.Iterator {
+	return newBadgerDBIterator(b.db.NewTransaction(false), true, start, end, true)
+}
+
+func (b *BadgerDB) Close() {
+	close(b.quit)
+	if err := b.db.Close(); err != nil {
+		log.Error("failed to close BadgerDB", "err", err)
+	}
+}
+
+func (b *BadgerDB) NewBatch() dbm.Batch {
+	return &badgerDBBatch{db: b.db}
+}
+
+func (b *BadgerDB) Print() {
+	it := b.Iterator(nil, nil)
+	defer it.Close()
+	for ; it.Valid(); it.Next() {
+		fmt.Printf("[%X]:\t[%X]\n", it.Key(), it.Value())
+	}
+}
+
+func (b *BadgerDB) Stats() map[string]string {
+	lsmSize, vlogSize := b.db.Size()
+	return map[string]string{
+		"database.type":  "badgerDB",
+		"database.lsm":   fmt.Sprint(lsmSize),
+		"database.vlog":  fmt.Sprint(vlogSize),
+		"database.total": fmt.Sprint(lsmSize + vlogSize),
+	}
+}
+
+// Compact merges all the levels of the LSM tree into one, and garbage collects the value log.
+func (b *BadgerDB) Compact() error {
+	if err := b.db.Flatten(runtime.NumCPU()); err != nil {
+		return errors.Wrap(err, "failed to flatten BadgerDB")
+	}
+	b.runValueLogGC()
+	return nil
+}
+
+func (b *BadgerDB) GetSnapshot() Snapshot {
+	return &BadgerDBSnapshot{
+		txn: b.db.NewTransaction(false),
+	}
+}
+
+// BadgerDBSnapshot is a consistent read-only view of a BadgerDB database, backed by a read-only
+// Badger transaction.
+type BadgerDBSnapshot struct {
+	txn *badger.Txn
+}
+
+var _ Snapshot = &BadgerDBSnapshot{}
+
+func (s *BadgerDBSnapshot) Get(key []byte) []byte {
+	val, err := badgerGet(s.txn, key)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (s *BadgerDBSnapshot) Has(key []byte) bool {
+	return s.Get(key) != nil
+}
+
+func (s *BadgerDBSnapshot) NewIterator(start, end []byte) dbm.Iterator {
+	return newBadgerDBIterator(s.txn, false, start, end, false)
+}
+
+func (s *BadgerDBSnapshot) Release() {
+	s.txn.Discard()
+}
+
+// badgerGet returns nil if the key doesn't exist, and a non-nil (but possibly empty) slice if
+// it does, to match the behavior of the other DB backends.
+func badgerGet(txn *badger.Txn, key []byte) ([]byte, error) {
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		val = []byte{}
+	}
+	return val, nil
+}
+
+type badgerDBBatchOp struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+// badgerDBBatch buffers writes until Write is called. Badger limits the size of a single
+// transaction, so a large batch may end up being committed in multiple transactions.
+type badgerDBBatch struct {
+	db  *badger.DB
+	ops []badgerDBBatchOp
+}
+
+func (b *badgerDBBatch) Set(key, value []byte) {
+	b.ops = append(b.ops, badgerDBBatchOp{key: key, value: value})
+}
+
+func (b *badgerDBBatch) Delete(key []byte) {
+	b.ops = append(b.ops, badgerDBBatchOp{key: key, delete: true})
+}
+
+func (b *badgerDBBatch) Write() {
+	if err := b.write(); err != nil {
+		panic(err)
+	}
+}
+
+func (b *badgerDBBatch) WriteSync() {
+	b.Write()
+}
+
+func (b *badgerDBBatch) write() error {
+	txn := b.db.NewTransaction(true)
+	defer func() {
+		txn.Discard()
+	}()
+
+	for _, op := range b.ops {
+		err := b.apply(txn, op)
+		if err == badger.ErrTxnTooBig {
+			if err := txn.Commit(); err != nil {
+				return err
+			}
+			txn = b.db.NewTransaction(true)
+			err = b.apply(txn, op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	b.ops = nil
+	return txn.Commit()
+}
+
+func (b *badgerDBBatch) apply(txn *badger.Txn, op badgerDBBatchOp) error {
+	if op.delete {
+		return txn.Delete(op.key)
+	}
+	return txn.Set(op.key, op.value)
+}
+
+type badgerDBIterator struct {
+	txn     *badger.Txn
+	ownsTxn bool
+	source  *badger.Iterator
+	start   []byte
+	end     []byte
+	reverse bool
+}
+
+var _ dbm.Iterator = &badgerDBIterator{}
+
+// newBadgerDBIterator creates an iterator over the [start, end) key range, if ownsTxn is true
+// the transaction will be discarded when the iterator is closed.
+func newBadgerDBIterator(txn *badger.Txn, ownsTxn bool, start, end []byte, reverse bool) *badgerDBIterator {
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = reverse
+	source := txn.NewIterator(opts)
+
+	if reverse {
+		if end == nil {
+			source.Rewind()
+		} else {
+			// in reverse mode Seek finds the largest key <= end, but end is exclusive
+			source.Seek(end)
+			if source.Valid() && bytes.Equal(source.Item().Key(), end) {
+				source.Next()
+			}
+		}
+	} else {
+		if start == nil {
+			source.Rewind()
+		} else {
+			source.Seek(start)
+		}
+	}
+
+	return &badgerDBIterator{
+		txn:     txn,
+		ownsTxn: ownsTxn,
+		source:  source,
+		start:   start,
+		end:     end,
+		reverse: reverse,
+	}
+}
+
+func (it *badgerDBIterator) Domain() ([]byte, []byte) {
+	return it.start, it.end
+}
+
+func (it *badgerDBIterator) Valid() bool {
+	if !it.source.Valid() {
+		return false
+	}
+	key := it.source.Item().Key()
+	if it.reverse {
+		return it.start == nil || bytes.Compare(key, it.start) >= 0
+	}
+	return it.end == nil || bytes.Compare(key, it.end) < 0
+}
+
+func (it *badgerDBIterator) Next() {
+	if !it.Valid() {
+		panic("badgerDBIterator is invalid")
+	}
+	it.source.Next()
+}
+
+func (it *badgerDBIterator) Key() []byte {
+	if !it.Valid() {
+		panic("badgerDBIterator is invalid")
+	}
+	return it.source.Item().KeyCopy(nil)
+}
+
+func (it *badgerDBIterator) Value() []byte {
+	if !it.Valid() {
+		panic("badgerDBIterator is invalid")
+	}
+	val, err := it.source.Item().ValueCopy(nil)
+	if err != nil {
+		panic(err)
+	}
+	if val == nil {
+		val = []byte{}
+	}
+	return val
+}
+
+func (it *badgerDBIterator) Close() {
+	it.source.Close()
+	if it.ownsTxn {
+		it.txn.Discard()
+	}
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badgerdb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := LoadBadgerDB("test", dir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.Nil(t, db.Get([]byte("a")))
+	require.False(t, db.Has([]byte("a")))
+
+	db.Set([]byte("a"), []byte("1"))
+	db.Set([]byte("b"), []byte{})
+	require.Equal(t, []byte("1"), db.Get([]byte("a")))
+	require.Equal(t, []byte{}, db.Get([]byte("b")))
+	require.True(t, db.Has([]byte("b")))
+
+	batch := db.NewBatch()
+	batch.Set([]byte("c"), []byte("3"))
+	batch.Set([]byte("d"), []byte("4"))
+	batch.Delete([]byte("a"))
+	batch.Write()
+	require.Nil(t, db.Get([]byte("a")))
+	require.Equal(t, []byte("4"), db.Get([]byte("d")))
+
+	snap := db.GetSnapshot()
+	db.Delete([]byte("c"))
+	require.Nil(t, db.Get([]byte("c")))
+	require.Equal(t, []byte("3"), snap.Get([]byte("c")), "snapshot shouldn't see later changes")
+	snap.Release()
+
+	db.Set([]byte("c"), []byte("3"))
+	var keys []string
+	it := db.Iterator([]byte("b"), []byte("d"))
+	for ; it.Valid(); it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	it.Close()
+	require.Equal(t, []string{"b", "c"}, keys)
+
+	keys = nil
+	it = db.ReverseIterator([]byte("b"), []byte("d"))
+	for ; it.Valid(); it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	it.Close()
+	require.Equal(t, []string{"c", "b"}, keys)
+
+	keys = nil
+	it = db.ReverseIterator(nil, nil)
+	for ; it.Valid(); it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	it.Close()
+	require.Equal(t, []string{"d", "c", "b"}, keys)
+}
@@ -10,6 +10,7 @@ const (
 	GoLevelDBBackend = "goleveldb"
 	CLevelDBBackend  = "cleveldb"
 	MemDBackend      = "memdb"
+	BadgerDBBackend  = "badgerdb"
 )
 
 type DBWrapper interface {
@@ -35,6 +36,8 @@ func LoadDB(dbBackend, name, directory string, cacheSizeMegs int, collectMetrics
 		return LoadCLevelDB(name, directory)
 	case MemDBackend:
 		return LoadMemDB()
+	case BadgerDBBackend:
+		return LoadBadgerDB(name, directory)
 	default:
 		return nil, fmt.Errorf("unknown db backend: %s", dbBackend)
 	}
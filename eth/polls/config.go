@@ -0,0 +1,45 @@
+package polls
+
+const (
+	FilterStoreBackendDB    = "db"
+	FilterStoreBackendRedis = "redis"
+)
+
+// FilterStoreConfig controls where the filters created by eth_newFilter, eth_newBlockFilter, and
+// eth_newPendingTransactionFilter are kept.
+type FilterStoreConfig struct {
+	// If disabled filters are only kept in memory, so they're lost when the node restarts.
+	Enabled bool
+	// Backend used to store filters, "db" stores them in a DB in the node's data dir, "redis"
+	// stores them in a Redis server that may be shared by multiple nodes.
+	Backend string
+	// DBName defines the database file name (only used by the db backend)
+	DBName string
+	// DBBackend defines the database type (only used by the db backend)
+	// available backend types are 'goleveldb', 'cleveldb', or 'badgerdb'
+	DBBackend string
+	// URL of the Redis server (only used by the redis backend)
+	RedisURL string
+	// Number of seconds a filter remains valid after it was created or last polled
+	TTL int64
+}
+
+func DefaultFilterStoreConfig() *FilterStoreConfig {
+	return &FilterStoreConfig{
+		Enabled:   false,
+		Backend:   FilterStoreBackendDB,
+		DBName:    "ethfilters",
+		DBBackend: "goleveldb",
+		RedisURL:  "redis://127.0.0.1:6379",
+		TTL:       600,
+	}
+}
+
+// Clone returns a deep clone of the config.
+func (c *FilterStoreConfig) Clone() *FilterStoreConfig {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	return &clone
+}
@@ -4,6 +4,8 @@ package polls
 
 import (
 	"fmt"
+	"time"
+
 	"github.com/diademnetwork/diademchain/store"
 
 	"github.com/diademnetwork/diademchain"
@@ -27,6 +29,11 @@ type EthSubscriptions struct {
 	lastPoll   map[string]uint64
 	timestamps map[uint64][]string
 	lastPrune  uint64
+
+	// If set polls are kept in the store instead of in memory, and expire if they're not polled
+	// within the TTL.
+	store FilterStore
+	ttl   time.Duration
 }
 
 func NewEthSubscriptions() *EthSubscriptions {
@@ -38,15 +45,67 @@ func NewEthSubscriptions() *EthSubscriptions {
 	return p
 }
 
-func (s EthSubscriptions) Add(poll EthPoll, height uint64) string {
+// NewEthSubscriptionsWithStore creates a poll registry that persists polls to the given store,
+// so poll IDs remain valid across node restarts, and can be polled via any node that shares the
+// store.
+func NewEthSubscriptionsWithStore(store FilterStore, ttl time.Duration) *EthSubscriptions {
+	p := NewEthSubscriptions()
+	p.store = store
+	p.ttl = ttl
+	return p
+}
+
+func (s EthSubscriptions) Add(poll EthPoll, height uint64) (string, error) {
 	id := utils.GetId()
+	if s.store != nil {
+		if err := s.storePoll(id, poll); err != nil {
+			return "", err
+		}
+		return id, nil
+	}
+
 	s.polls[id] = poll
 
 	s.lastPoll[id] = height
 	s.timestamps[height] = append(s.timestamps[height], id)
 	s.pruneSubs(height)
 
-	return id
+	return id, nil
+}
+
+func (s EthSubscriptions) getPoll(id string) (EthPoll, error) {
+	if s.store == nil {
+		if poll, ok := s.polls[id]; ok {
+			return poll, nil
+		}
+		return nil, fmt.Errorf("subscription not found")
+	}
+	data, err := s.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("subscription not found")
+	}
+	return decodePoll(data)
+}
+
+// updatePoll saves the state of a poll after it has been polled at the given height.
+func (s EthSubscriptions) updatePoll(id string, poll EthPoll, height uint64) error {
+	if s.store != nil {
+		return s.storePoll(id, poll)
+	}
+	s.polls[id] = poll
+	s.resetTimestamp(id, height)
+	return nil
+}
+
+func (s EthSubscriptions) storePoll(id string, poll EthPoll) error {
+	data, err := encodePoll(poll)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(id, data, s.ttl)
 }
 
 func (s EthSubscriptions) pruneSubs(height uint64) {
@@ -76,7 +135,7 @@ func (s EthSubscriptions) AddLogPoll(filter eth.EthFilter, height uint64) (strin
 	return s.Add(&EthLogPoll{
 		filter:        filter,
 		lastBlockRead: uint64(0),
-	}, height), nil
+	}, height)
 }
 
 func (s EthSubscriptions) LegacyAddLogPoll(filter string, height uint64) (string, error) {
@@ -84,48 +143,54 @@ func (s EthSubscriptions) LegacyAddLogPoll(filter string, height uint64) (string
 	if err != nil {
 		return "", err
 	}
-	return s.Add(newPoll, height), nil
+	return s.Add(newPoll, height)
 }
 
-func (s EthSubscriptions) AddBlockPoll(height uint64) string {
+func (s EthSubscriptions) AddBlockPoll(height uint64) (string, error) {
 	return s.Add(NewEthBlockPoll(height), height)
 }
 
-func (s EthSubscriptions) AddTxPoll(height uint64) string {
+func (s EthSubscriptions) AddTxPoll(height uint64) (string, error) {
 	return s.Add(NewEthTxPoll(height), height)
 }
 
 func (s EthSubscriptions) AllLogs(blockStore store.BlockStore, state diademchain.ReadOnlyState, id string, readReceipts diademchain.ReadReceiptHandler) (interface{}, error) {
-	if poll, ok := s.polls[id]; !ok {
-		return nil, fmt.Errorf("subscription not found")
-	} else {
-		return poll.AllLogs(blockStore, state, id, readReceipts)
+	poll, err := s.getPoll(id)
+	if err != nil {
+		return nil, err
 	}
+	return poll.AllLogs(blockStore, state, id, readReceipts)
 }
 
 func (s EthSubscriptions) Poll(blockStore store.BlockStore, state diademchain.ReadOnlyState, id string, readReceipts diademchain.ReadReceiptHandler) (interface{}, error) {
-	if poll, ok := s.polls[id]; !ok {
-		return nil, fmt.Errorf("subscription not found")
-	} else {
-		newPoll, result, err := poll.Poll(blockStore, state, id, readReceipts)
-		s.polls[id] = newPoll
-		s.resetTimestamp(id, uint64(state.Block().Height))
-		return result, err
+	poll, err := s.getPoll(id)
+	if err != nil {
+		return nil, err
+	}
+	newPoll, result, err := poll.Poll(blockStore, state, id, readReceipts)
+	if updateErr := s.updatePoll(id, newPoll, uint64(state.Block().Height)); updateErr != nil {
+		return nil, updateErr
 	}
+	return result, err
 }
 
 func (s EthSubscriptions) LegacyPoll(blockStore store.BlockStore, state diademchain.ReadOnlyState, id string, readReceipts diademchain.ReadReceiptHandler) ([]byte, error) {
-	if poll, ok := s.polls[id]; !ok {
-		return nil, fmt.Errorf("subscription not found")
-	} else {
-		newPoll, result, err := poll.LegacyPoll(blockStore, state, id, readReceipts)
-		s.polls[id] = newPoll
-		s.resetTimestamp(id, uint64(state.Block().Height))
-		return result, err
+	poll, err := s.getPoll(id)
+	if err != nil {
+		return nil, err
 	}
+	newPoll, result, err := poll.LegacyPoll(blockStore, state, id, readReceipts)
+	if updateErr := s.updatePoll(id, newPoll, uint64(state.Block().Height)); updateErr != nil {
+		return nil, updateErr
+	}
+	return result, err
 }
 
-func (s EthSubscriptions) Remove(id string) {
+func (s EthSubscriptions) Remove(id string) error {
+	if s.store != nil {
+		return s.store.Delete(id)
+	}
 	delete(s.polls, id)
 	delete(s.lastPoll, id)
+	return nil
 }
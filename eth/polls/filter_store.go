@@ -0,0 +1,168 @@
+package polls
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+const (
+	// How often expired filters are removed from a DBFilterStore
+	filterPruneInterval = time.Minute
+	filterKeyPrefix     = "filter:"
+)
+
+// FilterStore persists the state of filters, so that filter IDs remain valid across node restarts,
+// and can be polled via any node that has access to the store.
+type FilterStore interface {
+	// Get returns the filter with the given ID, or nil if the filter doesn't exist or has expired.
+	Get(id string) ([]byte, error)
+	// Put stores the filter with the given ID, the filter expires unless it's stored again within
+	// the given TTL.
+	Put(id string, filter []byte, ttl time.Duration) error
+	Delete(id string) error
+}
+
+// DBFilterStore keeps filters in a DB, the expiry time of each filter is stored along with it.
+type DBFilterStore struct {
+	db dbm.DB
+
+	mutex     sync.Mutex
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+var _ FilterStore = &DBFilterStore{}
+
+func NewDBFilterStore(db dbm.DB) *DBFilterStore {
+	return &DBFilterStore{
+		db:  db,
+		now: time.Now,
+	}
+}
+
+func (s *DBFilterStore) Get(id string) ([]byte, error) {
+	key := filterKey(id)
+	value := s.db.Get(key)
+	if value == nil {
+		return nil, nil
+	}
+	expiry, filter, err := decodeDBFilter(value)
+	if err != nil {
+		return nil, err
+	}
+	if !s.now().Before(expiry) {
+		s.db.Delete(key)
+		return nil, nil
+	}
+	return filter, nil
+}
+
+func (s *DBFilterStore) Put(id string, filter []byte, ttl time.Duration) error {
+	now := s.now()
+	value := make([]byte, 8, 8+len(filter))
+	binary.BigEndian.PutUint64(value, uint64(now.Add(ttl).UnixNano()))
+	s.db.Set(filterKey(id), append(value, filter...))
+	s.prune(now)
+	return nil
+}
+
+func (s *DBFilterStore) Delete(id string) error {
+	s.db.Delete(filterKey(id))
+	return nil
+}
+
+// prune removes expired filters, filters are removed at most once per prune interval.
+func (s *DBFilterStore) prune(now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if now.Sub(s.lastPrune) < filterPruneInterval {
+		return
+	}
+	s.lastPrune = now
+
+	var expired [][]byte
+	it := dbm.IteratePrefix(s.db, []byte(filterKeyPrefix))
+	for ; it.Valid(); it.Next() {
+		expiry, _, err := decodeDBFilter(it.Value())
+		if err != nil || !now.Before(expiry) {
+			expired = append(expired, it.Key())
+		}
+	}
+	it.Close()
+
+	for _, key := range expired {
+		s.db.Delete(key)
+	}
+}
+
+func filterKey(id string) []byte {
+	return []byte(filterKeyPrefix + id)
+}
+
+func decodeDBFilter(value []byte) (time.Time, []byte, error) {
+	if len(value) < 8 {
+		return time.Time{}, nil, errors.New("malformed filter")
+	}
+	expiry := time.Unix(0, int64(binary.BigEndian.Uint64(value[:8])))
+	return expiry, value[8:], nil
+}
+
+// RedisFilterStore keeps filters in a Redis server, which allows multiple nodes to share filters.
+// Redis takes care of removing expired filters.
+type RedisFilterStore struct {
+	pool *redis.Pool
+}
+
+var _ FilterStore = &RedisFilterStore{}
+
+func NewRedisFilterStore(url string) *RedisFilterStore {
+	return &RedisFilterStore{
+		pool: &redis.Pool{
+			MaxIdle:     10,
+			IdleTimeout: 4 * time.Minute,
+			Dial: func() (redis.Conn, error) {
+				return redis.DialURL(url)
+			},
+		},
+	}
+}
+
+func (s *RedisFilterStore) Get(id string) ([]byte, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	filter, err := redis.Bytes(conn.Do("GET", filterKey(id)))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load filter from Redis")
+	}
+	return filter, nil
+}
+
+func (s *RedisFilterStore) Put(id string, filter []byte, ttl time.Duration) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("SET", filterKey(id), filter, "PX", int64(ttl/time.Millisecond)); err != nil {
+		return errors.Wrap(err, "failed to store filter in Redis")
+	}
+	return nil
+}
+
+func (s *RedisFilterStore) Delete(id string) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("DEL", filterKey(id)); err != nil {
+		return errors.Wrap(err, "failed to delete filter from Redis")
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package polls
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+func TestDBFilterStoreExpiry(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewDBFilterStore(dbm.NewMemDB())
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.Put("1", []byte("filter1"), 30*time.Second))
+	require.NoError(t, s.Put("2", []byte("filter2"), 2*time.Minute))
+	filter, err := s.Get("1")
+	require.NoError(t, err)
+	require.Equal(t, []byte("filter1"), filter)
+
+	// expired filters can't be loaded
+	now = now.Add(time.Minute)
+	filter, err = s.Get("1")
+	require.NoError(t, err)
+	require.Nil(t, filter)
+
+	// storing a filter again resets the TTL
+	require.NoError(t, s.Put("2", []byte("filter2b"), 2*time.Minute))
+	now = now.Add(90 * time.Second)
+	filter, err = s.Get("2")
+	require.NoError(t, err)
+	require.Equal(t, []byte("filter2b"), filter)
+
+	// expired filters are pruned from the DB periodically
+	require.NoError(t, s.Put("3", []byte("filter3"), 30*time.Second))
+	now = now.Add(5 * time.Minute)
+	require.NoError(t, s.Put("4", []byte("filter4"), 30*time.Second))
+	require.False(t, s.db.Has(filterKey("2")))
+	require.False(t, s.db.Has(filterKey("3")))
+	require.True(t, s.db.Has(filterKey("4")))
+
+	require.NoError(t, s.Delete("4"))
+	filter, err = s.Get("4")
+	require.NoError(t, err)
+	require.Nil(t, filter)
+}
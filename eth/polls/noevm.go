@@ -3,6 +3,8 @@
 package polls
 
 import (
+	"time"
+
 	"github.com/diademnetwork/diademchain"
 	"github.com/diademnetwork/diademchain/rpc/eth"
 	"github.com/diademnetwork/diademchain/store"
@@ -15,19 +17,20 @@ func (s EthSubscriptions) LegacyAddLogPoll(_ string, _ uint64) (string, error) {
 	return "", nil
 }
 
-func (s EthSubscriptions) AddBlockPoll(_ uint64) string {
-	return ""
+func (s EthSubscriptions) AddBlockPoll(_ uint64) (string, error) {
+	return "", nil
 }
 
-func (s EthSubscriptions) AddTxPoll(_ uint64) string {
-	return ""
+func (s EthSubscriptions) AddTxPoll(_ uint64) (string, error) {
+	return "", nil
 }
 
 func (s *EthSubscriptions) LegacyPoll(_ store.BlockStore, _ diademchain.ReadOnlyState, _ string, _ diademchain.ReadReceiptHandler) ([]byte, error) {
 	return nil, nil
 }
 
-func (s *EthSubscriptions) Remove(_ string) {
+func (s *EthSubscriptions) Remove(_ string) error {
+	return nil
 }
 
 func (s EthSubscriptions) Poll(_ store.BlockStore, _ diademchain.ReadOnlyState, _ string, _ diademchain.ReadReceiptHandler) (interface{}, error) {
@@ -45,3 +48,7 @@ func (s EthSubscriptions) AddLogPoll(_ eth.EthFilter, _ uint64) (string, error)
 func NewEthSubscriptions() *EthSubscriptions {
 	return &EthSubscriptions{}
 }
+
+func NewEthSubscriptionsWithStore(_ FilterStore, _ time.Duration) *EthSubscriptions {
+	return &EthSubscriptions{}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/diademnetwork/diademchain/events"
 	"github.com/diademnetwork/diademchain/store"
@@ -19,6 +20,7 @@ import (
 	"github.com/diademnetwork/diademchain/receipts/handler"
 	"github.com/diademnetwork/diademchain/receipts/leveldb"
 	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tendermint/libs/db"
 )
 
 var (
@@ -117,7 +119,8 @@ func testLegacyTxPoll(t *testing.T, version handler.ReceiptHandlerVersion) {
 
 	sub := NewEthSubscriptions()
 	state := makeMockState(t, receiptHandler)
-	id := sub.AddTxPoll(uint64(5))
+	id, err := sub.AddTxPoll(uint64(5))
+	require.NoError(t, err)
 
 	blockStore := store.NewMockBlockStore()
 	var envolope types.EthFilterEnvelope
@@ -155,7 +158,8 @@ func testTxPoll(t *testing.T, version handler.ReceiptHandlerVersion) {
 
 	sub := NewEthSubscriptions()
 	state := makeMockState(t, receiptHandler)
-	id := sub.AddTxPoll(uint64(5))
+	id, err := sub.AddTxPoll(uint64(5))
+	require.NoError(t, err)
 
 	blockStore := store.NewMockBlockStore()
 
@@ -215,6 +219,52 @@ func TestTimeout(t *testing.T) {
 	testTimeout(t, handler.ReceiptHandlerLevelDb)
 }
 
+func TestPersistentPolls(t *testing.T) {
+	eventDispatcher := events.NewLogEventDispatcher()
+	eventHandler := diademchain.NewDefaultEventHandler(eventDispatcher)
+	receiptHandler, err := handler.NewReceiptHandler(handler.ReceiptHandlerChain, eventHandler, handler.DefaultMaxReceipts)
+	require.NoError(t, err)
+
+	filterStore := NewDBFilterStore(dbm.NewMemDB())
+	sub1 := NewEthSubscriptionsWithStore(filterStore, time.Minute)
+	state := makeMockState(t, receiptHandler)
+	txPollID, err := sub1.AddTxPoll(uint64(5))
+	require.NoError(t, err)
+	myFilter, err := eth.DecLogFilter(eth.JsonFilter{FromBlock: "0x0", ToBlock: "latest"})
+	require.NoError(t, err)
+	logPollID, err := sub1.AddLogPoll(myFilter, 1)
+	require.NoError(t, err)
+
+	blockStore := store.NewMockBlockStore()
+	state27 := common.MockStateAt(state, uint64(27))
+	result, err := sub1.Poll(blockStore, state27, txPollID, receiptHandler)
+	require.NoError(t, err)
+	data, ok := result.([]eth.Data)
+	require.True(t, ok)
+	require.Equal(t, 2, len(data), "wrong number of logs returned")
+
+	// a different registry sharing the same store (e.g. after a restart) should pick up where the
+	// previous one left off
+	sub2 := NewEthSubscriptionsWithStore(filterStore, time.Minute)
+	state50 := common.MockStateAt(state, uint64(50))
+	result, err = sub2.Poll(blockStore, state50, txPollID, receiptHandler)
+	require.NoError(t, err)
+	data, ok = result.([]eth.Data)
+	require.True(t, ok)
+	require.Equal(t, 1, len(data), "wrong number of logs returned")
+
+	result, err = sub2.Poll(blockStore, state50, logPollID, receiptHandler)
+	require.NoError(t, err)
+	logs, ok := result.([]eth.JsonLog)
+	require.True(t, ok)
+	require.Equal(t, 4, len(logs), "wrong number of logs returned")
+
+	require.NoError(t, sub2.Remove(txPollID))
+	_, err = sub1.Poll(blockStore, state50, txPollID, receiptHandler)
+	require.Error(t, err, "subscription not removed")
+	require.NoError(t, receiptHandler.Close())
+}
+
 func testTimeout(t *testing.T, version handler.ReceiptHandlerVersion) {
 	eventDispatcher := events.NewLogEventDispatcher()
 	eventHandler := diademchain.NewDefaultEventHandler(eventDispatcher)
@@ -228,10 +278,12 @@ func testTimeout(t *testing.T, version handler.ReceiptHandlerVersion) {
 
 	var envolope types.EthFilterEnvelope
 	var txHashes *types.EthTxHashList
-	id := sub.AddTxPoll(uint64(1))
+	id, err := sub.AddTxPoll(uint64(1))
+	require.NoError(t, err)
 
 	state5 := common.MockStateAt(state, uint64(5))
-	_ = sub.AddTxPoll(uint64(5))
+	_, err = sub.AddTxPoll(uint64(5))
+	require.NoError(t, err)
 
 	blockStore := store.NewMockBlockStore()
 	result, err := sub.LegacyPoll(blockStore, state5, id, receiptHandler)
@@ -242,7 +294,8 @@ func testTimeout(t *testing.T, version handler.ReceiptHandlerVersion) {
 	require.Equal(t, 1, len(txHashes.EthTxHash), "wrong number of logs returned")
 
 	state12 := common.MockStateAt(state, uint64(12))
-	_ = sub.AddTxPoll(uint64(12))
+	_, err = sub.AddTxPoll(uint64(12))
+	require.NoError(t, err)
 
 	result, err = sub.LegacyPoll(blockStore, state12, id, receiptHandler)
 	require.NoError(t, err)
@@ -252,7 +305,8 @@ func testTimeout(t *testing.T, version handler.ReceiptHandlerVersion) {
 	require.Equal(t, 0, len(txHashes.EthTxHash), "wrong number of logs returned")
 
 	state40 := common.MockStateAt(state, uint64(40))
-	_ = sub.AddTxPoll(uint64(40))
+	_, err = sub.AddTxPoll(uint64(40))
+	require.NoError(t, err)
 
 	result, err = sub.LegacyPoll(blockStore, state40, id, receiptHandler)
 	require.Error(t, err, "poll did not timed out")
@@ -0,0 +1,88 @@
+// +build evm
+
+package polls
+
+import (
+	"encoding/json"
+
+	"github.com/diademnetwork/go-diadem"
+	"github.com/pkg/errors"
+
+	"github.com/diademnetwork/diademchain/rpc/eth"
+)
+
+const (
+	storedLogPoll   = "logs"
+	storedBlockPoll = "blocks"
+	storedTxPoll    = "txs"
+)
+
+// storedPoll is the form in which polls are persisted to a FilterStore.
+type storedPoll struct {
+	Type       string     `json:"type"`
+	Addresses  [][]byte   `json:"addresses,omitempty"`
+	Topics     [][]string `json:"topics,omitempty"`
+	FromBlock  string     `json:"fromBlock,omitempty"`
+	ToBlock    string     `json:"toBlock,omitempty"`
+	StartBlock uint64     `json:"startBlock,omitempty"`
+	LastBlock  uint64     `json:"lastBlock"`
+}
+
+func encodePoll(poll EthPoll) ([]byte, error) {
+	var sp storedPoll
+	switch p := poll.(type) {
+	case *EthLogPoll:
+		sp.Type = storedLogPoll
+		for _, addr := range p.filter.Addresses {
+			sp.Addresses = append(sp.Addresses, []byte(addr))
+		}
+		sp.Topics = p.filter.Topics
+		sp.FromBlock = string(p.filter.FromBlock)
+		sp.ToBlock = string(p.filter.ToBlock)
+		sp.LastBlock = p.lastBlockRead
+	case *EthBlockPoll:
+		sp.Type = storedBlockPoll
+		sp.StartBlock = p.startBlock
+		sp.LastBlock = p.lastBlock
+	case *EthTxPoll:
+		sp.Type = storedTxPoll
+		sp.StartBlock = p.startBlock
+		sp.LastBlock = p.lastBlockRead
+	default:
+		return nil, errors.Errorf("unsupported poll type %T", poll)
+	}
+	return json.Marshal(&sp)
+}
+
+func decodePoll(data []byte) (EthPoll, error) {
+	var sp storedPoll
+	if err := json.Unmarshal(data, &sp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode poll")
+	}
+	switch sp.Type {
+	case storedLogPoll:
+		filter := eth.EthFilter{
+			EthBlockFilter: eth.EthBlockFilter{Topics: sp.Topics},
+			FromBlock:      eth.BlockHeight(sp.FromBlock),
+			ToBlock:        eth.BlockHeight(sp.ToBlock),
+		}
+		for _, addr := range sp.Addresses {
+			filter.Addresses = append(filter.Addresses, diadem.LocalAddress(addr))
+		}
+		return &EthLogPoll{
+			filter:        filter,
+			lastBlockRead: sp.LastBlock,
+		}, nil
+	case storedBlockPoll:
+		return &EthBlockPoll{
+			startBlock: sp.StartBlock,
+			lastBlock:  sp.LastBlock,
+		}, nil
+	case storedTxPoll:
+		return &EthTxPoll{
+			startBlock:    sp.StartBlock,
+			lastBlockRead: sp.LastBlock,
+		}, nil
+	}
+	return nil, errors.Errorf("unsupported poll type %s", sp.Type)
+}
@@ -3,6 +3,7 @@
 package query
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/diademnetwork/diademchain/eth/bdiadem"
@@ -21,6 +22,15 @@ import (
 func QueryChain(
 	blockStore store.BlockStore, state diademchain.ReadOnlyState, ethFilter eth.EthFilter,
 	readReceipts diademchain.ReadReceiptHandler,
+) ([]*ptypes.EthFilterLog, error) {
+	return QueryChainWithLimit(context.Background(), blockStore, state, ethFilter, readReceipts, 0)
+}
+
+// QueryChainWithLimit works like QueryChain but stops scanning blocks and returns an error as soon
+// as more than maxResults logs match the filter (zero means no limit), or the context is cancelled.
+func QueryChainWithLimit(
+	ctx context.Context, blockStore store.BlockStore, state diademchain.ReadOnlyState, ethFilter eth.EthFilter,
+	readReceipts diademchain.ReadReceiptHandler, maxResults uint64,
 ) ([]*ptypes.EthFilterLog, error) {
 	start, err := eth.DecBlockHeight(state.Block().Height, eth.BlockHeight(ethFilter.FromBlock))
 	if err != nil {
@@ -31,7 +41,7 @@ func QueryChain(
 		return nil, err
 	}
 
-	return GetBlockLogRange(blockStore, state, start, end, ethFilter.EthBlockFilter, readReceipts)
+	return getBlockLogRange(ctx, blockStore, state, start, end, ethFilter.EthBlockFilter, readReceipts, maxResults)
 }
 
 func DeprecatedQueryChain(
@@ -65,6 +75,18 @@ func GetBlockLogRange(
 	from, to uint64,
 	ethFilter eth.EthBlockFilter,
 	readReceipts diademchain.ReadReceiptHandler,
+) ([]*ptypes.EthFilterLog, error) {
+	return getBlockLogRange(context.Background(), blockStore, state, from, to, ethFilter, readReceipts, 0)
+}
+
+func getBlockLogRange(
+	ctx context.Context,
+	blockStore store.BlockStore,
+	state diademchain.ReadOnlyState,
+	from, to uint64,
+	ethFilter eth.EthBlockFilter,
+	readReceipts diademchain.ReadReceiptHandler,
+	maxResults uint64,
 ) ([]*ptypes.EthFilterLog, error) {
 	if from > to {
 		return nil, fmt.Errorf("to block before end block")
@@ -72,11 +94,17 @@ func GetBlockLogRange(
 	eventLogs := []*ptypes.EthFilterLog{}
 
 	for height := from; height <= to; height++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		blockLogs, err := GetBlockLogs(blockStore, state, ethFilter, height, readReceipts)
 		if err != nil {
 			return nil, err
 		}
 		eventLogs = append(eventLogs, blockLogs...)
+		if maxResults > 0 && uint64(len(eventLogs)) > maxResults {
+			return nil, fmt.Errorf("query returned more than %v results", maxResults)
+		}
 	}
 	return eventLogs, nil
 }
@@ -3,6 +3,8 @@
 package query
 
 import (
+	"context"
+
 	"github.com/diademnetwork/go-diadem/plugin/types"
 	"github.com/diademnetwork/diademchain"
 	"github.com/diademnetwork/diademchain/rpc/eth"
@@ -60,3 +62,9 @@ func QueryChain(
 ) ([]*types.EthFilterLog, error) {
 	return nil, nil
 }
+
+func QueryChainWithLimit(
+	_ context.Context, _ store.BlockStore, _ diademchain.ReadOnlyState, _ eth.EthFilter, _ diademchain.ReadReceiptHandler, _ uint64,
+) ([]*types.EthFilterLog, error) {
+	return nil, nil
+}
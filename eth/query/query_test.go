@@ -4,6 +4,7 @@ package query
 
 import (
 	"bytes"
+	"context"
 	"os"
 	"testing"
 
@@ -101,6 +102,16 @@ func testQueryChain(t *testing.T, v handler.ReceiptHandlerVersion) {
 	require.NoError(t, err, "error query chain, filter is %s", ethFilter)
 	require.Equal(t, 2, len(filterLogs), "wrong number of logs returned")
 
+	filterLogs, err = QueryChainWithLimit(context.Background(), blockStore, state30, ethFilter, receiptHandler, 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(filterLogs), "wrong number of logs returned")
+	_, err = QueryChainWithLimit(context.Background(), blockStore, state30, ethFilter, receiptHandler, 1)
+	require.Error(t, err, "scan should stop once the max number of results is exceeded")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = QueryChainWithLimit(ctx, blockStore, state30, ethFilter, receiptHandler, 0)
+	require.Equal(t, context.Canceled, err)
+
 	require.NoError(t, receiptHandler.Close())
 }
 
@@ -115,6 +115,88 @@ func (pt *pendingTxsResetHub) emitTxEvent(txHash []byte) (err error) {
 	return nil
 }
 
+type fullPendingTxsResetHub struct {
+	ethResetHub
+}
+
+func newFullPendingTxsResetHub() *fullPendingTxsResetHub {
+	hub := newEthResetHub()
+	return &fullPendingTxsResetHub{
+		ethResetHub: *hub,
+	}
+}
+
+// Subscribers are added by the websocket handler while events are emitted by the mempool poller,
+// so the hub must be locked when it's accessed.
+func (pt *fullPendingTxsResetHub) addSubscriber(conn *websocket.Conn) string {
+	id := utils.GetId()
+	sub := newTopicSubscriber(pt, id, NewPendingTransactions, conn)
+	pt.mutex.Lock()
+	pt.clients[id] = sub
+	pt.unsent[id] = true
+	pt.mutex.Unlock()
+	return id
+}
+
+func (pt *fullPendingTxsResetHub) hasSubscribers() bool {
+	pt.mutex.RLock()
+	defer pt.mutex.RUnlock()
+	return len(pt.clients) > 0
+}
+
+func (pt *fullPendingTxsResetHub) emitTxEvent(tx eth.JsonTxObject) (err error) {
+	if pt.hasSubscribers() {
+		txRawJson, err := json.Marshal(&tx)
+		if err != nil {
+			return errors.Wrapf(err, "json marshaling tx %v", tx.Hash)
+		}
+		pt.Reset()
+		pt.Publish(pubsub.NewMessage(NewPendingTransactions, txRawJson))
+	}
+	return nil
+}
+
+type syncingResetHub struct {
+	ethResetHub
+}
+
+func newSyncingResetHub() *syncingResetHub {
+	hub := newEthResetHub()
+	return &syncingResetHub{
+		ethResetHub: *hub,
+	}
+}
+
+// Subscribers are added by the websocket handler while events are emitted by the sync status
+// poller, so the hub must be locked when it's accessed.
+func (sh *syncingResetHub) addSubscriber(conn *websocket.Conn) string {
+	id := utils.GetId()
+	sub := newTopicSubscriber(sh, id, Syncing, conn)
+	sh.mutex.Lock()
+	sh.clients[id] = sub
+	sh.unsent[id] = true
+	sh.mutex.Unlock()
+	return id
+}
+
+func (sh *syncingResetHub) hasSubscribers() bool {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	return len(sh.clients) > 0
+}
+
+func (sh *syncingResetHub) emitSyncingEvent(status eth.JsonSyncStatus) (err error) {
+	if sh.hasSubscribers() {
+		statusRawJson, err := json.Marshal(&status)
+		if err != nil {
+			return errors.Wrapf(err, "json marshaling sync status %v", status)
+		}
+		sh.Reset()
+		sh.Publish(pubsub.NewMessage(Syncing, statusRawJson))
+	}
+	return nil
+}
+
 type logsResetHub struct {
 	ethResetHub
 	lMutex *sync.RWMutex
@@ -12,21 +12,29 @@ import (
 )
 
 type EthSubscriptionSet struct {
-	logsHub      logsResetHub
-	newHeadsHub  headsResetHub
-	pendingTxHub pendingTxsResetHub
+	logsHub          logsResetHub
+	newHeadsHub      headsResetHub
+	pendingTxHub     pendingTxsResetHub
+	fullPendingTxHub fullPendingTxsResetHub
+	syncingHub       syncingResetHub
 }
 
 func NewEthSubscriptionSet() *EthSubscriptionSet {
 	s := &EthSubscriptionSet{
-		logsHub:      *newLogsResetHubResetHub(),
-		newHeadsHub:  *newHeadsResetHub(),
-		pendingTxHub: *newPendingTxsResetHub(),
+		logsHub:          *newLogsResetHubResetHub(),
+		newHeadsHub:      *newHeadsResetHub(),
+		pendingTxHub:     *newPendingTxsResetHub(),
+		fullPendingTxHub: *newFullPendingTxsResetHub(),
+		syncingHub:       *newSyncingResetHub(),
 	}
 	return s
 }
 
-func (s *EthSubscriptionSet) AddSubscription(method string, filter eth.EthFilter, conn *websocket.Conn) (string, error) {
+// AddSubscription subscribes the given websocket connection to the specified events, when
+// fullTxs is set newPendingTransactions subscribers receive full tx objects rather than tx hashes.
+func (s *EthSubscriptionSet) AddSubscription(
+	method string, filter eth.EthFilter, fullTxs bool, conn *websocket.Conn,
+) (string, error) {
 	var id string
 	switch method {
 	case Logs:
@@ -34,9 +42,13 @@ func (s *EthSubscriptionSet) AddSubscription(method string, filter eth.EthFilter
 	case NewHeads:
 		id = s.newHeadsHub.addSubscriber(conn)
 	case NewPendingTransactions:
-		id = s.pendingTxHub.addSubscriber(conn)
+		if fullTxs {
+			id = s.fullPendingTxHub.addSubscriber(conn)
+		} else {
+			id = s.pendingTxHub.addSubscriber(conn)
+		}
 	case Syncing:
-		return "", fmt.Errorf("syncing not supported")
+		id = s.syncingHub.addSubscriber(conn)
 	default:
 		return "", fmt.Errorf("unrecognised method %s", method)
 	}
@@ -51,6 +63,22 @@ func (s *EthSubscriptionSet) EmitTxEvent(txHash []byte) (err error) {
 	return s.pendingTxHub.emitTxEvent(txHash)
 }
 
+// EmitPendingTxEvent sends a tx that has been added to the mempool to subscribers that want full
+// tx objects.
+func (s *EthSubscriptionSet) EmitPendingTxEvent(tx eth.JsonTxObject) error {
+	return s.fullPendingTxHub.emitTxEvent(tx)
+}
+
+// HasPendingTxSubscribers returns true if there are any subscribers that want full tx objects.
+func (s *EthSubscriptionSet) HasPendingTxSubscribers() bool {
+	return s.fullPendingTxHub.hasSubscribers()
+}
+
+// EmitSyncingEvent sends the given sync status to syncing subscribers.
+func (s *EthSubscriptionSet) EmitSyncingEvent(status eth.JsonSyncStatus) error {
+	return s.syncingHub.emitSyncingEvent(status)
+}
+
 func (s *EthSubscriptionSet) EmitEvent(data types.EventData) error {
 	ethMsg, err := proto.Marshal(&data)
 	if err != nil {
@@ -68,6 +96,8 @@ func (s *EthSubscriptionSet) Remove(id string) {
 	s.logsHub.closeSubscription(id)
 	s.newHeadsHub.closeSubscription(id)
 	s.pendingTxHub.closeSubscription(id)
+	s.fullPendingTxHub.closeSubscription(id)
+	s.syncingHub.closeSubscription(id)
 }
 
 func (s *EthSubscriptionSet) GetFilter(id string) (*eth.EthFilter, error) {
@@ -4,6 +4,9 @@ const (
 	DispatcherDBIndexer = "db_indexer"
 	DispatcherRedis     = "redis"
 	DispatcherLog       = "log"
+	DispatcherWebhook   = "webhook"
+	DispatcherNATS      = "nats"
+	DispatcherKafka     = "kafka"
 )
 
 type EventStoreConfig struct {
@@ -33,6 +36,11 @@ func (c *EventStoreConfig) Clone() *EventStoreConfig {
 type EventDispatcherConfig struct {
 	Dispatcher string
 	Redis      *RedisEventDispatcherConfig
+	Webhook    *WebhookEventSinkConfig
+	NATS       *NATSEventSinkConfig
+	Kafka      *KafkaEventSinkConfig
+	// Batching, retries, and queueing of events for the webhook, NATS, and Kafka dispatchers
+	Delivery *EventDeliveryConfig
 }
 
 func DefaultEventDispatcherConfig() *EventDispatcherConfig {
@@ -41,6 +49,21 @@ func DefaultEventDispatcherConfig() *EventDispatcherConfig {
 		Redis: &RedisEventDispatcherConfig{
 			URI: "127.0.0.1",
 		},
+		Webhook: &WebhookEventSinkConfig{
+			URL:     "http://127.0.0.1:8080/events",
+			Timeout: 10,
+		},
+		NATS: &NATSEventSinkConfig{
+			URL:     "nats://127.0.0.1:4222",
+			Subject: "diademchain.events",
+			Timeout: 10,
+		},
+		Kafka: &KafkaEventSinkConfig{
+			Brokers: "127.0.0.1:9092",
+			Topic:   "diademchain-events",
+			Timeout: 10,
+		},
+		Delivery: DefaultEventDeliveryConfig(),
 	}
 }
 
@@ -50,6 +73,22 @@ func (c *EventDispatcherConfig) Clone() *EventDispatcherConfig {
 		return nil
 	}
 	clone := *c
-	*clone.Redis = *c.Redis
+	if c.Redis != nil {
+		redis := *c.Redis
+		clone.Redis = &redis
+	}
+	if c.Webhook != nil {
+		webhook := *c.Webhook
+		clone.Webhook = &webhook
+	}
+	if c.NATS != nil {
+		nats := *c.NATS
+		clone.NATS = &nats
+	}
+	if c.Kafka != nil {
+		kafka := *c.Kafka
+		clone.Kafka = &kafka
+	}
+	clone.Delivery = c.Delivery.Clone()
 	return &clone
 }
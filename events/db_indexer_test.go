@@ -206,27 +206,30 @@ func TestDBIndexerBatchWriting(t *testing.T) {
 	dispatcher.Flush()
 
 	//check batch1 data
-	eventsData, err := eventStore.FilterEvents(store.EventFilter{FromBlock: 1, ToBlock: 1})
-	require.Equal(t, 10, len(eventsData), "The length of data does not match")
+	result, err := eventStore.FilterEvents(store.EventFilter{FromBlock: 1, ToBlock: 1})
 	require.Nil(t, err)
+	eventsData := result.Events
+	require.Equal(t, 10, len(eventsData), "The length of data does not match")
 	for _, event := range eventsData {
 		require.Equal(t, "plugin1", event.PluginName)
 		require.Equal(t, uint64(1), event.BlockHeight)
 	}
 
 	//check batch2 data
-	eventsData, err = eventStore.FilterEvents(store.EventFilter{FromBlock: 2, ToBlock: 2})
-	require.Equal(t, 20, len(eventsData), "The length of data does not match")
+	result, err = eventStore.FilterEvents(store.EventFilter{FromBlock: 2, ToBlock: 2})
 	require.Nil(t, err)
+	eventsData = result.Events
+	require.Equal(t, 20, len(eventsData), "The length of data does not match")
 	for _, event := range eventsData {
 		require.Equal(t, "plugin2", event.PluginName)
 		require.Equal(t, uint64(2), event.BlockHeight)
 	}
 
 	//check batch3 data
-	eventsData, err = eventStore.FilterEvents(store.EventFilter{FromBlock: 3, ToBlock: 3})
-	require.Equal(t, len(eventsData), 30, "The length of data does not match")
+	result, err = eventStore.FilterEvents(store.EventFilter{FromBlock: 3, ToBlock: 3})
 	require.Nil(t, err)
+	eventsData = result.Events
+	require.Equal(t, len(eventsData), 30, "The length of data does not match")
 	for _, event := range eventsData {
 		require.Equal(t, "plugin3", event.PluginName)
 		require.Equal(t, uint64(3), event.BlockHeight)
@@ -0,0 +1,118 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/diademnetwork/diademchain/log"
+	"github.com/pkg/errors"
+)
+
+type KafkaEventSinkConfig struct {
+	// Comma separated list of Kafka broker addresses, e.g. "127.0.0.1:9092"
+	Brokers string
+	// Topic events will be published to
+	Topic string
+	// How long to wait for the brokers to acknowledge a batch of events (in seconds)
+	Timeout int64
+}
+
+// KafkaEventSink publishes events to a Kafka topic (or any other broker that speaks the Kafka
+// protocol), each event is published as a separate message keyed by the block height. The batch
+// is considered delivered once all the in-sync replicas have acknowledged all the messages.
+type KafkaEventSink struct {
+	brokers  []string
+	topic    string
+	kafkaCfg *sarama.Config
+
+	mutex    sync.RWMutex
+	producer sarama.SyncProducer
+	closed   bool
+	quit     chan struct{}
+}
+
+var _ EventSink = &KafkaEventSink{}
+
+// NewKafkaEventSink creates a sink that publishes events to the given Kafka topic. If none of the
+// brokers can be reached the sink will keep trying to connect in the background.
+func NewKafkaEventSink(cfg *KafkaEventSinkConfig) (*KafkaEventSink, error) {
+	if cfg.Topic == "" {
+		return nil, fmt.Errorf("Kafka topic not specified")
+	}
+	kafkaCfg := sarama.NewConfig()
+	kafkaCfg.Producer.RequiredAcks = sarama.WaitForAll
+	kafkaCfg.Producer.Return.Successes = true
+	kafkaCfg.Producer.Timeout = time.Duration(cfg.Timeout) * time.Second
+	// the dispatcher takes care of retries
+	kafkaCfg.Producer.Retry.Max = 0
+	// keep events from the same block in order
+	kafkaCfg.Producer.Partitioner = sarama.NewHashPartitioner
+	s := &KafkaEventSink{
+		brokers:  strings.Split(cfg.Brokers, ","),
+		topic:    cfg.Topic,
+		kafkaCfg: kafkaCfg,
+		quit:     make(chan struct{}),
+	}
+	if err := s.connect(); err != nil {
+		log.Error("Failed to connect to Kafka brokers, will retry in the background", "brokers", cfg.Brokers, "err", err)
+		connectInBackground(s.Name(), s.connect, s.quit)
+	}
+	return s, nil
+}
+
+func (s *KafkaEventSink) connect() error {
+	producer, err := sarama.NewSyncProducer(s.brokers, s.kafkaCfg)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return producer.Close()
+	}
+	s.producer = producer
+	return nil
+}
+
+func (s *KafkaEventSink) Name() string {
+	return DispatcherKafka
+}
+
+func (s *KafkaEventSink) Deliver(events []*SinkEvent) error {
+	s.mutex.RLock()
+	producer := s.producer
+	s.mutex.RUnlock()
+
+	if producer == nil {
+		return errors.Errorf("not connected to Kafka brokers %s", strings.Join(s.brokers, ","))
+	}
+	msgs := make([]*sarama.ProducerMessage, 0, len(events))
+	for _, event := range events {
+		msgs = append(msgs, &sarama.ProducerMessage{
+			Topic: s.topic,
+			Key:   sarama.StringEncoder(fmt.Sprint(event.BlockHeight)),
+			Value: sarama.ByteEncoder(event.Data),
+		})
+	}
+	return producer.SendMessages(msgs)
+}
+
+func (s *KafkaEventSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.quit)
+	if s.producer != nil {
+		return s.producer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+func TestKafkaEventSink(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("diademchain-events", 0, broker.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t),
+	})
+
+	sink, err := NewKafkaEventSink(&KafkaEventSinkConfig{
+		Brokers: broker.Addr(),
+		Topic:   "diademchain-events",
+		Timeout: 5,
+	})
+	require.NoError(t, err)
+	d, err := NewSinkEventDispatcher(sink, dbm.NewMemDB(), testDeliveryConfig())
+	require.NoError(t, err)
+	defer d.Close()
+
+	sendTestEvents(t, d, 1, 5)
+	waitFor(t, func() bool {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		return d.queueSize == 0
+	})
+	numProduceRequests := 0
+	for _, rr := range broker.History() {
+		if _, ok := rr.Request.(*sarama.ProduceRequest); ok {
+			numProduceRequests++
+		}
+	}
+	require.True(t, numProduceRequests > 0)
+}
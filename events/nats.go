@@ -0,0 +1,109 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/diademnetwork/diademchain/log"
+	nats "github.com/nats-io/go-nats"
+	"github.com/pkg/errors"
+)
+
+type NATSEventSinkConfig struct {
+	// Comma separated list of NATS server URLs, e.g. "nats://127.0.0.1:4222"
+	URL string
+	// Subject events will be published to
+	Subject string
+	// How long to wait for the server to acknowledge a batch of events (in seconds)
+	Timeout int64
+}
+
+// NATSEventSink publishes events to a NATS subject, each event is published as a separate message.
+// The batch is considered delivered once the server has processed all the messages in the batch.
+type NATSEventSink struct {
+	url     string
+	subject string
+	timeout time.Duration
+
+	mutex  sync.RWMutex
+	conn   *nats.Conn
+	closed bool
+	quit   chan struct{}
+}
+
+var _ EventSink = &NATSEventSink{}
+
+// NewNATSEventSink creates a sink that publishes events to the given NATS subject. If the NATS
+// server can't be reached the sink will keep trying to connect in the background.
+func NewNATSEventSink(cfg *NATSEventSinkConfig) (*NATSEventSink, error) {
+	if cfg.Subject == "" {
+		return nil, fmt.Errorf("NATS subject not specified")
+	}
+	s := &NATSEventSink{
+		url:     cfg.URL,
+		subject: cfg.Subject,
+		timeout: time.Duration(cfg.Timeout) * time.Second,
+		quit:    make(chan struct{}),
+	}
+	if err := s.connect(); err != nil {
+		log.Error("Failed to connect to NATS server, will retry in the background", "url", cfg.URL, "err", err)
+		connectInBackground(s.Name(), s.connect, s.quit)
+	}
+	return s, nil
+}
+
+func (s *NATSEventSink) connect() error {
+	// keep trying to reconnect forever once connected, the dispatcher will keep retrying
+	// deliveries until then
+	conn, err := nats.Connect(s.url, nats.MaxReconnects(-1))
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		conn.Close()
+		return nil
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *NATSEventSink) Name() string {
+	return DispatcherNATS
+}
+
+func (s *NATSEventSink) Deliver(events []*SinkEvent) error {
+	s.mutex.RLock()
+	conn := s.conn
+	s.mutex.RUnlock()
+
+	if conn == nil {
+		return errors.Errorf("not connected to NATS server %s", s.url)
+	}
+	for _, event := range events {
+		if err := conn.Publish(s.subject, event.Data); err != nil {
+			return err
+		}
+	}
+	// Publish only buffers the messages, so wait for the server to process them
+	return conn.FlushTimeout(s.timeout)
+}
+
+func (s *NATSEventSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.quit)
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	return nil
+}
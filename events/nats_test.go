@@ -0,0 +1,77 @@
+package events
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/gnatsd/test"
+	nats "github.com/nats-io/go-nats"
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+func TestNATSEventSink(t *testing.T) {
+	server := natsserver.RunRandClientPortServer()
+	defer server.Shutdown()
+
+	conn, err := nats.Connect(server.ClientURL())
+	require.NoError(t, err)
+	defer conn.Close()
+	msgs := make(chan *nats.Msg, 10)
+	_, err = conn.ChanSubscribe("diademchain.events", msgs)
+	require.NoError(t, err)
+	require.NoError(t, conn.Flush())
+
+	sink, err := NewNATSEventSink(&NATSEventSinkConfig{
+		URL:     server.ClientURL(),
+		Subject: "diademchain.events",
+		Timeout: 5,
+	})
+	require.NoError(t, err)
+	d, err := NewSinkEventDispatcher(sink, dbm.NewMemDB(), testDeliveryConfig())
+	require.NoError(t, err)
+	defer d.Close()
+
+	sendTestEvents(t, d, 1, 5)
+	for i := 0; i < 5; i++ {
+		select {
+		case msg := <-msgs:
+			require.Equal(t, fmt.Sprintf(`"event-1-%d"`, i), string(msg.Data))
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
+
+func TestNATSEventSinkConnectsInBackground(t *testing.T) {
+	prevInterval := sinkReconnectInterval
+	sinkReconnectInterval = 50 * time.Millisecond
+	defer func() { sinkReconnectInterval = prevInterval }()
+
+	// find a free port for the server that'll be started later
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	// the server isn't running yet, but that shouldn't prevent the sink from being created
+	sink, err := NewNATSEventSink(&NATSEventSinkConfig{
+		URL:     fmt.Sprintf("nats://127.0.0.1:%d", port),
+		Subject: "diademchain.events",
+		Timeout: 5,
+	})
+	require.NoError(t, err)
+	defer sink.Close()
+	require.Error(t, sink.Deliver([]*SinkEvent{{BlockHeight: 1, Data: []byte(`"event"`)}}))
+
+	opts := natsserver.DefaultTestOptions
+	opts.Port = port
+	server := natsserver.RunServer(&opts)
+	defer server.Shutdown()
+
+	waitFor(t, func() bool {
+		return sink.Deliver([]*SinkEvent{{BlockHeight: 1, Data: []byte(`"event"`)}}) == nil
+	})
+}
@@ -0,0 +1,375 @@
+package events
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/log"
+	"github.com/go-kit/kit/metrics"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/pkg/errors"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+var (
+	sinkDeliveredCount   metrics.Counter
+	sinkFailedCount      metrics.Counter
+	sinkDeliveryDuration metrics.Histogram
+	sinkQueueSize        metrics.Gauge
+)
+
+func init() {
+	const namespace = "diademchain"
+	const subsystem = "event_sink"
+
+	sinkDeliveredCount = kitprometheus.NewCounterFrom(
+		stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "delivered_count",
+			Help:      "Number of events delivered to the sink",
+		}, []string{"sink"})
+	sinkFailedCount = kitprometheus.NewCounterFrom(
+		stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "failed_delivery_count",
+			Help:      "Number of failed attempts to deliver a batch of events to the sink",
+		}, []string{"sink"})
+	sinkDeliveryDuration = kitprometheus.NewSummaryFrom(
+		stdprometheus.SummaryOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "delivery_duration",
+			Help:      "How long it took to deliver a batch of events to the sink (in seconds)",
+		}, []string{"sink", "error"})
+	sinkQueueSize = kitprometheus.NewGaugeFrom(
+		stdprometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "queue_size",
+			Help:      "Number of events waiting to be delivered to the sink",
+		}, []string{"sink"})
+}
+
+// SinkEvent is an event that's waiting to be delivered to an EventSink.
+type SinkEvent struct {
+	BlockHeight uint64
+	EventIndex  uint32
+	// JSON encoded event
+	Data []byte
+}
+
+// EventSink delivers events to an external system, a sink can be plugged into SinkEventDispatcher
+// to get batching, retries, and a persistent delivery queue for free.
+type EventSink interface {
+	// Name identifies the sink in logs & metrics.
+	Name() string
+	// Deliver should deliver the given events in order, if an error is returned the whole batch
+	// will be redelivered later, so the receiver must be able to handle duplicate events.
+	Deliver(events []*SinkEvent) error
+	Close() error
+}
+
+// How long a sink waits before trying to connect again after failing to connect to the external
+// system.
+var sinkReconnectInterval = 5 * time.Second
+
+// connectInBackground keeps calling connect until it succeeds, or until quit is closed. Sinks use
+// this so the node can start even if the external system they deliver to is down, deliveries
+// will fail (and be retried by the dispatcher) until the sink is connected.
+func connectInBackground(sinkName string, connect func() error, quit <-chan struct{}) {
+	go func() {
+		for {
+			select {
+			case <-quit:
+				return
+			case <-time.After(sinkReconnectInterval):
+			}
+			err := connect()
+			if err == nil {
+				log.Info("Event sink connected", "sink", sinkName)
+				return
+			}
+			log.Error("Failed to connect event sink, will retry", "sink", sinkName, "err", err)
+		}
+	}()
+}
+
+type EventDeliveryConfig struct {
+	// Max number of events to deliver to the sink at once
+	BatchSize int
+	// Delay before the first retry of a failed delivery (in milliseconds), the delay is doubled
+	// after every subsequent failure.
+	MinRetryBackoff int64
+	// Max delay between retries (in milliseconds)
+	MaxRetryBackoff int64
+	// Name of the DB used to persist events that haven't been delivered yet
+	QueueDBName string
+	// Backend of the DB used to persist events that haven't been delivered yet
+	QueueDBBackend string
+}
+
+func DefaultEventDeliveryConfig() *EventDeliveryConfig {
+	return &EventDeliveryConfig{
+		BatchSize:       100,
+		MinRetryBackoff: 500,
+		MaxRetryBackoff: 60000,
+		QueueDBName:     "event_queue",
+		QueueDBBackend:  "goleveldb",
+	}
+}
+
+// Clone returns a deep clone of the config.
+func (c *EventDeliveryConfig) Clone() *EventDeliveryConfig {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	return &clone
+}
+
+const (
+	sinkQueueKeyPrefix byte = 1
+)
+
+var sinkCursorKey = []byte("cursor")
+
+// sinkCursor identifies the last event that was delivered to a sink.
+type sinkCursor struct {
+	BlockHeight uint64
+	EventIndex  uint32
+}
+
+// SinkEventDispatcher persists events to a queue DB when a block is flushed, and delivers them to
+// an EventSink in the background. Events are only removed from the queue after they've been
+// delivered successfully, failed deliveries are retried with an exponential backoff until they
+// succeed, so no events are lost if the sink goes down, or the node is restarted.
+// The position of the last delivered event is persisted so events that are re-emitted when blocks
+// are replayed aren't delivered twice.
+type SinkEventDispatcher struct {
+	sink EventSink
+	db   dbm.DB
+	cfg  *EventDeliveryConfig
+
+	mutex     sync.Mutex
+	pending   []*SinkEvent
+	cursor    *sinkCursor
+	queueSize int
+
+	notify chan struct{}
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+var _ diademchain.EventDispatcher = &SinkEventDispatcher{}
+
+// NewSinkEventDispatcher creates a dispatcher that delivers events to the given sink, the given DB
+// is used to queue the events until they're delivered. Any events left in the queue by a previous
+// instance of the dispatcher will be delivered first.
+func NewSinkEventDispatcher(sink EventSink, db dbm.DB, cfg *EventDeliveryConfig) (*SinkEventDispatcher, error) {
+	if cfg.BatchSize <= 0 {
+		return nil, errors.New("event delivery batch size must be greater than zero")
+	}
+	if cfg.MinRetryBackoff <= 0 || cfg.MaxRetryBackoff < cfg.MinRetryBackoff {
+		return nil, errors.New("invalid event delivery retry backoff")
+	}
+	d := &SinkEventDispatcher{
+		sink:   sink,
+		db:     db,
+		cfg:    cfg,
+		notify: make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+	}
+	if buf := db.Get(sinkCursorKey); buf != nil {
+		if len(buf) != 12 {
+			return nil, errors.Errorf("invalid %s event sink cursor", sink.Name())
+		}
+		d.cursor = &sinkCursor{
+			BlockHeight: binary.BigEndian.Uint64(buf[:8]),
+			EventIndex:  binary.BigEndian.Uint32(buf[8:]),
+		}
+	}
+	itr := db.Iterator([]byte{sinkQueueKeyPrefix}, []byte{sinkQueueKeyPrefix + 1})
+	for ; itr.Valid(); itr.Next() {
+		d.queueSize++
+	}
+	itr.Close()
+	sinkQueueSize.With("sink", sink.Name()).Set(float64(d.queueSize))
+
+	d.wg.Add(1)
+	go d.run()
+	d.wakeUp()
+	return d, nil
+}
+
+// Send queues the event for delivery, the event won't be persisted until Flush is called.
+func (d *SinkEventDispatcher) Send(blockHeight uint64, eventIndex int, msg []byte) error {
+	if eventIndex < 0 || uint64(eventIndex) > math.MaxUint32 {
+		return errors.Errorf("event index %d at height %d is out of range", eventIndex, blockHeight)
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.cursor != nil && (blockHeight < d.cursor.BlockHeight ||
+		(blockHeight == d.cursor.BlockHeight && uint32(eventIndex) <= d.cursor.EventIndex)) {
+		// already delivered
+		return nil
+	}
+	d.pending = append(d.pending, &SinkEvent{
+		BlockHeight: blockHeight,
+		EventIndex:  uint32(eventIndex),
+		Data:        msg,
+	})
+	return nil
+}
+
+// Flush persists all the events sent since the last flush, and wakes up the delivery loop.
+func (d *SinkEventDispatcher) Flush() {
+	d.mutex.Lock()
+	pending := d.pending
+	d.pending = nil
+	d.mutex.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+	d.mutex.Lock()
+	d.queueSize += len(pending)
+	sinkQueueSize.With("sink", d.sink.Name()).Set(float64(d.queueSize))
+	d.mutex.Unlock()
+
+	batch := d.db.NewBatch()
+	for _, event := range pending {
+		batch.Set(sinkQueueKey(event.BlockHeight, event.EventIndex), event.Data)
+	}
+	batch.WriteSync()
+	d.wakeUp()
+}
+
+// Close stops the delivery loop and closes the sink, events that haven't been delivered yet will
+// be delivered when a new dispatcher is created with the same queue DB.
+func (d *SinkEventDispatcher) Close() error {
+	close(d.quit)
+	d.wg.Wait()
+	return d.sink.Close()
+}
+
+func (d *SinkEventDispatcher) wakeUp() {
+	select {
+	case d.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (d *SinkEventDispatcher) run() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.quit:
+			return
+		case <-d.notify:
+		}
+		for {
+			select {
+			case <-d.quit:
+				return
+			default:
+			}
+			batch := d.loadBatch()
+			if len(batch) == 0 {
+				break
+			}
+			if !d.deliver(batch) {
+				return
+			}
+		}
+	}
+}
+
+// loadBatch loads the oldest events from the queue.
+func (d *SinkEventDispatcher) loadBatch() []*SinkEvent {
+	var batch []*SinkEvent
+	prefix := []byte{sinkQueueKeyPrefix}
+	itr := d.db.Iterator(prefix, []byte{sinkQueueKeyPrefix + 1})
+	defer itr.Close()
+	for ; itr.Valid() && len(batch) < d.cfg.BatchSize; itr.Next() {
+		key := itr.Key()
+		if len(key) != 13 {
+			log.Error("Skipping invalid event sink queue key", "sink", d.sink.Name(), "key", key)
+			continue
+		}
+		batch = append(batch, &SinkEvent{
+			BlockHeight: binary.BigEndian.Uint64(key[1:9]),
+			EventIndex:  binary.BigEndian.Uint32(key[9:]),
+			Data:        itr.Value(),
+		})
+	}
+	return batch
+}
+
+// deliver keeps trying to deliver the given batch until it succeeds, the delivered events are
+// then removed from the queue. Returns false if the dispatcher was closed before the batch could
+// be delivered.
+func (d *SinkEventDispatcher) deliver(batch []*SinkEvent) bool {
+	sinkName := d.sink.Name()
+	backoff := time.Duration(d.cfg.MinRetryBackoff) * time.Millisecond
+	maxBackoff := time.Duration(d.cfg.MaxRetryBackoff) * time.Millisecond
+	for {
+		begin := time.Now()
+		err := d.sink.Deliver(batch)
+		sinkDeliveryDuration.With("sink", sinkName, "error", fmt.Sprint(err != nil)).
+			Observe(time.Since(begin).Seconds())
+		if err == nil {
+			break
+		}
+		sinkFailedCount.With("sink", sinkName).Add(1)
+		log.Error(
+			"Failed to deliver events, will retry", "sink", sinkName, "events", len(batch),
+			"retryIn", backoff, "err", err,
+		)
+		select {
+		case <-d.quit:
+			return false
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+
+	last := batch[len(batch)-1]
+	cursor := make([]byte, 12)
+	binary.BigEndian.PutUint64(cursor[:8], last.BlockHeight)
+	binary.BigEndian.PutUint32(cursor[8:], last.EventIndex)
+
+	dbBatch := d.db.NewBatch()
+	for _, event := range batch {
+		dbBatch.Delete(sinkQueueKey(event.BlockHeight, event.EventIndex))
+	}
+	dbBatch.Set(sinkCursorKey, cursor)
+	dbBatch.WriteSync()
+
+	d.mutex.Lock()
+	d.cursor = &sinkCursor{BlockHeight: last.BlockHeight, EventIndex: last.EventIndex}
+	d.queueSize -= len(batch)
+	sinkQueueSize.With("sink", sinkName).Set(float64(d.queueSize))
+	d.mutex.Unlock()
+
+	sinkDeliveredCount.With("sink", sinkName).Add(float64(len(batch)))
+	return true
+}
+
+func sinkQueueKey(blockHeight uint64, eventIndex uint32) []byte {
+	key := make([]byte, 13)
+	key[0] = sinkQueueKeyPrefix
+	binary.BigEndian.PutUint64(key[1:9], blockHeight)
+	binary.BigEndian.PutUint32(key[9:], eventIndex)
+	return key
+}
@@ -0,0 +1,177 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+// mockEventSink records delivered events, and fails to deliver while down is set
+type mockEventSink struct {
+	sync.Mutex
+	down      bool
+	failures  int
+	delivered []*SinkEvent
+}
+
+func (s *mockEventSink) Name() string {
+	return "mock"
+}
+
+func (s *mockEventSink) Deliver(events []*SinkEvent) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.down {
+		s.failures++
+		return fmt.Errorf("sink is down")
+	}
+	s.delivered = append(s.delivered, events...)
+	return nil
+}
+
+func (s *mockEventSink) Close() error {
+	return nil
+}
+
+func (s *mockEventSink) numFailures() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.failures
+}
+
+func (s *mockEventSink) deliveredData() []string {
+	s.Lock()
+	defer s.Unlock()
+	var data []string
+	for _, event := range s.delivered {
+		data = append(data, string(event.Data))
+	}
+	return data
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	for start := time.Now(); !cond(); time.Sleep(10 * time.Millisecond) {
+		require.True(t, time.Since(start) < 5*time.Second, "timed out")
+	}
+}
+
+func testDeliveryConfig() *EventDeliveryConfig {
+	cfg := DefaultEventDeliveryConfig()
+	cfg.BatchSize = 3
+	cfg.MinRetryBackoff = 10
+	cfg.MaxRetryBackoff = 40
+	return cfg
+}
+
+func sendTestEvents(t *testing.T, d *SinkEventDispatcher, blockHeight uint64, count int) {
+	for i := 0; i < count; i++ {
+		require.NoError(t, d.Send(blockHeight, i, []byte(fmt.Sprintf(`"event-%d-%d"`, blockHeight, i))))
+	}
+	d.Flush()
+}
+
+func TestSinkEventDispatcherRetriesAndPersistsEvents(t *testing.T) {
+	db := dbm.NewMemDB()
+	sink := &mockEventSink{down: true}
+	d, err := NewSinkEventDispatcher(sink, db, testDeliveryConfig())
+	require.NoError(t, err)
+
+	sendTestEvents(t, d, 1, 4)
+	waitFor(t, func() bool { return sink.numFailures() >= 3 })
+	require.Empty(t, sink.deliveredData())
+
+	// events that weren't delivered should be picked up by the next dispatcher
+	require.NoError(t, d.Close())
+	sink = &mockEventSink{}
+	d, err = NewSinkEventDispatcher(sink, db, testDeliveryConfig())
+	require.NoError(t, err)
+	sendTestEvents(t, d, 2, 2)
+
+	expected := []string{
+		`"event-1-0"`, `"event-1-1"`, `"event-1-2"`, `"event-1-3"`, `"event-2-0"`, `"event-2-1"`,
+	}
+	waitFor(t, func() bool { return len(sink.deliveredData()) == len(expected) })
+	require.Equal(t, expected, sink.deliveredData())
+	require.NoError(t, d.Close())
+
+	// events that were already delivered shouldn't be delivered again when blocks are replayed
+	sink = &mockEventSink{}
+	d, err = NewSinkEventDispatcher(sink, db, testDeliveryConfig())
+	require.NoError(t, err)
+	sendTestEvents(t, d, 2, 3)
+	waitFor(t, func() bool { return len(sink.deliveredData()) == 1 })
+	require.Equal(t, []string{`"event-2-2"`}, sink.deliveredData())
+	require.NoError(t, d.Close())
+}
+
+func TestSinkEventDispatcherLargeEventIndex(t *testing.T) {
+	sink := &mockEventSink{}
+	d, err := NewSinkEventDispatcher(sink, dbm.NewMemDB(), testDeliveryConfig())
+	require.NoError(t, err)
+
+	// events past the first 65536 in a block shouldn't overwrite earlier ones in the queue
+	require.NoError(t, d.Send(1, 0, []byte(`"event-1-0"`)))
+	require.NoError(t, d.Send(1, 1<<16, []byte(`"event-1-65536"`)))
+	require.Error(t, d.Send(1, -1, []byte(`"event-1--1"`)))
+	d.Flush()
+
+	expected := []string{`"event-1-0"`, `"event-1-65536"`}
+	waitFor(t, func() bool { return len(sink.deliveredData()) == len(expected) })
+	require.Equal(t, expected, sink.deliveredData())
+	require.NoError(t, d.Close())
+}
+
+func TestSinkEventDispatcherInvalidConfig(t *testing.T) {
+	cfg := testDeliveryConfig()
+	cfg.BatchSize = 0
+	_, err := NewSinkEventDispatcher(&mockEventSink{}, dbm.NewMemDB(), cfg)
+	require.Error(t, err)
+
+	cfg = testDeliveryConfig()
+	cfg.MaxRetryBackoff = cfg.MinRetryBackoff - 1
+	_, err = NewSinkEventDispatcher(&mockEventSink{}, dbm.NewMemDB(), cfg)
+	require.Error(t, err)
+}
+
+func TestWebhookEventSink(t *testing.T) {
+	var mutex sync.Mutex
+	var received []string
+	fail := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if fail {
+			fail = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		var events []string
+		require.NoError(t, json.Unmarshal(body, &events))
+		received = append(received, events...)
+	}))
+	defer ts.Close()
+
+	sink, err := NewWebhookEventSink(&WebhookEventSinkConfig{URL: ts.URL, Timeout: 5})
+	require.NoError(t, err)
+	d, err := NewSinkEventDispatcher(sink, dbm.NewMemDB(), testDeliveryConfig())
+	require.NoError(t, err)
+	defer d.Close()
+
+	sendTestEvents(t, d, 1, 2)
+	waitFor(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(received) == 2
+	})
+	require.Equal(t, []string{"event-1-0", "event-1-1"}, received)
+}
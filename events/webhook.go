@@ -0,0 +1,69 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+type WebhookEventSinkConfig struct {
+	// URL that batches of events will be POSTed to
+	URL string
+	// Request timeout (in seconds)
+	Timeout int64
+}
+
+// WebhookEventSink POSTs batches of events to an HTTP endpoint, the body of each request is a JSON
+// array of events. The batch is considered delivered when the endpoint responds with a 2xx status.
+type WebhookEventSink struct {
+	url    string
+	client *http.Client
+}
+
+var _ EventSink = &WebhookEventSink{}
+
+func NewWebhookEventSink(cfg *WebhookEventSinkConfig) (*WebhookEventSink, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook URL not specified")
+	}
+	return &WebhookEventSink{
+		url: cfg.URL,
+		client: &http.Client{
+			Timeout: time.Duration(cfg.Timeout) * time.Second,
+		},
+	}, nil
+}
+
+func (s *WebhookEventSink) Name() string {
+	return DispatcherWebhook
+}
+
+func (s *WebhookEventSink) Deliver(events []*SinkEvent) error {
+	msgs := make([]json.RawMessage, 0, len(events))
+	for _, event := range events {
+		msgs = append(msgs, json.RawMessage(event.Data))
+	}
+	body, err := json.Marshal(msgs)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// drain the body so the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *WebhookEventSink) Close() error {
+	return nil
+}
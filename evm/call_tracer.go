@@ -0,0 +1,249 @@
+// +build evm
+
+package evm
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// CallFrame describes a call made by a tx, it's compatible with the output of the callTracer built
+// into go-ethereum.
+type CallFrame struct {
+	Type    string       `json:"type"`
+	From    string       `json:"from"`
+	To      string       `json:"to,omitempty"`
+	Value   string       `json:"value,omitempty"`
+	Gas     string       `json:"gas,omitempty"`
+	GasUsed string       `json:"gasUsed,omitempty"`
+	Input   string       `json:"input,omitempty"`
+	Output  string       `json:"output,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Calls   []*CallFrame `json:"calls,omitempty"`
+
+	gasIn   uint64
+	gasCost uint64
+	// gas available to the callee, only set if the call actually entered the callee
+	gas    *uint64
+	outOff *big.Int
+	outLen *big.Int
+}
+
+// callTracer implements the vm.Tracer interface, it's a port of the JavaScript callTracer from
+// go-ethereum.
+type callTracer struct {
+	root *CallFrame
+	// the first frame collects the calls made by the tx, and any error raised by the EVM
+	callStack []*CallFrame
+	descended bool
+}
+
+func newCallTracer() *callTracer {
+	return &callTracer{
+		root:      &CallFrame{},
+		callStack: []*CallFrame{{}},
+	}
+}
+
+func (t *callTracer) CaptureStart(
+	from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int,
+) error {
+	t.root.Type = "CALL"
+	if create {
+		t.root.Type = "CREATE"
+	}
+	t.root.From = encAddress(from)
+	t.root.To = encAddress(to)
+	t.root.Input = hexutil.Encode(input)
+	t.root.Gas = hexutil.EncodeUint64(gas)
+	if value != nil {
+		t.root.Value = hexutil.EncodeBig(value)
+	}
+	return nil
+}
+
+func (t *callTracer) CaptureState(
+	env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack,
+	contract *vm.Contract, depth int, err error,
+) error {
+	if err != nil {
+		t.fault(err)
+		return nil
+	}
+
+	switch op {
+	case vm.CREATE, vm.CREATE2:
+		t.callStack = append(t.callStack, &CallFrame{
+			Type:    op.String(),
+			From:    encAddress(contract.Address()),
+			Input:   hexutil.Encode(memoryCopy(memory, stack.Back(1), stack.Back(2))),
+			Value:   hexutil.EncodeBig(stack.Back(0)),
+			gasIn:   gas,
+			gasCost: cost,
+		})
+		t.descended = true
+		return nil
+
+	case vm.SELFDESTRUCT:
+		t.top().Calls = append(t.top().Calls, &CallFrame{
+			Type:  op.String(),
+			From:  encAddress(contract.Address()),
+			To:    encAddress(common.BigToAddress(stack.Back(0))),
+			Value: hexutil.EncodeBig(env.StateDB.GetBalance(contract.Address())),
+		})
+		return nil
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		to := common.BigToAddress(stack.Back(1))
+		if _, isPrecompile := vm.PrecompiledContractsByzantium[to]; isPrecompile {
+			return nil
+		}
+		// DELEGATECALL & STATICCALL don't take a value argument
+		off := 1
+		if op == vm.DELEGATECALL || op == vm.STATICCALL {
+			off = 0
+		}
+		call := &CallFrame{
+			Type:    op.String(),
+			From:    encAddress(contract.Address()),
+			To:      encAddress(to),
+			Input:   hexutil.Encode(memoryCopy(memory, stack.Back(2+off), stack.Back(3+off))),
+			gasIn:   gas,
+			gasCost: cost,
+			outOff:  new(big.Int).Set(stack.Back(4 + off)),
+			outLen:  new(big.Int).Set(stack.Back(5 + off)),
+		}
+		if off == 1 {
+			call.Value = hexutil.EncodeBig(stack.Back(2))
+		}
+		t.callStack = append(t.callStack, call)
+		t.descended = true
+		return nil
+	}
+
+	// If the previous op was a call or create, and the EVM entered the callee, record how much gas
+	// the callee received.
+	if t.descended {
+		if depth >= len(t.callStack) {
+			calleeGas := gas
+			t.top().gas = &calleeGas
+		}
+		t.descended = false
+	}
+
+	if op == vm.REVERT {
+		t.top().Error = "execution reverted"
+		return nil
+	}
+
+	// If the depth dropped the callee has returned, the result of the call is on the stack now.
+	if depth == len(t.callStack)-1 {
+		call := t.pop()
+		ret := stack.Back(0)
+		if call.Type == vm.CREATE.String() || call.Type == vm.CREATE2.String() {
+			call.GasUsed = hexutil.EncodeUint64(call.gasIn - call.gasCost - gas)
+			if ret.Sign() != 0 {
+				addr := common.BigToAddress(ret)
+				call.To = encAddress(addr)
+				call.Output = hexutil.Encode(env.StateDB.GetCode(addr))
+			} else if call.Error == "" {
+				call.Error = "internal failure"
+			}
+		} else {
+			if call.gas != nil {
+				call.GasUsed = hexutil.EncodeUint64(call.gasIn - call.gasCost + *call.gas - gas)
+			}
+			if ret.Sign() != 0 {
+				call.Output = hexutil.Encode(memoryCopy(memory, call.outOff, call.outLen))
+			} else if call.Error == "" {
+				call.Error = "internal failure"
+			}
+		}
+		if call.gas != nil {
+			call.Gas = hexutil.EncodeUint64(*call.gas)
+		}
+		t.top().Calls = append(t.top().Calls, call)
+	}
+	return nil
+}
+
+func (t *callTracer) CaptureFault(
+	env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack,
+	contract *vm.Contract, depth int, err error,
+) error {
+	t.fault(err)
+	return nil
+}
+
+func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	t.root.Output = hexutil.Encode(output)
+	t.root.GasUsed = hexutil.EncodeUint64(gasUsed)
+	if err != nil {
+		t.root.Error = err.Error()
+	}
+	return nil
+}
+
+// fault records an error raised by the EVM in the current call frame.
+func (t *callTracer) fault(err error) {
+	if t.top().Error != "" {
+		return
+	}
+	call := t.pop()
+	call.Error = err.Error()
+	if call.gas != nil {
+		call.Gas = hexutil.EncodeUint64(*call.gas)
+		call.GasUsed = call.Gas
+	}
+	if len(t.callStack) > 0 {
+		t.top().Calls = append(t.top().Calls, call)
+	} else {
+		t.callStack = append(t.callStack, call)
+	}
+}
+
+func (t *callTracer) top() *CallFrame {
+	return t.callStack[len(t.callStack)-1]
+}
+
+func (t *callTracer) pop() *CallFrame {
+	call := t.top()
+	t.callStack = t.callStack[:len(t.callStack)-1]
+	return call
+}
+
+// result returns the call made by the tx, along with all the nested calls.
+func (t *callTracer) result() *CallFrame {
+	result := *t.root
+	result.Calls = t.callStack[0].Calls
+	if t.callStack[0].Error != "" {
+		result.Error = t.callStack[0].Error
+	}
+	if result.Error != "" && (result.Error != "execution reverted" || result.Output == "0x") {
+		result.Output = ""
+	}
+	return &result
+}
+
+func encAddress(addr common.Address) string {
+	return hexutil.Encode(addr.Bytes())
+}
+
+// memoryCopy returns a copy of the given memory range, the range is truncated to the current memory
+// size because the tracer is invoked before the memory is expanded by an op.
+func memoryCopy(memory *vm.Memory, offset, size *big.Int) []byte {
+	data := memory.Data()
+	if !offset.IsUint64() || offset.Uint64() >= uint64(len(data)) {
+		return nil
+	}
+	start := offset.Uint64()
+	end := uint64(len(data))
+	if size.IsUint64() && size.Uint64() < end-start {
+		end = start + size.Uint64()
+	}
+	return append([]byte(nil), data[start:end]...)
+}
@@ -17,6 +17,7 @@ import (
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
 	"github.com/diademnetwork/go-diadem"
 	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/auth"
 	"github.com/diademnetwork/diademchain/log"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
 )
@@ -26,8 +27,15 @@ const EVMEnabled = true
 
 var (
 	gasLimit = uint64(math.MaxUint64)
+	// Txs don't pay for gas, so the gas price is always zero
+	gasPrice = big.NewInt(0)
 )
 
+// GasPrice returns the gas price EVM txs are executed with.
+func GasPrice() *big.Int {
+	return new(big.Int).Set(gasPrice)
+}
+
 //Metrics
 var (
 	txLatency metrics.Histogram
@@ -139,6 +147,10 @@ type Evm struct {
 	context     vm.Context
 	chainConfig params.ChainConfig
 	vmConfig    vm.Config
+	// Gas used by Create & Call is added to this meter (if it's not nil)
+	gasMeter *GasMeter
+	// Gas limit Create & Call are executed with
+	txGasLimit uint64
 }
 
 func NewEvm(sdb vm.StateDB, lstate diademchain.State, abm *evmAccountBalanceManager, debug bool) *Evm {
@@ -146,6 +158,12 @@ func NewEvm(sdb vm.StateDB, lstate diademchain.State, abm *evmAccountBalanceMana
 	p.sdb = sdb
 	p.chainConfig = defaultChainConfig()
 	p.vmConfig = defaultVmConfig(debug)
+	p.gasMeter = GasMeterFromContext(lstate.Context())
+	p.txGasLimit = gasLimit
+	// Ethereum txs are executed with the gas limit they were signed with
+	if ethTx := auth.EthTxFromContext(lstate.Context()); ethTx != nil {
+		p.txGasLimit = ethTx.GasLimit
+	}
 	p.context = vm.Context{
 		CanTransfer: core.CanTransfer,
 		Transfer:    core.Transfer,
@@ -157,7 +175,7 @@ func NewEvm(sdb vm.StateDB, lstate diademchain.State, abm *evmAccountBalanceMana
 		Time:        big.NewInt(lstate.Block().Time),
 		Difficulty:  new(big.Int),
 		GasLimit:    gasLimit,
-		GasPrice:    big.NewInt(0),
+		GasPrice:    GasPrice(),
 	}
 	if abm != nil {
 		p.context.CanTransfer = func(db vm.StateDB, addr common.Address, amount *big.Int) bool {
@@ -178,8 +196,18 @@ func (e Evm) Create(caller diadem.Address, code []byte, value *diadem.BigUInt) (
 		txCount.With(lvs...).Add(1)
 		txLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 		txGas.With(lvs...).Observe(float64(usedGas))
-
+		if e.gasMeter != nil {
+			e.gasMeter.Consume(usedGas)
+		}
 	}(time.Now())
+	var runCode []byte
+	var diademAddress diadem.Address
+	runCode, diademAddress, usedGas, err = e.create(caller, code, value, e.txGasLimit)
+	return runCode, diademAddress, err
+}
+
+// create deploys a contract with the given gas limit, and returns the amount of gas used.
+func (e Evm) create(caller diadem.Address, code []byte, value *diadem.BigUInt, gas uint64) ([]byte, diadem.Address, uint64, error) {
 	origin := common.BytesToAddress(caller.Local)
 	vmenv := e.NewEnv(origin)
 
@@ -189,13 +217,12 @@ func (e Evm) Create(caller diadem.Address, code []byte, value *diadem.BigUInt) (
 	} else {
 		val = value.Int
 	}
-	runCode, address, leftOverGas, err := vmenv.Create(vm.AccountRef(origin), code, gasLimit, val)
-	usedGas = gasLimit - leftOverGas
+	runCode, address, leftOverGas, err := vmenv.Create(vm.AccountRef(origin), code, gas, val)
 	diademAddress := diadem.Address{
 		ChainID: caller.ChainID,
 		Local:   address.Bytes(),
 	}
-	return runCode, diademAddress, err
+	return runCode, diademAddress, gas - leftOverGas, wrapRevertError(runCode, err)
 }
 
 func (e Evm) Call(caller, addr diadem.Address, input []byte, value *diadem.BigUInt) ([]byte, error) {
@@ -206,8 +233,17 @@ func (e Evm) Call(caller, addr diadem.Address, input []byte, value *diadem.BigUI
 		txCount.With(lvs...).Add(1)
 		txGas.With(lvs...).Observe(float64(usedGas))
 		txLatency.With(lvs...).Observe(time.Since(begin).Seconds())
-
+		if e.gasMeter != nil {
+			e.gasMeter.Consume(usedGas)
+		}
 	}(time.Now())
+	var ret []byte
+	ret, usedGas, err = e.call(caller, addr, input, value, e.txGasLimit)
+	return ret, err
+}
+
+// call calls a contract with the given gas limit, and returns the amount of gas used.
+func (e Evm) call(caller, addr diadem.Address, input []byte, value *diadem.BigUInt, gas uint64) ([]byte, uint64, error) {
 	origin := common.BytesToAddress(caller.Local)
 	contract := common.BytesToAddress(addr.Local)
 	vmenv := e.NewEnv(origin)
@@ -222,9 +258,8 @@ func (e Evm) Call(caller, addr diadem.Address, input []byte, value *diadem.BigUI
 			val = common.Big0
 		}
 	}
-	ret, leftOverGas, err := vmenv.Call(vm.AccountRef(origin), contract, input, gasLimit, val)
-	usedGas = gasLimit - leftOverGas
-	return ret, err
+	ret, leftOverGas, err := vmenv.Call(vm.AccountRef(origin), contract, input, gas, val)
+	return ret, gas - leftOverGas, wrapRevertError(ret, err)
 }
 
 func (e Evm) StaticCall(caller, addr diadem.Address, input []byte) ([]byte, error) {
@@ -232,13 +267,25 @@ func (e Evm) StaticCall(caller, addr diadem.Address, input []byte) ([]byte, erro
 	contract := common.BytesToAddress(addr.Local)
 	vmenv := e.NewEnv(origin)
 	ret, _, err := vmenv.StaticCall(vm.AccountRef(origin), contract, input, gasLimit)
-	return ret, err
+	return ret, wrapRevertError(ret, err)
 }
 
 func (e Evm) GetCode(addr diadem.Address) []byte {
 	return e.sdb.GetCode(common.BytesToAddress(addr.Local))
 }
 
+// The EVM doesn't export the error it returns when a contract executes REVERT.
+const errExecutionRevertedMsg = "evm: execution reverted"
+
+// wrapRevertError replaces the error returned by the EVM when a contract reverts with a RevertError
+// that contains the data the contract passed to REVERT.
+func wrapRevertError(ret []byte, err error) error {
+	if err != nil && err.Error() == errExecutionRevertedMsg {
+		return NewRevertError(ret)
+	}
+	return err
+}
+
 // TODO: this doesn't need to be exported, rename to newEVM
 func (e Evm) NewEnv(origin common.Address) *vm.EVM {
 	e.context.Origin = origin
@@ -317,7 +364,7 @@ func defaultContext() vm.Context {
 		Time:        big.NewInt(time.Now().Unix()),
 		Difficulty:  new(big.Int),
 		GasLimit:    gasLimit,
-		GasPrice:    big.NewInt(0),
+		GasPrice:    GasPrice(),
 	}
 }
 
@@ -0,0 +1,88 @@
+// +build evm
+
+package evm
+
+import (
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/go-diadem"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+// EstimateGas simulates a contract call (or a contract deployment if contract is nil) and returns
+// the lowest gas limit the call succeeds with. The simulation runs against the given state, but none
+// of the changes made by the call are persisted. If gasCap is zero the call is simulated without a
+// gas limit, just like EVM txs are executed.
+func EstimateGas(
+	diademState diademchain.State,
+	evmDB dbm.DB,
+	createABM AccountBalanceManagerFactoryFunc,
+	caller diadem.Address,
+	contract *diadem.Address,
+	input []byte,
+	value *diadem.BigUInt,
+	gasCap uint64,
+) (uint64, error) {
+	var abm AccountBalanceManager
+	if createABM != nil {
+		abm = createABM(true)
+	}
+	levm, err := NewDiademEvm(diademState, evmDB, abm, nil, false)
+	if err != nil {
+		return 0, err
+	}
+	if gasCap == 0 {
+		gasCap = gasLimit
+	}
+
+	// run executes the call with the given gas limit, and then reverts all the changes it made
+	run := func(gas uint64) (uint64, error) {
+		snapshot := levm.sdb.Snapshot()
+		defer levm.sdb.RevertToSnapshot(snapshot)
+		if contract == nil {
+			_, _, usedGas, err := levm.create(caller, input, value, gas)
+			return usedGas, err
+		}
+		_, usedGas, err := levm.call(caller, *contract, input, value, gas)
+		return usedGas, err
+	}
+
+	usedGas, err := run(gasCap)
+	if err != nil {
+		return 0, err
+	}
+	if usedGas == gasCap {
+		return usedGas, nil
+	}
+	if _, err := run(usedGas); err == nil {
+		return usedGas, nil
+	}
+
+	// Nested calls only receive 63/64 of the remaining gas (EIP-150), so a call may need a higher
+	// gas limit than the amount of gas it ends up using. Find an upper bound the call succeeds with,
+	// then binary search for the lowest limit between the last failure and the upper bound.
+	lo, hi := usedGas, usedGas
+	for {
+		if hi > gasCap/2 {
+			hi = gasCap
+			break
+		}
+		if hi == 0 {
+			hi = 1
+		} else {
+			hi *= 2
+		}
+		if _, err := run(hi); err == nil {
+			break
+		}
+		lo = hi
+	}
+	for lo+1 < hi {
+		mid := lo + (hi-lo)/2
+		if _, err := run(mid); err != nil {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi, nil
+}
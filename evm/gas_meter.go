@@ -0,0 +1,40 @@
+package evm
+
+import (
+	"context"
+)
+
+type contextKey string
+
+func (c contextKey) String() string {
+	return "evm " + string(c)
+}
+
+// ContextKeyGasMeter can be used to attach a GasMeter to the context of the state EVM txs are
+// executed with.
+var ContextKeyGasMeter = contextKey("GasMeter")
+
+// GasMeter keeps track of the gas used by all the EVM deployments & calls made while processing
+// a tx. It's not safe for concurrent use.
+type GasMeter struct {
+	used uint64
+}
+
+// Consume adds the given amount of gas to the total used.
+func (m *GasMeter) Consume(gas uint64) {
+	m.used += gas
+}
+
+// Used returns the total amount of gas used so far.
+func (m *GasMeter) Used() uint64 {
+	return m.used
+}
+
+// GasMeterFromContext returns the GasMeter attached to the given context, or nil if there is none.
+func GasMeterFromContext(ctx context.Context) *GasMeter {
+	if ctx == nil {
+		return nil
+	}
+	meter, _ := ctx.Value(ContextKeyGasMeter).(*GasMeter)
+	return meter
+}
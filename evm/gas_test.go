@@ -0,0 +1,64 @@
+// +build evm
+
+package evm
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"math/big"
+	"testing"
+
+	"github.com/diademnetwork/go-diadem"
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+func TestEstimateGas(t *testing.T) {
+	caller := diadem.Address{
+		ChainID: "myChainID",
+		Local:   []byte("myCaller"),
+	}
+	state := mockState()
+	evmDB := dbm.NewMemDB()
+
+	bytetext, err := ioutil.ReadFile("testdata/GlobalProperties.bin")
+	require.NoError(t, err)
+	bytecode, err := hex.DecodeString(string(bytetext))
+	require.NoError(t, err)
+
+	// estimating the cost of a deployment shouldn't deploy the contract
+	createGas, err := EstimateGas(state, evmDB, nil, caller, nil, bytecode, nil, 0)
+	require.NoError(t, err)
+	require.True(t, createGas > 0)
+	require.Nil(t, state.Get(rootKey))
+
+	// the estimate should match the gas used by the actual deployment
+	levm, err := NewDiademEvm(state, evmDB, nil, nil, false)
+	require.NoError(t, err)
+	_, contractAddr, usedGas, err := levm.create(caller, bytecode, nil, gasLimit)
+	require.NoError(t, err)
+	require.Equal(t, usedGas, createGas)
+	_, err = levm.Commit()
+	require.NoError(t, err)
+
+	vm := NewDiademVm(state, evmDB, nil, nil, nil, false)
+	abiGP, _ := deploySolContract(t, caller, "GlobalProperties", vm)
+	input, err := abiGP.Pack("txGasPrice")
+	require.NoError(t, err)
+	callGas, err := EstimateGas(state, evmDB, nil, caller, &contractAddr, input, nil, 0)
+	require.NoError(t, err)
+	require.True(t, callGas > 0)
+
+	levm, err = NewDiademEvm(state, evmDB, nil, nil, false)
+	require.NoError(t, err)
+	ret, usedGas, err := levm.call(caller, contractAddr, input, nil, gasLimit)
+	require.NoError(t, err)
+	require.Equal(t, usedGas, callGas)
+	var gasPrice *big.Int
+	require.NoError(t, abiGP.Unpack(&gasPrice, "txGasPrice", ret))
+	require.Equal(t, GasPrice(), gasPrice)
+
+	// the call should fail if the gas cap is too low
+	_, err = EstimateGas(state, evmDB, nil, caller, &contractAddr, input, nil, callGas-1)
+	require.Error(t, err)
+}
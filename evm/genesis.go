@@ -0,0 +1,164 @@
+// +build evm
+
+package evm
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/diademnetwork/diademchain"
+	genesiscfg "github.com/diademnetwork/diademchain/config/genesis"
+	"github.com/diademnetwork/go-diadem"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/pkg/errors"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+// exportStateDB opens the EVM state at the root recorded in the given app state, so the EVM state
+// matches the height of the app state rather than the latest EVM state in the EVM DB.
+func exportStateDB(diademState diademchain.State, evmDB dbm.DB) (*state.StateDB, error) {
+	root := diademState.Get(rootKey)
+	if len(root) == 0 {
+		return nil, fmt.Errorf("no EVM state root found at height %d", diademState.Block().Height)
+	}
+	levm, err := NewDiademEvm(diademState, evmDB, nil, nil, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "EVM state at height %d is not available", diademState.Block().Height)
+	}
+	sdb, err := state.New(common.BytesToHash(root), state.NewDatabase(levm.db))
+	if err != nil {
+		return nil, errors.Wrapf(err, "EVM state at height %d is not available", diademState.Block().Height)
+	}
+	return sdb, nil
+}
+
+// ExportContractState returns the code, nonce, balance, and storage of the EVM contract at the
+// given address, at the height of the given app state.
+func ExportContractState(
+	diademState diademchain.State, evmDB dbm.DB, addr diadem.Address,
+) (*genesiscfg.ContractState, error) {
+	sdb, err := exportStateDB(diademState, evmDB)
+	if err != nil {
+		return nil, err
+	}
+
+	ethAddr := common.BytesToAddress(addr.Local)
+	code := sdb.GetCode(ethAddr)
+	if len(code) == 0 {
+		return nil, fmt.Errorf("no EVM contract found at %v", addr)
+	}
+
+	var storage []genesiscfg.StateEntry
+	if st := sdb.StorageTrie(ethAddr); st != nil {
+		it := trie.NewIterator(st.NodeIterator(nil))
+		for it.Next() {
+			// storage trie keys are hashed, the preimages are stored alongside the trie
+			key := st.GetKey(it.Key)
+			if key == nil {
+				return nil, fmt.Errorf("missing preimage for storage key %X of %v", it.Key, addr)
+			}
+			_, value, _, err := rlp.Split(it.Value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to decode storage value of %v", addr)
+			}
+			storage = append(storage, genesiscfg.StateEntry{
+				Key:   common.BytesToHash(key).Bytes(),
+				Value: common.BytesToHash(value).Bytes(),
+			})
+		}
+		if it.Err != nil {
+			return nil, errors.Wrapf(it.Err, "failed to iterate storage of %v", addr)
+		}
+	}
+
+	return &genesiscfg.ContractState{
+		Storage: storage,
+		Code:    code,
+		Nonce:   sdb.GetNonce(ethAddr),
+		Balance: sdb.GetBalance(ethAddr).String(),
+	}, nil
+}
+
+// ImportContractState writes the code, nonce, balance, and storage of an exported EVM contract to
+// the EVM state, the contract will be placed at the given address.
+func ImportContractState(
+	diademState diademchain.State, evmDB dbm.DB, addr diadem.Address, contractState *genesiscfg.ContractState,
+) error {
+	levm, err := NewDiademEvm(diademState, evmDB, nil, nil, false)
+	if err != nil {
+		return err
+	}
+
+	ethAddr := common.BytesToAddress(addr.Local)
+	if len(levm.sdb.GetCode(ethAddr)) != 0 {
+		return fmt.Errorf("EVM contract already exists at %v", addr)
+	}
+	levm.sdb.CreateAccount(ethAddr)
+	levm.sdb.SetCode(ethAddr, contractState.Code)
+	levm.sdb.SetNonce(ethAddr, contractState.Nonce)
+	if contractState.Balance != "" {
+		balance, ok := new(big.Int).SetString(contractState.Balance, 10)
+		if !ok {
+			return fmt.Errorf("invalid balance %s for %v", contractState.Balance, addr)
+		}
+		levm.sdb.AddBalance(ethAddr, balance)
+	}
+	for _, entry := range contractState.Storage {
+		levm.sdb.SetState(ethAddr, common.BytesToHash(entry.Key), common.BytesToHash(entry.Value))
+	}
+
+	_, err = levm.Commit()
+	return err
+}
+
+// ExportAccountNonces returns the nonces of all the EVM accounts that aren't contracts (the nonces of
+// contracts are exported by ExportContractState), accounts with a zero nonce are skipped. The nonces
+// are read at the height of the given app state.
+func ExportAccountNonces(diademState diademchain.State, evmDB dbm.DB) ([]genesiscfg.AccountNonce, error) {
+	if len(diademState.Get(rootKey)) == 0 {
+		// nothing has been written to the EVM state yet
+		return nil, nil
+	}
+	sdb, err := exportStateDB(diademState, evmDB)
+	if err != nil {
+		return nil, err
+	}
+	var nonces []genesiscfg.AccountNonce
+	for addr, account := range sdb.RawDump().Accounts {
+		if account.Nonce == 0 || len(account.Code) > 0 {
+			continue
+		}
+		nonces = append(nonces, genesiscfg.AccountNonce{
+			Address: common.HexToAddress(addr).Hex(),
+			Nonce:   account.Nonce,
+		})
+	}
+	sort.Slice(nonces, func(i, j int) bool {
+		return nonces[i].Address < nonces[j].Address
+	})
+	return nonces, nil
+}
+
+// ImportAccountNonces sets the nonces of the given EVM accounts, creating any accounts that don't
+// exist yet.
+func ImportAccountNonces(diademState diademchain.State, evmDB dbm.DB, nonces []genesiscfg.AccountNonce) error {
+	if len(nonces) == 0 {
+		return nil
+	}
+	levm, err := NewDiademEvm(diademState, evmDB, nil, nil, false)
+	if err != nil {
+		return err
+	}
+	for _, entry := range nonces {
+		if !common.IsHexAddress(entry.Address) {
+			return fmt.Errorf("invalid EVM account address %s", entry.Address)
+		}
+		levm.sdb.SetNonce(common.HexToAddress(entry.Address), entry.Nonce)
+	}
+	_, err = levm.Commit()
+	return err
+}
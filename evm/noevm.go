@@ -3,8 +3,14 @@
 package evm
 
 import (
+	"context"
+	"errors"
+	"math/big"
+
 	"github.com/diademnetwork/diademchain"
+	genesiscfg "github.com/diademnetwork/diademchain/config/genesis"
 	lvm "github.com/diademnetwork/diademchain/vm"
+	"github.com/diademnetwork/go-diadem"
 	dbm "github.com/tendermint/tendermint/libs/db"
 )
 
@@ -27,3 +33,55 @@ func NewDiademVm(
 }
 
 func AddDiademPrecompiles() {}
+
+func ExportContractState(
+	diademState diademchain.State, evmDB dbm.DB, addr diadem.Address,
+) (*genesiscfg.ContractState, error) {
+	return nil, errors.New("EVM contracts are not supported in non-EVM builds")
+}
+
+func ImportContractState(
+	diademState diademchain.State, evmDB dbm.DB, addr diadem.Address, contractState *genesiscfg.ContractState,
+) error {
+	return errors.New("EVM contracts are not supported in non-EVM builds")
+}
+
+func ExportAccountNonces(diademState diademchain.State, evmDB dbm.DB) ([]genesiscfg.AccountNonce, error) {
+	return nil, nil
+}
+
+func ImportAccountNonces(diademState diademchain.State, evmDB dbm.DB, nonces []genesiscfg.AccountNonce) error {
+	if len(nonces) > 0 {
+		return errors.New("EVM accounts are not supported in non-EVM builds")
+	}
+	return nil
+}
+
+func EstimateGas(
+	diademState diademchain.State,
+	evmDB dbm.DB,
+	createABM AccountBalanceManagerFactoryFunc,
+	caller diadem.Address,
+	contract *diadem.Address,
+	input []byte,
+	value *diadem.BigUInt,
+	gasCap uint64,
+) (uint64, error) {
+	return 0, errors.New("EVM contracts are not supported in non-EVM builds")
+}
+
+func GasPrice() *big.Int {
+	return big.NewInt(0)
+}
+
+func TraceTx(
+	_ context.Context,
+	diademState diademchain.State,
+	evmDB dbm.DB,
+	createABM AccountBalanceManagerFactoryFunc,
+	replay []*TraceMessage,
+	msg *TraceMessage,
+	cfg *TraceConfig,
+) (interface{}, error) {
+	return nil, errors.New("EVM contracts are not supported in non-EVM builds")
+}
@@ -0,0 +1,106 @@
+package evm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+var (
+	// Selector of Error(string), which Solidity uses to encode the reason passed to require & revert.
+	revertErrorSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+	// Selector of Panic(uint256), which Solidity uses to encode failed asserts, overflows, etc.
+	revertPanicSelector = []byte{0x4e, 0x48, 0x7b, 0x71}
+
+	panicReasons = map[uint64]string{
+		0x00: "generic panic",
+		0x01: "assert(false)",
+		0x11: "arithmetic underflow or overflow",
+		0x12: "division or modulo by zero",
+		0x21: "enum overflow",
+		0x22: "invalid encoded storage byte array accessed",
+		0x31: "out-of-bounds array access; popping on an empty array",
+		0x32: "out-of-bounds access of an array or bytesN",
+		0x41: "out of memory",
+		0x51: "uninitialized function",
+	}
+)
+
+// RevertError is returned when a contract reverts the EVM execution, it contains the data the
+// contract passed to REVERT.
+type RevertError struct {
+	// Reason decoded from the revert data, empty if the data doesn't contain an Error(string) or
+	// a Panic(uint256), e.g. when the contract reverts with a custom error.
+	Reason string
+	Data   []byte
+}
+
+func NewRevertError(data []byte) *RevertError {
+	return &RevertError{
+		Reason: UnpackRevertReason(data),
+		Data:   data,
+	}
+}
+
+func (e *RevertError) Error() string {
+	if e.Reason == "" {
+		return "execution reverted"
+	}
+	return "execution reverted: " + e.Reason
+}
+
+// RevertReason returns the decoded revert reason, it's used to store the reason in the receipt of
+// the failed tx.
+func (e *RevertError) RevertReason() string {
+	return e.Reason
+}
+
+// ErrorCode returns the JSON-RPC error code Ethereum clients expect for reverted calls.
+func (e *RevertError) ErrorCode() int {
+	return 3
+}
+
+// ErrorData returns the hex-encoded revert data, clients need it to decode custom errors.
+func (e *RevertError) ErrorData() interface{} {
+	return "0x" + hex.EncodeToString(e.Data)
+}
+
+// UnpackRevertReason decodes the reason from the data passed to REVERT by a Solidity contract.
+// An empty string is returned if the data doesn't contain an Error(string) or a Panic(uint256).
+func UnpackRevertReason(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+	args := data[4:]
+	switch {
+	case bytes.Equal(data[:4], revertErrorSelector):
+		// the string is ABI encoded as the offset of the string, followed by its length & contents
+		if len(args) < 64 {
+			return ""
+		}
+		offset := new(big.Int).SetBytes(args[:32])
+		if !offset.IsUint64() || offset.Uint64() > uint64(len(args)-32) {
+			return ""
+		}
+		start := offset.Uint64() + 32
+		length := new(big.Int).SetBytes(args[start-32 : start])
+		if !length.IsUint64() || length.Uint64() > uint64(len(args))-start {
+			return ""
+		}
+		return string(args[start : start+length.Uint64()])
+
+	case bytes.Equal(data[:4], revertPanicSelector):
+		if len(args) != 32 {
+			return ""
+		}
+		code := new(big.Int).SetBytes(args)
+		if code.IsUint64() {
+			if reason, ok := panicReasons[code.Uint64()]; ok {
+				return reason
+			}
+		}
+		return fmt.Sprintf("unknown panic code: %#x", code)
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+// +build evm
+
+package evm
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/diademnetwork/go-diadem"
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+// revertReasonData returns the ABI encoded Error(string) data Solidity passes to REVERT.
+func revertReasonData(reason string) []byte {
+	data := append([]byte{}, revertErrorSelector...)
+	data = append(data, common.LeftPadBytes([]byte{0x20}, 32)...)
+	data = append(data, common.LeftPadBytes([]byte{byte(len(reason))}, 32)...)
+	return append(data, common.RightPadBytes([]byte(reason), 32)...)
+}
+
+func TestUnpackRevertReason(t *testing.T) {
+	require.Equal(t, "nope", UnpackRevertReason(revertReasonData("nope")))
+	require.Equal(t, "", UnpackRevertReason(revertReasonData("nope")[:40]))
+	require.Equal(t, "", UnpackRevertReason(nil))
+
+	panicData := append(append([]byte{}, revertPanicSelector...), common.LeftPadBytes([]byte{0x11}, 32)...)
+	require.Equal(t, "arithmetic underflow or overflow", UnpackRevertReason(panicData))
+
+	// custom errors can't be decoded without the contract ABI
+	customErr := NewRevertError([]byte{0xde, 0xad, 0xbe, 0xef, 0x01})
+	require.Equal(t, "", customErr.Reason)
+	require.Equal(t, "execution reverted", customErr.Error())
+	require.Equal(t, "0xdeadbeef01", customErr.ErrorData())
+}
+
+func TestRevertError(t *testing.T) {
+	caller := diadem.Address{
+		ChainID: "myChainID",
+		Local:   []byte("myCaller"),
+	}
+	levm, err := NewDiademEvm(mockState(), dbm.NewMemDB(), nil, nil, false)
+	require.NoError(t, err)
+
+	// init code that copies the revert data that follows it to memory, and then reverts with it
+	data := revertReasonData("nope")
+	code, err := hex.DecodeString("6064600c60003960646000fd")
+	require.NoError(t, err)
+	require.Len(t, data, 0x64)
+	code = append(code, data...)
+
+	_, _, err = levm.Create(caller, code, nil)
+	require.Error(t, err)
+	revertErr, ok := err.(*RevertError)
+	require.True(t, ok)
+	require.Equal(t, "nope", revertErr.Reason)
+	require.Equal(t, data, revertErr.Data)
+	require.Equal(t, "execution reverted: nope", revertErr.Error())
+	require.Equal(t, 3, revertErr.ErrorCode())
+}
@@ -0,0 +1,154 @@
+// +build evm
+
+package evm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/diademnetwork/diademchain"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+// ExecutionResult is the trace produced by the struct logger, it's compatible with the output of
+// debug_traceTransaction in go-ethereum.
+type ExecutionResult struct {
+	Gas         uint64         `json:"gas"`
+	Failed      bool           `json:"failed"`
+	ReturnValue string         `json:"returnValue"`
+	StructLogs  []StructLogRes `json:"structLogs"`
+}
+
+// StructLogRes describes a single step of the EVM execution.
+type StructLogRes struct {
+	Pc      uint64             `json:"pc"`
+	Op      string             `json:"op"`
+	Gas     uint64             `json:"gas"`
+	GasCost uint64             `json:"gasCost"`
+	Depth   int                `json:"depth"`
+	Error   string             `json:"error,omitempty"`
+	Stack   *[]string          `json:"stack,omitempty"`
+	Memory  *[]string          `json:"memory,omitempty"`
+	Storage *map[string]string `json:"storage,omitempty"`
+}
+
+// TraceTx re-executes an EVM tx against the given state, and returns the trace produced by the tracer
+// specified in the config. The txs in replay are executed (without being traced) before the traced
+// tx, they should be the EVM txs that preceded the traced tx in the same block. None of the changes
+// made by the txs are persisted, but any writes made to the Diadem state by Go contracts will be, so
+// the given state should be disposable. The replay is abandoned if the context is cancelled.
+func TraceTx(
+	ctx context.Context,
+	diademState diademchain.State,
+	evmDB dbm.DB,
+	createABM AccountBalanceManagerFactoryFunc,
+	replay []*TraceMessage,
+	msg *TraceMessage,
+	cfg *TraceConfig,
+) (interface{}, error) {
+	var abm AccountBalanceManager
+	if createABM != nil {
+		abm = createABM(true)
+	}
+	levm, err := NewDiademEvm(diademState, evmDB, abm, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range replay {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// changes made by failed txs are discarded when the block is executed
+		snapshot := levm.sdb.Snapshot()
+		if _, _, err := levm.execute(m, gasLimit); err != nil {
+			levm.sdb.RevertToSnapshot(snapshot)
+		}
+	}
+
+	switch cfg.Tracer {
+	case "":
+		logger := vm.NewStructLogger(&vm.LogConfig{
+			DisableMemory:  cfg.DisableMemory,
+			DisableStack:   cfg.DisableStack,
+			DisableStorage: cfg.DisableStorage,
+			Limit:          cfg.Limit,
+		})
+		levm.vmConfig = tracingVmConfig(logger)
+		ret, usedGas, err := levm.execute(msg, gasLimit)
+		return &ExecutionResult{
+			Gas:         usedGas,
+			Failed:      err != nil,
+			ReturnValue: fmt.Sprintf("%x", ret),
+			StructLogs:  formatStructLogs(logger.StructLogs()),
+		}, nil
+
+	case CallTracer:
+		tracer := newCallTracer()
+		levm.vmConfig = tracingVmConfig(tracer)
+		// the error (if any) is recorded by the tracer
+		levm.execute(msg, gasLimit)
+		return tracer.result(), nil
+
+	default:
+		return nil, fmt.Errorf("tracer %s not supported", cfg.Tracer)
+	}
+}
+
+// execute runs a tx with the given gas limit, and returns the amount of gas used.
+func (e Evm) execute(msg *TraceMessage, gas uint64) ([]byte, uint64, error) {
+	if msg.Contract == nil {
+		ret, _, usedGas, err := e.create(msg.Caller, msg.Input, msg.Value, gas)
+		return ret, usedGas, err
+	}
+	return e.call(msg.Caller, *msg.Contract, msg.Input, msg.Value, gas)
+}
+
+func tracingVmConfig(tracer vm.Tracer) vm.Config {
+	cfg := defaultVmConfig(false)
+	cfg.Debug = true
+	cfg.Tracer = tracer
+	return cfg
+}
+
+// formatStructLogs converts the steps captured by the struct logger to the format used by
+// go-ethereum in the output of debug_traceTransaction.
+func formatStructLogs(logs []vm.StructLog) []StructLogRes {
+	formatted := make([]StructLogRes, len(logs))
+	for i, log := range logs {
+		formatted[i] = StructLogRes{
+			Pc:      log.Pc,
+			Op:      log.Op.String(),
+			Gas:     log.Gas,
+			GasCost: log.GasCost,
+			Depth:   log.Depth,
+		}
+		if log.Err != nil {
+			formatted[i].Error = log.Err.Error()
+		}
+		if log.Stack != nil {
+			stack := make([]string, len(log.Stack))
+			for j, value := range log.Stack {
+				stack[j] = fmt.Sprintf("%x", math.PaddedBigBytes(value, 32))
+			}
+			formatted[i].Stack = &stack
+		}
+		if log.Memory != nil {
+			memory := make([]string, 0, (len(log.Memory)+31)/32)
+			for j := 0; j+32 <= len(log.Memory); j += 32 {
+				memory = append(memory, fmt.Sprintf("%x", log.Memory[j:j+32]))
+			}
+			formatted[i].Memory = &memory
+		}
+		if log.Storage != nil {
+			storage := make(map[string]string)
+			for key, value := range log.Storage {
+				storage[fmt.Sprintf("%x", key)] = fmt.Sprintf("%x", value)
+			}
+			formatted[i].Storage = &storage
+		}
+	}
+	return formatted
+}
@@ -0,0 +1,32 @@
+package evm
+
+import (
+	"github.com/diademnetwork/go-diadem"
+)
+
+// CallTracer is the name of the tracer that produces a tree of the calls made by a tx, it's
+// compatible with the callTracer built into go-ethereum.
+const CallTracer = "callTracer"
+
+// TraceConfig specifies how EVM txs should be traced, it mirrors the options accepted by the
+// debug_traceTransaction method in go-ethereum.
+type TraceConfig struct {
+	// By default the struct logger captures the memory, stack, and storage of each step.
+	DisableMemory  bool
+	DisableStack   bool
+	DisableStorage bool
+	// Maximum number of steps the struct logger should capture, zero means unlimited.
+	Limit int
+	// Name of the tracer to use, if empty the struct logger will be used.
+	Tracer string
+}
+
+// TraceMessage describes an EVM tx that should be re-executed by TraceTx.
+type TraceMessage struct {
+	Caller diadem.Address
+	// Address of the contract that should be called, nil if the tx deploys a contract.
+	Contract *diadem.Address
+	// Call input, or the contract bytecode if the tx deploys a contract.
+	Input []byte
+	Value *diadem.BigUInt
+}
@@ -0,0 +1,93 @@
+// +build evm
+
+package evm
+
+import (
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/diademnetwork/go-diadem"
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+func TestTraceTx(t *testing.T) {
+	caller := diadem.Address{
+		ChainID: "myChainID",
+		Local:   []byte("myCaller"),
+	}
+	state := mockState()
+	evmDB := dbm.NewMemDB()
+
+	vm := NewDiademVm(state, evmDB, nil, nil, nil, false)
+	abiGP, contractAddr := deploySolContract(t, caller, "GlobalProperties", vm)
+	input, err := abiGP.Pack("txGasPrice")
+	require.NoError(t, err)
+	msg := &TraceMessage{
+		Caller:   caller,
+		Contract: &contractAddr,
+		Input:    input,
+	}
+	ret, err := vm.StaticCall(caller, contractAddr, input)
+	require.NoError(t, err)
+
+	result, err := TraceTx(context.Background(), state, evmDB, nil, nil, msg, &TraceConfig{})
+	require.NoError(t, err)
+	execResult := result.(*ExecutionResult)
+	require.False(t, execResult.Failed)
+	require.Equal(t, hex.EncodeToString(ret), execResult.ReturnValue)
+	require.NotEmpty(t, execResult.StructLogs)
+	require.Equal(t, "PUSH1", execResult.StructLogs[0].Op)
+	require.Equal(t, 1, execResult.StructLogs[0].Depth)
+	require.NotNil(t, execResult.StructLogs[0].Stack)
+	require.NotNil(t, execResult.StructLogs[0].Memory)
+
+	// capture can be disabled, and the number of steps limited
+	result, err = TraceTx(context.Background(), state, evmDB, nil, nil, msg, &TraceConfig{
+		DisableMemory:  true,
+		DisableStack:   true,
+		DisableStorage: true,
+		Limit:          2,
+	})
+	require.NoError(t, err)
+	execResult = result.(*ExecutionResult)
+	require.Len(t, execResult.StructLogs, 2)
+	for _, log := range execResult.StructLogs {
+		require.Nil(t, log.Stack)
+		require.Nil(t, log.Memory)
+		require.Nil(t, log.Storage)
+	}
+
+	result, err = TraceTx(context.Background(), state, evmDB, nil, nil, msg, &TraceConfig{Tracer: CallTracer})
+	require.NoError(t, err)
+	call := result.(*CallFrame)
+	require.Equal(t, "CALL", call.Type)
+	require.Equal(t, hexutil.Encode(common.BytesToAddress(caller.Local).Bytes()), call.From)
+	require.Equal(t, hexutil.Encode(contractAddr.Local), call.To)
+	require.Equal(t, hexutil.Encode(input), call.Input)
+	require.Equal(t, hexutil.Encode(ret), call.Output)
+	require.Empty(t, call.Error)
+	require.Empty(t, call.Calls)
+
+	// tracing a deployment shouldn't deploy the contract
+	bytetext, err := ioutil.ReadFile("testdata/GlobalProperties.bin")
+	require.NoError(t, err)
+	bytecode, err := hex.DecodeString(string(bytetext))
+	require.NoError(t, err)
+	root := state.Get(rootKey)
+	result, err = TraceTx(context.Background(), state, evmDB, nil, nil, &TraceMessage{Caller: caller, Input: bytecode}, &TraceConfig{
+		Tracer: CallTracer,
+	})
+	require.NoError(t, err)
+	call = result.(*CallFrame)
+	require.Equal(t, "CREATE", call.Type)
+	require.NotEmpty(t, call.Output)
+	require.Equal(t, root, state.Get(rootKey))
+
+	_, err = TraceTx(context.Background(), state, evmDB, nil, nil, msg, &TraceConfig{Tracer: "prestateTracer"})
+	require.Error(t, err)
+}
@@ -6,9 +6,20 @@ const (
 	TGCheckTxHashFeature = "tg:check-txhash"
 
 	// Enables processing of txs via MultiChainSignatureTxMiddleware, there's a feature flag per
-	// allowed chain ID, e.g. auth:sigtx:default, auth:sigtx:eth
+	// allowed chain ID, e.g. auth:sigtx:default, auth:sigtx:eth, auth:sigtx:multisig
 	AuthSigTxFeaturePrefix = "auth:sigtx:"
 
+	// Enables processing of EIP-155 RLP encoded Ethereum txs via MultiChainSignatureTxMiddleware,
+	// the chain the tx sender is mapped from must also be enabled, e.g. auth:sigtx:eth
+	AuthSigTxEthRLPFeature = "auth:sigtx"
+
+	// Enables RotateKeyTx, and resolution of the accounts controlled by rotated ed25519 keys.
+	AuthKeyRotationFeature = "auth:key-rotation"
+
+	// Enables session keys, which can sign a limited number of txs calling specific contracts on
+	// behalf of the accounts that granted them.
+	AuthSessionKeysFeature = "auth:session-keys"
+
 	// Enables DPOS v3
 	// NOTE: The DPOS v3 contract must be loaded & deployed first!
 	DPOSVersion3Feature = "dpos:v3"
@@ -24,6 +35,10 @@ const (
 	// deploy contracts & run migrations.
 	DeployerWhitelistFeature = "mw:deploy-wl"
 
+	// Enables charging of tx fees via TxFeeMiddleware, the middleware must also be enabled in the
+	// TxFees section of the config.
+	TxFeesFeature = "tx:fees"
+
 	// Enables processing of MigrationTx.
 	MigrationTxFeature = "tx:migration"
 
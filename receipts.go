@@ -14,12 +14,18 @@ type ReadReceiptHandler interface {
 	GetPendingReceipt(txHash []byte) (types.EvmTxReceipt, error)
 	GetPendingTxHashList() [][]byte
 	GetCurrentReceipt() *types.EvmTxReceipt
+	// GetRevertReason returns the reason the contract gave for reverting a failed tx, or an empty
+	// string if the reason isn't known.
+	GetRevertReason(txHash []byte) string
 }
 
 type ReceiptHandlerStore interface {
 	SetFailStatusCurrentReceipt()
 	CommitBlock(state State, height int64) error
 	CommitCurrentReceipt()
+	// CommitFailedReceipt keeps the receipt of the given failed tx if receipts are stored outside
+	// the app state, otherwise the receipt is discarded.
+	CommitFailedReceipt(txBytes []byte)
 	DiscardCurrentReceipt()
 	ClearData() error
 	Close() error
@@ -22,6 +22,7 @@ func DepreciatedWriteReceipt(
 	events []*types.EventData,
 	status int32,
 	eventHadler diademchain.EventHandler,
+	txHash []byte,
 ) (types.EvmTxReceipt, error) {
 	txReceipt := types.EvmTxReceipt{
 		TransactionIndex:  block.NumTxs,
@@ -35,13 +36,16 @@ func DepreciatedWriteReceipt(
 		CallerAddress:     caller.MarshalPB(),
 	}
 
-	preTxReceipt, err := proto.Marshal(&txReceipt)
-	if err != nil {
-		return types.EvmTxReceipt{}, errors.Wrapf(err, "marshalling receipt")
+	// txs that don't have a hash of their own are identified by the hash of the receipt
+	if len(txHash) == 0 {
+		preTxReceipt, err := proto.Marshal(&txReceipt)
+		if err != nil {
+			return types.EvmTxReceipt{}, errors.Wrapf(err, "marshalling receipt")
+		}
+		h := sha256.New()
+		h.Write(preTxReceipt)
+		txHash = h.Sum(nil)
 	}
-	h := sha256.New()
-	h.Write(preTxReceipt)
-	txHash := h.Sum(nil)
 
 	txReceipt.TxHash = txHash
 	blockHeight := uint64(txReceipt.BlockNumber)
@@ -54,6 +54,10 @@ func (r *ReceiptHandler) GetPendingTxHashList() [][]byte {
 	return nil
 }
 
+func (r *ReceiptHandler) GetRevertReason(txHash []byte) string {
+	return ""
+}
+
 func (r *ReceiptHandler) Close() error {
 	return nil
 }
@@ -65,6 +69,9 @@ func (r *ReceiptHandler) ClearData() error {
 func (r *ReceiptHandler) CommitCurrentReceipt() {
 }
 
+func (r *ReceiptHandler) CommitFailedReceipt(txBytes []byte) {
+}
+
 func (r *ReceiptHandler) DiscardCurrentReceipt() {
 }
 
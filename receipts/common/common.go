@@ -54,6 +54,15 @@ func SetBdiademFilter(state diademchain.State, filter []byte, height uint64) {
 	bdiademState.Set(BlockHeightToBytes(height), filter)
 }
 
+// RevertReason returns the reason a contract gave for reverting a tx, or an empty string if the
+// tx failed for some other reason.
+func RevertReason(err error) string {
+	if revertErr, ok := errors.Cause(err).(interface{ RevertReason() string }); ok {
+		return revertErr.RevertReason()
+	}
+	return ""
+}
+
 func BlockHeightToBytes(height uint64) []byte {
 	heightB := make([]byte, 8)
 	binary.LittleEndian.PutUint64(heightB, height)
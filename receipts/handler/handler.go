@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"sync"
 
 	"github.com/diademnetwork/go-diadem"
@@ -12,6 +13,7 @@ import (
 	"github.com/diademnetwork/diademchain/receipts/common"
 	"github.com/diademnetwork/diademchain/receipts/leveldb"
 	"github.com/pkg/errors"
+	"github.com/tendermint/tendermint/crypto/tmhash"
 )
 
 type ReceiptHandlerVersion int32
@@ -46,16 +48,30 @@ type ReceiptHandler struct {
 	mutex         *sync.RWMutex
 	receiptsCache []*types.EvmTxReceipt
 	txHashList    [][]byte
+	// Revert reasons of the failed txs in receiptsCache, keyed by tx hash.
+	revertReasons map[string]string
 
-	currentReceipt *types.EvmTxReceipt
+	currentReceipt      *types.EvmTxReceipt
+	currentRevertReason string
+	// Set if the current receipt is identified by the hash of an Ethereum tx.
+	currentEthTxHash bool
 }
 
 func NewReceiptHandler(version ReceiptHandlerVersion, eventHandler diademchain.EventHandler, maxReceipts uint64) (*ReceiptHandler, error) {
+	return NewReceiptHandlerWithDBBackend(version, eventHandler, maxReceipts, "")
+}
+
+// NewReceiptHandlerWithDBBackend creates a new ReceiptHandler, if the receipts are stored outside
+// the app state they will be stored in a DB with the given backend (goleveldb by default).
+func NewReceiptHandlerWithDBBackend(
+	version ReceiptHandlerVersion, eventHandler diademchain.EventHandler, maxReceipts uint64, dbBackend string,
+) (*ReceiptHandler, error) {
 	rh := &ReceiptHandler{
 		v:              version,
 		eventHandler:   eventHandler,
 		receiptsCache:  []*types.EvmTxReceipt{},
 		txHashList:     [][]byte{},
+		revertReasons:  map[string]string{},
 		currentReceipt: nil,
 		mutex:          &sync.RWMutex{},
 	}
@@ -64,7 +80,7 @@ func NewReceiptHandler(version ReceiptHandlerVersion, eventHandler diademchain.E
 	case ReceiptHandlerChain:
 		rh.chainReceipts = &chain.StateDBReceipts{}
 	case ReceiptHandlerLevelDb:
-		leveldbHandler, err := leveldb.NewLevelDbReceipts(maxReceipts)
+		leveldbHandler, err := leveldb.NewLevelDbReceiptsWithDBBackend(dbBackend, maxReceipts)
 		if err != nil {
 			return nil, errors.Wrap(err, "new leved db receipt handler")
 		}
@@ -114,6 +130,20 @@ func (r *ReceiptHandler) GetCurrentReceipt() *types.EvmTxReceipt {
 	return r.currentReceipt
 }
 
+func (r *ReceiptHandler) GetRevertReason(txHash []byte) string {
+	r.mutex.RLock()
+	reason, ok := r.revertReasons[string(txHash)]
+	r.mutex.RUnlock()
+	if ok || r.v != ReceiptHandlerLevelDb {
+		return reason
+	}
+	reason, err := r.leveldbReceipts.GetRevertReason(txHash)
+	if err != nil {
+		return ""
+	}
+	return reason
+}
+
 func (r *ReceiptHandler) GetPendingTxHashList() [][]byte {
 	r.mutex.RLock()
 	hashListCopy := make([][]byte, len(r.txHashList))
@@ -157,10 +187,48 @@ func (r *ReceiptHandler) CommitCurrentReceipt() {
 
 		r.currentReceipt = nil
 	}
+	r.currentRevertReason = ""
+	r.currentEthTxHash = false
+}
+
+// CommitFailedReceipt keeps the receipt of a failed tx, along with the reason the tx was reverted,
+// so they can be looked up by the tx hash. Failed txs don't modify the app state, so their
+// receipts are only kept when receipts are stored in the node-local receipts DB. The receipt isn't
+// added to the pending tx hash list since the tx isn't included in the block's tx hash list.
+// Failed txs don't advance the caller's nonce, so identical failed txs would end up with identical
+// receipts, unless the receipt is identified by the hash of an Ethereum tx its hash is mixed with
+// the hash of the given Tendermint tx to keep it unique.
+func (r *ReceiptHandler) CommitFailedReceipt(txBytes []byte) {
+	if r.currentReceipt == nil || r.v != ReceiptHandlerLevelDb {
+		r.DiscardCurrentReceipt()
+		return
+	}
+	r.currentReceipt.Status = common.StatusTxFail
+	if !r.currentEthTxHash {
+		h := sha256.New()
+		h.Write(r.currentReceipt.TxHash)
+		h.Write(tmhash.Sum(txBytes))
+		r.currentReceipt.TxHash = h.Sum(nil)
+		for _, event := range r.currentReceipt.Logs {
+			event.TxHash = r.currentReceipt.TxHash
+		}
+	}
+	r.mutex.Lock()
+	r.receiptsCache = append(r.receiptsCache, r.currentReceipt)
+	if r.currentRevertReason != "" {
+		r.revertReasons[string(r.currentReceipt.TxHash)] = r.currentRevertReason
+	}
+	r.mutex.Unlock()
+
+	r.currentReceipt = nil
+	r.currentRevertReason = ""
+	r.currentEthTxHash = false
 }
 
 func (r *ReceiptHandler) DiscardCurrentReceipt() {
 	r.currentReceipt = nil
+	r.currentRevertReason = ""
+	r.currentEthTxHash = false
 }
 
 func (r *ReceiptHandler) CommitBlock(state diademchain.State, height int64) error {
@@ -173,7 +241,7 @@ func (r *ReceiptHandler) CommitBlock(state diademchain.State, height int64) erro
 		r.mutex.RUnlock()
 	case ReceiptHandlerLevelDb:
 		r.mutex.RLock()
-		err = r.leveldbReceipts.CommitBlock(state, r.receiptsCache, uint64(height))
+		err = r.leveldbReceipts.CommitBlock(state, r.receiptsCache, r.revertReasons, uint64(height))
 		r.mutex.RUnlock()
 	default:
 		err = diademchain.ErrInvalidVersion
@@ -182,6 +250,7 @@ func (r *ReceiptHandler) CommitBlock(state diademchain.State, height int64) erro
 	r.mutex.Lock()
 	r.txHashList = [][]byte{}
 	r.receiptsCache = []*types.EvmTxReceipt{}
+	r.revertReasons = map[string]string{}
 	r.mutex.Unlock()
 
 	return err
@@ -196,16 +265,22 @@ func (r *ReceiptHandler) CacheReceipt(state diademchain.State, caller, addr diad
 		status = common.StatusTxFail
 	}
 
+	// Ethereum txs are identified by their own hash
+	var txHash []byte
+	if ethTx := auth.EthTxFromContext(state.Context()); ethTx != nil {
+		txHash = ethTx.Hash
+	}
+
 	var err error
 	var receipt types.EvmTxReceipt
 	switch r.v {
 	case ReceiptHandlerChain:
 		r.mutex.RLock()
-		receipt, err = chain.DepreciatedWriteReceipt(state.Block(), caller, addr, events, status, r.eventHandler)
+		receipt, err = chain.DepreciatedWriteReceipt(state.Block(), caller, addr, events, status, r.eventHandler, txHash)
 		r.mutex.RUnlock()
 	case ReceiptHandlerLevelDb:
 		r.mutex.RLock()
-		receipt, err = leveldb.WriteReceipt(state.Block(), caller, addr, events, status, r.eventHandler, int32(len(r.receiptsCache)), int64(auth.Nonce(state, caller)))
+		receipt, err = leveldb.WriteReceipt(state.Block(), caller, addr, events, status, r.eventHandler, r.numSuccessfulReceipts(), int64(auth.Nonce(state, caller)), txHash)
 		r.mutex.RUnlock()
 	default:
 		err = diademchain.ErrInvalidVersion
@@ -215,9 +290,23 @@ func (r *ReceiptHandler) CacheReceipt(state diademchain.State, caller, addr diad
 		return []byte{}, errors.Wrap(err, "receipt not written, returning empty hash")
 	}
 	r.currentReceipt = &receipt
+	r.currentRevertReason = common.RevertReason(txErr)
+	r.currentEthTxHash = len(txHash) > 0
 	return r.currentReceipt.TxHash, err
 }
 
+// numSuccessfulReceipts returns the number of cached receipts of successful txs, the receipts of
+// failed txs don't take up a tx index in the block since they're not in the block's tx hash list.
+func (r *ReceiptHandler) numSuccessfulReceipts() int32 {
+	count := int32(0)
+	for _, receipt := range r.receiptsCache {
+		if receipt.Status == common.StatusTxSuccess {
+			count++
+		}
+	}
+	return count
+}
+
 func (r *ReceiptHandler) SetFailStatusCurrentReceipt() {
 	if r.currentReceipt != nil {
 		r.currentReceipt.Status = common.StatusTxFail
@@ -192,3 +192,86 @@ func testHandler(t *testing.T, v ReceiptHandlerVersion) {
 	require.NoError(t, receiptHandler.Close())
 	require.NoError(t, receiptHandler.ClearData())
 }
+
+type revertError struct {
+	reason string
+}
+
+func (e *revertError) Error() string {
+	return "execution reverted: " + e.reason
+}
+
+func (e *revertError) RevertReason() string {
+	return e.reason
+}
+
+func TestReceiptsHandlerFailedReceipts(t *testing.T) {
+	os.RemoveAll(leveldb.Db_Filename)
+	height := uint64(1)
+	state := common.MockState(height)
+
+	handler, err := NewReceiptHandler(ReceiptHandlerLevelDb, &diademchain.DefaultEventHandler{}, DefaultMaxReceipts)
+	require.NoError(t, err)
+
+	// the app state shouldn't be modified if none of the txs in the block were successful
+	stateI := common.MockStateTx(state, height, 0)
+	_, err = handler.CacheReceipt(stateI, addr1, addr2, []*types.EventData{}, &revertError{reason: "nope"})
+	require.NoError(t, err)
+	handler.CommitFailedReceipt([]byte("tx1"))
+	require.NoError(t, handler.CommitBlock(state, int64(height)))
+	txHashList, err := common.GetTxHashList(state, height)
+	require.NoError(t, err)
+	require.Len(t, txHashList, 0)
+	require.Len(t, common.GetBdiademFilter(state, height), 0)
+
+	// identical failed txs shouldn't share a receipt
+	stateI = common.MockStateTx(state, height, 0)
+	_, err = handler.CacheReceipt(stateI, addr1, addr2, []*types.EventData{}, &revertError{reason: "nope"})
+	require.NoError(t, err)
+	handler.CommitFailedReceipt([]byte("tx2"))
+	_, err = handler.CacheReceipt(stateI, addr1, addr2, []*types.EventData{}, &revertError{reason: "nope"})
+	require.NoError(t, err)
+	handler.CommitFailedReceipt([]byte("tx3"))
+	require.Len(t, handler.receiptsCache, 2)
+	failedTxHash := handler.receiptsCache[0].TxHash
+	require.NotEqual(t, failedTxHash, handler.receiptsCache[1].TxHash)
+
+	stateI = common.MockStateTx(state, height, 1)
+	txHash, err := handler.CacheReceipt(stateI, addr1, addr2, []*types.EventData{}, nil)
+	require.NoError(t, err)
+	handler.CommitCurrentReceipt()
+
+	// failed txs aren't pending since they won't be in the block's tx hash list
+	require.Equal(t, [][]byte{txHash}, handler.GetPendingTxHashList())
+	require.Equal(t, "nope", handler.GetRevertReason(failedTxHash))
+
+	require.NoError(t, handler.CommitBlock(state, int64(height)))
+
+	txHashList, err = common.GetTxHashList(state, height)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{txHash}, txHashList)
+
+	failedReceipt, err := handler.GetReceipt(state, failedTxHash)
+	require.NoError(t, err)
+	require.EqualValues(t, common.StatusTxFail, failedReceipt.Status)
+	require.Equal(t, "nope", handler.GetRevertReason(failedTxHash))
+
+	// failed txs don't take up a tx index
+	receipt, err := handler.GetReceipt(state, txHash)
+	require.NoError(t, err)
+	require.EqualValues(t, common.StatusTxSuccess, receipt.Status)
+	require.EqualValues(t, 0, receipt.TransactionIndex)
+	require.Equal(t, "", handler.GetRevertReason(txHash))
+
+	require.NoError(t, handler.Close())
+	require.NoError(t, handler.ClearData())
+
+	// receipts stored in the app state are only kept for successful txs
+	chainHandler, err := NewReceiptHandler(ReceiptHandlerChain, &diademchain.DefaultEventHandler{}, DefaultMaxReceipts)
+	require.NoError(t, err)
+	_, err = chainHandler.CacheReceipt(stateI, addr1, addr2, []*types.EventData{}, &revertError{reason: "nope"})
+	require.NoError(t, err)
+	chainHandler.CommitFailedReceipt([]byte("tx4"))
+	require.Len(t, chainHandler.receiptsCache, 0)
+	require.Nil(t, chainHandler.GetCurrentReceipt())
+}
@@ -0,0 +1,167 @@
+package leveldb
+
+import (
+	cdb "github.com/diademnetwork/diademchain/db"
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+var errNotFound = errors.New("not found")
+
+type receiptsReader interface {
+	// Get returns an error if the key doesn't exist.
+	Get(key []byte) ([]byte, error)
+	Has(key []byte) (bool, error)
+}
+
+// receiptsDB is the key-value store the receipts are persisted to.
+type receiptsDB interface {
+	receiptsReader
+	OpenTransaction() (receiptsTx, error)
+	Close() error
+}
+
+// receiptsTx must be able to read back any changes written to it before they're committed.
+type receiptsTx interface {
+	receiptsReader
+	Put(key, value []byte) error
+	Delete(key []byte) error
+	Commit() error
+	Discard()
+}
+
+// goLevelDBReceiptsDB stores receipts in a GoLevelDB database, this is the original receipts
+// storage format, and uses Db_Filename as the location of the database.
+type goLevelDBReceiptsDB struct {
+	*leveldb.DB
+}
+
+func (db *goLevelDBReceiptsDB) Get(key []byte) ([]byte, error) {
+	return db.DB.Get(key, nil)
+}
+
+func (db *goLevelDBReceiptsDB) Has(key []byte) (bool, error) {
+	return db.DB.Has(key, nil)
+}
+
+func (db *goLevelDBReceiptsDB) OpenTransaction() (receiptsTx, error) {
+	tran, err := db.DB.OpenTransaction()
+	if err != nil {
+		return nil, err
+	}
+	return &goLevelDBReceiptsTx{Transaction: tran}, nil
+}
+
+type goLevelDBReceiptsTx struct {
+	*leveldb.Transaction
+}
+
+func (tx *goLevelDBReceiptsTx) Get(key []byte) ([]byte, error) {
+	return tx.Transaction.Get(key, nil)
+}
+
+func (tx *goLevelDBReceiptsTx) Has(key []byte) (bool, error) {
+	return tx.Transaction.Has(key, nil)
+}
+
+func (tx *goLevelDBReceiptsTx) Put(key, value []byte) error {
+	return tx.Transaction.Put(key, value, nil)
+}
+
+func (tx *goLevelDBReceiptsTx) Delete(key []byte) error {
+	return tx.Transaction.Delete(key, nil)
+}
+
+// dbWrapperReceiptsDB stores receipts in any of the DB backends supported by db.LoadDB.
+type dbWrapperReceiptsDB struct {
+	db cdb.DBWrapper
+}
+
+func (db *dbWrapperReceiptsDB) Get(key []byte) ([]byte, error) {
+	val := db.db.Get(key)
+	if val == nil {
+		return nil, errNotFound
+	}
+	return val, nil
+}
+
+func (db *dbWrapperReceiptsDB) Has(key []byte) (bool, error) {
+	return db.db.Has(key), nil
+}
+
+func (db *dbWrapperReceiptsDB) OpenTransaction() (receiptsTx, error) {
+	return &dbWrapperReceiptsTx{
+		db:      db.db,
+		batch:   db.db.NewBatch(),
+		pending: map[string][]byte{},
+		deleted: map[string]bool{},
+	}, nil
+}
+
+func (db *dbWrapperReceiptsDB) Close() error {
+	db.db.Close()
+	return nil
+}
+
+// dbWrapperReceiptsTx buffers all changes in a batch, which is written out to the DB on commit.
+// Pending changes are tracked separately so they can be read back before the commit.
+type dbWrapperReceiptsTx struct {
+	db      dbm.DB
+	batch   dbm.Batch
+	pending map[string][]byte
+	deleted map[string]bool
+}
+
+func (tx *dbWrapperReceiptsTx) Get(key []byte) ([]byte, error) {
+	if tx.deleted[string(key)] {
+		return nil, errNotFound
+	}
+	if val, ok := tx.pending[string(key)]; ok {
+		return val, nil
+	}
+	val := tx.db.Get(key)
+	if val == nil {
+		return nil, errNotFound
+	}
+	return val, nil
+}
+
+func (tx *dbWrapperReceiptsTx) Has(key []byte) (bool, error) {
+	if tx.deleted[string(key)] {
+		return false, nil
+	}
+	if _, ok := tx.pending[string(key)]; ok {
+		return true, nil
+	}
+	return tx.db.Has(key), nil
+}
+
+func (tx *dbWrapperReceiptsTx) Put(key, value []byte) error {
+	delete(tx.deleted, string(key))
+	tx.pending[string(key)] = value
+	tx.batch.Set(key, value)
+	return nil
+}
+
+func (tx *dbWrapperReceiptsTx) Delete(key []byte) error {
+	delete(tx.pending, string(key))
+	tx.deleted[string(key)] = true
+	tx.batch.Delete(key)
+	return nil
+}
+
+func (tx *dbWrapperReceiptsTx) Commit() error {
+	if tx.batch == nil {
+		return errors.New("transaction already closed")
+	}
+	tx.batch.WriteSync()
+	tx.Discard()
+	return nil
+}
+
+func (tx *dbWrapperReceiptsTx) Discard() {
+	tx.batch = nil
+	tx.pending = nil
+	tx.deleted = nil
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/diademnetwork/go-diadem/plugin/types"
 	diadem_types "github.com/diademnetwork/go-diadem/types"
 	"github.com/diademnetwork/diademchain"
+	cdb "github.com/diademnetwork/diademchain/db"
 	"github.com/diademnetwork/diademchain/eth/bdiadem"
 	"github.com/diademnetwork/diademchain/log"
 	"github.com/diademnetwork/diademchain/receipts/common"
@@ -26,8 +27,14 @@ var (
 	headKey          = []byte("leveldb:head")
 	tailKey          = []byte("leveldb:tail")
 	currentDbSizeKey = []byte("leveldb:size")
+	// Prefix of the keys the revert reasons of failed txs are stored under.
+	revertReasonPrefix = []byte("leveldb:revert:")
 )
 
+func revertReasonKey(txHash []byte) []byte {
+	return append(append([]byte{}, revertReasonPrefix...), txHash...)
+}
+
 func WriteReceipt(
 	block diadem_types.BlockHeader,
 	caller, addr diadem.Address,
@@ -36,6 +43,7 @@ func WriteReceipt(
 	eventHadler diademchain.EventHandler,
 	evmTxIndex int32,
 	nonce int64,
+	txHash []byte,
 ) (types.EvmTxReceipt, error) {
 	txReceipt := types.EvmTxReceipt{
 		Nonce:             nonce,
@@ -50,13 +58,16 @@ func WriteReceipt(
 		CallerAddress:     caller.MarshalPB(),
 	}
 
-	preTxReceipt, err := proto.Marshal(&txReceipt)
-	if err != nil {
-		return types.EvmTxReceipt{}, errors.Wrapf(err, "marshalling receipt")
+	// txs that don't have a hash of their own are identified by the hash of the receipt
+	if len(txHash) == 0 {
+		preTxReceipt, err := proto.Marshal(&txReceipt)
+		if err != nil {
+			return types.EvmTxReceipt{}, errors.Wrapf(err, "marshalling receipt")
+		}
+		h := sha256.New()
+		h.Write(preTxReceipt)
+		txHash = h.Sum(nil)
 	}
-	h := sha256.New()
-	h.Write(preTxReceipt)
-	txHash := h.Sum(nil)
 
 	txReceipt.TxHash = txHash
 	blockHeight := uint64(txReceipt.BlockNumber)
@@ -76,7 +87,7 @@ func WriteReceipt(
 }
 
 func (lr *LevelDbReceipts) GetReceipt(txHash []byte) (types.EvmTxReceipt, error) {
-	txReceiptProto, err := lr.db.Get(txHash, nil)
+	txReceiptProto, err := lr.db.Get(txHash)
 	if err != nil {
 		return types.EvmTxReceipt{}, errors.Wrapf(err, "get receipt for %s", string(txHash))
 	}
@@ -85,10 +96,25 @@ func (lr *LevelDbReceipts) GetReceipt(txHash []byte) (types.EvmTxReceipt, error)
 	return *txReceipt.Receipt, err
 }
 
+// GetRevertReason returns the reason the contract gave for reverting the tx with the given hash,
+// or an empty string if the tx wasn't reverted by a contract.
+func (lr *LevelDbReceipts) GetRevertReason(txHash []byte) (string, error) {
+	key := revertReasonKey(txHash)
+	found, err := lr.db.Has(key)
+	if err != nil || !found {
+		return "", err
+	}
+	reason, err := lr.db.Get(key)
+	if err != nil {
+		return "", errors.Wrapf(err, "get revert reason for %x", txHash)
+	}
+	return string(reason), nil
+}
+
 type LevelDbReceipts struct {
 	MaxDbSize uint64
-	db        *leveldb.DB
-	tran      *leveldb.Transaction
+	db        receiptsDB
+	tran      receiptsTx
 }
 
 func NewLevelDbReceipts(maxReceipts uint64) (*LevelDbReceipts, error) {
@@ -98,7 +124,24 @@ func NewLevelDbReceipts(maxReceipts uint64) (*LevelDbReceipts, error) {
 	}
 	return &LevelDbReceipts{
 		MaxDbSize: maxReceipts,
-		db:        db,
+		db:        &goLevelDBReceiptsDB{DB: db},
+		tran:      nil,
+	}, nil
+}
+
+// NewLevelDbReceiptsWithDBBackend creates a receipts store that persists receipts to a DB using
+// the given backend (see db.LoadDB), the goleveldb backend is equivalent to NewLevelDbReceipts.
+func NewLevelDbReceiptsWithDBBackend(dbBackend string, maxReceipts uint64) (*LevelDbReceipts, error) {
+	if dbBackend == "" || dbBackend == cdb.GoLevelDBBackend {
+		return NewLevelDbReceipts(maxReceipts)
+	}
+	db, err := cdb.LoadDB(dbBackend, Db_Filename, ".", 0, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening %s receipts db", dbBackend)
+	}
+	return &LevelDbReceipts{
+		MaxDbSize: maxReceipts,
+		db:        &dbWrapperReceiptsDB{db: db},
 		tran:      nil,
 	}, nil
 }
@@ -110,7 +153,12 @@ func (lr LevelDbReceipts) Close() error {
 	return nil
 }
 
-func (lr *LevelDbReceipts) CommitBlock(state diademchain.State, receipts []*types.EvmTxReceipt, height uint64) error {
+// CommitBlock persists the given receipts, and the revert reasons of any failed txs (keyed by tx
+// hash). Failed txs don't modify the app state, so only successful txs are added to the block's
+// tx hash list & bloom filter, and the app state isn't touched at all if no tx was successful.
+func (lr *LevelDbReceipts) CommitBlock(
+	state diademchain.State, receipts []*types.EvmTxReceipt, revertReasons map[string]string, height uint64,
+) error {
 	if len(receipts) == 0 {
 		return nil
 	}
@@ -128,7 +176,7 @@ func (lr *LevelDbReceipts) CommitBlock(state diademchain.State, receipts []*type
 
 	tailReceiptItem := types.EvmTxReceiptListItem{}
 	if len(headHash) > 0 {
-		tailItemProto, err := lr.tran.Get(tailHash, nil)
+		tailItemProto, err := lr.tran.Get(tailHash)
 		if err != nil {
 			return errors.Wrap(err, "cannot find tail")
 		}
@@ -154,12 +202,12 @@ func (lr *LevelDbReceipts) CommitBlock(state diademchain.State, receipts []*type
 				log.Error(fmt.Sprintf("commit block receipts: marshal receipt item: %s", err.Error()))
 				continue
 			}
-			updating, err := lr.tran.Has(tailHash, nil)
+			updating, err := lr.tran.Has(tailHash)
 			if err != nil {
 				return errors.Wrap(err, "cannot find tail hash")
 			}
 
-			if err := lr.tran.Put(tailHash, protoTail, nil); err != nil {
+			if err := lr.tran.Put(tailHash, protoTail); err != nil {
 				log.Error(fmt.Sprintf("commit block receipts: put receipt in db: %s", err.Error()))
 				continue
 			} else if !updating {
@@ -174,20 +222,23 @@ func (lr *LevelDbReceipts) CommitBlock(state diademchain.State, receipts []*type
 		// only upload hashes to app db if transaction successful
 		if txReceipt.Status == common.StatusTxSuccess {
 			txHashArray = append(txHashArray, txReceipt.TxHash)
+			events = append(events, txReceipt.Logs...)
+		} else if reason, ok := revertReasons[string(txReceipt.TxHash)]; ok {
+			if err := lr.tran.Put(revertReasonKey(txReceipt.TxHash), []byte(reason)); err != nil {
+				log.Error(fmt.Sprintf("commit block receipts: put revert reason in db: %s", err.Error()))
+			}
 		}
-
-		events = append(events, txReceipt.Logs...)
 	}
 	if len(tailHash) > 0 {
 		protoTail, err := proto.Marshal(&tailReceiptItem)
 		if err != nil {
 			log.Error(fmt.Sprintf("commit block receipts: marshal receipt item: %s", err.Error()))
 		} else {
-			updating, err := lr.tran.Has(tailHash, nil)
+			updating, err := lr.tran.Has(tailHash)
 			if err != nil {
 				return errors.Wrap(err, "cannot find tail hash")
 			}
-			if err := lr.tran.Put(tailHash, protoTail, nil); err != nil {
+			if err := lr.tran.Put(tailHash, protoTail); err != nil {
 				log.Error(fmt.Sprintf("commit block receipts: putting receipt in db: %s", err.Error()))
 			} else if !updating {
 				size++
@@ -210,11 +261,13 @@ func (lr *LevelDbReceipts) CommitBlock(state diademchain.State, receipts []*type
 		return errors.Wrap(err, "saving receipt db params")
 	}
 
-	if err := common.AppendTxHashList(state, txHashArray, height); err != nil {
-		return errors.Wrap(err, "append tx list")
+	if len(txHashArray) > 0 {
+		if err := common.AppendTxHashList(state, txHashArray, height); err != nil {
+			return errors.Wrap(err, "append tx list")
+		}
+		filter := bdiadem.GenBdiademFilter(events)
+		common.SetBdiademFilter(state, filter, height)
 	}
-	filter := bdiadem.GenBdiademFilter(events)
-	common.SetBdiademFilter(state, filter, height)
 
 	if err := lr.tran.Commit(); err != nil {
 		return errors.Wrap(err, "committing level db transaction")
@@ -225,6 +278,8 @@ func (lr *LevelDbReceipts) CommitBlock(state diademchain.State, receipts []*type
 
 func (lr *LevelDbReceipts) ClearData() {
 	os.RemoveAll(Db_Filename)
+	// non-goleveldb backends are loaded via db.LoadDB, which appends the .db extension
+	os.RemoveAll(Db_Filename + ".db")
 }
 
 func (lr *LevelDbReceipts) closeTransaction() {
@@ -234,10 +289,10 @@ func (lr *LevelDbReceipts) closeTransaction() {
 	}
 }
 
-func removeOldEntries(tran *leveldb.Transaction, head []byte, number uint64) ([]byte, uint64, error) {
+func removeOldEntries(tran receiptsTx, head []byte, number uint64) ([]byte, uint64, error) {
 	itemsDeleted := uint64(0)
 	for i := uint64(0); i < number && len(head) > 0; i++ {
-		headItem, err := tran.Get(head, nil)
+		headItem, err := tran.Get(head)
 		if err != nil {
 			return head, itemsDeleted, errors.Wrapf(err, "get head %s", string(head))
 		}
@@ -245,7 +300,14 @@ func removeOldEntries(tran *leveldb.Transaction, head []byte, number uint64) ([]
 		if err := proto.Unmarshal(headItem, &txHeadReceiptItem); err != nil {
 			return head, itemsDeleted, errors.Wrapf(err, "unmarshal head %s", string(headItem))
 		}
-		tran.Delete(head, nil)
+		if err := tran.Delete(head); err != nil {
+			return head, itemsDeleted, errors.Wrapf(err, "delete head %s", string(head))
+		}
+		if txHeadReceiptItem.Receipt != nil && txHeadReceiptItem.Receipt.Status != common.StatusTxSuccess {
+			if err := tran.Delete(revertReasonKey(head)); err != nil {
+				return head, itemsDeleted, errors.Wrapf(err, "delete revert reason %s", string(head))
+			}
+		}
 		itemsDeleted++
 		head = txHeadReceiptItem.NextTxHash
 	}
@@ -256,8 +318,8 @@ func removeOldEntries(tran *leveldb.Transaction, head []byte, number uint64) ([]
 	return head, itemsDeleted, nil
 }
 
-func getDBParams(db *leveldb.DB) (size uint64, head, tail []byte, err error) {
-	notEmpty, err := db.Has(currentDbSizeKey, nil)
+func getDBParams(db receiptsReader) (size uint64, head, tail []byte, err error) {
+	notEmpty, err := db.Has(currentDbSizeKey)
 	if err != nil {
 		return size, head, tail, err
 	}
@@ -265,7 +327,7 @@ func getDBParams(db *leveldb.DB) (size uint64, head, tail []byte, err error) {
 		return 0, []byte{}, []byte{}, nil
 	}
 
-	sizeB, err := db.Get(currentDbSizeKey, nil)
+	sizeB, err := db.Get(currentDbSizeKey)
 	if err != nil {
 		return size, head, tail, err
 	}
@@ -274,7 +336,7 @@ func getDBParams(db *leveldb.DB) (size uint64, head, tail []byte, err error) {
 		return 0, []byte{}, []byte{}, nil
 	}
 
-	head, err = db.Get(headKey, nil)
+	head, err = db.Get(headKey)
 	if err != nil {
 		return size, head, tail, err
 	}
@@ -282,7 +344,7 @@ func getDBParams(db *leveldb.DB) (size uint64, head, tail []byte, err error) {
 		return 0, []byte{}, []byte{}, errors.New("no head for non zero size receipt db")
 	}
 
-	tail, err = db.Get(tailKey, nil)
+	tail, err = db.Get(tailKey)
 	if err != nil {
 		return size, head, tail, err
 	}
@@ -293,16 +355,16 @@ func getDBParams(db *leveldb.DB) (size uint64, head, tail []byte, err error) {
 	return size, head, tail, nil
 }
 
-func setDBParams(tr *leveldb.Transaction, size uint64, head, tail []byte) error {
-	if err := tr.Put(headKey, head, nil); err != nil {
+func setDBParams(tr receiptsTx, size uint64, head, tail []byte) error {
+	if err := tr.Put(headKey, head); err != nil {
 		return err
 	}
 
-	if err := tr.Put(tailKey, tail, nil); err != nil {
+	if err := tr.Put(tailKey, tail); err != nil {
 		return err
 	}
 
 	sizeB := make([]byte, 8)
 	binary.LittleEndian.PutUint64(sizeB, size)
-	return tr.Put(currentDbSizeKey, sizeB, nil)
+	return tr.Put(currentDbSizeKey, sizeB)
 }
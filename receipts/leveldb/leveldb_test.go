@@ -32,7 +32,7 @@ func TestReceiptsCyclicDB(t *testing.T) {
 	state := common.MockState(height)
 	receipts1 := common.MakeDummyReceipts(t, 5, height)
 	// store 5 receipts
-	require.NoError(t, handler.CommitBlock(state, receipts1, height))
+	require.NoError(t, handler.CommitBlock(state, receipts1, nil, height))
 	confirmDbConsistency(t, handler, 5, receipts1[0].TxHash, receipts1[4].TxHash, receipts1)
 	confirmStateConsistency(t, state, receipts1, height)
 
@@ -41,7 +41,7 @@ func TestReceiptsCyclicDB(t *testing.T) {
 	state2 := common.MockStateAt(state, height)
 	receipts2 := common.MakeDummyReceipts(t, 7, height)
 	// store another 7 receipts
-	require.NoError(t, handler.CommitBlock(state2, receipts2, height))
+	require.NoError(t, handler.CommitBlock(state2, receipts2, nil, height))
 	confirmDbConsistency(t, handler, maxSize, receipts1[2].TxHash, receipts2[6].TxHash, append(receipts1[2:5], receipts2...))
 	confirmStateConsistency(t, state2, receipts2, height)
 
@@ -50,7 +50,7 @@ func TestReceiptsCyclicDB(t *testing.T) {
 	state3 := common.MockStateAt(state, height)
 	receipts3 := common.MakeDummyReceipts(t, 5, height)
 	// store another 5 receipts
-	require.NoError(t, handler.CommitBlock(state3, receipts3, height))
+	require.NoError(t, handler.CommitBlock(state3, receipts3, nil, height))
 	confirmDbConsistency(t, handler, maxSize, receipts2[2].TxHash, receipts3[4].TxHash, append(receipts2[2:7], receipts3...))
 	confirmStateConsistency(t, state3, receipts3, height)
 
@@ -76,7 +76,7 @@ func TestReceiptsCommitAllInOneBlock(t *testing.T) {
 	state := common.MockState(height)
 	receipts1 := common.MakeDummyReceipts(t, maxSize+1, height)
 	// store 11 receipts, which is more than max that can be stored
-	require.NoError(t, handler.CommitBlock(state, receipts1, height))
+	require.NoError(t, handler.CommitBlock(state, receipts1, nil, height))
 
 	confirmDbConsistency(t, handler, maxSize, receipts1[1].TxHash, receipts1[10].TxHash, receipts1[1:])
 	confirmStateConsistency(t, state, receipts1, height)
@@ -116,7 +116,7 @@ func confirmDbConsistency(t *testing.T, handler *LevelDbReceipts, size uint64, h
 		require.EqualValues(t, 0, bytes.Compare(receipts[i].TxHash, getDBReceipt.TxHash))
 	}
 
-	dbActualSize, err := countDbEntries(handler.db)
+	dbActualSize, err := countDbEntries(handler.db.(*goLevelDBReceiptsDB).DB)
 	require.NoError(t, err)
 	require.EqualValues(t, size+dbConfigKeys, dbActualSize)
 
@@ -128,7 +128,7 @@ func confirmDbConsistency(t *testing.T, handler *LevelDbReceipts, size uint64, h
 		if previous.Receipt != nil {
 			require.EqualValues(t, 0, bytes.Compare(receipts[i].TxHash, previous.NextTxHash))
 		}
-		txReceiptItemProto, err := handler.db.Get(receipts[i].TxHash, nil)
+		txReceiptItemProto, err := handler.db.Get(receipts[i].TxHash)
 		require.NoError(t, err)
 		require.NoError(t, proto.Unmarshal(txReceiptItemProto, &previous))
 		require.EqualValues(t, 0, bytes.Compare(receipts[i].TxHash, previous.Receipt.TxHash))
@@ -154,7 +154,7 @@ func TestConfirmTransactionReceipts(t *testing.T) {
 	state := common.MockState(height)
 	receipts1 := common.MakeDummyReceipts(t, 5, height)
 	// store 5 receipts
-	require.NoError(t, handler.CommitBlock(state, receipts1, height))
+	require.NoError(t, handler.CommitBlock(state, receipts1, nil, height))
 	txHashes, err := common.GetTxHashList(state, height)
 	require.NoError(t, err)
 	a := []byte("0xf0675dc27bC62b584Ab2E8E1D483a55CFac9E960")
@@ -22,6 +22,8 @@ type ReceiptHandlerProvider struct {
 	eventHandler diademchain.EventHandler
 	resolveCfg   ResolveReceiptHandlerCfg
 	handler      ReceiptReaderWriter
+	// DB backend to use for receipts that aren't stored in the app state, defaults to goleveldb.
+	DBBackend string
 }
 
 func NewReceiptHandlerProvider(
@@ -62,7 +64,9 @@ func (h *ReceiptHandlerProvider) resolve(blockHeight int64, v2Feature bool) (Rec
 			h.handler = legacy_v2.NewReceiptHandler(h.eventHandler)
 
 		default:
-			handler, err := handler.NewReceiptHandler(ver, h.eventHandler, maxPersistentReceipts)
+			handler, err := handler.NewReceiptHandlerWithDBBackend(
+				ver, h.eventHandler, maxPersistentReceipts, h.DBBackend,
+			)
 			if err != nil {
 				return nil, errors.Wrapf(err, "failed to create receipt handler at height %d", blockHeight)
 			}
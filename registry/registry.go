@@ -23,4 +23,6 @@ type Registry interface {
 	Resolve(contractName string) (diadem.Address, error)
 	// GetRecord looks up the meta data previously stored for the given contract
 	GetRecord(contractAddr diadem.Address) (*Record, error)
+	// GetRecords returns the meta data of all the registered contracts
+	GetRecords() ([]*Record, error)
 }
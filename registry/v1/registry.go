@@ -18,10 +18,13 @@ const (
 
 var (
 	validNameRE = regexp.MustCompile("^[a-zA-Z0-9\\.\\-]+$")
+
+	// Store Keys
+	recordKeyPrefix = []byte("registry")
 )
 
 func recordKey(name string) []byte {
-	return util.PrefixKey([]byte("registry"), []byte(name))
+	return util.PrefixKey(recordKeyPrefix, []byte(name))
 }
 
 // StateRegistry stores contract meta data for named contracts only, and allows lookup by contract name.
@@ -80,6 +83,20 @@ func (r *StateRegistry) GetRecord(contractAddr diadem.Address) (*common.Record,
 	return nil, common.ErrNotImplemented
 }
 
+// GetRecords returns the records of all the named contracts, unnamed contracts aren't stored in
+// this version of the registry.
+func (r *StateRegistry) GetRecords() ([]*common.Record, error) {
+	var records []*common.Record
+	for _, entry := range r.State.Range(recordKeyPrefix) {
+		var record common.Record
+		if err := proto.Unmarshal(entry.Value, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, &record)
+	}
+	return records, nil
+}
+
 func validateName(name string) error {
 	if len(name) < minNameLen {
 		return errors.New("name length too short")
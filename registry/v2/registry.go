@@ -104,6 +104,19 @@ func (r *StateRegistry) GetRecord(contractAddr diadem.Address) (*common.Record,
 	return &record, nil
 }
 
+// GetRecords returns the records of all the named & unnamed contracts.
+func (r *StateRegistry) GetRecords() ([]*common.Record, error) {
+	var records []*common.Record
+	for _, entry := range r.State.Range(contractRecordKeyPrefix) {
+		var record common.Record
+		if err := proto.Unmarshal(entry.Value, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, &record)
+	}
+	return records, nil
+}
+
 func validateName(name string) error {
 	if len(name) < minNameLen {
 		return errors.New("name length too short")
@@ -5,11 +5,13 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
 	"github.com/gorilla/websocket"
 
+	"github.com/diademnetwork/diademchain/config"
 	"github.com/diademnetwork/diademchain/log"
 	"github.com/diademnetwork/diademchain/rpc/eth"
 )
@@ -44,6 +46,9 @@ type Client struct {
 
 	// Buffered channel of outbound messages.
 	send chan []byte
+
+	// Limits the calls made over the connection, nil if the RPC limiter is disabled.
+	rpcClient *rpcClient
 }
 
 // readPump pumps messages from the websocket connection.
@@ -51,8 +56,11 @@ type Client struct {
 // The application runs readPump in a per-connection goroutine. The application
 // ensures that there is at most one reader on a connection by executing all
 // reads from this goroutine.
-func (c *Client) readPump(funcMap map[string]eth.RPCFunc, logger log.TMLogger) {
+func (c *Client) readPump(funcMap map[string]eth.RPCFunc, logger log.TMLogger, limits *config.RPCLimitsConfig) {
+	// cancels any calls that are still running when the connection is closed
+	ctx, cancel := context.WithCancel(context.Background())
 	defer func() {
+		cancel()
 		c.hub.unregister <- c
 		_ = c.conn.Close()
 	}()
@@ -69,7 +77,11 @@ func (c *Client) readPump(funcMap map[string]eth.RPCFunc, logger log.TMLogger) {
 
 		logger.Debug("JSON-RPC2 websocket request", "received message", string(message))
 
-		outBytes, ethError := handleMessage(message, funcMap, c.conn)
+		var outBytes []byte
+		ethError := c.rpcClient.allowMessage(message)
+		if ethError == nil {
+			outBytes, ethError = handleMessage(ctx, message, funcMap, c.conn, limits)
+		}
 
 		if ethError != nil {
 			logger.Error("error handling message", "err", ethError.Error())
@@ -1,18 +1,28 @@
 package eth
 
 import (
+	"context"
 	"encoding/json"
 	"reflect"
 	"strings"
 
 	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
 )
 
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
 type HttpRPCFunc struct {
 	method    reflect.Value
 	signature []reflect.Type
+	// Set if the first parameter of the method is a context.Context, the method is passed a context
+	// that's cancelled when the request times out or the client goes away.
+	takesContext bool
 }
 
+// NewRPCFunc creates an RPCFunc that calls the given method, if the first parameter of the method
+// is a context.Context it must be named in paramNamesString but isn't read from the request params.
+
 func NewRPCFunc(method interface{}, paramNamesString string) RPCFunc {
 	var paramNames []string
 	if len(paramNamesString) > 0 {
@@ -26,14 +36,19 @@ func NewRPCFunc(method interface{}, paramNamesString string) RPCFunc {
 	if len(paramNames) != rMethod.NumIn() {
 		panic("parameter count mismatch making diadem api method")
 	}
+	takesContext := rMethod.NumIn() > 0 && rMethod.In(0) == contextType
 	signature := []reflect.Type{}
 	for p := 0; p < rMethod.NumIn(); p++ {
+		if p == 0 && takesContext {
+			continue
+		}
 		signature = append(signature, rMethod.In(p))
 	}
 
 	return &HttpRPCFunc{
-		method:    reflect.ValueOf(method),
-		signature: signature,
+		method:       reflect.ValueOf(method),
+		signature:    signature,
+		takesContext: takesContext,
 	}
 }
 
@@ -69,11 +84,16 @@ func (m *HttpRPCFunc) GetResponse(result json.RawMessage, id int64) (*JsonRpcRes
 	}, nil
 }
 
-func (m *HttpRPCFunc) UnmarshalParamsAndCall(input JsonRpcRequest, _ *websocket.Conn) (resp json.RawMessage, jsonErr *Error) {
+func (m *HttpRPCFunc) UnmarshalParamsAndCall(
+	ctx context.Context, input JsonRpcRequest, _ *websocket.Conn,
+) (resp json.RawMessage, jsonErr *Error) {
 	inValues, jsonErr := m.getInputValues(input)
 	if jsonErr != nil {
 		return resp, jsonErr
 	}
+	if m.takesContext {
+		inValues = append([]reflect.Value{reflect.ValueOf(ctx)}, inValues...)
+	}
 	return m.call(inValues, input.ID)
 }
 
@@ -81,6 +101,13 @@ func (m *HttpRPCFunc) call(inValues []reflect.Value, id int64) (resp json.RawMes
 	outValues := m.method.Call(inValues)
 
 	if outValues[1].Interface() != nil {
+		if dataErr, ok := errors.Cause(outValues[1].Interface().(error)).(DataError); ok {
+			return resp, &Error{
+				Code:    ErrorCode(dataErr.ErrorCode()),
+				Message: dataErr.Error(),
+				Data:    dataErr.ErrorData(),
+			}
+		}
 		return resp, NewErrorf(EcServer, "Server error", "diadem error: %v", outValues[1].Interface())
 	}
 
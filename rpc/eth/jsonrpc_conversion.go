@@ -2,6 +2,7 @@ package eth
 
 import (
 	"encoding/hex"
+	"math/big"
 	"reflect"
 	"strconv"
 	"strings"
@@ -54,6 +55,8 @@ type JsonTxReceipt struct {
 	Logs              []JsonLog `json:"logs"`
 	LogsBdiadem         Data      `json:"logsBdiadem,omitempty"`
 	Status            Quantity  `json:"status,omitempty"`
+	// Reason the contract gave for reverting the tx, only set for failed txs.
+	RevertReason string `json:"revertReason,omitempty"`
 }
 
 type JsonTxObject struct {
@@ -70,6 +73,20 @@ type JsonTxObject struct {
 	Input            Data     `json:"input,omitempty"`
 }
 
+// JsonSyncStatus is sent to syncing subscribers when the node starts or stops catching up with
+// the rest of the network.
+type JsonSyncStatus struct {
+	Syncing bool              `json:"syncing"`
+	Status  *JsonSyncProgress `json:"status,omitempty"`
+}
+
+// JsonSyncProgress doesn't include the highest block, Tendermint doesn't report the height of the
+// chain while the node is catching up.
+type JsonSyncProgress struct {
+	StartingBlock Quantity `json:"startingBlock"`
+	CurrentBlock  Quantity `json:"currentBlock"`
+}
+
 type JsonBlockObject struct {
 	Number           Quantity      `json:"number,omitempty"`
 	Hash             Data          `json:"hash,omitempty"`
@@ -102,6 +119,16 @@ type JsonTxCallObject struct {
 	Nonce    Quantity `json:"nonce.omitempty"`
 }
 
+// JsonTraceConfig contains the options accepted by debug_traceTransaction & debug_traceCall,
+// see https://github.com/ethereum/go-ethereum/wiki/Management-APIs#debug_tracetransaction
+type JsonTraceConfig struct {
+	DisableMemory  bool   `json:"disableMemory,omitempty"`
+	DisableStack   bool   `json:"disableStack,omitempty"`
+	DisableStorage bool   `json:"disableStorage,omitempty"`
+	Limit          int    `json:"limit,omitempty"`
+	Tracer         string `json:"tracer,omitempty"`
+}
+
 type JsonFilter struct {
 	FromBlock BlockHeight   `json:"fromBlock,omitempty"`
 	ToBlock   BlockHeight   `json:"toBlock,omitempty"`
@@ -199,6 +226,10 @@ func EncUint(value uint64) Quantity {
 	return Quantity("0x" + strconv.FormatUint(value, 16))
 }
 
+func EncBigInt(value *big.Int) Quantity {
+	return Quantity("0x" + value.Text(16))
+}
+
 // Hex
 func EncBytes(value []byte) Data {
 	bytesStr := "0x" + hex.EncodeToString(value)
@@ -344,6 +375,17 @@ func DecQuantityToUint(value Quantity) (uint64, error) {
 	return strconv.ParseUint(string(value), 0, 64)
 }
 
+func DecQuantityToBigInt(value Quantity) (*big.Int, error) {
+	if len(value) <= 2 || value[0:2] != "0x" {
+		return nil, errors.Errorf("invalid quantity format: %v", value)
+	}
+	result, ok := new(big.Int).SetString(string(value[2:]), 16)
+	if !ok {
+		return nil, errors.Errorf("invalid quantity format: %v", value)
+	}
+	return result, nil
+}
+
 func DecDataToBytes(value Data) ([]byte, error) {
 	if len(value) <= 2 || value[0:2] != "0x" {
 		return []byte{}, errors.Errorf("invalid data format: %v", value)
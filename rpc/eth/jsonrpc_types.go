@@ -1,6 +1,7 @@
 package eth
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -8,7 +9,7 @@ import (
 )
 
 type RPCFunc interface {
-	UnmarshalParamsAndCall(JsonRpcRequest, *websocket.Conn) (json.RawMessage, *Error)
+	UnmarshalParamsAndCall(context.Context, JsonRpcRequest, *websocket.Conn) (json.RawMessage, *Error)
 	GetResponse(json.RawMessage, int64) (*JsonRpcResponse, *Error)
 }
 
@@ -42,6 +43,7 @@ const (
 	EcInvalidParams  ErrorCode = -32602 // Invalid method parameter(s).
 	EcInternal       ErrorCode = -32603 // Internal JSON-RPC error.
 	EcServer         ErrorCode = -32000 // Reserved for implementation-defined server-errors.
+	EcLimitExceeded  ErrorCode = -32005 // Request exceeds a rate limit.
 )
 
 type Error struct {
@@ -65,3 +67,12 @@ func NewErrorf(code ErrorCode, message, format string, args ...interface{}) *Err
 func (e *Error) Error() string {
 	return e.Message
 }
+
+// DataError can be implemented by errors returned by RPC methods to control the code & data of the
+// JSON-RPC error returned to the client, e.g. the data passed to REVERT by a contract is returned
+// this way.
+type DataError interface {
+	error
+	ErrorCode() int
+	ErrorData() interface{}
+}
@@ -1,8 +1,11 @@
 package eth
 
 import (
+	"context"
 	"encoding/json"
 
+	etypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/gorilla/websocket"
 	"github.com/tendermint/tendermint/rpc/core"
 	"github.com/tendermint/tendermint/types"
@@ -23,7 +26,9 @@ func NewTendermintRPCFunc(funcName string) RPCFunc {
 	}
 }
 
-func (t *TendermintPRCFunc) UnmarshalParamsAndCall(input JsonRpcRequest, conn *websocket.Conn) (json.RawMessage, *Error) {
+func (t *TendermintPRCFunc) UnmarshalParamsAndCall(
+	_ context.Context, input JsonRpcRequest, conn *websocket.Conn,
+) (json.RawMessage, *Error) {
 	var txBytes types.Tx
 	switch t.name {
 	case "eth_sendRawTransaction":
@@ -45,7 +50,7 @@ func (t *TendermintPRCFunc) UnmarshalParamsAndCall(input JsonRpcRequest, conn *w
 	}
 
 	var result json.RawMessage
-	result, err = json.Marshal(EncBytes(r.Hash))
+	result, err = json.Marshal(EncBytes(txHash(txBytes, r.Hash)))
 	if err != nil {
 		log.Info("marshal transaction hash %v", err)
 	}
@@ -53,6 +58,21 @@ func (t *TendermintPRCFunc) UnmarshalParamsAndCall(input JsonRpcRequest, conn *w
 	return result, nil
 }
 
+// txHash returns the hash eth_sendRawTransaction responds with. The receipt of an Ethereum tx is
+// stored under the keccak256 hash of the RLP encoded tx, other txs are identified by the Tendermint
+// tx hash.
+func txHash(txBytes types.Tx, tmHash []byte) []byte {
+	if len(txBytes) > 0 && txBytes[0] >= 0xc0 {
+		var ethTx etypes.Transaction
+		if err := rlp.DecodeBytes(txBytes, &ethTx); err == nil {
+			return ethTx.Hash().Bytes()
+		}
+	}
+	return tmHash
+}
+
+// TranslateSendRawTransactionParams decodes the raw tx, which may either be a SignedTx or an RLP
+// encoded Ethereum tx, it's up to the auth middleware to decide if the tx is acceptable.
 func (t *TendermintPRCFunc) TranslateSendRawTransactionParams(input JsonRpcRequest) (types.Tx, *Error) {
 	paramsBytes := []json.RawMessage{}
 	if len(input.Params) > 0 {
@@ -1,6 +1,7 @@
 package eth
 
 import (
+	"context"
 	"encoding/json"
 	"reflect"
 	"strings"
@@ -45,7 +46,9 @@ func NewWSRPCFunc(method interface{}, paramNamesString string) RPCFunc {
 	}
 }
 
-func (w *WSPRCFunc) UnmarshalParamsAndCall(input JsonRpcRequest, conn *websocket.Conn) (resp json.RawMessage, jsonErr *Error) {
+func (w *WSPRCFunc) UnmarshalParamsAndCall(
+	_ context.Context, input JsonRpcRequest, conn *websocket.Conn,
+) (resp json.RawMessage, jsonErr *Error) {
 	inValues, jsonErr := w.getInputValues(input)
 	if jsonErr != nil {
 		return resp, jsonErr
@@ -0,0 +1,201 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	ltypes "github.com/diademnetwork/go-diadem/types"
+	"github.com/diademnetwork/go-diadem/vm"
+	"github.com/pkg/errors"
+	rpccore "github.com/tendermint/tendermint/rpc/core"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	ttypes "github.com/tendermint/tendermint/types"
+
+	"github.com/diademnetwork/diademchain/auth"
+	"github.com/diademnetwork/diademchain/eth/subs"
+	"github.com/diademnetwork/diademchain/log"
+	"github.com/diademnetwork/diademchain/rpc/eth"
+)
+
+const (
+	defaultEthSubPollInterval = time.Second
+	// Max number of txs read from the mempool each time it's polled
+	maxPolledPendingTxs = 1000
+)
+
+// EthSubscriptionPoller periodically checks the sync status of the node & the contents of the
+// mempool, and sends any changes to the syncing & newPendingTransactions eth_subscribe subscribers.
+// Neither is visible to the app itself, which only sees txs once they're in a block.
+type EthSubscriptionPoller struct {
+	ChainID  string
+	AuthCfg  *auth.Config
+	Subs     *subs.EthSubscriptionSet
+	Interval time.Duration
+
+	status         func() (*ctypes.ResultStatus, error)
+	unconfirmedTxs func(limit int) (*ctypes.ResultUnconfirmedTxs, error)
+
+	syncing       bool
+	startingBlock int64
+	// Hashes of the txs that were in the mempool the last time it was polled
+	seenTxs map[string]bool
+}
+
+func NewEthSubscriptionPoller(chainID string, authCfg *auth.Config, ethSubs *subs.EthSubscriptionSet) *EthSubscriptionPoller {
+	return &EthSubscriptionPoller{
+		ChainID:        chainID,
+		AuthCfg:        authCfg,
+		Subs:           ethSubs,
+		Interval:       defaultEthSubPollInterval,
+		status:         rpccore.Status,
+		unconfirmedTxs: rpccore.UnconfirmedTxs,
+		seenTxs:        map[string]bool{},
+	}
+}
+
+// Run polls until the process exits.
+func (p *EthSubscriptionPoller) Run() {
+	for {
+		p.poll()
+		time.Sleep(p.Interval)
+	}
+}
+
+func (p *EthSubscriptionPoller) poll() {
+	if err := p.pollSyncStatus(); err != nil {
+		log.Error("Failed to poll sync status", "err", err)
+	}
+	if err := p.pollPendingTxs(); err != nil {
+		log.Error("Failed to poll pending txs", "err", err)
+	}
+}
+
+func (p *EthSubscriptionPoller) pollSyncStatus() error {
+	status, err := p.status()
+	if err != nil {
+		return err
+	}
+	height := status.SyncInfo.LatestBlockHeight
+	if status.SyncInfo.CatchingUp == p.syncing {
+		if !p.syncing {
+			return nil
+		}
+		// while syncing subscribers are kept up to date with the progress
+		return p.Subs.EmitSyncingEvent(eth.JsonSyncStatus{
+			Syncing: true,
+			Status: &eth.JsonSyncProgress{
+				StartingBlock: eth.EncInt(p.startingBlock),
+				CurrentBlock:  eth.EncInt(height),
+			},
+		})
+	}
+
+	p.syncing = status.SyncInfo.CatchingUp
+	if p.syncing {
+		p.startingBlock = height
+		return p.Subs.EmitSyncingEvent(eth.JsonSyncStatus{
+			Syncing: true,
+			Status: &eth.JsonSyncProgress{
+				StartingBlock: eth.EncInt(height),
+				CurrentBlock:  eth.EncInt(height),
+			},
+		})
+	}
+	return p.Subs.EmitSyncingEvent(eth.JsonSyncStatus{Syncing: false})
+}
+
+func (p *EthSubscriptionPoller) pollPendingTxs() error {
+	if !p.Subs.HasPendingTxSubscribers() {
+		// start from scratch when someone subscribes, txs already in the mempool will be sent
+		// to the new subscriber
+		p.seenTxs = map[string]bool{}
+		return nil
+	}
+	result, err := p.unconfirmedTxs(maxPolledPendingTxs)
+	if err != nil {
+		return err
+	}
+	seenTxs := make(map[string]bool, len(result.Txs))
+	for _, tx := range result.Txs {
+		hash := string(tx.Hash())
+		seenTxs[hash] = true
+		if p.seenTxs[hash] {
+			continue
+		}
+		txObj, err := decodePendingTx(tx, p.ChainID, p.AuthCfg)
+		if err != nil {
+			// not every tx is an EVM tx, the rest aren't of interest to subscribers
+			continue
+		}
+		if err := p.Subs.EmitPendingTxEvent(txObj); err != nil {
+			return err
+		}
+	}
+	p.seenTxs = seenTxs
+	return nil
+}
+
+// decodePendingTx converts an EVM contract deployment or call in the mempool to an Ethereum tx
+// object, the tx hash is the Tendermint hash of the tx since the EVM hash isn't known until the
+// tx is executed.
+func decodePendingTx(txBytes ttypes.Tx, chainID string, authCfg *auth.Config) (eth.JsonTxObject, error) {
+	var txObj eth.JsonTxObject
+	nonceTxBytes, err := auth.UnwrapTx(txBytes, chainID, authCfg)
+	if err != nil {
+		return txObj, err
+	}
+	var nonceTx auth.NonceTx
+	if err := proto.Unmarshal(nonceTxBytes, &nonceTx); err != nil {
+		return txObj, errors.Wrap(err, "failed to unmarshal NonceTx")
+	}
+	var tx ltypes.Transaction
+	if err := proto.Unmarshal(nonceTx.Inner, &tx); err != nil {
+		return txObj, errors.Wrap(err, "failed to unmarshal Transaction")
+	}
+	var msgTx vm.MessageTx
+	if err := proto.Unmarshal(tx.Data, &msgTx); err != nil {
+		return txObj, errors.Wrap(err, "failed to unmarshal MessageTx")
+	}
+	if msgTx.From == nil {
+		return txObj, errors.New("malformed MessageTx, sender not specified")
+	}
+
+	var value *ltypes.BigUInt
+	switch tx.Id {
+	case 1:
+		var deployTx vm.DeployTx
+		if err := proto.Unmarshal(msgTx.Data, &deployTx); err != nil {
+			return txObj, errors.Wrap(err, "failed to unmarshal DeployTx")
+		}
+		if deployTx.VmType != vm.VMType_EVM {
+			return txObj, errors.New("not an EVM tx")
+		}
+		txObj.Input = eth.EncBytes(deployTx.Code)
+		value = deployTx.Value
+	case 2:
+		var callTx vm.CallTx
+		if err := proto.Unmarshal(msgTx.Data, &callTx); err != nil {
+			return txObj, errors.Wrap(err, "failed to unmarshal CallTx")
+		}
+		if callTx.VmType != vm.VMType_EVM {
+			return txObj, errors.New("not an EVM tx")
+		}
+		txObj.To = eth.EncAddress(msgTx.To)
+		txObj.Input = eth.EncBytes(callTx.Input)
+		value = callTx.Value
+	default:
+		return txObj, errors.Errorf("unsupported tx type %d", tx.Id)
+	}
+
+	txObj.Hash = eth.EncBytes(txBytes.Hash())
+	// the nonce of the first tx sent by an account is 1, but Ethereum starts counting at 0
+	txObj.Nonce = eth.EncUint(nonceTx.Sequence - 1)
+	txObj.From = eth.EncAddress(msgTx.From)
+	txObj.Value = eth.EncInt(0)
+	if value != nil && value.Value.Int != nil {
+		txObj.Value = eth.EncBigInt(value.Value.Int)
+	}
+	txObj.Gas = eth.EncInt(0)
+	txObj.GasPrice = eth.EncInt(0)
+	return txObj, nil
+}
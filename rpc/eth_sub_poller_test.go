@@ -0,0 +1,88 @@
+package rpc
+
+import (
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	diadem "github.com/diademnetwork/go-diadem"
+	ltypes "github.com/diademnetwork/go-diadem/types"
+	"github.com/diademnetwork/go-diadem/vm"
+	"github.com/stretchr/testify/require"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	ttypes "github.com/tendermint/tendermint/types"
+
+	"github.com/diademnetwork/diademchain/auth"
+	"github.com/diademnetwork/diademchain/eth/subs"
+	"github.com/diademnetwork/diademchain/rpc/eth"
+)
+
+func TestDecodePendingTx(t *testing.T) {
+	from := diadem.MustParseAddress("default:0xb16a379ec18d4093666f8f38b11a3071c920207d")
+	to := diadem.MustParseAddress("default:0x5cecd1f7261e1f4c684e297be3edf03b825e01c4")
+
+	callTxBytes, err := proto.Marshal(&vm.CallTx{
+		VmType: vm.VMType_EVM,
+		Input:  []byte{0xde, 0xad},
+	})
+	require.NoError(t, err)
+	msgTxBytes, err := proto.Marshal(&vm.MessageTx{
+		From: from.MarshalPB(),
+		To:   to.MarshalPB(),
+		Data: callTxBytes,
+	})
+	require.NoError(t, err)
+	txBytes, err := proto.Marshal(&ltypes.Transaction{Id: 2, Data: msgTxBytes})
+	require.NoError(t, err)
+	nonceTxBytes, err := proto.Marshal(&auth.NonceTx{Inner: txBytes, Sequence: 3})
+	require.NoError(t, err)
+	signedTxBytes, err := proto.Marshal(&auth.SignedTx{Inner: nonceTxBytes})
+	require.NoError(t, err)
+
+	tx := ttypes.Tx(signedTxBytes)
+	txObj, err := decodePendingTx(tx, "default", auth.DefaultConfig())
+	require.NoError(t, err)
+	require.Equal(t, eth.EncBytes(tx.Hash()), txObj.Hash)
+	require.Equal(t, eth.EncUint(2), txObj.Nonce)
+	require.Equal(t, eth.EncAddress(from.MarshalPB()), txObj.From)
+	require.Equal(t, eth.EncAddress(to.MarshalPB()), txObj.To)
+	require.Equal(t, eth.Data("0xdead"), txObj.Input)
+	require.Equal(t, eth.EncInt(0), txObj.Value)
+
+	// non-EVM txs are skipped
+	callTxBytes, err = proto.Marshal(&vm.CallTx{VmType: vm.VMType_PLUGIN})
+	require.NoError(t, err)
+	msgTxBytes, err = proto.Marshal(&vm.MessageTx{From: from.MarshalPB(), To: to.MarshalPB(), Data: callTxBytes})
+	require.NoError(t, err)
+	txBytes, err = proto.Marshal(&ltypes.Transaction{Id: 2, Data: msgTxBytes})
+	require.NoError(t, err)
+	nonceTxBytes, err = proto.Marshal(&auth.NonceTx{Inner: txBytes, Sequence: 1})
+	require.NoError(t, err)
+	signedTxBytes, err = proto.Marshal(&auth.SignedTx{Inner: nonceTxBytes})
+	require.NoError(t, err)
+	_, err = decodePendingTx(ttypes.Tx(signedTxBytes), "default", auth.DefaultConfig())
+	require.Error(t, err)
+}
+
+func TestEthSubscriptionPollerSyncStatus(t *testing.T) {
+	status := &ctypes.ResultStatus{}
+	p := NewEthSubscriptionPoller("default", auth.DefaultConfig(), subs.NewEthSubscriptionSet())
+	p.status = func() (*ctypes.ResultStatus, error) { return status, nil }
+
+	status.SyncInfo.LatestBlockHeight = 10
+	require.NoError(t, p.pollSyncStatus())
+	require.False(t, p.syncing)
+
+	status.SyncInfo.CatchingUp = true
+	require.NoError(t, p.pollSyncStatus())
+	require.True(t, p.syncing)
+	require.Equal(t, int64(10), p.startingBlock)
+
+	// the starting block doesn't change while the node is catching up
+	status.SyncInfo.LatestBlockHeight = 20
+	require.NoError(t, p.pollSyncStatus())
+	require.Equal(t, int64(10), p.startingBlock)
+
+	status.SyncInfo.CatchingUp = false
+	require.NoError(t, p.pollSyncStatus())
+	require.False(t, p.syncing)
+}
@@ -0,0 +1,558 @@
+// Package graphql implements an EIP-1767 style GraphQL endpoint that lets clients fetch blocks
+// along with their txs, receipts & logs in a single request.
+package graphql
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	ptypes "github.com/diademnetwork/go-diadem/plugin/types"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/pkg/errors"
+
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/eth/query"
+	"github.com/diademnetwork/diademchain/rpc/eth"
+	"github.com/diademnetwork/diademchain/store"
+)
+
+// StateProvider provides the read-only app state the resolvers query.
+type StateProvider interface {
+	ReadOnlyState() diademchain.State
+}
+
+// Backend provides access to the data stores the resolvers read from.
+type Backend struct {
+	StateProvider          StateProvider
+	ReceiptHandlerProvider diademchain.ReceiptHandlerProvider
+	BlockStore             store.BlockStore
+	// Max number of blocks that can be queried by a single blocks or logs query, zero means no limit.
+	MaxBlockRange uint64
+}
+
+// Limits restricts how expensive the queries served by the handler can be.
+type Limits struct {
+	// Max depth of a query, zero means no limit.
+	MaxDepth int
+	// Max number of resolvers that can run in parallel for a query, zero means the graphql-go default.
+	MaxParallelism int
+}
+
+// NewHandler returns an HTTP handler that serves GraphQL queries sent via POST requests.
+func NewHandler(backend *Backend, limits Limits) (http.Handler, error) {
+	var opts []graphql.SchemaOpt
+	if limits.MaxDepth > 0 {
+		opts = append(opts, graphql.MaxDepth(limits.MaxDepth))
+	}
+	if limits.MaxParallelism > 0 {
+		opts = append(opts, graphql.MaxParallelism(limits.MaxParallelism))
+	}
+	s, err := graphql.ParseSchema(schema, &Resolver{backend: backend}, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse GraphQL schema")
+	}
+	h := &relay.Handler{Schema: s}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodOptions:
+			// CORS preflight request, the headers are set by the caller
+			return
+		case http.MethodPost:
+			h.ServeHTTP(w, req)
+		default:
+			http.Error(w, "GraphQL queries must be sent via POST", http.StatusMethodNotAllowed)
+		}
+	}), nil
+}
+
+// snapshot returns a snapshot of the current app state, and a reader for the receipts stored
+// in it. The caller must release the snapshot when done.
+func (b *Backend) snapshot() (diademchain.State, diademchain.ReadReceiptHandler, error) {
+	snapshot := b.StateProvider.ReadOnlyState()
+	r, err := b.ReceiptHandlerProvider.ReaderAt(
+		snapshot.Block().Height,
+		snapshot.FeatureEnabled(diademchain.EvmTxReceiptsVersion2Feature, false),
+	)
+	if err != nil {
+		snapshot.Release()
+		return nil, nil, err
+	}
+	return snapshot, r, nil
+}
+
+func (b *Backend) checkBlockRange(from, to uint64) error {
+	if from > to {
+		return fmt.Errorf("from block %v is after to block %v", from, to)
+	}
+	if b.MaxBlockRange > 0 && to-from >= b.MaxBlockRange {
+		return fmt.Errorf("block range exceeded, maximum range: %v", b.MaxBlockRange)
+	}
+	return nil
+}
+
+// Resolver resolves the root Query type.
+type Resolver struct {
+	backend *Backend
+}
+
+type blockArgs struct {
+	Number *Long
+	Hash   *HexData
+}
+
+func (r *Resolver) Block(args blockArgs) (*Block, error) {
+	snapshot := r.backend.StateProvider.ReadOnlyState()
+	defer snapshot.Release()
+
+	if args.Hash != nil {
+		hash, err := eth.DecDataToBytes(eth.Data(*args.Hash))
+		if err != nil {
+			return nil, err
+		}
+		height, err := query.GetBlockHeightFromHash(r.backend.BlockStore, snapshot, hash)
+		if err != nil {
+			return nil, err
+		}
+		return &Block{backend: r.backend, height: height}, nil
+	}
+
+	height := snapshot.Block().Height
+	if args.Number != nil {
+		if int64(*args.Number) < 1 || int64(*args.Number) > height {
+			return nil, nil
+		}
+		height = int64(*args.Number)
+	}
+	return &Block{backend: r.backend, height: height}, nil
+}
+
+type blocksArgs struct {
+	From Long
+	To   *Long
+}
+
+func (r *Resolver) Blocks(args blocksArgs) ([]*Block, error) {
+	snapshot := r.backend.StateProvider.ReadOnlyState()
+	latest := snapshot.Block().Height
+	snapshot.Release()
+
+	from := int64(args.From)
+	if from < 1 {
+		from = 1
+	}
+	to := latest
+	if args.To != nil && int64(*args.To) < to {
+		to = int64(*args.To)
+	}
+	if from > to {
+		return []*Block{}, nil
+	}
+	if err := r.backend.checkBlockRange(uint64(from), uint64(to)); err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*Block, 0, to-from+1)
+	for height := from; height <= to; height++ {
+		blocks = append(blocks, &Block{backend: r.backend, height: height})
+	}
+	return blocks, nil
+}
+
+func (r *Resolver) Transaction(args struct{ Hash HexData }) (*Transaction, error) {
+	hash, err := eth.DecDataToBytes(eth.Data(args.Hash))
+	if err != nil {
+		return nil, err
+	}
+	tx := &Transaction{backend: r.backend, hash: hash}
+	if _, err := tx.resolve(); err != nil {
+		// the tx doesn't exist, or its receipt has been pruned
+		return nil, nil
+	}
+	return tx, nil
+}
+
+type filterCriteria struct {
+	FromBlock *Long
+	ToBlock   *Long
+	Addresses *[]HexData
+	Topics    *[][]HexData
+}
+
+func (r *Resolver) Logs(args struct{ Filter filterCriteria }) ([]*Log, error) {
+	snapshot, receipts, err := r.backend.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Release()
+
+	latest := snapshot.Block().Height
+	from, to := latest, latest
+	if args.Filter.FromBlock != nil {
+		from = int64(*args.Filter.FromBlock)
+	}
+	if args.Filter.ToBlock != nil {
+		to = int64(*args.Filter.ToBlock)
+	}
+	if from < 1 || to > latest {
+		return nil, fmt.Errorf("block range %v-%v is outside of the chain range 1-%v", from, to, latest)
+	}
+	if err := r.backend.checkBlockRange(uint64(from), uint64(to)); err != nil {
+		return nil, err
+	}
+
+	blockFilter, err := decBlockFilter(args.Filter.Addresses, args.Filter.Topics)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := query.QueryChain(r.backend.BlockStore, snapshot, eth.EthFilter{
+		EthBlockFilter: blockFilter,
+		FromBlock:      eth.BlockHeight(strconv.FormatInt(from, 10)),
+		ToBlock:        eth.BlockHeight(strconv.FormatInt(to, 10)),
+	}, receipts)
+	if err != nil {
+		return nil, err
+	}
+	return newFilterLogs(r.backend, logs), nil
+}
+
+type blockFilterCriteria struct {
+	Addresses *[]HexData
+	Topics    *[][]HexData
+}
+
+func decBlockFilter(addresses *[]HexData, topics *[][]HexData) (eth.EthBlockFilter, error) {
+	filter := eth.EthBlockFilter{}
+	if addresses != nil {
+		for _, addr := range *addresses {
+			local, err := eth.DecDataToBytes(eth.Data(addr))
+			if err != nil {
+				return filter, errors.Wrapf(err, "invalid address %s", addr)
+			}
+			filter.Addresses = append(filter.Addresses, local)
+		}
+	}
+	if topics != nil {
+		for _, position := range *topics {
+			positionTopics := []string{}
+			for _, topic := range position {
+				positionTopics = append(positionTopics, string(topic))
+			}
+			filter.Topics = append(filter.Topics, positionTopics)
+		}
+	}
+	return filter, nil
+}
+
+// Account resolves the Account type.
+type Account struct {
+	address eth.Data
+}
+
+func (a *Account) Address() HexData {
+	return HexData(a.address)
+}
+
+// Block resolves the Block type, the block is only loaded if one of its fields is queried.
+type Block struct {
+	backend *Backend
+	height  int64
+
+	mutex sync.Mutex
+	block *eth.JsonBlockObject
+}
+
+func (b *Block) resolve() (*eth.JsonBlockObject, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.block != nil {
+		return b.block, nil
+	}
+	snapshot, receipts, err := b.backend.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Release()
+
+	block, err := query.GetBlockByNumber(b.backend.BlockStore, snapshot, b.height, false, receipts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load block %v", b.height)
+	}
+	b.block = &block
+	return b.block, nil
+}
+
+func (b *Block) Number() Long {
+	return Long(b.height)
+}
+
+func (b *Block) Hash() (HexData, error) {
+	block, err := b.resolve()
+	if err != nil {
+		return "", err
+	}
+	return HexData(block.Hash), nil
+}
+
+func (b *Block) Parent() *Block {
+	if b.height <= 1 {
+		return nil
+	}
+	return &Block{backend: b.backend, height: b.height - 1}
+}
+
+func (b *Block) Timestamp() (Long, error) {
+	block, err := b.resolve()
+	if err != nil {
+		return 0, err
+	}
+	timestamp, err := eth.DecQuantityToInt(block.Timestamp)
+	return Long(timestamp), err
+}
+
+func (b *Block) Miner() (*Account, error) {
+	block, err := b.resolve()
+	if err != nil {
+		return nil, err
+	}
+	return &Account{address: block.Miner}, nil
+}
+
+func (b *Block) TransactionCount() (*int32, error) {
+	block, err := b.resolve()
+	if err != nil {
+		return nil, err
+	}
+	count := int32(len(block.Transactions))
+	return &count, nil
+}
+
+func (b *Block) Transactions() (*[]*Transaction, error) {
+	block, err := b.resolve()
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]*Transaction, 0, len(block.Transactions))
+	for _, txHash := range block.Transactions {
+		tx, err := b.newTransaction(txHash)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	return &txs, nil
+}
+
+func (b *Block) TransactionAt(args struct{ Index int32 }) (*Transaction, error) {
+	block, err := b.resolve()
+	if err != nil {
+		return nil, err
+	}
+	if args.Index < 0 || int(args.Index) >= len(block.Transactions) {
+		return nil, nil
+	}
+	return b.newTransaction(block.Transactions[args.Index])
+}
+
+func (b *Block) newTransaction(txHash interface{}) (*Transaction, error) {
+	hashData, ok := txHash.(eth.Data)
+	if !ok {
+		return nil, fmt.Errorf("unexpected tx hash type %T", txHash)
+	}
+	hash, err := eth.DecDataToBytes(hashData)
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{backend: b.backend, hash: hash}, nil
+}
+
+func (b *Block) Logs(args struct{ Filter blockFilterCriteria }) ([]*Log, error) {
+	blockFilter, err := decBlockFilter(args.Filter.Addresses, args.Filter.Topics)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, receipts, err := b.backend.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Release()
+
+	height := eth.BlockHeight(strconv.FormatInt(b.height, 10))
+	logs, err := query.QueryChain(b.backend.BlockStore, snapshot, eth.EthFilter{
+		EthBlockFilter: blockFilter,
+		FromBlock:      height,
+		ToBlock:        height,
+	}, receipts)
+	if err != nil {
+		return nil, err
+	}
+	return newFilterLogs(b.backend, logs), nil
+}
+
+// Transaction resolves the Transaction type, all the fields other than the hash are read from the
+// tx receipt, which is only loaded if one of those fields is queried.
+type Transaction struct {
+	backend *Backend
+	hash    []byte
+
+	mutex   sync.Mutex
+	receipt *ptypes.EvmTxReceipt
+}
+
+func (t *Transaction) resolve() (*ptypes.EvmTxReceipt, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.receipt != nil {
+		return t.receipt, nil
+	}
+	snapshot, receipts, err := t.backend.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Release()
+
+	receipt, err := receipts.GetReceipt(snapshot, t.hash)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load tx receipt")
+	}
+	t.receipt = &receipt
+	return t.receipt, nil
+}
+
+func (t *Transaction) Hash() HexData {
+	return HexData(eth.EncBytes(t.hash))
+}
+
+func (t *Transaction) Nonce() (Long, error) {
+	receipt, err := t.resolve()
+	if err != nil {
+		return 0, err
+	}
+	return Long(receipt.Nonce), nil
+}
+
+func (t *Transaction) Index() (*int32, error) {
+	receipt, err := t.resolve()
+	if err != nil {
+		return nil, err
+	}
+	index := receipt.TransactionIndex
+	return &index, nil
+}
+
+func (t *Transaction) From() (*Account, error) {
+	receipt, err := t.resolve()
+	if err != nil {
+		return nil, err
+	}
+	return &Account{address: eth.EncAddress(receipt.CallerAddress)}, nil
+}
+
+func (t *Transaction) To() (*Account, error) {
+	receipt, err := t.resolve()
+	if err != nil {
+		return nil, err
+	}
+	if len(receipt.ContractAddress) == 0 {
+		return nil, nil
+	}
+	return &Account{address: eth.EncBytes(receipt.ContractAddress)}, nil
+}
+
+func (t *Transaction) Value() HexData {
+	return HexData(eth.ZeroedQuantity)
+}
+
+func (t *Transaction) Block() (*Block, error) {
+	receipt, err := t.resolve()
+	if err != nil {
+		return nil, err
+	}
+	return &Block{backend: t.backend, height: receipt.BlockNumber}, nil
+}
+
+func (t *Transaction) Status() (*Long, error) {
+	receipt, err := t.resolve()
+	if err != nil {
+		return nil, err
+	}
+	status := Long(receipt.Status)
+	return &status, nil
+}
+
+func (t *Transaction) GasUsed() (*Long, error) {
+	receipt, err := t.resolve()
+	if err != nil {
+		return nil, err
+	}
+	gasUsed := Long(receipt.GasUsed)
+	return &gasUsed, nil
+}
+
+func (t *Transaction) CumulativeGasUsed() (*Long, error) {
+	receipt, err := t.resolve()
+	if err != nil {
+		return nil, err
+	}
+	gasUsed := Long(receipt.CumulativeGasUsed)
+	return &gasUsed, nil
+}
+
+func (t *Transaction) Logs() (*[]*Log, error) {
+	receipt, err := t.resolve()
+	if err != nil {
+		return nil, err
+	}
+	jsonLogs := eth.EncEvents(receipt.Logs)
+	logs := make([]*Log, 0, len(jsonLogs))
+	for _, log := range jsonLogs {
+		logs = append(logs, &Log{log: log, tx: t})
+	}
+	return &logs, nil
+}
+
+// Log resolves the Log type.
+type Log struct {
+	log eth.JsonLog
+	tx  *Transaction
+}
+
+func newFilterLogs(backend *Backend, filterLogs []*ptypes.EthFilterLog) []*Log {
+	logs := make([]*Log, 0, len(filterLogs))
+	for _, filterLog := range filterLogs {
+		logs = append(logs, &Log{
+			log: eth.EncLog(*filterLog),
+			tx:  &Transaction{backend: backend, hash: filterLog.TransactionHash},
+		})
+	}
+	return logs
+}
+
+func (l *Log) Index() (int32, error) {
+	index, err := eth.DecQuantityToInt(l.log.LogIndex)
+	return int32(index), err
+}
+
+func (l *Log) Account() *Account {
+	return &Account{address: l.log.Address}
+}
+
+func (l *Log) Topics() []HexData {
+	topics := make([]HexData, 0, len(l.log.Topics))
+	for _, topic := range l.log.Topics {
+		topics = append(topics, HexData(topic))
+	}
+	return topics
+}
+
+func (l *Log) Data() HexData {
+	return HexData(l.log.Data)
+}
+
+func (l *Log) Transaction() *Transaction {
+	return l.tx
+}
@@ -0,0 +1,138 @@
+// +build evm
+
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/go-diadem/plugin/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/events"
+	"github.com/diademnetwork/diademchain/receipts/common"
+	"github.com/diademnetwork/diademchain/receipts/handler"
+	"github.com/diademnetwork/diademchain/rpc/eth"
+	"github.com/diademnetwork/diademchain/store"
+)
+
+var (
+	addr1 = diadem.MustParseAddress("chain:0xb16a379ec18d4093666f8f38b11a3071c920207d")
+	addr2 = diadem.MustParseAddress("chain:0x5cecd1f7261e1f4c684e297be3edf03b825e01c4")
+	topic = "0x0000000000000000000000000000000000000000000000000000000000000001"
+)
+
+type stateProvider struct {
+	state diademchain.State
+}
+
+func (s *stateProvider) ReadOnlyState() diademchain.State {
+	return s.state
+}
+
+// receiptHandlerProvider always returns the same receipt handler
+type receiptHandlerProvider struct {
+	*handler.ReceiptHandler
+}
+
+func (p *receiptHandlerProvider) StoreAt(_ int64, _ bool) (diademchain.ReceiptHandlerStore, error) {
+	return p.ReceiptHandler, nil
+}
+
+func (p *receiptHandlerProvider) ReaderAt(_ int64, _ bool) (diademchain.ReadReceiptHandler, error) {
+	return p.ReceiptHandler, nil
+}
+
+func (p *receiptHandlerProvider) WriterAt(_ int64, _ bool) (diademchain.WriteReceiptHandler, error) {
+	return p.ReceiptHandler, nil
+}
+
+func TestGraphQLBlockQuery(t *testing.T) {
+	eventHandler := diademchain.NewDefaultEventHandler(events.NewLogEventDispatcher())
+	receiptHandler, err := handler.NewReceiptHandler(
+		handler.ReceiptHandlerChain, eventHandler, handler.DefaultMaxReceipts,
+	)
+	require.NoError(t, err)
+	defer receiptHandler.Close()
+
+	state := common.MockState(0)
+	state4 := common.MockStateAt(state, 4)
+	txHash, err := receiptHandler.CacheReceipt(state4, addr1, addr2, []*types.EventData{
+		{
+			Topics:      []string{topic},
+			EncodedBody: []byte("somedata"),
+			Address:     addr2.MarshalPB(),
+			Caller:      addr2.MarshalPB(),
+		},
+	}, nil)
+	require.NoError(t, err)
+	receiptHandler.CommitCurrentReceipt()
+	require.NoError(t, receiptHandler.CommitBlock(state4, 4))
+
+	h, err := NewHandler(&Backend{
+		StateProvider:          &stateProvider{state: common.MockStateAt(state, 5)},
+		ReceiptHandlerProvider: &receiptHandlerProvider{receiptHandler},
+		BlockStore:             store.NewMockBlockStore(),
+		MaxBlockRange:          3,
+	}, Limits{MaxDepth: 5})
+	require.NoError(t, err)
+
+	send := func(query string) map[string]interface{} {
+		body, err := json.Marshal(map[string]string{"query": query})
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+
+	// a block along with its txs, receipts & logs
+	resp := send(`{
+		block(number: 4) {
+			number
+			transactionCount
+			transactions { hash status from { address } logs { topics } }
+			logs(filter: { topics: [["` + topic + `"]] }) { index transaction { hash } }
+		}
+	}`)
+	require.Nil(t, resp["errors"])
+	block := resp["data"].(map[string]interface{})["block"].(map[string]interface{})
+	require.EqualValues(t, 4, block["number"])
+	require.EqualValues(t, 1, block["transactionCount"])
+	tx := block["transactions"].([]interface{})[0].(map[string]interface{})
+	require.Equal(t, string(eth.EncBytes(txHash)), tx["hash"])
+	require.EqualValues(t, 1, tx["status"])
+	require.Equal(t, string(eth.EncAddress(addr1.MarshalPB())), tx["from"].(map[string]interface{})["address"])
+	txLogs := tx["logs"].([]interface{})
+	require.Len(t, txLogs, 1)
+	require.Equal(t, []interface{}{topic}, txLogs[0].(map[string]interface{})["topics"])
+	blockLogs := block["logs"].([]interface{})
+	require.Len(t, blockLogs, 1)
+	logTx := blockLogs[0].(map[string]interface{})["transaction"].(map[string]interface{})
+	require.Equal(t, string(eth.EncBytes(txHash)), logTx["hash"])
+
+	resp = send(`{ logs(filter: { fromBlock: "0x3", toBlock: 4 }) { index } }`)
+	require.Nil(t, resp["errors"])
+	require.Len(t, resp["data"].(map[string]interface{})["logs"], 1)
+
+	// the number of blocks a single query can span is limited
+	resp = send(`{ logs(filter: { fromBlock: 1, toBlock: 4 }) { index } }`)
+	require.NotNil(t, resp["errors"])
+	resp = send(`{ blocks(from: 1) { number } }`)
+	require.NotNil(t, resp["errors"])
+	resp = send(`{ blocks(from: 3, to: 20) { number } }`)
+	require.Nil(t, resp["errors"])
+	require.Len(t, resp["data"].(map[string]interface{})["blocks"], 3)
+
+	// so is the depth of a query
+	resp = send(`{ block(number: 4) { transactions { block { parent { parent { parent { number } } } } } } }`)
+	require.NotNil(t, resp["errors"])
+}
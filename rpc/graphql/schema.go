@@ -0,0 +1,91 @@
+package graphql
+
+// schema is a subset of the schema proposed by EIP-1767, only the parts that map onto data stored
+// by the node are included, so there's no mutation, account state, or gas related fields.
+// See https://eips.ethereum.org/EIPS/eip-1767
+const schema = `
+    # Bytes32 is a 32 byte binary string, represented as 0x-prefixed hexadecimal.
+    scalar Bytes32
+    # Address is a 20 byte Ethereum address, represented as 0x-prefixed hexadecimal.
+    scalar Address
+    # Bytes is an arbitrary length binary string, represented as 0x-prefixed hexadecimal.
+    scalar Bytes
+    # BigInt is a large integer, represented as 0x-prefixed hexadecimal.
+    scalar BigInt
+    # Long is a 64 bit unsigned integer, input may be a number or a hexadecimal/decimal string.
+    scalar Long
+
+    schema {
+        query: Query
+    }
+
+    type Account {
+        address: Address!
+    }
+
+    type Log {
+        # Index of the log in the tx (or the block if the log was returned by a filter query).
+        index: Int!
+        # Account of the contract that emitted the log.
+        account: Account!
+        topics: [Bytes32!]!
+        data: Bytes!
+        transaction: Transaction!
+    }
+
+    type Transaction {
+        hash: Bytes32!
+        nonce: Long!
+        # Index of the tx in the block.
+        index: Int
+        from: Account!
+        # Contract that was called or deployed by the tx.
+        to: Account
+        # Value is always zero, the value sent with a tx isn't stored in the receipt.
+        value: BigInt!
+        block: Block
+        # 1 if the tx succeeded, 0 if it failed.
+        status: Long
+        gasUsed: Long
+        cumulativeGasUsed: Long
+        logs: [Log!]
+    }
+
+    input BlockFilterCriteria {
+        # Only logs emitted by one of these contracts are returned, all logs are returned if empty.
+        addresses: [Address!]
+        # Topics to match at each position, an empty list matches any topic.
+        topics: [[Bytes32!]!]
+    }
+
+    type Block {
+        number: Long!
+        hash: Bytes32!
+        parent: Block
+        timestamp: Long!
+        miner: Account!
+        # Number of EVM txs in the block.
+        transactionCount: Int
+        transactions: [Transaction!]
+        transactionAt(index: Int!): Transaction
+        logs(filter: BlockFilterCriteria!): [Log!]!
+    }
+
+    input FilterCriteria {
+        # First block to return logs from, defaults to the latest block.
+        fromBlock: Long
+        # Last block to return logs from, defaults to the latest block.
+        toBlock: Long
+        addresses: [Address!]
+        topics: [[Bytes32!]!]
+    }
+
+    type Query {
+        # Returns the block with the given number or hash, or the latest block if neither is given.
+        block(number: Long, hash: Bytes32): Block
+        # Returns the blocks in the given range (inclusive), to defaults to the latest block.
+        blocks(from: Long!, to: Long): [Block!]!
+        transaction(hash: Bytes32!): Transaction
+        logs(filter: FilterCriteria!): [Log!]!
+    }
+`
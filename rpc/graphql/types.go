@@ -0,0 +1,58 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// HexData implements the Bytes32, Address, Bytes & BigInt scalars, values of all these types are
+// passed around as 0x-prefixed hex strings, same as in the Ethereum JSON-RPC API.
+type HexData string
+
+func (HexData) ImplementsGraphQLType(name string) bool {
+	switch name {
+	case "Bytes32", "Address", "Bytes", "BigInt":
+		return true
+	}
+	return false
+}
+
+func (h *HexData) UnmarshalGraphQL(input interface{}) error {
+	s, ok := input.(string)
+	if !ok {
+		return fmt.Errorf("unexpected type %T for hex data", input)
+	}
+	if len(s) < 2 || s[0:2] != "0x" {
+		return fmt.Errorf("hex data must be 0x-prefixed: %s", s)
+	}
+	*h = HexData(s)
+	return nil
+}
+
+// Long implements the Long scalar.
+type Long int64
+
+func (Long) ImplementsGraphQLType(name string) bool {
+	return name == "Long"
+}
+
+func (l *Long) UnmarshalGraphQL(input interface{}) error {
+	switch v := input.(type) {
+	case string:
+		// strconv handles both hex & decimal strings
+		value, err := strconv.ParseInt(v, 0, 64)
+		if err != nil {
+			return err
+		}
+		*l = Long(value)
+	case int32:
+		*l = Long(v)
+	case int64:
+		*l = Long(v)
+	case float64:
+		*l = Long(v)
+	default:
+		return fmt.Errorf("unexpected type %T for Long", input)
+	}
+	return nil
+}
@@ -1,18 +1,47 @@
 package rpc
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/go-kit/kit/metrics"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
 	"github.com/gorilla/websocket"
-	"github.com/diademnetwork/go-diadem/plugin/types"
 	"github.com/diademnetwork/diademchain/config"
 	"github.com/diademnetwork/diademchain/rpc/eth"
+	"github.com/diademnetwork/diademchain/store"
 	"github.com/diademnetwork/diademchain/vm"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
 	rpctypes "github.com/tendermint/tendermint/rpc/lib/types"
 )
 
+var (
+	jsonRPCBatchSize    metrics.Histogram
+	jsonRPCResponseSize metrics.Histogram
+)
+
+// The per-method metrics of the /eth endpoint are captured by InstrumentingMiddleware for the
+// QueryService methods that serve the requests, only the metrics that span whole JSON-RPC messages
+// are captured by the JSON-RPC handler.
+func init() {
+	jsonRPCBatchSize = kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Namespace: "diademchain",
+		Subsystem: "json_rpc",
+		Name:      "batch_size",
+		Help:      "Number of requests in JSON-RPC batches.",
+		Buckets:   []float64{1, 2, 5, 10, 20, 50, 100, 200, 500},
+	}, []string{})
+	jsonRPCResponseSize = kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Namespace: "diademchain",
+		Subsystem: "json_rpc",
+		Name:      "response_size_bytes",
+		Help:      "Size of JSON-RPC responses in bytes.",
+		Buckets:   stdprometheus.ExponentialBuckets(256, 4, 8),
+	}, []string{})
+}
+
 // InstrumentingMiddleware implements QuerySerice interface
 type InstrumentingMiddleware struct {
 	requestCount   metrics.Counter
@@ -41,6 +70,42 @@ func (m InstrumentingMiddleware) Query(caller, contract string, query []byte, vm
 	return
 }
 
+// QueryAt calls service QueryAt and captures metrics
+func (m InstrumentingMiddleware) QueryAt(caller, contract string, query []byte, vmType vm.VMType, height int64) (resp []byte, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "QueryAt", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.QueryAt(caller, contract, query, vmType, height)
+	return
+}
+
+// QueryProof calls service QueryProof and captures metrics
+func (m InstrumentingMiddleware) QueryProof(contract string, key []byte) (resp *store.ValueProof, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "QueryProof", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.QueryProof(contract, key)
+	return
+}
+
+// PruningStatus calls service PruningStatus and captures metrics
+func (m InstrumentingMiddleware) PruningStatus() (resp *store.PruningStatus, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "PruningStatus", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.PruningStatus()
+	return
+}
+
 func (m InstrumentingMiddleware) QueryEnv() (resp *config.EnvInfo, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "QueryEnv", "error", fmt.Sprint(err != nil)}
@@ -54,14 +119,14 @@ func (m InstrumentingMiddleware) QueryEnv() (resp *config.EnvInfo, err error) {
 }
 
 // Nonce call service Nonce method and captures metrics
-func (m InstrumentingMiddleware) Nonce(key, account string) (resp uint64, err error) {
+func (m InstrumentingMiddleware) Nonce(key, account string, pending bool) (resp uint64, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "Nonce", "error", fmt.Sprint(err != nil)}
 		m.requestCount.With(lvs...).Add(1)
 		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	resp, err = m.next.Nonce(key, account)
+	resp, err = m.next.Nonce(key, account, pending)
 	return
 }
 
@@ -95,13 +160,16 @@ func (m InstrumentingMiddleware) EvmTxReceipt(txHash []byte) (resp []byte, err e
 	return
 }
 
-func (m InstrumentingMiddleware) ContractEvents(fromBlock uint64, toBlock uint64, contractName string) (result *types.ContractEventsResult, err error) {
+func (m InstrumentingMiddleware) ContractEvents(
+	fromBlock uint64, toBlock uint64, contractName string, topic string, origin string, txHash []byte,
+	limit uint64, cursor string,
+) (result *ContractEventsResult, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "ContractEvents", "error", fmt.Sprint(err != nil)}
 		m.requestCount.With(lvs...).Add(1)
 		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	result, err = m.next.ContractEvents(fromBlock, toBlock, contractName)
+	result, err = m.next.ContractEvents(fromBlock, toBlock, contractName, topic, origin, txHash, limit, cursor)
 	return
 }
 
@@ -362,14 +430,14 @@ func (m InstrumentingMiddleware) EthCall(query eth.JsonTxCallObject, block eth.B
 	return
 }
 
-func (m InstrumentingMiddleware) EthGetLogs(filter eth.JsonFilter) (resp []eth.JsonLog, err error) {
+func (m InstrumentingMiddleware) EthGetLogs(ctx context.Context, filter eth.JsonFilter) (resp []eth.JsonLog, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "EthGetLogs", "error", fmt.Sprint(err != nil)}
 		m.requestCount.With(lvs...).Add(1)
 		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	resp, err = m.next.EthGetLogs(filter)
+	resp, err = m.next.EthGetLogs(ctx, filter)
 	return
 }
 
@@ -439,14 +507,14 @@ func (m InstrumentingMiddleware) EthNewFilter(filter eth.JsonFilter) (resp eth.Q
 	return
 }
 
-func (m InstrumentingMiddleware) EthSubscribe(conn *websocket.Conn, method eth.Data, filter eth.JsonFilter) (resp eth.Data, err error) {
+func (m InstrumentingMiddleware) EthSubscribe(conn *websocket.Conn, method eth.Data, params json.RawMessage) (resp eth.Data, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "EthSubscribe", "error", fmt.Sprint(err != nil)}
 		m.requestCount.With(lvs...).Add(1)
 		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	resp, err = m.next.EthSubscribe(conn, method, filter)
+	resp, err = m.next.EthSubscribe(conn, method, params)
 	return
 }
 
@@ -516,6 +584,32 @@ func (m InstrumentingMiddleware) EthAccounts() (resp []eth.Data, err error) {
 	return
 }
 
+func (m InstrumentingMiddleware) DebugTraceTransaction(
+	ctx context.Context, hash eth.Data, config eth.JsonTraceConfig,
+) (resp interface{}, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "DebugTraceTransaction", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.DebugTraceTransaction(ctx, hash, config)
+	return
+}
+
+func (m InstrumentingMiddleware) DebugTraceCall(
+	ctx context.Context, query eth.JsonTxCallObject, block eth.BlockHeight, config eth.JsonTraceConfig,
+) (resp interface{}, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "DebugTraceCall", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.DebugTraceCall(ctx, query, block, config)
+	return
+}
+
 func (m InstrumentingMiddleware) EthGetTransactionCount(local eth.Data, block eth.BlockHeight) (resp eth.Quantity, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "EthGetTransactionCount", "error", fmt.Sprint(err != nil)}
@@ -1,19 +1,26 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 
+	"github.com/diademnetwork/diademchain/config"
 	"github.com/diademnetwork/diademchain/log"
 	"github.com/diademnetwork/diademchain/rpc/eth"
 )
 
-func RegisterRPCFuncs(mux *http.ServeMux, funcMap map[string]eth.RPCFunc, logger log.TMLogger, hub *Hub) {
+func RegisterRPCFuncs(
+	mux *http.ServeMux, funcMap map[string]eth.RPCFunc, logger log.TMLogger, hub *Hub,
+	limits *config.RPCLimitsConfig,
+) {
 	mux.HandleFunc("/", func(writer http.ResponseWriter, reader *http.Request) {
 		if isWebSocketConnection(reader) {
 			conn, err := upgrader.Upgrade(writer, reader, nil)
@@ -21,10 +28,15 @@ func RegisterRPCFuncs(mux *http.ServeMux, funcMap map[string]eth.RPCFunc, logger
 				logger.Error("JSON-RPC2 http request, message with no body received")
 				return
 			}
-			client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256)}
+			client := &Client{
+				hub:       hub,
+				conn:      conn,
+				send:      make(chan []byte, 256),
+				rpcClient: rpcClientFromContext(reader.Context()),
+			}
 			client.hub.register <- client
 
-			go client.readPump(funcMap, logger)
+			go client.readPump(funcMap, logger, limits)
 			go client.writePump(logger)
 			return
 		}
@@ -39,7 +51,7 @@ func RegisterRPCFuncs(mux *http.ServeMux, funcMap map[string]eth.RPCFunc, logger
 		}
 		logger.Debug("JSON-RPC2 http request", "message", string(body))
 
-		outBytes, ethError := handleMessage(body, funcMap, nil)
+		outBytes, ethError := handleMessage(reader.Context(), body, funcMap, nil, limits)
 
 		if ethError != nil {
 			WriteResponse(writer, eth.JsonRpcErrorResponse{
@@ -60,49 +72,59 @@ func RegisterRPCFuncs(mux *http.ServeMux, funcMap map[string]eth.RPCFunc, logger
 	})
 }
 
-func handleMessage(body []byte, funcMap map[string]eth.RPCFunc, conn *websocket.Conn) ([]byte, *eth.Error) {
+// handleMessage serves the requests in a JSON-RPC message, the given context should be cancelled when
+// the client goes away.
+func handleMessage(
+	ctx context.Context, body []byte, funcMap map[string]eth.RPCFunc, conn *websocket.Conn,
+	limits *config.RPCLimitsConfig,
+) ([]byte, *eth.Error) {
 	requestList, isBatch, reqListErr := getRequests(body)
 
 	if reqListErr != nil {
 		return nil, reqListErr
 	}
 
-	outputList := []interface{}{}
-
-	for _, jsonRequest := range requestList {
-		method, jsonErr := getRequest(jsonRequest, funcMap)
-		if jsonErr != nil {
-			outputList = append(outputList, eth.JsonRpcErrorResponse{
-				Version: "2.0",
-				ID:      jsonRequest.ID,
-				Error:   *jsonErr,
-			})
-			continue
-		}
-
-		rawResult, jsonErr := method.UnmarshalParamsAndCall(jsonRequest, conn)
-
-		if jsonErr != nil {
-			outputList = append(outputList, eth.JsonRpcErrorResponse{
-				Version: "2.0",
-				ID:      jsonRequest.ID,
-				Error:   *jsonErr,
-			})
-			continue
-		}
+	if limits == nil {
+		limits = &config.RPCLimitsConfig{}
+	}
 
-		resp, jsonErr := method.GetResponse(rawResult, jsonRequest.ID)
-		if jsonErr != nil {
-			outputList = append(outputList, eth.JsonRpcErrorResponse{
-				Version: "2.0",
-				ID:      jsonRequest.ID,
-				Error:   *jsonErr,
-			})
-			continue
+	if isBatch {
+		jsonRPCBatchSize.Observe(float64(len(requestList)))
+		if limits.MaxBatchSize > 0 && len(requestList) > limits.MaxBatchSize {
+			return nil, eth.NewErrorf(eth.EcInvalidRequest, "Batch too large",
+				"batch contains %d requests, max batch size is %d", len(requestList), limits.MaxBatchSize,
+			)
 		}
+	}
 
-		outputList = append(outputList, resp)
+	// Batch entries are served by a bounded pool of workers, the responses are returned in the same
+	// order as the requests.
+	outputList := make([]interface{}, len(requestList))
+	workerCount := limits.BatchConcurrency
+	if workerCount < 1 {
+		workerCount = 1
+	}
+	if workerCount > len(requestList) {
+		workerCount = len(requestList)
+	}
+	timeout := time.Duration(limits.CallTimeout) * time.Second
+	budget := newResponseBudget(limits.MaxResponseBytes)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				outputList[idx] = handleRequest(ctx, requestList[idx], funcMap, conn, timeout, budget)
+			}
+		}()
 	}
+	for idx := range requestList {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
 
 	var outBytes []byte
 	var err error
@@ -115,9 +137,135 @@ func handleMessage(body []byte, funcMap map[string]eth.RPCFunc, conn *websocket.
 		return nil, eth.NewErrorf(eth.EcServer, "Server error", "error  marshalling result %v", err)
 	}
 
+	// the results have already been checked against the response budget, but the response also
+	// includes the JSON-RPC envelopes of the results
+	jsonRPCResponseSize.Observe(float64(len(outBytes)))
+	if limits.MaxResponseBytes > 0 && len(outBytes) > limits.MaxResponseBytes {
+		return nil, eth.NewErrorf(eth.EcLimitExceeded, "Response too large",
+			"response size %d bytes exceeds the max response size of %d bytes", len(outBytes), limits.MaxResponseBytes,
+		)
+	}
+
 	return outBytes, nil
 }
 
+// handleRequest calls the method specified in the request, and returns the response that should
+// be sent to the client. The method is passed a context that's cancelled once the given timeout
+// (zero meaning no timeout) expires, or the parent context is cancelled, it's up to the method to
+// stop when that happens. handleRequest doesn't return until the method does, so a worker serving
+// a batch isn't freed up while the method is still running.
+// The result of the method is charged to the given response budget, once the budget is exhausted
+// the remaining requests in the message are rejected without calling their methods.
+func handleRequest(
+	parent context.Context, jsonRequest eth.JsonRpcRequest, funcMap map[string]eth.RPCFunc,
+	conn *websocket.Conn, timeout time.Duration, budget *responseBudget,
+) interface{} {
+	method, jsonErr := getRequest(jsonRequest, funcMap)
+	if jsonErr == nil && budget.exhausted() {
+		jsonErr = eth.NewErrorf(eth.EcLimitExceeded, "Response too large",
+			"max response size of %d bytes exceeded", budget.maxBytes,
+		)
+	}
+	if jsonErr != nil {
+		return eth.JsonRpcErrorResponse{
+			Version: "2.0",
+			ID:      jsonRequest.ID,
+			Error:   *jsonErr,
+		}
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	defer cancel()
+
+	rawResult, jsonErr := callMethod(ctx, method, jsonRequest, conn)
+	if ctx.Err() == context.DeadlineExceeded {
+		jsonErr = eth.NewErrorf(eth.EcServer, "Request timed out",
+			"%s didn't complete within %v", jsonRequest.Method, timeout,
+		)
+	}
+
+	if jsonErr == nil && !budget.take(len(rawResult)) {
+		jsonErr = eth.NewErrorf(eth.EcLimitExceeded, "Response too large",
+			"result of %s exceeds the max response size of %d bytes", jsonRequest.Method, budget.maxBytes,
+		)
+	}
+	if jsonErr != nil {
+		return eth.JsonRpcErrorResponse{
+			Version: "2.0",
+			ID:      jsonRequest.ID,
+			Error:   *jsonErr,
+		}
+	}
+
+	resp, jsonErr := method.GetResponse(rawResult, jsonRequest.ID)
+	if jsonErr != nil {
+		return eth.JsonRpcErrorResponse{
+			Version: "2.0",
+			ID:      jsonRequest.ID,
+			Error:   *jsonErr,
+		}
+	}
+	return resp
+}
+
+// responseBudget tracks how many bytes are left for the results of the requests in a message, so
+// oversized results are dropped as soon as they're returned, instead of being marshalled into a
+// response that's only rejected once it's complete. A nil budget is unlimited.
+type responseBudget struct {
+	mutex    sync.Mutex
+	maxBytes int
+	left     int
+}
+
+func newResponseBudget(maxBytes int) *responseBudget {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &responseBudget{maxBytes: maxBytes, left: maxBytes}
+}
+
+func (b *responseBudget) exhausted() bool {
+	if b == nil {
+		return false
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.left <= 0
+}
+
+// take charges the given number of bytes to the budget, if there aren't enough bytes left the
+// budget is exhausted and false is returned.
+func (b *responseBudget) take(n int) bool {
+	if b == nil {
+		return true
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if n > b.left {
+		b.left = 0
+		return false
+	}
+	b.left -= n
+	return true
+}
+
+func callMethod(
+	ctx context.Context, method eth.RPCFunc, jsonRequest eth.JsonRpcRequest, conn *websocket.Conn,
+) (rawResult json.RawMessage, jsonErr *eth.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			jsonErr = eth.NewErrorf(eth.EcInternal, "Internal error", "panic: %v", r)
+		}
+	}()
+	return method.UnmarshalParamsAndCall(ctx, jsonRequest, conn)
+}
+
 func getRequests(message []byte) ([]eth.JsonRpcRequest, bool, *eth.Error) {
 	var isBatchRequest bool = true
 	var inputList []eth.JsonRpcRequest
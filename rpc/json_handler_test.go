@@ -1,6 +1,9 @@
 package rpc
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http/httptest"
 	"strings"
 	"sync"
@@ -11,7 +14,9 @@ import (
 	"github.com/posener/wstest"
 	"github.com/stretchr/testify/require"
 
+	"github.com/diademnetwork/diademchain/config"
 	"github.com/diademnetwork/diademchain/log"
+	"github.com/diademnetwork/diademchain/rpc/eth"
 )
 
 var (
@@ -45,6 +50,8 @@ var (
 		{"net_version", "EthNetVersion", ``},
 		{"eth_getTransactionCount", "EthGetTransactionCount", ``},
 		{"eth_accounts", "EthAccounts", ``},
+		{"debug_traceTransaction", "DebugTraceTransaction", ``},
+		{"debug_traceCall", "DebugTraceCall", ``},
 	}
 )
 
@@ -54,13 +61,15 @@ func TestJsonRpcHandler(t *testing.T) {
 
 	t.Run("Http JSON-RPC", testHttpJsonHandler)
 	t.Run("Http JSON-RPC batch", testBatchHttpJsonHandler)
+	t.Run("JSON-RPC batch limits", testBatchLimits)
+	t.Run("Debug methods disabled", testDebugMethodsDisabled)
 	t.Run("Multi Websocket JSON-RPC", testMultipleWebsocketConnections)
 	t.Run("Single Websocket JSON-RPC", testSingleWebsocketConnections)
 }
 
 func testHttpJsonHandler(t *testing.T) {
 	qs := &MockQueryService{}
-	handler := MakeEthQueryServiceHandler(qs, testlog, nil)
+	handler := MakeEthQueryServiceHandler(qs, testlog, nil, nil, true)
 
 	for _, test := range tests {
 		payload := `{"jsonrpc":"2.0","method":"` + test.method + `","params":[` + test.params + `],"id":99}`
@@ -74,7 +83,7 @@ func testHttpJsonHandler(t *testing.T) {
 
 func testBatchHttpJsonHandler(t *testing.T) {
 	qs := &MockQueryService{}
-	handler := MakeEthQueryServiceHandler(qs, testlog, nil)
+	handler := MakeEthQueryServiceHandler(qs, testlog, nil, nil, true)
 
 	blockPayload := "["
 	first := true
@@ -95,11 +104,111 @@ func testBatchHttpJsonHandler(t *testing.T) {
 	}
 }
 
+func testDebugMethodsDisabled(t *testing.T) {
+	qs := &MockQueryService{}
+	handler := MakeEthQueryServiceHandler(qs, testlog, nil, nil, false)
+
+	for _, method := range []string{"debug_traceTransaction", "debug_traceCall"} {
+		payload := `{"jsonrpc":"2.0","method":"` + method + `","params":[],"id":99}`
+		req := httptest.NewRequest("POST", "http://localhost/eth", strings.NewReader(payload))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var resp eth.JsonRpcErrorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.Equal(t, eth.EcMethodNotFound, resp.Error.Code)
+		require.Len(t, qs.MethodsCalled, 0)
+	}
+}
+
+func testBatchLimits(t *testing.T) {
+	cancelled := make(chan bool, 1)
+	funcMap := map[string]eth.RPCFunc{
+		"echo": eth.NewRPCFunc(func(value eth.Quantity) (eth.Quantity, error) {
+			return value, nil
+		}, "value"),
+		"sleep": eth.NewRPCFunc(func(ctx context.Context) (eth.Quantity, error) {
+			select {
+			case <-time.After(2 * time.Second):
+				return "0x0", nil
+			case <-ctx.Done():
+				cancelled <- true
+				return "", ctx.Err()
+			}
+		}, "ctx"),
+		"panic": eth.NewRPCFunc(func() (eth.Quantity, error) {
+			panic("boom")
+		}, ""),
+	}
+	limits := &config.RPCLimitsConfig{
+		MaxBatchSize:     10,
+		BatchConcurrency: 4,
+		CallTimeout:      1,
+	}
+
+	// responses are returned in the same order as the requests even when served concurrently
+	payload := "["
+	for i := 0; i < 10; i++ {
+		if i > 0 {
+			payload += ","
+		}
+		payload += fmt.Sprintf(`{"jsonrpc":"2.0","method":"echo","params":["0x%x"],"id":%d}`, i, i)
+	}
+	payload += "]"
+	outBytes, jsonErr := handleMessage(context.Background(), []byte(payload), funcMap, nil, limits)
+	require.Nil(t, jsonErr)
+	var resps []eth.JsonRpcResponse
+	require.NoError(t, json.Unmarshal(outBytes, &resps))
+	require.Len(t, resps, 10)
+	for i, resp := range resps {
+		require.Equal(t, int64(i), resp.ID)
+		require.Equal(t, fmt.Sprintf(`"0x%x"`, i), string(resp.Result))
+	}
+
+	// batches that are too large are rejected
+	_, jsonErr = handleMessage(context.Background(), []byte(strings.Replace(payload, "]", `,{"method":"echo","id":10}]`, 1)), funcMap, nil, limits)
+	require.NotNil(t, jsonErr)
+	require.Equal(t, eth.EcInvalidRequest, jsonErr.Code)
+
+	// slow & failing requests don't hold up the rest of the batch
+	payload = `[{"method":"sleep","id":1},{"method":"panic","id":2},{"method":"echo","params":["0x1"],"id":3}]`
+	outBytes, jsonErr = handleMessage(context.Background(), []byte(payload), funcMap, nil, limits)
+	require.Nil(t, jsonErr)
+	var errResps []eth.JsonRpcErrorResponse
+	require.NoError(t, json.Unmarshal(outBytes, &errResps))
+	require.Equal(t, "Request timed out", errResps[0].Error.Message)
+	// the slow request was told to stop, and has done so by the time the response is sent
+	require.Len(t, cancelled, 1)
+	require.Equal(t, eth.EcInternal, errResps[1].Error.Code)
+	require.Equal(t, int64(3), errResps[2].ID)
+
+	// responses that are too large are rejected
+	limits.MaxResponseBytes = 50
+	_, jsonErr = handleMessage(context.Background(), []byte(`[{"method":"echo","params":["0x1"],"id":1}]`), funcMap, nil, limits)
+	require.NotNil(t, jsonErr)
+	require.Equal(t, eth.EcLimitExceeded, jsonErr.Code)
+
+	// results are charged to the response budget as they're returned, once the budget is exhausted
+	// the remaining requests aren't served
+	budget := newResponseBudget(8)
+	echo := func(value string, id int64) interface{} {
+		req := eth.JsonRpcRequest{Method: "echo", Params: json.RawMessage(`["` + value + `"]`), ID: id}
+		return handleRequest(context.Background(), req, funcMap, nil, 0, budget)
+	}
+	_, ok := echo("0x1", 1).(eth.JsonRpcErrorResponse)
+	require.False(t, ok)
+	errResp, ok := echo("0x123456789", 2).(eth.JsonRpcErrorResponse)
+	require.True(t, ok)
+	require.Equal(t, eth.EcLimitExceeded, errResp.Error.Code)
+	errResp, ok = echo("0x1", 3).(eth.JsonRpcErrorResponse)
+	require.True(t, ok)
+	require.Equal(t, eth.EcLimitExceeded, errResp.Error.Code)
+}
+
 func testMultipleWebsocketConnections(t *testing.T) {
 	hub := newHub()
 	go hub.run()
 	qs := &MockQueryService{}
-	handler := MakeEthQueryServiceHandler(qs, testlog, hub)
+	handler := MakeEthQueryServiceHandler(qs, testlog, hub, nil, true)
 
 	for _, test := range tests {
 		dialer := wstest.NewDialer(handler)
@@ -129,7 +238,7 @@ func testSingleWebsocketConnections(t *testing.T) {
 	hub := newHub()
 	go hub.run()
 	qs := &MockQueryService{}
-	handler := MakeEthQueryServiceHandler(qs, testlog, hub)
+	handler := MakeEthQueryServiceHandler(qs, testlog, hub, nil, true)
 	dialer := wstest.NewDialer(handler)
 	conn, _, err := dialer.Dial("ws://localhost/eth", nil)
 	writeMutex := &sync.Mutex{}
@@ -1,13 +1,15 @@
 package rpc
 
 import (
+	"context"
+	"encoding/json"
+
 	"github.com/gorilla/websocket"
 	rpctypes "github.com/tendermint/tendermint/rpc/lib/types"
 
-	"github.com/diademnetwork/go-diadem/plugin/types"
-
 	"github.com/diademnetwork/diademchain/config"
 	"github.com/diademnetwork/diademchain/rpc/eth"
+	"github.com/diademnetwork/diademchain/store"
 	"github.com/diademnetwork/diademchain/vm"
 )
 
@@ -20,12 +22,27 @@ func (m *MockQueryService) Query(caller, contract string, query []byte, vmType v
 	return nil, nil
 }
 
+func (m *MockQueryService) QueryAt(caller, contract string, query []byte, vmType vm.VMType, height int64) ([]byte, error) {
+	m.MethodsCalled = append([]string{"QueryAt"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) QueryProof(contract string, key []byte) (*store.ValueProof, error) {
+	m.MethodsCalled = append([]string{"QueryProof"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) PruningStatus() (*store.PruningStatus, error) {
+	m.MethodsCalled = append([]string{"PruningStatus"}, m.MethodsCalled...)
+	return nil, nil
+}
+
 func (m *MockQueryService) Resolve(name string) (string, error) {
 	m.MethodsCalled = append([]string{"Resolve"}, m.MethodsCalled...)
 	return "", nil
 }
 
-func (m *MockQueryService) Nonce(key, account string) (uint64, error) {
+func (m *MockQueryService) Nonce(key, account string, pending bool) (uint64, error) {
 	m.MethodsCalled = append([]string{"Nonce"}, m.MethodsCalled...)
 	return 0, nil
 }
@@ -81,7 +98,7 @@ func (m *MockQueryService) EthCall(query eth.JsonTxCallObject, block eth.BlockHe
 	return "", nil
 }
 
-func (m *MockQueryService) EthGetLogs(filter eth.JsonFilter) ([]eth.JsonLog, error) {
+func (m *MockQueryService) EthGetLogs(ctx context.Context, filter eth.JsonFilter) ([]eth.JsonLog, error) {
 	m.MethodsCalled = append([]string{"EthGetLogs"}, m.MethodsCalled...)
 	return nil, nil
 }
@@ -136,7 +153,7 @@ func (m *MockQueryService) EthNewFilter(filter eth.JsonFilter) (eth.Quantity, er
 	return "", nil
 }
 
-func (m *MockQueryService) EthSubscribe(conn *websocket.Conn, method eth.Data, filter eth.JsonFilter) (id eth.Data, err error) {
+func (m *MockQueryService) EthSubscribe(conn *websocket.Conn, method eth.Data, params json.RawMessage) (id eth.Data, err error) {
 	m.MethodsCalled = append([]string{"EthSubscribe"}, m.MethodsCalled...)
 	return "", nil
 }
@@ -176,7 +193,24 @@ func (m *MockQueryService) EthAccounts() ([]eth.Data, error) {
 	return nil, nil
 }
 
-func (m *MockQueryService) ContractEvents(fromBlock uint64, toBlock uint64, contract string) (*types.ContractEventsResult, error) {
+func (m *MockQueryService) DebugTraceTransaction(
+	ctx context.Context, hash eth.Data, config eth.JsonTraceConfig,
+) (interface{}, error) {
+	m.MethodsCalled = append([]string{"DebugTraceTransaction"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) DebugTraceCall(
+	ctx context.Context, query eth.JsonTxCallObject, block eth.BlockHeight, config eth.JsonTraceConfig,
+) (interface{}, error) {
+	m.MethodsCalled = append([]string{"DebugTraceCall"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) ContractEvents(
+	fromBlock uint64, toBlock uint64, contract string, topic string, origin string, txHash []byte,
+	limit uint64, cursor string,
+) (*ContractEventsResult, error) {
 	m.MethodsCalled = append([]string{"ContractEvents"}, m.MethodsCalled...)
 	return nil, nil
 }
@@ -0,0 +1,24 @@
+package rpc
+
+import (
+	"github.com/diademnetwork/diademchain/store"
+	diadem "github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/go-diadem/util"
+)
+
+// ContractStateKey returns the app store key under which the given contract state key is stored.
+func ContractStateKey(contractAddr diadem.Address, key []byte) []byte {
+	return util.PrefixKey(diadem.DataPrefix(contractAddr), key)
+}
+
+// VerifyContractStateProof checks that a proof returned by the queryproof route proves the value
+// (or absence) of the given key in the state of the given contract.
+//
+// The app hash must be obtained from a trusted source, and it must be the AppHash from the header
+// of the block at height proof.Version + 1, since the AppHash in a block header commits to the
+// state resulting from the execution of the previous block.
+func VerifyContractStateProof(
+	appHash []byte, contractAddr diadem.Address, key []byte, proof *store.ValueProof,
+) error {
+	return store.VerifyValueProof(appHash, ContractStateKey(contractAddr, key), proof)
+}
@@ -1,9 +1,10 @@
 package rpc
 
 import (
+	"context"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"math/big"
 	"strconv"
 	"strings"
 
@@ -14,25 +15,31 @@ import (
 	"github.com/diademnetwork/go-diadem/plugin"
 	"github.com/diademnetwork/go-diadem/plugin/contractpb"
 	"github.com/diademnetwork/go-diadem/plugin/types"
+	ltypes "github.com/diademnetwork/go-diadem/types"
 	"github.com/diademnetwork/go-diadem/vm"
 	"github.com/diademnetwork/diademchain"
 	"github.com/diademnetwork/diademchain/auth"
+	"github.com/diademnetwork/diademchain/builtin/plugins/address_mapper"
+	"github.com/diademnetwork/diademchain/builtin/plugins/coin"
+	"github.com/diademnetwork/diademchain/builtin/plugins/ethcoin"
 	"github.com/diademnetwork/diademchain/config"
 	"github.com/diademnetwork/diademchain/eth/polls"
 	"github.com/diademnetwork/diademchain/eth/query"
 	"github.com/diademnetwork/diademchain/eth/subs"
+	"github.com/diademnetwork/diademchain/eth/utils"
 	levm "github.com/diademnetwork/diademchain/evm"
 	"github.com/diademnetwork/diademchain/log"
 	lcp "github.com/diademnetwork/diademchain/plugin"
 	hsmpv "github.com/diademnetwork/diademchain/privval/hsm"
+	rcommon "github.com/diademnetwork/diademchain/receipts/common"
 	registry "github.com/diademnetwork/diademchain/registry/factory"
 	"github.com/diademnetwork/diademchain/rpc/eth"
 	"github.com/diademnetwork/diademchain/store"
 	lvm "github.com/diademnetwork/diademchain/vm"
-	sha3 "github.com/miguelmota/go-solidity-sha3"
 	pubsub "github.com/phonkee/go-pubsub"
 	"github.com/pkg/errors"
 	dbm "github.com/tendermint/tendermint/libs/db"
+	abci "github.com/tendermint/tendermint/abci/types"
 	ctypes "github.com/tendermint/tendermint/rpc/core/types"
 	rpctypes "github.com/tendermint/tendermint/rpc/lib/types"
 )
@@ -42,6 +49,23 @@ type StateProvider interface {
 	ReadOnlyState() diademchain.State
 }
 
+// HistoricalStateProvider interface is used by QueryServer to access the read-only application
+// state at previous block heights
+type HistoricalStateProvider interface {
+	ReadOnlyStateAt(block abci.Header, blockHash []byte) (diademchain.State, error)
+}
+
+// ProofProvider interface is used by QueryServer to obtain Merkle proofs for the application state
+type ProofProvider interface {
+	StoreProof(key []byte) (*store.ValueProof, error)
+}
+
+// PruningStatusProvider interface is used by QueryServer to report the progress of the background
+// pruning of the application state
+type PruningStatusProvider interface {
+	PruningStatus() (*store.PruningStatus, error)
+}
+
 // QueryServer provides the ability to query the current state of the DAppChain via RPC.
 //
 // Contract state can be queried via:
@@ -93,8 +117,16 @@ type StateProvider interface {
 //     "id": "123456789"
 //   }
 // - POST request to "/nonce" endpoint with form-encoded key param.
+// The nonce of the last tx accepted into the mempool of the node, rather than the last committed
+// tx, can be obtained by setting the pending param to true.
 type QueryServer struct {
 	StateProvider
+	// If this is nil only the latest state can be queried.
+	HistoricalStateProvider HistoricalStateProvider
+	// If this is nil proofs won't be available via the queryproof route.
+	ProofProvider ProofProvider
+	// If this is nil the pruning status won't be available via the prune_status route.
+	PruningStatusProvider  PruningStatusProvider
 	ChainID                string
 	Loader                 lcp.Loader
 	Subscriptions          *diademchain.SubscriptionSet
@@ -110,13 +142,40 @@ type QueryServer struct {
 	EventStore store.EventStore
 	EvmDB      dbm.DB
 	AuthCfg    *auth.Config
+
+	// Name of the builtin contract eth_getBalance reads account balances from, defaults to ethcoin.
+	BalanceContract string
+
+	// Max number of blocks & logs eth_getLogs can query & return, zero means no limit.
+	EthGetLogsMaxBlockRange uint64
+	EthGetLogsMaxResults    uint64
+	// Max number of blocks & events contractevents can query & return, if the max block range is
+	// zero the default range is used.
+	ContractEventsMaxBlockRange uint64
+	ContractEventsMaxResults    uint64
+	// Max number of steps debug_traceTransaction & debug_traceCall can capture, if this is zero
+	// the default limit is used.
+	DebugTraceMaxSteps int
 }
 
 var _ QueryService = &QueryServer{}
 
+// Number of blocks contractevents can query when the max block range isn't configured.
+const defaultContractEventsMaxBlockRange = uint64(20)
+
+// Number of steps the debug_* methods can capture when the max number of steps isn't configured.
+const defaultDebugTraceMaxSteps = 10000
+
 // Query returns data of given contract from the application states
 // The contract parameter should be a hex-encoded local address prefixed by 0x
 func (s *QueryServer) Query(caller, contract string, query []byte, vmType vm.VMType) ([]byte, error) {
+	return s.QueryAt(caller, contract, query, vmType, 0)
+}
+
+// QueryAt returns data of given contract from the application state at a previous block height,
+// if the height is zero the latest state will be queried.
+// The contract parameter should be a hex-encoded local address prefixed by 0x
+func (s *QueryServer) QueryAt(caller, contract string, query []byte, vmType vm.VMType, height int64) ([]byte, error) {
 	var callerAddr diadem.Address
 	var err error
 	if len(caller) == 0 {
@@ -137,11 +196,112 @@ func (s *QueryServer) Query(caller, contract string, query []byte, vmType vm.VMT
 		Local:   localContractAddr,
 	}
 
+	snapshot, err := s.readOnlyStateAt(height)
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Release()
+
 	if vmType == lvm.VMType_PLUGIN {
-		return s.queryPlugin(callerAddr, contractAddr, query)
+		return s.queryPlugin(snapshot, callerAddr, contractAddr, query)
 	} else {
-		return s.queryEvm(callerAddr, contractAddr, query)
+		return s.queryEvm(snapshot, callerAddr, contractAddr, query)
+	}
+}
+
+// readOnlyStateAt returns a read-only snapshot of the app state at the given block height, if the
+// height is zero the latest state will be returned. The caller is responsible for releasing the
+// snapshot.
+func (s *QueryServer) readOnlyStateAt(height int64) (diademchain.State, error) {
+	if height < 0 {
+		return nil, fmt.Errorf("invalid height %d", height)
+	}
+
+	snapshot := s.StateProvider.ReadOnlyState()
+	latestHeight := snapshot.Block().Height
+	if height == 0 || height == latestHeight {
+		return snapshot, nil
+	}
+	snapshot.Release()
+
+	if height > latestHeight {
+		return nil, fmt.Errorf("state at height %d is not available yet, latest height is %d", height, latestHeight)
+	}
+
+	if s.HistoricalStateProvider == nil {
+		return nil, fmt.Errorf(
+			"state at height %d is not available, the app store doesn't keep previous versions so only "+
+				"the latest state can be queried", height,
+		)
+	}
+
+	blockResult, err := s.BlockStore.GetBlockByHeight(&height)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load block at height %d", height)
+	}
+	blockHeader := blockResult.Block.Header
+	return s.HistoricalStateProvider.ReadOnlyStateAt(
+		abci.Header{
+			ChainID: s.ChainID,
+			Height:  height,
+			Time:    blockHeader.Time,
+			NumTxs:  blockHeader.NumTxs,
+			LastBlockId: abci.BlockID{
+				Hash: blockHeader.LastBlockID.Hash,
+			},
+			ValidatorsHash: blockHeader.ValidatorsHash,
+			AppHash:        blockHeader.AppHash,
+		},
+		blockResult.BlockMeta.BlockID.Hash,
+	)
+}
+
+// ethReadOnlyStateAt returns a read-only snapshot of the app state at the given block height.
+// The caller is responsible for releasing the snapshot.
+func (s *QueryServer) ethReadOnlyStateAt(block eth.BlockHeight) (diademchain.State, error) {
+	switch block {
+	case "", "latest", "pending":
+		return s.StateProvider.ReadOnlyState(), nil
+	}
+
+	snapshot := s.StateProvider.ReadOnlyState()
+	height, err := eth.DecBlockHeight(snapshot.Block().Height, block)
+	snapshot.Release()
+	if err != nil {
+		return nil, err
 	}
+	return s.readOnlyStateAt(int64(height))
+}
+
+// QueryProof returns the raw value stored under the given key in the state of a contract, along with
+// a Merkle proof that can be used to verify the value (or its absence) against the AppHash of the
+// block following the one at which the state was read, see VerifyContractStateProof.
+// The contract parameter should be a hex-encoded local address prefixed by 0x.
+func (s *QueryServer) QueryProof(contract string, key []byte) (*store.ValueProof, error) {
+	if s.ProofProvider == nil {
+		return nil, errors.New("proofs not available")
+	}
+	if len(key) == 0 {
+		return nil, errors.New("key not specified")
+	}
+
+	localContractAddr, err := decodeHexAddress(contract)
+	if err != nil {
+		return nil, err
+	}
+	contractAddr := diadem.Address{
+		ChainID: s.ChainID,
+		Local:   localContractAddr,
+	}
+	return s.ProofProvider.StoreProof(ContractStateKey(contractAddr, key))
+}
+
+// PruningStatus returns the progress of the background pruning of old versions of the app state.
+func (s *QueryServer) PruningStatus() (*store.PruningStatus, error) {
+	if s.PruningStatusProvider == nil {
+		return nil, errors.New("pruning status not available")
+	}
+	return s.PruningStatusProvider.PruningStatus()
 }
 
 func (s *QueryServer) QueryEnv() (*config.EnvInfo, error) {
@@ -182,10 +342,7 @@ func (s *QueryServer) QueryEnv() (*config.EnvInfo, error) {
 	return &envInfo, err
 }
 
-func (s *QueryServer) queryPlugin(caller, contract diadem.Address, query []byte) ([]byte, error) {
-	snapshot := s.StateProvider.ReadOnlyState()
-	defer snapshot.Release()
-
+func (s *QueryServer) queryPlugin(snapshot diademchain.State, caller, contract diadem.Address, query []byte) ([]byte, error) {
 	callerAddr, err := auth.ResolveAccountAddress(caller, snapshot, s.AuthCfg, s.createAddressMapperCtx)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to resolve account address")
@@ -224,37 +381,40 @@ func (s *QueryServer) queryPlugin(caller, contract diadem.Address, query []byte)
 	return resp.Body, nil
 }
 
-func (s *QueryServer) queryEvm(caller, contract diadem.Address, query []byte) ([]byte, error) {
-	snapshot := s.StateProvider.ReadOnlyState()
-	defer snapshot.Release()
-
+func (s *QueryServer) queryEvm(snapshot diademchain.State, caller, contract diadem.Address, query []byte) ([]byte, error) {
 	callerAddr, err := auth.ResolveAccountAddress(caller, snapshot, s.AuthCfg, s.createAddressMapperCtx)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to resolve account address")
 	}
 
-	var createABM levm.AccountBalanceManagerFactoryFunc
-	if s.NewABMFactory != nil {
-		pvm := lcp.NewPluginVM(
-			s.Loader,
-			snapshot,
-			s.EvmDB,
-			s.CreateRegistry(snapshot),
-			nil,
-			log.Default,
-			s.NewABMFactory,
-			nil,
-			nil,
-		)
-		createABM, err = s.NewABMFactory(pvm)
-		if err != nil {
-			return nil, err
-		}
+	createABM, err := s.createABMFactory(snapshot)
+	if err != nil {
+		return nil, err
 	}
 	vm := levm.NewDiademVm(snapshot, s.EvmDB, nil, nil, createABM, false)
 	return vm.StaticCall(callerAddr, contract, query)
 }
 
+// createABMFactory returns a factory for account balance managers that can be used by the EVM to
+// access account balances in the given state, or nil if the EVM has no access to account balances.
+func (s *QueryServer) createABMFactory(snapshot diademchain.State) (levm.AccountBalanceManagerFactoryFunc, error) {
+	if s.NewABMFactory == nil {
+		return nil, nil
+	}
+	pvm := lcp.NewPluginVM(
+		s.Loader,
+		snapshot,
+		s.EvmDB,
+		s.CreateRegistry(snapshot),
+		nil,
+		log.Default,
+		s.NewABMFactory,
+		nil,
+		nil,
+	)
+	return s.NewABMFactory(pvm)
+}
+
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_call
 func (s QueryServer) EthCall(query eth.JsonTxCallObject, block eth.BlockHeight) (resp eth.Data, err error) {
 	var caller diadem.Address
@@ -272,7 +432,14 @@ func (s QueryServer) EthCall(query eth.JsonTxCallObject, block eth.BlockHeight)
 	if err != nil {
 		return resp, err
 	}
-	bytes, err := s.queryEvm(caller, contract, data)
+
+	snapshot, err := s.ethReadOnlyStateAt(block)
+	if err != nil {
+		return resp, err
+	}
+	defer snapshot.Release()
+
+	bytes, err := s.queryEvm(snapshot, caller, contract, data)
 	return eth.EncBytes(bytes), err
 }
 
@@ -299,7 +466,10 @@ func (s *QueryServer) EthGetCode(address eth.Data, block eth.BlockHeight) (eth.D
 		return "", errors.Wrapf(err, "decoding input address parameter %v", address)
 	}
 
-	snapshot := s.StateProvider.ReadOnlyState()
+	snapshot, err := s.ethReadOnlyStateAt(block)
+	if err != nil {
+		return "", err
+	}
 	defer snapshot.Release()
 
 	evm := levm.NewDiademVm(snapshot, s.EvmDB, nil, nil, nil, false)
@@ -331,10 +501,14 @@ func (s *QueryServer) createAddressMapperCtx(state diademchain.State) (contractp
 	return ctx, nil
 }
 
-// Nonce returns the nonce of the last committed tx sent by the given account.
+// Nonce returns the nonce of the last committed tx sent by the given account, or the nonce of the
+// last tx accepted into the mempool if pending is true.
 // NOTE: Either the key or the account must be provided. The account (if not empty) is used in
 //       preference to the key.
-func (s *QueryServer) Nonce(key, account string) (uint64, error) {
+func (s *QueryServer) Nonce(key, account string, pending bool) (uint64, error) {
+	snapshot := s.StateProvider.ReadOnlyState()
+	defer snapshot.Release()
+
 	var addr diadem.Address
 
 	if key != "" && account == "" {
@@ -342,9 +516,14 @@ func (s *QueryServer) Nonce(key, account string) (uint64, error) {
 		if err != nil {
 			return 0, err
 		}
+		// the key may control an account whose key has been rotated
+		local, err := auth.ResolveAccountKey(snapshot, k)
+		if err != nil {
+			return 0, err
+		}
 		addr = diadem.Address{
 			ChainID: s.ChainID,
-			Local:   diadem.LocalAddressFromPublicKey(k),
+			Local:   local,
 		}
 	} else if account != "" {
 		var err error
@@ -356,9 +535,13 @@ func (s *QueryServer) Nonce(key, account string) (uint64, error) {
 		return 0, errors.New("no key or account specified")
 	}
 
-	snapshot := s.StateProvider.ReadOnlyState()
-	defer snapshot.Release()
+	if pending {
+		return s.pendingNonce(snapshot, addr)
+	}
+	return s.nonce(snapshot, addr)
+}
 
+func (s *QueryServer) nonce(snapshot diademchain.State, addr diadem.Address) (uint64, error) {
 	resolvedAddr, err := auth.ResolveAccountAddress(addr, snapshot, s.AuthCfg, s.createAddressMapperCtx)
 	if err != nil {
 		return 0, errors.Wrap(err, "failed to resolve account address")
@@ -367,6 +550,20 @@ func (s *QueryServer) Nonce(key, account string) (uint64, error) {
 	return auth.Nonce(snapshot, resolvedAddr), nil
 }
 
+func (s *QueryServer) pendingNonce(snapshot diademchain.State, addr diadem.Address) (uint64, error) {
+	resolvedAddr, err := auth.ResolveAccountAddress(addr, snapshot, s.AuthCfg, s.createAddressMapperCtx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to resolve account address")
+	}
+
+	nonce := auth.Nonce(snapshot, resolvedAddr)
+	// the mempool may lag behind the committed state while the txs in it are rechecked
+	if pendingNonce, ok := auth.PendingNonce(resolvedAddr); ok && pendingNonce > nonce {
+		return pendingNonce, nil
+	}
+	return nonce, nil
+}
+
 func (s *QueryServer) Resolve(name string) (string, error) {
 	snapshot := s.StateProvider.ReadOnlyState()
 	defer snapshot.Release()
@@ -501,7 +698,25 @@ func (s *QueryServer) EvmTxReceipt(txHash []byte) ([]byte, error) {
 	return proto.Marshal(&txReceipt)
 }
 
-func (s *QueryServer) ContractEvents(fromBlock uint64, toBlock uint64, contractName string) (*types.ContractEventsResult, error) {
+// ContractEventsResult is wire compatible with types.ContractEventsResult, it just adds a cursor
+// that can be used to fetch the next page of events.
+type ContractEventsResult struct {
+	Events    []*types.EventData `json:"events,omitempty"`
+	FromBlock uint64             `json:"from_block,omitempty"`
+	ToBlock   uint64             `json:"to_block,omitempty"`
+	// Set when there are more events matching the query than the limit allows, pass it in as the
+	// cursor to the next query to fetch the next page of events.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ContractEvents returns the events emitted by contracts within the given block range.
+// The events can be further filtered by topic, origin (the address of the caller), and tx hash.
+// When a non-zero limit is specified the result will contain at most that many events, and a
+// cursor that can be used to fetch the rest of the matching events.
+func (s *QueryServer) ContractEvents(
+	fromBlock uint64, toBlock uint64, contractName string, topic string, origin string, txHash []byte,
+	limit uint64, cursor string,
+) (*ContractEventsResult, error) {
 	if s.EventStore == nil {
 		return nil, errors.New("event store is not available")
 	}
@@ -518,28 +733,56 @@ func (s *QueryServer) ContractEvents(fromBlock uint64, toBlock uint64, contractN
 		return nil, fmt.Errorf("toBlock must be equal or greater than")
 	}
 
-	// default to max 20 blocks for now.
-	maxRange := uint64(20)
+	maxRange := s.ContractEventsMaxBlockRange
+	if maxRange == 0 {
+		maxRange = defaultContractEventsMaxBlockRange
+	}
 
 	if toBlock-fromBlock > maxRange {
 		return nil, fmt.Errorf("range exceeded, maximum range: %v", maxRange)
 	}
 
+	if origin != "" {
+		addr, err := diadem.ParseAddress(origin)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid origin")
+		}
+		origin = addr.String()
+	}
+
+	if s.ContractEventsMaxResults > 0 && (limit == 0 || limit > s.ContractEventsMaxResults) {
+		limit = s.ContractEventsMaxResults
+	}
+
 	filter := store.EventFilter{
 		FromBlock: fromBlock,
 		ToBlock:   toBlock,
 		Contract:  contractName,
+		Topic:     topic,
+		Origin:    origin,
+		TxHash:    txHash,
+		Limit:     limit,
+	}
+	if cursor != "" {
+		var err error
+		if filter.Cursor, err = store.ParseEventCursor(cursor); err != nil {
+			return nil, err
+		}
 	}
-	events, err := s.EventStore.FilterEvents(filter)
+	filterResult, err := s.EventStore.FilterEvents(filter)
 	if err != nil {
 		return nil, err
 	}
 
-	return &types.ContractEventsResult{
-		Events:    events,
+	result := &ContractEventsResult{
+		Events:    filterResult.Events,
 		FromBlock: fromBlock,
 		ToBlock:   toBlock,
-	}, nil
+	}
+	if filterResult.NextCursor != nil {
+		result.NextCursor = filterResult.NextCursor.String()
+	}
+	return result, nil
 }
 
 // Takes a filter and returns a list of data relative to transactions that satisfies the filter
@@ -570,7 +813,7 @@ func (s *QueryServer) NewBlockEvmFilter() (string, error) {
 	snapshot := s.StateProvider.ReadOnlyState()
 	defer snapshot.Release()
 
-	return s.EthPolls.AddBlockPoll(uint64(snapshot.Block().Height)), nil
+	return s.EthPolls.AddBlockPoll(uint64(snapshot.Block().Height))
 }
 
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_newpendingtransactionfilter
@@ -578,7 +821,7 @@ func (s *QueryServer) NewPendingTransactionEvmFilter() (string, error) {
 	snapshot := s.StateProvider.ReadOnlyState()
 	defer snapshot.Release()
 
-	return s.EthPolls.AddTxPoll(uint64(snapshot.Block().Height)), nil
+	return s.EthPolls.AddTxPoll(uint64(snapshot.Block().Height))
 }
 
 // Get the logs since last poll
@@ -600,7 +843,9 @@ func (s *QueryServer) GetEvmFilterChanges(id string) ([]byte, error) {
 // Forget the filter.
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_uninstallfilter
 func (s *QueryServer) UninstallEvmFilter(id string) (bool, error) {
-	s.EthPolls.Remove(id)
+	if err := s.EthPolls.Remove(id); err != nil {
+		return false, err
+	}
 	return true, nil
 }
 
@@ -734,7 +979,11 @@ func (s *QueryServer) EthGetTransactionReceipt(hash eth.Data) (resp eth.JsonTxRe
 		}
 	}
 
-	return eth.EncTxReceipt(txReceipt), nil
+	jsonReceipt := eth.EncTxReceipt(txReceipt)
+	if txReceipt.Status != rcommon.StatusTxSuccess {
+		jsonReceipt.RevertReason = r.GetRevertReason(txHash)
+	}
+	return jsonReceipt, nil
 }
 
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getblocktransactioncountbyhash
@@ -867,7 +1116,7 @@ func (s *QueryServer) EthGetTransactionByBlockNumberAndIndex(block eth.BlockHeig
 }
 
 /// https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getlogs
-func (s *QueryServer) EthGetLogs(filter eth.JsonFilter) (resp []eth.JsonLog, err error) {
+func (s *QueryServer) EthGetLogs(ctx context.Context, filter eth.JsonFilter) (resp []eth.JsonLog, err error) {
 	ethFilter, err := eth.DecLogFilter(filter)
 	if err != nil {
 		return resp, err
@@ -883,30 +1132,63 @@ func (s *QueryServer) EthGetLogs(filter eth.JsonFilter) (resp []eth.JsonLog, err
 	// TODO: Reading from the TM block store could take a while, might be more efficient to release
 	//       the current snapshot and get a new one after pulling out whatever we need from the TM
 	//       block store.
-	logs, err := query.QueryChain(s.BlockStore, snapshot, ethFilter, r)
+	if err := s.checkEthLogsBlockRange(snapshot.Block().Height, ethFilter); err != nil {
+		return resp, err
+	}
+	logs, err := query.QueryChainWithLimit(ctx, s.BlockStore, snapshot, ethFilter, r, s.EthGetLogsMaxResults)
 	if err != nil {
 		return resp, err
 	}
 	return eth.EncLogs(logs), err
 }
 
+// checkEthLogsBlockRange returns an error if the given filter spans more than
+// EthGetLogsMaxBlockRange blocks.
+func (s *QueryServer) checkEthLogsBlockRange(height int64, ethFilter eth.EthFilter) error {
+	if s.EthGetLogsMaxBlockRange == 0 {
+		return nil
+	}
+	from, err := eth.DecBlockHeight(height, eth.BlockHeight(ethFilter.FromBlock))
+	if err != nil {
+		return err
+	}
+	to, err := eth.DecBlockHeight(height, eth.BlockHeight(ethFilter.ToBlock))
+	if err != nil {
+		return err
+	}
+	if to >= from && to-from >= s.EthGetLogsMaxBlockRange {
+		return fmt.Errorf("block range exceeded, maximum range: %v", s.EthGetLogsMaxBlockRange)
+	}
+	return nil
+}
+
 // todo add EthNewBlockFilter EthNewPendingTransactionFilter EthUninstallFilter EthGetFilterChanges and EthGetFilterLogs
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_newblockfilter
 func (s QueryServer) EthNewBlockFilter() (eth.Quantity, error) {
 	state := s.StateProvider.ReadOnlyState()
-	return eth.Quantity(s.EthPolls.AddBlockPoll(uint64(state.Block().Height))), nil
+	id, err := s.EthPolls.AddBlockPoll(uint64(state.Block().Height))
+	if err != nil {
+		return "", err
+	}
+	return eth.Quantity(id), nil
 }
 
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_newpendingtransactionfilter
 func (s QueryServer) EthNewPendingTransactionFilter() (eth.Quantity, error) {
 	state := s.StateProvider.ReadOnlyState()
-	return eth.Quantity(s.EthPolls.AddTxPoll(uint64(state.Block().Height))), nil
+	id, err := s.EthPolls.AddTxPoll(uint64(state.Block().Height))
+	if err != nil {
+		return "", err
+	}
+	return eth.Quantity(id), nil
 }
 
 // Forget the filter.
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_uninstallfilter
 func (s *QueryServer) EthUninstallFilter(id eth.Quantity) (bool, error) {
-	s.EthPolls.Remove(string(id))
+	if err := s.EthPolls.Remove(string(id)); err != nil {
+		return false, err
+	}
 	return true, nil
 }
 
@@ -935,7 +1217,13 @@ func (s *QueryServer) EthGetFilterLogs(id eth.Quantity) (interface{}, error) {
 	}
 
 	if filter, err := s.EthSubscriptions.GetFilter(string(id)); filter != nil || err != nil {
-		logs, err := query.QueryChain(s.BlockStore, state, *filter, r)
+		// the same limits as eth_getLogs apply
+		if err := s.checkEthLogsBlockRange(state.Block().Height, *filter); err != nil {
+			return nil, err
+		}
+		logs, err := query.QueryChainWithLimit(
+			context.Background(), s.BlockStore, state, *filter, r, s.EthGetLogsMaxResults,
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -957,12 +1245,25 @@ func (s *QueryServer) EthNewFilter(filter eth.JsonFilter) (eth.Quantity, error)
 	return eth.Quantity(id), err
 }
 
-func (s *QueryServer) EthSubscribe(conn *websocket.Conn, method eth.Data, filter eth.JsonFilter) (eth.Data, error) {
+func (s *QueryServer) EthSubscribe(conn *websocket.Conn, method eth.Data, params json.RawMessage) (eth.Data, error) {
+	var filter eth.JsonFilter
+	var fullTxs bool
+	if len(params) > 0 && string(params) != "null" {
+		var err error
+		if string(method) == subs.NewPendingTransactions {
+			err = json.Unmarshal(params, &fullTxs)
+		} else {
+			err = json.Unmarshal(params, &filter)
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "decode params")
+		}
+	}
 	f, err := eth.DecLogFilter(filter)
 	if err != nil {
 		return "", errors.Wrapf(err, "decode filter")
 	}
-	id, err := s.EthSubscriptions.AddSubscription(string(method), f, conn)
+	id, err := s.EthSubscriptions.AddSubscription(string(method), f, fullTxs, conn)
 	if err != nil {
 		return "", errors.Wrapf(err, "add subscription")
 	}
@@ -975,22 +1276,23 @@ func (s *QueryServer) EthUnsubscribe(id eth.Quantity) (unsubscribed bool, err er
 }
 
 func (s *QueryServer) EthGetTransactionCount(local eth.Data, block eth.BlockHeight) (eth.Quantity, error) {
-	snapshot := s.StateProvider.ReadOnlyState()
-	defer snapshot.Release()
-
-	height, err := eth.DecBlockHeight(snapshot.Block().Height, block)
+	address, err := eth.DecDataToAddress(s.ChainID, local)
 	if err != nil {
 		return eth.Quantity("0x0"), err
 	}
 
-	if height != uint64(snapshot.Block().Height) {
-		return eth.Quantity("0x0"), fmt.Errorf("transaction count only implemted for the latest block %v, block %v requested", snapshot.Block().Height, height)
-	}
-	address, err := eth.DecDataToAddress(s.ChainID, local)
+	snapshot, err := s.ethReadOnlyStateAt(block)
 	if err != nil {
 		return eth.Quantity("0x0"), err
 	}
-	nonce, err := s.Nonce("", address.String())
+	defer snapshot.Release()
+
+	var nonce uint64
+	if block == "pending" {
+		nonce, err = s.pendingNonce(snapshot, address)
+	} else {
+		nonce, err = s.nonce(snapshot, address)
+	}
 	if err != nil {
 		return eth.Quantity("0x0"), errors.Wrap(err, "requesting transaction count")
 	}
@@ -998,23 +1300,420 @@ func (s *QueryServer) EthGetTransactionCount(local eth.Data, block eth.BlockHeig
 	return eth.EncUint(nonce), nil
 }
 
+// EthGetBalance returns the balance of the given account at the given block height.
+// The balance is read from the builtin contract specified by QueryServer.BalanceContract, if the
+// Ethereum account is mapped to a DAppChain account by the address mapper the balance of the mapped
+// account is returned instead. If the balance contract isn't deployed all accounts have a zero
+// balance.
+// https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getbalance
 func (s *QueryServer) EthGetBalance(address eth.Data, block eth.BlockHeight) (eth.Quantity, error) {
-	return eth.Quantity("0x0"), nil
+	local, err := eth.DecDataToBytes(address)
+	if err != nil {
+		return eth.Quantity("0x0"), errors.Wrapf(err, "decoding input address parameter %v", address)
+	}
+
+	snapshot, err := s.ethReadOnlyStateAt(block)
+	if err != nil {
+		return eth.Quantity("0x0"), err
+	}
+	defer snapshot.Release()
+
+	addr, err := s.resolveEthAccountAddress(snapshot, local)
+	if err != nil {
+		return eth.Quantity("0x0"), errors.Wrap(err, "failed to resolve account address")
+	}
+	balance, err := s.balanceOf(snapshot, addr)
+	if err != nil {
+		return eth.Quantity("0x0"), errors.Wrap(err, "requesting balance")
+	}
+	if balance == nil || balance.Int == nil {
+		return eth.Quantity("0x0"), nil
+	}
+	return eth.EncBigInt(balance.Int), nil
+}
+
+// resolveEthAccountAddress returns the DAppChain account an Ethereum account is mapped to via the
+// address mapper, or the DAppChain account with the same local address if there's no such mapping.
+// Ethereum accounts are identified by the chain ID the auth config assigns to the senders of
+// Ethereum signed txs.
+func (s *QueryServer) resolveEthAccountAddress(snapshot diademchain.State, local []byte) (diadem.Address, error) {
+	addr := diadem.Address{ChainID: s.ChainID, Local: local}
+	if s.AuthCfg == nil {
+		return addr, nil
+	}
+	ethChainID, err := auth.EthSenderChainID(s.AuthCfg.Chains)
+	if err != nil {
+		// no chain accepts Ethereum signed txs, so Ethereum accounts can't have been mapped
+		return addr, nil
+	}
+	if _, err := s.CreateRegistry(snapshot).Resolve("addressmapper"); err != nil {
+		// no address mapper, so there can't be any mappings
+		return addr, nil
+	}
+	ctx, err := s.createAddressMapperCtx(snapshot)
+	if err != nil {
+		return diadem.Address{}, err
+	}
+
+	ethAddr := diadem.Address{ChainID: ethChainID, Local: local}
+	am := &address_mapper.AddressMapper{}
+	resp, err := am.HasMapping(ctx, &address_mapper.HasMappingRequest{From: ethAddr.MarshalPB()})
+	if err != nil {
+		return diadem.Address{}, err
+	}
+	if !resp.HasMapping {
+		return addr, nil
+	}
+	mapping, err := am.GetMapping(ctx, &address_mapper.GetMappingRequest{From: ethAddr.MarshalPB()})
+	if err != nil {
+		return diadem.Address{}, err
+	}
+	return diadem.UnmarshalAddressPB(mapping.To), nil
+}
+
+// balanceOf returns the balance of the given account held by the balance contract, or nil if the
+// balance contract isn't deployed.
+func (s *QueryServer) balanceOf(snapshot diademchain.State, addr diadem.Address) (*diadem.BigUInt, error) {
+	contractName := s.BalanceContract
+	if contractName == "" {
+		contractName = "ethcoin"
+	}
+	if _, err := s.CreateRegistry(snapshot).Resolve(contractName); err != nil {
+		return nil, nil
+	}
+
+	vm := lcp.NewPluginVM(
+		s.Loader,
+		snapshot,
+		s.EvmDB,
+		s.CreateRegistry(snapshot),
+		nil, // event handler
+		log.Default,
+		s.NewABMFactory,
+		nil, // receipt writer
+		nil, // receipt reader
+	)
+	ctx, err := lcp.NewInternalContractContext(contractName, vm)
+	if err != nil {
+		return nil, err
+	}
+
+	switch contractName {
+	case "ethcoin":
+		return ethcoin.BalanceOf(ctx, addr)
+	case "coin":
+		resp, err := (&coin.Coin{}).BalanceOf(ctx, &coin.BalanceOfRequest{Owner: addr.MarshalPB()})
+		if err != nil {
+			return nil, err
+		}
+		if resp.Balance == nil {
+			return nil, nil
+		}
+		return &resp.Balance.Value, nil
+	default:
+		return nil, fmt.Errorf("unsupported balance contract %s", contractName)
+	}
 }
 
+// EthEstimateGas simulates the given call (or contract deployment if query.To is empty) against the
+// latest state, and returns the lowest gas limit the call succeeds with.
+// https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_estimategas
 func (s *QueryServer) EthEstimateGas(query eth.JsonTxCallObject) (eth.Quantity, error) {
-	return eth.Quantity("0x0"), nil
+	caller, contract, input, value, err := s.decodeCallObject(query)
+	if err != nil {
+		return "", err
+	}
+	// the gas limit specified by the caller (if any) caps the estimate
+	var gasCap uint64
+	if len(query.Gas) > 0 {
+		gasCap, err = eth.DecQuantityToUint(query.Gas)
+		if err != nil {
+			return "", errors.Wrap(err, "invalid gas")
+		}
+	}
+
+	snapshot := s.StateProvider.ReadOnlyState()
+	defer snapshot.Release()
+
+	callerAddr, err := auth.ResolveAccountAddress(caller, snapshot, s.AuthCfg, s.createAddressMapperCtx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to resolve account address")
+	}
+	createABM, err := s.createABMFactory(snapshot)
+	if err != nil {
+		return "", err
+	}
+	gas, err := levm.EstimateGas(snapshot, s.EvmDB, createABM, callerAddr, contract, input, value, gasCap)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to estimate gas")
+	}
+	return eth.EncUint(gas), nil
+}
+
+// decodeCallObject extracts the caller, contract, input, and value from the given call object.
+// The contract will be nil if the call object doesn't specify one.
+func (s *QueryServer) decodeCallObject(query eth.JsonTxCallObject) (
+	caller diadem.Address, contract *diadem.Address, input []byte, value *diadem.BigUInt, err error,
+) {
+	if len(query.From) > 0 {
+		caller, err = eth.DecDataToAddress(s.ChainID, query.From)
+		if err != nil {
+			return caller, nil, nil, nil, errors.Wrap(err, "invalid from address")
+		}
+	}
+	if len(query.To) > 0 {
+		addr, err := eth.DecDataToAddress(s.ChainID, query.To)
+		if err != nil {
+			return caller, nil, nil, nil, errors.Wrap(err, "invalid to address")
+		}
+		contract = &addr
+	}
+	if len(query.Data) > 0 {
+		input, err = eth.DecDataToBytes(query.Data)
+		if err != nil {
+			return caller, nil, nil, nil, errors.Wrap(err, "invalid data")
+		}
+	}
+	if len(query.Value) > 0 {
+		v, err := eth.DecQuantityToBigInt(query.Value)
+		if err != nil {
+			return caller, nil, nil, nil, errors.Wrap(err, "invalid value")
+		}
+		value = diadem.NewBigUInt(v)
+	}
+	return caller, contract, input, value, nil
 }
 
+// EthGasPrice returns the gas price EVM txs are executed with.
 func (s *QueryServer) EthGasPrice() (eth.Quantity, error) {
-	return eth.Quantity("0x0"), nil
+	return eth.EncBigInt(levm.GasPrice()), nil
+}
+
+// DebugTraceTransaction re-executes an EVM tx against the state at the height preceding the block
+// the tx was included in, and returns its trace. The EVM txs that preceded the traced tx in the same
+// block are re-executed before the traced tx, other txs that modified the state (e.g. Go contract
+// txs) can't be re-executed, so txs that are preceded by such txs in their block can't be traced.
+// Failed txs can be traced as long as their receipts have been kept.
+// https://github.com/ethereum/go-ethereum/wiki/Management-APIs#debug_tracetransaction
+func (s *QueryServer) DebugTraceTransaction(
+	ctx context.Context, hash eth.Data, config eth.JsonTraceConfig,
+) (interface{}, error) {
+	txHash, err := eth.DecDataToBytes(hash)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid tx hash")
+	}
+
+	snapshot := s.StateProvider.ReadOnlyState()
+	r, err := s.ReceiptHandlerProvider.ReaderAt(snapshot.Block().Height, snapshot.FeatureEnabled(diademchain.EvmTxReceiptsVersion2Feature, false))
+	if err != nil {
+		snapshot.Release()
+		return nil, err
+	}
+	receipt, err := r.GetReceipt(snapshot, txHash)
+	snapshot.Release()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading receipt")
+	}
+
+	height := receipt.BlockNumber
+	if height < 2 {
+		return nil, fmt.Errorf("txs in block %d can't be traced", height)
+	}
+	blockResult, err := s.BlockStore.GetBlockByHeight(&height)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load block at height %d", height)
+	}
+	blockResults, err := s.BlockStore.GetBlockResults(&height)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load results for block %d", height)
+	}
+	txs := blockResult.Block.Data.Txs
+	if len(txs) != len(blockResults.Results.DeliverTx) {
+		return nil, fmt.Errorf("block %d doesn't match its results", height)
+	}
+
+	parentState, err := s.readOnlyStateAt(height - 1)
+	if err != nil {
+		return nil, err
+	}
+	defer parentState.Release()
+	state := diademchain.NewCachedStoreState(parentState)
+	caller := diadem.UnmarshalAddressPB(receipt.CallerAddress)
+	failed := receipt.Status != rcommon.StatusTxSuccess
+
+	// Failed EVM txs don't take up a tx index, so the index of the receipt is the index of the tx
+	// among the EVM txs that have been successfully executed in the block, the receipt of a failed
+	// tx has the index of the first successful EVM tx that followed it.
+	var replay []*levm.TraceMessage
+	evmTxIndex := int32(0)
+	for i, result := range blockResults.Results.DeliverTx {
+		if result.IsErr() {
+			// failed txs didn't modify the state, so they don't need to be replayed
+			if !failed || evmTxIndex != receipt.TransactionIndex {
+				continue
+			}
+			msg, seq, err := s.decodeEvmTx(state, txs[i])
+			if err != nil {
+				// not an EVM tx
+				continue
+			}
+			// the receipt of a failed tx can only be matched to the tx by its caller & nonce
+			if msg.Caller.Compare(caller) == 0 && int64(seq) == receipt.Nonce {
+				return s.traceTx(ctx, state, replay, msg, config)
+			}
+			continue
+		}
+		if result.Info != utils.DeployEvm && result.Info != utils.CallEVM {
+			return nil, fmt.Errorf(
+				"tx %s can't be traced because it's preceded by a non-EVM tx in block %d", hash, height,
+			)
+		}
+		if failed && evmTxIndex == receipt.TransactionIndex {
+			break
+		}
+		msg, _, err := s.decodeEvmTx(state, txs[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode tx %d in block %d", i, height)
+		}
+		if !failed && evmTxIndex == receipt.TransactionIndex {
+			msg.Caller = caller
+			return s.traceTx(ctx, state, replay, msg, config)
+		}
+		replay = append(replay, msg)
+		evmTxIndex++
+	}
+	return nil, fmt.Errorf("tx %s not found in block %d", hash, height)
+}
+
+// traceTx re-executes the given EVM txs, and returns the trace of the last one.
+func (s *QueryServer) traceTx(
+	ctx context.Context, state diademchain.State, replay []*levm.TraceMessage, msg *levm.TraceMessage,
+	config eth.JsonTraceConfig,
+) (interface{}, error) {
+	createABM, err := s.createABMFactory(state)
+	if err != nil {
+		return nil, err
+	}
+	return levm.TraceTx(ctx, state, s.EvmDB, createABM, replay, msg, s.decodeTraceConfig(config))
+}
+
+// DebugTraceCall executes the given call (or contract deployment if query.To is empty) against the
+// state at the given block height, and returns its trace.
+func (s *QueryServer) DebugTraceCall(
+	ctx context.Context, query eth.JsonTxCallObject, block eth.BlockHeight, config eth.JsonTraceConfig,
+) (interface{}, error) {
+	caller, contract, input, value, err := s.decodeCallObject(query)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := s.ethReadOnlyStateAt(block)
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Release()
+	state := diademchain.NewCachedStoreState(snapshot)
+
+	callerAddr, err := auth.ResolveAccountAddress(caller, state, s.AuthCfg, s.createAddressMapperCtx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve account address")
+	}
+	createABM, err := s.createABMFactory(state)
+	if err != nil {
+		return nil, err
+	}
+	msg := &levm.TraceMessage{
+		Caller:   callerAddr,
+		Contract: contract,
+		Input:    input,
+		Value:    value,
+	}
+	return levm.TraceTx(ctx, state, s.EvmDB, createABM, nil, msg, s.decodeTraceConfig(config))
+}
+
+// decodeEvmTx extracts the EVM contract deployment or call from a signed tx, or an RLP encoded
+// Ethereum tx, along with the nonce of the tx.
+func (s *QueryServer) decodeEvmTx(state diademchain.State, txBytes []byte) (*levm.TraceMessage, uint64, error) {
+	nonceTxBytes, err := auth.UnwrapTx(txBytes, s.ChainID, s.AuthCfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	var nonceTx auth.NonceTx
+	if err := proto.Unmarshal(nonceTxBytes, &nonceTx); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to unmarshal NonceTx")
+	}
+	var tx ltypes.Transaction
+	if err := proto.Unmarshal(nonceTx.Inner, &tx); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to unmarshal Transaction")
+	}
+	var msgTx vm.MessageTx
+	if err := proto.Unmarshal(tx.Data, &msgTx); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to unmarshal MessageTx")
+	}
+
+	caller, err := auth.ResolveAccountAddress(
+		diadem.UnmarshalAddressPB(msgTx.From), state, s.AuthCfg, s.createAddressMapperCtx,
+	)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to resolve account address")
+	}
+	msg := &levm.TraceMessage{Caller: caller}
+
+	switch tx.Id {
+	case 1:
+		var deployTx vm.DeployTx
+		if err := proto.Unmarshal(msgTx.Data, &deployTx); err != nil {
+			return nil, 0, errors.Wrap(err, "failed to unmarshal DeployTx")
+		}
+		if deployTx.VmType != vm.VMType_EVM {
+			return nil, 0, errors.New("not an EVM tx")
+		}
+		msg.Input = deployTx.Code
+		if deployTx.Value != nil {
+			msg.Value = &deployTx.Value.Value
+		}
+	case 2:
+		var callTx vm.CallTx
+		if err := proto.Unmarshal(msgTx.Data, &callTx); err != nil {
+			return nil, 0, errors.Wrap(err, "failed to unmarshal CallTx")
+		}
+		if callTx.VmType != vm.VMType_EVM {
+			return nil, 0, errors.New("not an EVM tx")
+		}
+		contract := diadem.UnmarshalAddressPB(msgTx.To)
+		msg.Contract = &contract
+		msg.Input = callTx.Input
+		if callTx.Value != nil {
+			msg.Value = &callTx.Value.Value
+		}
+	default:
+		return nil, 0, fmt.Errorf("unsupported tx type %d", tx.Id)
+	}
+	return msg, nonceTx.Sequence, nil
+}
+
+// decodeTraceConfig converts the trace options supplied by the caller, the number of steps that
+// can be captured is capped at DebugTraceMaxSteps.
+func (s *QueryServer) decodeTraceConfig(config eth.JsonTraceConfig) *levm.TraceConfig {
+	maxSteps := s.DebugTraceMaxSteps
+	if maxSteps == 0 {
+		maxSteps = defaultDebugTraceMaxSteps
+	}
+	limit := config.Limit
+	if limit <= 0 || limit > maxSteps {
+		limit = maxSteps
+	}
+	return &levm.TraceConfig{
+		DisableMemory:  config.DisableMemory,
+		DisableStack:   config.DisableStack,
+		DisableStorage: config.DisableStorage,
+		Limit:          limit,
+		Tracer:         config.Tracer,
+	}
 }
 
 func (s *QueryServer) EthNetVersion() (string, error) {
-	hash := sha3.SoliditySHA3(sha3.String(s.ChainID))
-	versionBigInt := new(big.Int)
-	versionBigInt.SetString(hex.EncodeToString(hash)[0:13], 16)
-	return versionBigInt.String(), nil
+	return auth.EthChainID(s.ChainID).String(), nil
 }
 
 func (s *QueryServer) EthAccounts() ([]eth.Data, error) {
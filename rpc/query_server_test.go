@@ -11,14 +11,17 @@ import (
 
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
 	proto "github.com/gogo/protobuf/proto"
+	diadem "github.com/diademnetwork/go-diadem"
 	lp "github.com/diademnetwork/go-diadem/plugin"
 	"github.com/diademnetwork/go-diadem/plugin/types"
 	"github.com/diademnetwork/diademchain"
 	"github.com/diademnetwork/diademchain/auth"
+	"github.com/diademnetwork/diademchain/builtin/plugins/ethcoin"
 	"github.com/diademnetwork/diademchain/eth/subs"
 	llog "github.com/diademnetwork/diademchain/log"
 	"github.com/diademnetwork/diademchain/plugin"
 	registry "github.com/diademnetwork/diademchain/registry/factory"
+	"github.com/diademnetwork/diademchain/rpc/eth"
 	"github.com/diademnetwork/diademchain/store"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
@@ -101,6 +104,15 @@ func (s *stateProvider) ReadOnlyState() diademchain.State {
 	)
 }
 
+// persistentStateProvider returns the same state on every call
+type persistentStateProvider struct {
+	state diademchain.State
+}
+
+func (s *persistentStateProvider) ReadOnlyState() diademchain.State {
+	return s.state
+}
+
 var testlog llog.TMLogger
 
 func TestQueryServer(t *testing.T) {
@@ -111,6 +123,9 @@ func TestQueryServer(t *testing.T) {
 	t.Run("Query Metric", testQueryMetric)
 	t.Run("Query Contract Events", testQueryServerContractEvents)
 	t.Run("Query Contract Events Without Event", testQueryServerContractEventsNoEventStore)
+	t.Run("Query Eth Balance", testQueryServerEthGetBalance)
+	t.Run("Query State At Height", testQueryServerStateAtHeight)
+	t.Run("Debug Trace Step Limit", testQueryServerDebugTraceStepLimit)
 }
 
 func testQueryServerContractQuery(t *testing.T) {
@@ -215,6 +230,13 @@ func testQueryServerNonce(t *testing.T) {
 
 	_, err = rpcClient.Call("nonce", map[string]interface{}{"key": pubKey, "account": account}, &result)
 	require.NoError(t, err)
+
+	// Query for the pending nonce
+	_, err = http.Get(fmt.Sprintf("%s/nonce?account=\"%s\"&pending=true", ts.URL, account))
+	require.NoError(t, err)
+
+	_, err = rpcClient.Call("nonce", map[string]interface{}{"account": account, "pending": true}, &result)
+	require.NoError(t, err)
 }
 
 func testQueryMetric(t *testing.T) {
@@ -405,6 +427,34 @@ func testQueryServerContractEvents(t *testing.T) {
 		_, err := rpcClient.Call("contractevents", params, result)
 		require.NotNil(t, err)
 	})
+
+	t.Run("Test query pagination", func(t *testing.T) {
+		params := map[string]interface{}{}
+		params["fromBlock"] = 1
+		params["toBlock"] = 1
+		params["contract"] = "plugin1"
+		params["limit"] = 4
+
+		var events []*types.EventData
+		for pages := 0; pages < 3; pages++ {
+			result := &ContractEventsResult{}
+			_, err := rpcClient.Call("contractevents", params, result)
+			require.NoError(t, err)
+			events = append(events, result.Events...)
+			if result.NextCursor == "" {
+				break
+			}
+			params["cursor"] = result.NextCursor
+		}
+		require.Equal(t, len(eventData), len(events))
+		for i, e := range events {
+			require.True(t, proto.Equal(eventData[i], e))
+		}
+
+		params["cursor"] = "invalid"
+		_, err := rpcClient.Call("contractevents", params, &ContractEventsResult{})
+		require.NotNil(t, err)
+	})
 }
 
 func testQueryServerContractEventsNoEventStore(t *testing.T) {
@@ -440,3 +490,76 @@ func testQueryServerContractEventsNoEventStore(t *testing.T) {
 		require.NotNil(t, err)
 	})
 }
+
+func testQueryServerEthGetBalance(t *testing.T) {
+	state := diademchain.NewStoreState(
+		nil,
+		store.NewMemStore(),
+		abci.Header{
+			ChainID: "default",
+		},
+		nil,
+		nil,
+	)
+	createRegistry, err := registry.NewRegistryFactory(registry.LatestRegistryVersion)
+	require.NoError(t, err)
+	qs := &QueryServer{
+		ChainID:        "default",
+		StateProvider:  &persistentStateProvider{state: state},
+		CreateRegistry: createRegistry,
+		BlockStore:     store.NewMockBlockStore(),
+		AuthCfg:        auth.DefaultConfig(),
+	}
+	account := "0xb16a379ec18d4093666f8f38b11a3071c920207d"
+
+	// all accounts have a zero balance if the ethcoin contract isn't deployed
+	balance, err := qs.EthGetBalance(eth.Data(account), "latest")
+	require.NoError(t, err)
+	require.Equal(t, eth.Quantity("0x0"), balance)
+
+	ethCoinAddr := diadem.MustParseAddress("default:0x5cecd1f7261e1f4c684e297be3edf03b825e01c4")
+	require.NoError(t, createRegistry(state).Register("ethcoin", ethCoinAddr, ethCoinAddr))
+	vm := plugin.NewPluginVM(
+		qs.Loader, state, nil, createRegistry(state), nil, nil, nil, nil, nil,
+	)
+	ctx, err := plugin.NewInternalContractContext("ethcoin", vm)
+	require.NoError(t, err)
+	addr, err := eth.DecDataToAddress("default", eth.Data(account))
+	require.NoError(t, err)
+	require.NoError(t, ethcoin.Mint(ctx, addr, diadem.NewBigUIntFromInt(1000)))
+
+	balance, err = qs.EthGetBalance(eth.Data(account), "latest")
+	require.NoError(t, err)
+	require.Equal(t, eth.Quantity("0x3e8"), balance)
+}
+
+func testQueryServerStateAtHeight(t *testing.T) {
+	qs := &QueryServer{
+		StateProvider: &stateProvider{},
+		BlockStore:    store.NewMockBlockStore(),
+		AuthCfg:       auth.DefaultConfig(),
+	}
+
+	snapshot, err := qs.readOnlyStateAt(0)
+	require.NoError(t, err)
+	snapshot.Release()
+
+	// the state of blocks that haven't been committed yet shouldn't silently fall back to the
+	// latest state
+	_, err = qs.readOnlyStateAt(5)
+	require.Error(t, err)
+
+	_, err = qs.readOnlyStateAt(-1)
+	require.Error(t, err)
+}
+
+func testQueryServerDebugTraceStepLimit(t *testing.T) {
+	qs := &QueryServer{}
+	require.Equal(t, defaultDebugTraceMaxSteps, qs.decodeTraceConfig(eth.JsonTraceConfig{}).Limit)
+	require.Equal(t, 5, qs.decodeTraceConfig(eth.JsonTraceConfig{Limit: 5}).Limit)
+	require.Equal(t, defaultDebugTraceMaxSteps, qs.decodeTraceConfig(eth.JsonTraceConfig{Limit: defaultDebugTraceMaxSteps + 1}).Limit)
+
+	qs.DebugTraceMaxSteps = 100
+	require.Equal(t, 100, qs.decodeTraceConfig(eth.JsonTraceConfig{}).Limit)
+	require.Equal(t, 100, qs.decodeTraceConfig(eth.JsonTraceConfig{Limit: 1000}).Limit)
+}
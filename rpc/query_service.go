@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/gorilla/websocket"
@@ -12,20 +13,23 @@ import (
 	rpctypes "github.com/tendermint/tendermint/rpc/lib/types"
 	"golang.org/x/net/context"
 
-	"github.com/diademnetwork/go-diadem/plugin/types"
 	"github.com/diademnetwork/diademchain"
 	"github.com/diademnetwork/diademchain/config"
 	"github.com/diademnetwork/diademchain/eth/subs"
 	"github.com/diademnetwork/diademchain/log"
 	"github.com/diademnetwork/diademchain/rpc/eth"
+	"github.com/diademnetwork/diademchain/store"
 	"github.com/diademnetwork/diademchain/vm"
 )
 
 // QueryService provides necessary methods for the client to query application states
 type QueryService interface {
 	Query(caller, contract string, query []byte, vmType vm.VMType) ([]byte, error)
+	QueryAt(caller, contract string, query []byte, vmType vm.VMType, height int64) ([]byte, error)
+	QueryProof(contract string, key []byte) (*store.ValueProof, error)
+	PruningStatus() (*store.PruningStatus, error)
 	Resolve(name string) (string, error)
-	Nonce(key, account string) (uint64, error)
+	Nonce(key, account string, pending bool) (uint64, error)
 	Subscribe(wsCtx rpctypes.WSRPCContext, topics []string) (*WSEmptyResult, error)
 	UnSubscribe(wsCtx rpctypes.WSRPCContext, topics string) (*WSEmptyResult, error)
 	QueryEnv() (*config.EnvInfo, error)
@@ -37,7 +41,7 @@ type QueryService interface {
 	EthGetTransactionByHash(hash eth.Data) (eth.JsonTxObject, error)
 	EthGetCode(address eth.Data, block eth.BlockHeight) (eth.Data, error)
 	EthCall(query eth.JsonTxCallObject, block eth.BlockHeight) (eth.Data, error)
-	EthGetLogs(filter eth.JsonFilter) ([]eth.JsonLog, error)
+	EthGetLogs(ctx context.Context, filter eth.JsonFilter) ([]eth.JsonLog, error)
 	EthGetBlockTransactionCountByHash(hash eth.Data) (eth.Quantity, error)
 	EthGetBlockTransactionCountByNumber(block eth.BlockHeight) (eth.Quantity, error)
 	EthGetTransactionByBlockHashAndIndex(hash eth.Data, index eth.Quantity) (eth.JsonTxObject, error)
@@ -50,7 +54,9 @@ type QueryService interface {
 	EthGetFilterLogs(id eth.Quantity) (interface{}, error)
 
 	EthNewFilter(filter eth.JsonFilter) (eth.Quantity, error)
-	EthSubscribe(conn *websocket.Conn, method eth.Data, filter eth.JsonFilter) (id eth.Data, err error)
+	// The params of eth_subscribe depend on the method, a filter object for logs, and an optional
+	// boolean for newPendingTransactions that indicates if full tx objects should be sent.
+	EthSubscribe(conn *websocket.Conn, method eth.Data, params json.RawMessage) (id eth.Data, err error)
 	EthUnsubscribe(id eth.Quantity) (unsubscribed bool, err error)
 
 	EthGetBalance(address eth.Data, block eth.BlockHeight) (eth.Quantity, error)
@@ -60,7 +66,15 @@ type QueryService interface {
 	EthGetTransactionCount(local eth.Data, block eth.BlockHeight) (eth.Quantity, error)
 	EthAccounts() ([]eth.Data, error)
 
-	ContractEvents(fromBlock uint64, toBlock uint64, contract string) (*types.ContractEventsResult, error)
+	DebugTraceTransaction(ctx context.Context, hash eth.Data, config eth.JsonTraceConfig) (interface{}, error)
+	DebugTraceCall(
+		ctx context.Context, query eth.JsonTxCallObject, block eth.BlockHeight, config eth.JsonTraceConfig,
+	) (interface{}, error)
+
+	ContractEvents(
+		fromBlock uint64, toBlock uint64, contract string, topic string, origin string, txHash []byte,
+		limit uint64, cursor string,
+	) (*ContractEventsResult, error)
 
 	// deprecated function
 	EvmTxReceipt(txHash []byte) ([]byte, error)
@@ -105,31 +119,22 @@ func MakeQueryServiceHandler(svc QueryService, logger log.TMLogger, bus *QueryEv
 	// set up websocket route
 	codec := amino.NewCodec()
 	wsmux := http.NewServeMux()
-	routes := map[string]*rpcserver.RPCFunc{}
-	routes["query"] = rpcserver.NewRPCFunc(svc.Query, "caller,contract,query,vmType")
-	routes["env"] = rpcserver.NewRPCFunc(svc.QueryEnv, "")
-	routes["nonce"] = rpcserver.NewRPCFunc(svc.Nonce, "key,account")
-	routes["subevents"] = rpcserver.NewWSRPCFunc(svc.Subscribe, "topics")
-	routes["unsubevents"] = rpcserver.NewWSRPCFunc(svc.UnSubscribe, "topic")
-	routes["resolve"] = rpcserver.NewRPCFunc(svc.Resolve, "name")
-	routes["evmtxreceipt"] = rpcserver.NewRPCFunc(svc.EvmTxReceipt, "txHash")
-	routes["getevmcode"] = rpcserver.NewRPCFunc(svc.GetEvmCode, "contract")
-	routes["getevmlogs"] = rpcserver.NewRPCFunc(svc.GetEvmLogs, "filter")
-	routes["newevmfilter"] = rpcserver.NewRPCFunc(svc.NewEvmFilter, "filter")
-	routes["newblockevmfilter"] = rpcserver.NewRPCFunc(svc.NewBlockEvmFilter, "")
-	routes["newpendingtransactionevmfilter"] = rpcserver.NewRPCFunc(svc.NewPendingTransactionEvmFilter, "")
-	routes["getevmfilterchanges"] = rpcserver.NewRPCFunc(svc.GetEvmFilterChanges, "id")
-	routes["evmunsubscribe"] = rpcserver.NewRPCFunc(svc.EvmUnSubscribe, "id")
-	routes["uninstallevmfilter"] = rpcserver.NewRPCFunc(svc.UninstallEvmFilter, "id")
-	routes["getblockheight"] = rpcserver.NewRPCFunc(svc.GetBlockHeight, "")
-	routes["getevmblockbynumber"] = rpcserver.NewRPCFunc(svc.GetEvmBlockByNumber, "number,full")
-	routes["getevmblockbyhash"] = rpcserver.NewRPCFunc(svc.GetEvmBlockByHash, "hash,full")
-	routes["getevmtransactionbyhash"] = rpcserver.NewRPCFunc(svc.GetEvmTransactionByHash, "txHash")
-	routes["evmsubscribe"] = rpcserver.NewWSRPCFunc(svc.EvmSubscribe, "method,filter")
-	routes["contractevents"] = rpcserver.NewRPCFunc(svc.ContractEvents, "fromBlock,toBlock,contract")
+	routes := queryServiceRoutes(svc, nil)
 	rpcserver.RegisterRPCFuncs(wsmux, routes, codec, logger)
 	wm := rpcserver.NewWebsocketManager(routes, codec, rpcserver.EventSubscriber(bus))
-	wsmux.HandleFunc("/queryws", wm.WebsocketHandler)
+	wsmux.HandleFunc("/queryws", func(w http.ResponseWriter, req *http.Request) {
+		// Calls made over the connection are charged to the client that opened it, so the
+		// connection needs its own set of routes.
+		if client := rpcClientFromContext(req.Context()); client != nil {
+			clientWM := rpcserver.NewWebsocketManager(
+				queryServiceRoutes(svc, client), codec, rpcserver.EventSubscriber(bus),
+			)
+			clientWM.SetLogger(logger)
+			clientWM.WebsocketHandler(w, req)
+			return
+		}
+		wm.WebsocketHandler(w, req)
+	})
 
 	// setup default route
 	mux := http.NewServeMux()
@@ -149,8 +154,41 @@ func MakeQueryServiceHandler(svc QueryService, logger log.TMLogger, bus *QueryEv
 	return mux
 }
 
-// makeQueryServiceHandler returns a http handler mapping to query service
-func MakeEthQueryServiceHandler(svc QueryService, logger log.TMLogger, hub *Hub) http.Handler {
+// queryServiceRoutes returns the routes served by the /query & /queryws endpoints, if client isn't
+// nil every call is checked against the client's limits.
+func queryServiceRoutes(svc QueryService, client *rpcClient) map[string]*rpcserver.RPCFunc {
+	routes := map[string]*rpcserver.RPCFunc{}
+	routes["query"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("query", svc.Query), "caller,contract,query,vmType")
+	routes["queryat"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("queryat", svc.QueryAt), "caller,contract,query,vmType,height")
+	routes["queryproof"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("queryproof", svc.QueryProof), "contract,key")
+	routes["env"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("env", svc.QueryEnv), "")
+	routes["nonce"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("nonce", svc.Nonce), "key,account,pending")
+	routes["subevents"] = rpcserver.NewWSRPCFunc(client.wrapRPCFunc("subevents", svc.Subscribe), "topics")
+	routes["unsubevents"] = rpcserver.NewWSRPCFunc(client.wrapRPCFunc("unsubevents", svc.UnSubscribe), "topic")
+	routes["resolve"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("resolve", svc.Resolve), "name")
+	routes["evmtxreceipt"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("evmtxreceipt", svc.EvmTxReceipt), "txHash")
+	routes["getevmcode"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("getevmcode", svc.GetEvmCode), "contract")
+	routes["getevmlogs"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("getevmlogs", svc.GetEvmLogs), "filter")
+	routes["newevmfilter"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("newevmfilter", svc.NewEvmFilter), "filter")
+	routes["newblockevmfilter"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("newblockevmfilter", svc.NewBlockEvmFilter), "")
+	routes["newpendingtransactionevmfilter"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("newpendingtransactionevmfilter", svc.NewPendingTransactionEvmFilter), "")
+	routes["getevmfilterchanges"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("getevmfilterchanges", svc.GetEvmFilterChanges), "id")
+	routes["evmunsubscribe"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("evmunsubscribe", svc.EvmUnSubscribe), "id")
+	routes["uninstallevmfilter"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("uninstallevmfilter", svc.UninstallEvmFilter), "id")
+	routes["getblockheight"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("getblockheight", svc.GetBlockHeight), "")
+	routes["getevmblockbynumber"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("getevmblockbynumber", svc.GetEvmBlockByNumber), "number,full")
+	routes["getevmblockbyhash"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("getevmblockbyhash", svc.GetEvmBlockByHash), "hash,full")
+	routes["getevmtransactionbyhash"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("getevmtransactionbyhash", svc.GetEvmTransactionByHash), "txHash")
+	routes["evmsubscribe"] = rpcserver.NewWSRPCFunc(client.wrapRPCFunc("evmsubscribe", svc.EvmSubscribe), "method,filter")
+	routes["contractevents"] = rpcserver.NewRPCFunc(client.wrapRPCFunc("contractevents", svc.ContractEvents), "fromBlock,toBlock,contract,topic,origin,txHash,limit,cursor")
+	return routes
+}
+
+// makeQueryServiceHandler returns a http handler mapping to query service, the debug_* methods
+// are only registered if enableDebug is set.
+func MakeEthQueryServiceHandler(
+	svc QueryService, logger log.TMLogger, hub *Hub, limits *config.RPCLimitsConfig, enableDebug bool,
+) http.Handler {
 	wsmux := http.NewServeMux()
 	routesJson := map[string]eth.RPCFunc{}
 	routesJson["eth_blockNumber"] = eth.NewRPCFunc(svc.EthBlockNumber, "")
@@ -160,7 +198,7 @@ func MakeEthQueryServiceHandler(svc QueryService, logger log.TMLogger, hub *Hub)
 	routesJson["eth_getTransactionByHash"] = eth.NewRPCFunc(svc.EthGetTransactionByHash, "hash")
 	routesJson["eth_getCode"] = eth.NewRPCFunc(svc.EthGetCode, "address,block")
 	routesJson["eth_call"] = eth.NewRPCFunc(svc.EthCall, "query,block")
-	routesJson["eth_getLogs"] = eth.NewRPCFunc(svc.EthGetLogs, "filter")
+	routesJson["eth_getLogs"] = eth.NewRPCFunc(svc.EthGetLogs, "ctx,filter")
 	routesJson["eth_getBlockTransactionCountByNumber"] = eth.NewRPCFunc(svc.EthGetBlockTransactionCountByNumber, "block")
 	routesJson["eth_getBlockTransactionCountByHash"] = eth.NewRPCFunc(svc.EthGetBlockTransactionCountByHash, "hash")
 	routesJson["eth_getTransactionByBlockHashAndIndex"] = eth.NewRPCFunc(svc.EthGetTransactionByBlockHashAndIndex, "block,index")
@@ -173,7 +211,7 @@ func MakeEthQueryServiceHandler(svc QueryService, logger log.TMLogger, hub *Hub)
 	routesJson["eth_getFilterLogs"] = eth.NewRPCFunc(svc.EthGetFilterLogs, "id")
 
 	routesJson["eth_newFilter"] = eth.NewRPCFunc(svc.EthNewFilter, "filter")
-	routesJson["eth_subscribe"] = eth.NewWSRPCFunc(svc.EthSubscribe, "conn,method,filter")
+	routesJson["eth_subscribe"] = eth.NewWSRPCFunc(svc.EthSubscribe, "conn,method,params")
 	routesJson["eth_unsubscribe"] = eth.NewRPCFunc(svc.EthUnsubscribe, "id")
 
 	routesJson["eth_accounts"] = eth.NewRPCFunc(svc.EthAccounts, "")
@@ -183,8 +221,13 @@ func MakeEthQueryServiceHandler(svc QueryService, logger log.TMLogger, hub *Hub)
 	routesJson["net_version"] = eth.NewRPCFunc(svc.EthNetVersion, "")
 	routesJson["eth_getTransactionCount"] = eth.NewRPCFunc(svc.EthGetTransactionCount, "local,block")
 
+	if enableDebug {
+		routesJson["debug_traceTransaction"] = eth.NewRPCFunc(svc.DebugTraceTransaction, "ctx,hash,config")
+		routesJson["debug_traceCall"] = eth.NewRPCFunc(svc.DebugTraceCall, "ctx,query,block,config")
+	}
+
 	routesJson["eth_sendRawTransaction"] = eth.NewTendermintRPCFunc("eth_sendRawTransaction")
-	RegisterRPCFuncs(wsmux, routesJson, logger, hub)
+	RegisterRPCFuncs(wsmux, routesJson, logger, hub, limits)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
@@ -200,7 +243,7 @@ func MakeEthQueryServiceHandler(svc QueryService, logger log.TMLogger, hub *Hub)
 }
 
 // MakeUnsafeQueryServiceHandler returns a http handler for unsafe RPC routes
-func MakeUnsafeQueryServiceHandler(logger log.TMLogger) http.Handler {
+func MakeUnsafeQueryServiceHandler(svc QueryService, logger log.TMLogger) http.Handler {
 	codec := amino.NewCodec()
 	mux := http.NewServeMux()
 	routes := map[string]*rpcserver.RPCFunc{}
@@ -213,6 +256,9 @@ func MakeUnsafeQueryServiceHandler(logger log.TMLogger) http.Handler {
 	routes["unsafe_stop_cpu_profiler"] = rpcserver.NewRPCFunc(rpccore.UnsafeStopCPUProfiler, "")
 	routes["unsafe_write_heap_profile"] = rpcserver.NewRPCFunc(rpccore.UnsafeWriteHeapProfile, "filename")
 
+	// app store maintenance API
+	routes["prune_status"] = rpcserver.NewRPCFunc(svc.PruningStatus, "")
+
 	rpcserver.RegisterRPCFuncs(mux, routes, codec, logger)
 	return mux
 }
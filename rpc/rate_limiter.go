@@ -0,0 +1,492 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	rpcserver "github.com/tendermint/tendermint/rpc/lib/server"
+
+	"github.com/diademnetwork/diademchain/config"
+	"github.com/diademnetwork/diademchain/log"
+	"github.com/diademnetwork/diademchain/rpc/eth"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	apiKeyParam  = "apikey"
+
+	// How often buckets that have been refilled to the max are dropped
+	bucketPruneInterval = time.Minute
+
+	// Queries sent to /graphql are treated as calls to this method
+	graphqlMethod = "graphql"
+)
+
+var rejectedRequestCount metrics.Counter
+
+func init() {
+	rejectedRequestCount = kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "diademchain",
+		Subsystem: "rpc",
+		Name:      "rejected_requests",
+		Help:      "Number of requests rejected by the RPC limiter.",
+	}, []string{"reason"})
+}
+
+// tokenBucket holds the request units a client has available, the bucket is refilled at a fixed
+// rate up to the burst size.
+type tokenBucket struct {
+	tokens   float64
+	lastFill time.Time
+	rate     float64
+	burst    float64
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.lastFill).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.lastFill = now
+}
+
+// RPCLimiter rate limits the requests made by each client, and filters out calls to methods that
+// aren't allowed.
+type RPCLimiter struct {
+	cfg            *config.RPCLimitsConfig
+	apiKeys        map[string]bool
+	methodCosts    map[string]int64
+	allowedMethods map[string]bool
+	deniedMethods  map[string]bool
+
+	trustedProxies []*net.IPNet
+
+	mutex     sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// NewRPCLimiter creates a limiter from the given config, method names are case insensitive since
+// the config loader lowercases map keys.
+func NewRPCLimiter(cfg *config.RPCLimitsConfig) *RPCLimiter {
+	l := &RPCLimiter{
+		cfg:            cfg,
+		apiKeys:        map[string]bool{},
+		methodCosts:    map[string]int64{},
+		allowedMethods: map[string]bool{},
+		deniedMethods:  map[string]bool{},
+		buckets:        map[string]*tokenBucket{},
+		now:            time.Now,
+	}
+	for _, key := range cfg.APIKeys {
+		l.apiKeys[key] = true
+	}
+	for method, cost := range cfg.MethodCosts {
+		l.methodCosts[strings.ToLower(method)] = cost
+	}
+	for _, method := range cfg.AllowedMethods {
+		l.allowedMethods[strings.ToLower(method)] = true
+	}
+	for _, method := range cfg.DeniedMethods {
+		l.deniedMethods[strings.ToLower(method)] = true
+	}
+	for _, proxy := range cfg.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			log.Error("Ignoring invalid trusted proxy address", "proxy", proxy, "err", err)
+			continue
+		}
+		l.trustedProxies = append(l.trustedProxies, ipNet)
+	}
+	return l
+}
+
+// Handler returns middleware that rejects requests from clients that exceed their rate limit,
+// or call methods that aren't allowed. The request that opens a websocket connection is rate
+// limited by the middleware, calls made over the connection must be checked by the websocket
+// handler via the rpcClient stored in the request context.
+func (l *RPCLimiter) Handler(next http.Handler) http.Handler {
+	if !l.cfg.Enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodOptions {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		apiKey := req.Header.Get(apiKeyHeader)
+		if apiKey == "" {
+			apiKey = req.URL.Query().Get(apiKeyParam)
+		}
+		if apiKey != "" && !l.apiKeys[apiKey] {
+			rejectRequest(w, http.StatusUnauthorized, "api_key", eth.NewError(
+				eth.EcInvalidRequest, "Invalid API key", "",
+			))
+			return
+		}
+
+		methods, complexity, err := l.requestMethods(w, req)
+		if err != nil {
+			rejectRequest(w, http.StatusBadRequest, "invalid_request", eth.NewErrorf(
+				eth.EcInvalidRequest, "Invalid request", "error reading message body %v", err,
+			))
+			return
+		}
+
+		client := &rpcClient{limiter: l}
+		if apiKey != "" {
+			client.key, client.rate, client.burst = "key:"+apiKey, l.cfg.APIKeyRateLimit, l.cfg.APIKeyBurst
+		} else {
+			client.key, client.rate, client.burst = "ip:"+l.clientIP(req), l.cfg.IPRateLimit, l.cfg.IPBurst
+		}
+		if reason, rpcErr := client.allowCalls(methods, complexity); rpcErr != nil {
+			status := http.StatusTooManyRequests
+			if reason == "method" {
+				status = http.StatusForbidden
+			}
+			rejectRequest(w, status, reason, rpcErr)
+			return
+		}
+
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), contextKeyRPCClient, client)))
+	})
+}
+
+// filterRoutes returns the routes that clients are allowed to call.
+func (l *RPCLimiter) filterRoutes(routes map[string]*rpcserver.RPCFunc) map[string]*rpcserver.RPCFunc {
+	if !l.cfg.Enabled {
+		return routes
+	}
+	allowed := make(map[string]*rpcserver.RPCFunc, len(routes))
+	for method, route := range routes {
+		if l.isMethodAllowed(method) {
+			allowed[method] = route
+		}
+	}
+	return allowed
+}
+
+func (l *RPCLimiter) isMethodAllowed(method string) bool {
+	method = strings.ToLower(method)
+	if l.deniedMethods[method] {
+		return false
+	}
+	return len(l.allowedMethods) == 0 || l.allowedMethods[method]
+}
+
+func (l *RPCLimiter) methodCost(method string) int64 {
+	if cost, ok := l.methodCosts[strings.ToLower(method)]; ok {
+		return cost
+	}
+	return 1
+}
+
+// take removes the given number of units from the client's bucket, returns false if there aren't
+// enough units left in the bucket.
+func (l *RPCLimiter) take(client string, rate float64, burst int64, cost int64) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastPrune) > bucketPruneInterval {
+		// Clients whose buckets are full again don't need to be tracked anymore
+		for k, b := range l.buckets {
+			b.refill(now)
+			if b.tokens >= b.burst {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{
+			tokens:   float64(burst),
+			lastFill: now,
+			rate:     rate,
+			burst:    float64(burst),
+		}
+		l.buckets[client] = b
+	}
+	b.refill(now)
+	if b.tokens < float64(cost) {
+		return false
+	}
+	b.tokens -= float64(cost)
+	return true
+}
+
+// clientIP returns the address of the client that sent the request. The X-Forwarded-For header is
+// only used when the request comes from one of the trusted proxies, in which case the last address
+// in the header is the one the proxy received the request from, any addresses before it were
+// supplied by the client and can't be trusted.
+func (l *RPCLimiter) clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	if !l.isTrustedProxy(host) {
+		return host
+	}
+	values := req.Header[http.CanonicalHeaderKey("X-Forwarded-For")]
+	if len(values) == 0 {
+		return host
+	}
+	addrs := strings.Split(values[len(values)-1], ",")
+	if ip := strings.TrimSpace(addrs[len(addrs)-1]); ip != "" {
+		return ip
+	}
+	return host
+}
+
+func (l *RPCLimiter) isTrustedProxy(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range l.trustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestMethods returns the names of the methods called by a request, and the complexity of the
+// calls, the request body is restored so the next handler can read it again.
+func (l *RPCLimiter) requestMethods(w http.ResponseWriter, req *http.Request) ([]string, int64, error) {
+	if isWebSocketConnection(req) {
+		return nil, 1, nil
+	}
+
+	var body []byte
+	if req.Body != nil {
+		reqBody := req.Body
+		if l.cfg.MaxRequestBytes > 0 {
+			reqBody = http.MaxBytesReader(w, reqBody, l.cfg.MaxRequestBytes)
+		}
+		var err error
+		body, err = ioutil.ReadAll(reqBody)
+		if err != nil {
+			return nil, 0, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	methods, err := messageMethods(body)
+	if err != nil {
+		return nil, 0, err
+	}
+	// URI requests (e.g. /nonce?key=...) & GraphQL queries don't name a method, they're identified
+	// by the endpoint instead
+	if methods == nil {
+		methods = pathMethods(req)
+		if len(methods) == 1 && methods[0] == graphqlMethod {
+			return methods, graphqlComplexity(body), nil
+		}
+	}
+	return methods, 1, nil
+}
+
+// graphqlComplexity estimates the cost of the GraphQL query in a request body by counting the
+// selection sets in the query. Each selection set fetches another object (or list of objects), so
+// the wider & deeper a query is the more it costs.
+func graphqlComplexity(body []byte) int64 {
+	var params struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal(body, &params); err != nil {
+		return 1
+	}
+	complexity := int64(0)
+	inString, inComment := false, false
+	for i := 0; i < len(params.Query); i++ {
+		c := params.Query[i]
+		switch {
+		case inComment:
+			inComment = c != '\n' && c != '\r'
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '#':
+			inComment = true
+		case c == '"':
+			inString = true
+		case c == '{':
+			complexity++
+		}
+	}
+	if complexity == 0 {
+		return 1
+	}
+	return complexity
+}
+
+// messageMethods returns the names of the methods called by a JSON-RPC message, or nil if the
+// message doesn't name a method.
+func messageMethods(body []byte) ([]string, error) {
+	// IDs are ignored since the Tendermint & Ethereum endpoints use different types
+	type methodCall struct {
+		Method string `json:"method"`
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, nil
+	}
+	var calls []methodCall
+	if body[0] == '[' {
+		if err := json.Unmarshal(body, &calls); err != nil {
+			return nil, err
+		}
+	} else {
+		var call methodCall
+		if err := json.Unmarshal(body, &call); err != nil {
+			return nil, err
+		}
+		if call.Method == "" {
+			return nil, nil
+		}
+		calls = append(calls, call)
+	}
+	methods := make([]string, 0, len(calls))
+	for _, call := range calls {
+		methods = append(methods, call.Method)
+	}
+	return methods, nil
+}
+
+func pathMethods(req *http.Request) []string {
+	if method := strings.Trim(req.URL.Path, "/"); method != "" {
+		return []string{method}
+	}
+	return nil
+}
+
+type contextKey string
+
+const contextKeyRPCClient = contextKey("rpc-client")
+
+// rpcClient is a client whose request has been let through by the limiter, calls the client makes
+// over a websocket connection are charged to the same bucket as its HTTP requests.
+type rpcClient struct {
+	limiter *RPCLimiter
+	key     string
+	rate    float64
+	burst   int64
+}
+
+// rpcClientFromContext returns the client stored in the request context by the limiter, or nil if
+// the limiter is disabled.
+func rpcClientFromContext(ctx context.Context) *rpcClient {
+	client, _ := ctx.Value(contextKeyRPCClient).(*rpcClient)
+	return client
+}
+
+// allowCalls checks the client is allowed to call the given methods, and takes the cost of the
+// calls (multiplied by their complexity) from the client's bucket. If the calls are rejected the
+// reason is returned along with the error.
+func (c *rpcClient) allowCalls(methods []string, complexity int64) (string, *eth.Error) {
+	if c == nil {
+		return "", nil
+	}
+	cost := int64(0)
+	for _, method := range methods {
+		if !c.limiter.isMethodAllowed(method) {
+			return "method", eth.NewErrorf(
+				eth.EcMethodNotFound, "Method not allowed", "method %s is not allowed", method,
+			)
+		}
+		cost += c.limiter.methodCost(method) * complexity
+	}
+	if cost == 0 {
+		cost = 1
+	}
+	if !c.limiter.take(c.key, c.rate, c.burst, cost) {
+		return "rate_limit", eth.NewError(eth.EcLimitExceeded, "Rate limit exceeded", "")
+	}
+	return "", nil
+}
+
+// allowMessage checks the client is allowed to make the calls in a JSON-RPC message received over
+// a websocket connection.
+func (c *rpcClient) allowMessage(message []byte) *eth.Error {
+	if c == nil {
+		return nil
+	}
+	methods, err := messageMethods(message)
+	if err != nil {
+		return eth.NewErrorf(eth.EcInvalidRequest, "Invalid request", "error  unmarshalling message body %v", err)
+	}
+	reason, rpcErr := c.allowCalls(methods, 1)
+	if rpcErr != nil {
+		rejectedRequestCount.With("reason", reason).Add(1)
+	}
+	return rpcErr
+}
+
+// wrapRPCFunc wraps a function served by a Tendermint RPC route so that each call to it is checked
+// against the client's limits, f is returned as is if c is nil.
+func (c *rpcClient) wrapRPCFunc(method string, f interface{}) interface{} {
+	if c == nil {
+		return f
+	}
+	fv := reflect.ValueOf(f)
+	ft := fv.Type()
+	return reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
+		if reason, rpcErr := c.allowCalls([]string{method}, 1); rpcErr != nil {
+			rejectedRequestCount.With("reason", reason).Add(1)
+			// RPC functions always return a result and an error
+			results := make([]reflect.Value, ft.NumOut())
+			for i := range results {
+				results[i] = reflect.Zero(ft.Out(i))
+			}
+			errValue := reflect.New(ft.Out(ft.NumOut() - 1)).Elem()
+			errValue.Set(reflect.ValueOf(error(rpcErr)))
+			results[len(results)-1] = errValue
+			return results
+		}
+		if ft.IsVariadic() {
+			return fv.CallSlice(args)
+		}
+		return fv.Call(args)
+	}).Interface()
+}
+
+func rejectRequest(w http.ResponseWriter, status int, reason string, rpcErr *eth.Error) {
+	rejectedRequestCount.With("reason", reason).Add(1)
+	outBytes, err := json.MarshalIndent(eth.JsonRpcErrorResponse{
+		Version: "2.0",
+		Error:   *rpcErr,
+	}, "", "  ")
+	if err != nil {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(outBytes)
+}
@@ -0,0 +1,202 @@
+package rpc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/diademnetwork/diademchain/config"
+)
+
+func TestRPCLimiter(t *testing.T) {
+	cfg := config.DefaultRPCLimitsConfig()
+	cfg.Enabled = true
+	cfg.IPRateLimit = 1
+	cfg.IPBurst = 10
+	cfg.APIKeys = []string{"secret"}
+	cfg.APIKeyRateLimit = 10
+	cfg.APIKeyBurst = 100
+	cfg.DeniedMethods = []string{"debug_traceCall"}
+
+	limiter := NewRPCLimiter(cfg)
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+
+	var body string
+	handler := limiter.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// the body must still be readable after the limiter has inspected it
+		data, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		body = string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(payload string, apiKey string) int {
+		req := httptest.NewRequest("POST", "/eth", strings.NewReader(payload))
+		req.RemoteAddr = "10.0.0.1:1234"
+		if apiKey != "" {
+			req.Header.Set(apiKeyHeader, apiKey)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// eth_getLogs costs 10 units, which uses up the whole burst
+	logsReq := `{"jsonrpc":"2.0","method":"eth_getLogs","params":[{}],"id":1}`
+	require.Equal(t, http.StatusOK, send(logsReq, ""))
+	require.Equal(t, logsReq, body)
+	require.Equal(t, http.StatusTooManyRequests, send(`{"method":"eth_blockNumber","id":2}`, ""))
+
+	// a unit is refilled every second
+	now = now.Add(time.Second)
+	require.Equal(t, http.StatusOK, send(`{"method":"eth_blockNumber","id":3}`, ""))
+	require.Equal(t, http.StatusTooManyRequests, send(`{"method":"eth_blockNumber","id":4}`, ""))
+
+	// clients with an API key have their own bucket
+	batchReq := `[{"method":"eth_getLogs","id":1},{"method":"eth_getLogs","id":2}]`
+	require.Equal(t, http.StatusOK, send(batchReq, "secret"))
+	require.Equal(t, http.StatusUnauthorized, send(batchReq, "guess"))
+
+	// denied methods are rejected even if they're part of a batch
+	now = now.Add(time.Minute)
+	require.Equal(t, http.StatusForbidden, send(`{"method":"debug_tracecall","id":5}`, ""))
+	require.Equal(t, http.StatusForbidden, send(`[{"method":"eth_blockNumber"},{"method":"debug_traceCall"}]`, "secret"))
+
+	// only allowed methods can be called when an allow-list is configured
+	cfg.AllowedMethods = []string{"nonce"}
+	limiter = NewRPCLimiter(cfg)
+	handler = limiter.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	req := httptest.NewRequest("GET", "/nonce?key=0x00", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, http.StatusForbidden, send(`{"method":"eth_blockNumber","id":6}`, ""))
+}
+
+func TestRPCLimiterClientIP(t *testing.T) {
+	cfg := config.DefaultRPCLimitsConfig()
+	cfg.TrustedProxies = []string{"10.0.0.1", "192.168.0.0/16"}
+	limiter := NewRPCLimiter(cfg)
+
+	clientIP := func(remoteAddr string, forwardedFor ...string) string {
+		req := httptest.NewRequest("POST", "/eth", nil)
+		req.RemoteAddr = remoteAddr
+		for _, fwd := range forwardedFor {
+			req.Header.Add("X-Forwarded-For", fwd)
+		}
+		return limiter.clientIP(req)
+	}
+
+	// the header is ignored unless the request comes from a trusted proxy
+	require.Equal(t, "10.0.0.2", clientIP("10.0.0.2:1234", "1.1.1.1"))
+	require.Equal(t, "10.0.0.1", clientIP("10.0.0.1:1234"))
+	// the last address is the one the proxy received the request from, the rest are client supplied
+	require.Equal(t, "2.2.2.2", clientIP("10.0.0.1:1234", "1.1.1.1, 2.2.2.2"))
+	require.Equal(t, "3.3.3.3", clientIP("192.168.1.1:1234", "1.1.1.1, 2.2.2.2", "3.3.3.3"))
+}
+
+func TestRPCLimiterMaxRequestBytes(t *testing.T) {
+	cfg := config.DefaultRPCLimitsConfig()
+	cfg.Enabled = true
+	cfg.MaxRequestBytes = 64
+	handler := NewRPCLimiter(cfg).Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+
+	send := func(payload string) int {
+		req := httptest.NewRequest("POST", "/eth", strings.NewReader(payload))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	require.Equal(t, http.StatusOK, send(`{"method":"eth_blockNumber","id":1}`))
+	require.Equal(t, http.StatusBadRequest, send(`{"method":"eth_blockNumber","params":["`+strings.Repeat("0", 64)+`"],"id":1}`))
+}
+
+func TestRPCLimiterWebsocketCalls(t *testing.T) {
+	cfg := config.DefaultRPCLimitsConfig()
+	cfg.Enabled = true
+	cfg.IPRateLimit = 1
+	cfg.IPBurst = 12
+	cfg.DeniedMethods = []string{"debug_traceCall"}
+	limiter := NewRPCLimiter(cfg)
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+
+	var client *rpcClient
+	handler := limiter.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		client = rpcClientFromContext(req.Context())
+	}))
+	req := httptest.NewRequest("GET", "/queryws", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.NotNil(t, client)
+
+	// calls made over /eth websocket connections are charged to the client that opened the
+	// connection, which has already used up one unit
+	require.Nil(t, client.allowMessage([]byte(`{"method":"eth_getLogs","id":1}`)))
+	require.NotNil(t, client.allowMessage([]byte(`{"method":"debug_traceCall","id":2}`)))
+	require.Nil(t, client.allowMessage([]byte(`{"method":"eth_blockNumber","id":3}`)))
+	require.NotNil(t, client.allowMessage([]byte(`{"method":"eth_blockNumber","id":4}`)))
+
+	// and so are calls made over /queryws connections
+	now = now.Add(time.Minute)
+	nonce := client.wrapRPCFunc("nonce", func(key string) (uint64, error) {
+		return 1, nil
+	}).(func(string) (uint64, error))
+	getLogs := client.wrapRPCFunc("getevmlogs", func(filter string) ([]byte, error) {
+		return []byte(filter), nil
+	}).(func(string) ([]byte, error))
+	logs, err := getLogs("filter")
+	require.NoError(t, err)
+	require.Equal(t, []byte("filter"), logs)
+	n, err := nonce("key")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), n)
+	_, err = nonce("key")
+	require.NoError(t, err)
+	_, err = getLogs("filter")
+	require.Error(t, err)
+
+	// a nil client means the limiter is disabled
+	client = nil
+	require.Nil(t, client.allowMessage([]byte(`{"method":"debug_traceCall","id":5}`)))
+}
+
+func TestRPCLimiterGraphQLComplexity(t *testing.T) {
+	cfg := config.DefaultRPCLimitsConfig()
+	cfg.Enabled = true
+	cfg.IPRateLimit = 1
+	cfg.IPBurst = 60
+	cfg.MethodCosts = map[string]int64{"graphql": 10}
+	limiter := NewRPCLimiter(cfg)
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+	handler := limiter.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+
+	send := func(query string) int {
+		body, err := json.Marshal(map[string]string{"query": query})
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, int64(2), graphqlComplexity([]byte(`{"query":"{ block { number } }"}`)))
+	require.Equal(t, int64(3), graphqlComplexity([]byte(`{"query":"{ block { transactions { hash } } }"}`)))
+	// braces in strings & comments aren't selection sets
+	require.Equal(t, int64(2), graphqlComplexity([]byte(`{"query":"# {\n{ block(hash: \"{\") { number } }"}`)))
+
+	// each selection set costs 10 units
+	require.Equal(t, http.StatusOK, send(`{ block { transactions { logs { data } } } }`))
+	require.Equal(t, http.StatusOK, send(`{ block { number } }`))
+	require.Equal(t, http.StatusTooManyRequests, send(`{ block { number } }`))
+}
@@ -5,6 +5,7 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/diademnetwork/diademchain/config"
 	"github.com/diademnetwork/diademchain/log"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -35,32 +36,47 @@ func init() {
 		"tendermint/PrivKeySecp256k1", nil)
 }
 
-// RPCServer starts up HTTP servers that handle client requests.
+// addTendermintRoutes adds the query service routes that are also served by the Tendermint
+// /websocket and /rpc endpoints to the given routes.
+func addTendermintRoutes(routes map[string]*rpcserver.RPCFunc, qsvc QueryService) {
+	// Add the nonce route to the TM routes so clients can query the nonce from the /websocket
+	// and /rpc endpoints.
+	routes["nonce"] = rpcserver.NewRPCFunc(qsvc.Nonce, "key,account,pending")
+}
+
+// RPCServer starts up HTTP servers that handle client requests, the /graphql endpoint is only
+// served if graphqlHandler isn't nil, and the debug_* methods are only served if enableDebug is set.
 func RPCServer(
 	qsvc QueryService, logger log.TMLogger, bus *QueryEventBus, bindAddr string,
-	enableUnsafeRPC bool, unsafeRPCBindAddress string,
+	enableUnsafeRPC bool, unsafeRPCBindAddress string, limits *config.RPCLimitsConfig,
+	graphqlHandler http.Handler, enableDebug bool,
 ) error {
-	queryHandler := MakeQueryServiceHandler(qsvc, logger, bus)
+	limiter := NewRPCLimiter(limits)
+	queryHandler := limiter.Handler(MakeQueryServiceHandler(qsvc, logger, bus))
 	hub := newHub()
 	go hub.run()
-	ethHandler := MakeEthQueryServiceHandler(qsvc, logger, hub)
+	ethHandler := limiter.Handler(MakeEthQueryServiceHandler(qsvc, logger, hub, limits, enableDebug))
 
-	// Add the nonce route to the TM routes so clients can query the nonce from the /websocket
-	// and /rpc endpoints.
-	rpccore.Routes["nonce"] = rpcserver.NewRPCFunc(qsvc.Nonce, "key,account")
+	addTendermintRoutes(rpccore.Routes, qsvc)
 
-	wm := rpcserver.NewWebsocketManager(rpccore.Routes, cdc, rpcserver.EventSubscriber(bus))
+	// The Tendermint websocket handler doesn't expose the calls made over a connection, so only the
+	// request that opens the connection is rate limited, methods that aren't allowed aren't served.
+	wm := rpcserver.NewWebsocketManager(limiter.filterRoutes(rpccore.Routes), cdc, rpcserver.EventSubscriber(bus))
 	wm.SetLogger(logger)
 	mux := http.NewServeMux()
-	mux.HandleFunc("/websocket", wm.WebsocketHandler)
+	mux.Handle("/websocket", limiter.Handler(http.HandlerFunc(wm.WebsocketHandler)))
 	mux.Handle("/query/", stripPrefix("/query", queryHandler))
 	mux.Handle("/query", stripPrefix("/query", queryHandler)) //backwards compatibility
 	mux.Handle("/queryws", queryHandler)
 	mux.Handle("/eth", ethHandler)
+	if graphqlHandler != nil {
+		mux.Handle("/graphql", limiter.Handler(CORSMethodMiddleware(graphqlHandler)))
+	}
 	rpcmux := http.NewServeMux()
 	rpcserver.RegisterRPCFuncs(rpcmux, rpccore.Routes, cdc, logger)
-	mux.Handle("/rpc/", stripPrefix("/rpc", CORSMethodMiddleware(rpcmux)))
-	mux.Handle("/rpc", stripPrefix("/rpc", CORSMethodMiddleware(rpcmux)))
+	rpcHandler := limiter.Handler(CORSMethodMiddleware(rpcmux))
+	mux.Handle("/rpc/", stripPrefix("/rpc", rpcHandler))
+	mux.Handle("/rpc", stripPrefix("/rpc", rpcHandler))
 
 	listener, err := rpcserver.Listen(
 		bindAddr,
@@ -94,7 +110,7 @@ func RPCServer(
 
 	if enableUnsafeRPC {
 		unsafeLogger := logger.With("interface", "unsafe")
-		unsafeHandler := MakeUnsafeQueryServiceHandler(unsafeLogger)
+		unsafeHandler := MakeUnsafeQueryServiceHandler(qsvc, unsafeLogger)
 		unsafeListener, err := rpcserver.Listen(
 			unsafeRPCBindAddress,
 			rpcserver.Config{MaxOpenConnections: 0},
@@ -0,0 +1,51 @@
+package rpc
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	tmlog "github.com/tendermint/tendermint/libs/log"
+	rpcclient "github.com/tendermint/tendermint/rpc/lib/client"
+	rpcserver "github.com/tendermint/tendermint/rpc/lib/server"
+)
+
+type nonceQueryService struct {
+	MockQueryService
+	pending bool
+}
+
+func (s *nonceQueryService) Nonce(key, account string, pending bool) (uint64, error) {
+	s.pending = pending
+	return 0, nil
+}
+
+func TestTendermintNonceRoute(t *testing.T) {
+	qs := &nonceQueryService{}
+	routes := map[string]*rpcserver.RPCFunc{}
+	addTendermintRoutes(routes, qs)
+	mux := http.NewServeMux()
+	rpcserver.RegisterRPCFuncs(mux, routes, cdc, tmlog.NewNopLogger())
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	account := "default:0xb16a379ec18d4093666f8f38b11a3071c920207d"
+
+	var result uint64
+	rpcClient := rpcclient.NewJSONRPCClient(ts.URL)
+	_, err := rpcClient.Call("nonce", map[string]interface{}{"account": account, "pending": true}, &result)
+	require.NoError(t, err)
+	require.True(t, qs.pending)
+
+	_, err = rpcClient.Call("nonce", map[string]interface{}{"account": account}, &result)
+	require.NoError(t, err)
+	require.False(t, qs.pending)
+
+	resp, err := http.Get(fmt.Sprintf("%s/nonce?account=\"%s\"&pending=true", ts.URL, account))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, qs.pending)
+}
@@ -218,9 +218,14 @@ func (s *TendermintBlockStore) GetBlockByHeight(height *int64) (*ctypes.ResultBl
 		BlockID: blockResult.BlockMeta.BlockID,
 	}
 	header := types.Header{
+		ChainID:         blockResult.Block.Header.ChainID,
+		Height:          blockResult.Block.Header.Height,
+		NumTxs:          blockResult.Block.Header.NumTxs,
 		LastBlockID:     blockResult.Block.Header.LastBlockID,
 		Time:            blockResult.Block.Header.Time,
 		ProposerAddress: blockResult.Block.Header.ProposerAddress,
+		ValidatorsHash:  blockResult.Block.Header.ValidatorsHash,
+		AppHash:         blockResult.Block.Header.AppHash,
 	}
 	block := types.Block{
 		Header: header,
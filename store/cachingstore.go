@@ -1,9 +1,10 @@
 package store
 
 import (
-	"errors"
+	"encoding/binary"
 	"fmt"
 	"math"
+	"sync"
 	"time"
 
 	"github.com/allegro/bigcache"
@@ -11,6 +12,8 @@ import (
 	"github.com/go-kit/kit/metrics"
 
 	diadem "github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/go-diadem/plugin"
+	"github.com/pkg/errors"
 
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
@@ -121,12 +124,22 @@ func init() {
 }
 
 // CachingStore wraps a write-through cache around a VersionedKVStore.
-// NOTE: Writes update the cache, reads do not, to read from the cache use the store returned by
-//       ReadOnly().
+// NOTE: Writes update the cache, reads do not, to read from the cache use a snapshot obtained via
+// GetSnapshot().
+//
+// Each cache entry is tagged with the store version the value was written at, and snapshots only
+// use the entries that were written at or before the version they were created at, so snapshots
+// never see uncommitted writes, or writes committed after the snapshot was created.
 type CachingStore struct {
 	VersionedKVStore
 	cache  *bigcache.BigCache
 	logger *diadem.Logger
+	// Guards version & pendingDeletes, all writes hold the write lock, so readers can be certain
+	// the cache & the underlying store won't change while they hold the read lock.
+	mutex   sync.RWMutex
+	version int64 // last saved version
+	// Keys that have been deleted since the last saved version.
+	pendingDeletes map[string]struct{}
 }
 
 func DefaultCachingStoreConfig() *CachingStoreConfig {
@@ -192,6 +205,8 @@ func NewCachingStore(source VersionedKVStore, config *CachingStoreConfig) (*Cach
 		VersionedKVStore: source,
 		cache:            cache,
 		logger:           cacheLogger,
+		version:          source.Version(),
+		pendingDeletes:   map[string]struct{}{},
 	}, nil
 }
 
@@ -202,12 +217,16 @@ func (c *CachingStore) Delete(key []byte) {
 		deleteDuration.With("error", fmt.Sprint(err != nil)).Observe(float64(time.Since(begin).Nanoseconds()) / math.Pow10(6))
 	}(time.Now())
 
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	err = c.cache.Delete(string(key))
-	if err != nil {
+	if err != nil && err != bigcache.ErrEntryNotFound {
 		// Only log error and dont error out
 		cacheErrors.With("cache_operation", "delete").Add(1)
 		c.logger.Error(fmt.Sprintf("[CachingStore] error while deleting key: %s in cache, error: %v", string(key), err.Error()))
 	}
+	c.pendingDeletes[string(key)] = struct{}{}
 	c.VersionedKVStore.Delete(key)
 }
 
@@ -218,121 +237,218 @@ func (c *CachingStore) Set(key, val []byte) {
 		setDuration.With("error", fmt.Sprint(err != nil)).Observe(float64(time.Since(begin).Nanoseconds()) / math.Pow10(6))
 	}(time.Now())
 
-	err = c.cache.Set(string(key), val)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// the value will only become visible to snapshots once the next version is saved
+	err = c.cache.Set(string(key), encodeCacheEntry(c.version+1, val))
 	if err != nil {
 		// Only log error and dont error out
 		cacheErrors.With("cache_operation", "set").Add(1)
 		c.logger.Error(fmt.Sprintf("[CachingStore] error while setting key: %s in cache, error: %v", string(key), err.Error()))
 	}
+	delete(c.pendingDeletes, string(key))
 	c.VersionedKVStore.Set(key, val)
 }
 
-// ReadOnlyCachingStore prevents any modification to the underlying backing store,
-// and uses the cache for reads.
-type ReadOnlyCachingStore struct {
-	*CachingStore
-}
+func (c *CachingStore) SaveVersion() ([]byte, int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-func NewReadOnlyCachingStore(cachingStore *CachingStore) *ReadOnlyCachingStore {
-	return &ReadOnlyCachingStore{
-		CachingStore: cachingStore,
+	hash, version, err := c.VersionedKVStore.SaveVersion()
+	if err != nil {
+		return nil, 0, err
 	}
+	c.version = version
+	c.pendingDeletes = map[string]struct{}{}
+	return hash, version, nil
 }
 
-func (c *ReadOnlyCachingStore) Delete(key []byte) {
-	panic("[ReadOnlyCachingStore] Delete() not implemented")
-}
+func (c *CachingStore) Prune() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-func (c *ReadOnlyCachingStore) Set(key, val []byte) {
-	panic("[ReadOnlyCachingStore] Set() not implemented")
+	return c.VersionedKVStore.Prune()
 }
 
-func (c *ReadOnlyCachingStore) Has(key []byte) bool {
-	var err error
+// GetSnapshot returns a read-only snapshot of the last saved version of the store, the snapshot
+// will read from the cache whenever possible. If the last saved version can't be loaded from the
+// underlying store the snapshot will fall back to the underlying store's own snapshot, use
+// GetSnapshotAt to get the error instead.
+func (c *CachingStore) GetSnapshot() Snapshot {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 
-	defer func(begin time.Time) {
-		hasDuration.With("error", fmt.Sprint(err != nil), "isCacheHit", fmt.Sprint(err == nil)).Observe(float64(time.Since(begin).Nanoseconds()) / math.Pow10(6))
-	}(time.Now())
+	snap, err := c.lastSavedSnapshot()
+	if err != nil {
+		c.logger.Error(fmt.Sprintf("[CachingStore] error while creating snapshot of last saved version, error: %v", err))
+		snap = c.VersionedKVStore.GetSnapshot()
+	}
+	return &cachingStoreSnapshot{
+		store:    c,
+		snapshot: snap,
+		version:  c.version,
+	}
+}
 
-	data, err := c.cache.Get(string(key))
-	exists := true
+// lastSavedSnapshot returns a snapshot of the last saved version of the underlying store.
+func (c *CachingStore) lastSavedSnapshot() (Snapshot, error) {
+	if hs, ok := c.VersionedKVStore.(HistoricalStore); ok && c.version > 0 {
+		snap, err := hs.GetSnapshotAt(c.version)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[CachingStore] failed to create snapshot at version %d", c.version)
+		}
+		return snap, nil
+	}
+	return c.VersionedKVStore.GetSnapshot(), nil
+}
 
-	if err != nil {
-		cacheMisses.With("store_operation", "has").Add(1)
-		switch err {
-		case bigcache.ErrEntryNotFound:
-			break
-		default:
-			// Since, there is no provision of passing error in the interface
-			// we would directly access source and only log the error
-			cacheErrors.With("cache_operation", "get").Add(1)
-			c.logger.Error(fmt.Sprintf("[ReadOnlyCachingStore] error while getting key: %s from cache, error: %v", string(key), err.Error()))
+// cacheSnapshotValue adds a value loaded from a snapshot to the cache, as long as the snapshot is
+// of the last saved version, and the key hasn't been modified since then.
+func (c *CachingStore) cacheSnapshotValue(key, val []byte, version int64) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if version != c.version {
+		return
+	}
+	if _, deleted := c.pendingDeletes[string(key)]; deleted {
+		return
+	}
+	if data, err := c.cache.Get(string(key)); err == nil {
+		if entryVersion, _, err := decodeCacheEntry(data); err == nil && entryVersion > version {
+			return
 		}
+	}
+	if err := c.cache.Set(string(key), encodeCacheEntry(version, val)); err != nil {
+		cacheErrors.With("cache_operation", "set").Add(1)
+		c.logger.Error(fmt.Sprintf("[CachingStore] error while setting key: %s in cache, error: %v", string(key), err.Error()))
+	}
+}
 
-		data = c.VersionedKVStore.Get(key)
-		if data == nil {
-			exists = false
-		} else {
-			exists = true
-			setErr := c.cache.Set(string(key), data)
-			if setErr != nil {
-				cacheErrors.With("cache_operation", "set").Add(1)
-				c.logger.Error(fmt.Sprintf("[ReadOnlyCachingStore] error while setting key: %s in cache, error: %v", string(key), setErr.Error()))
-			}
+// GetWithProof bypasses the cache and returns the value of the given key, along with a Merkle
+// proof, from the underlying store (if the underlying store supports proofs).
+func (c *CachingStore) GetWithProof(key []byte) (*ValueProof, error) {
+	if ps, ok := c.VersionedKVStore.(ProvableStore); ok {
+		return ps.GetWithProof(key)
+	}
+	return nil, fmt.Errorf("[CachingStore] underlying store doesn't support proofs")
+}
+
+// GetSnapshotAt returns a read-only snapshot of the given version of the store. Snapshots of the
+// last saved version will read from the cache whenever possible, while snapshots of previous
+// versions bypass the cache, which only contains the latest state, and are loaded from the
+// underlying store (if the underlying store supports it).
+func (c *CachingStore) GetSnapshotAt(version int64) (Snapshot, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if version == c.version {
+		snap, err := c.lastSavedSnapshot()
+		if err != nil {
+			return nil, err
 		}
-	} else {
-		cacheHits.With("store_operation", "has").Add(1)
+		return &cachingStoreSnapshot{
+			store:    c,
+			snapshot: snap,
+			version:  c.version,
+		}, nil
 	}
 
-	return exists
+	if hs, ok := c.VersionedKVStore.(HistoricalStore); ok {
+		return hs.GetSnapshotAt(version)
+	}
+	return nil, fmt.Errorf("[CachingStore] underlying store doesn't support historical state")
 }
 
-func (c *ReadOnlyCachingStore) Get(key []byte) []byte {
-	var err error
+// PruningStatus returns the pruning progress of the underlying store (if the underlying store
+// prunes old versions in the background).
+func (c *CachingStore) PruningStatus() (*PruningStatus, error) {
+	if pr, ok := c.VersionedKVStore.(PruningStatusReporter); ok {
+		return pr.PruningStatus()
+	}
+	return nil, fmt.Errorf("[CachingStore] underlying store doesn't support background pruning")
+}
 
-	defer func(begin time.Time) {
-		getDuration.With("error", fmt.Sprint(err != nil), "isCacheHit", fmt.Sprint(err == nil)).Observe(float64(time.Since(begin).Nanoseconds()) / math.Pow10(6))
-	}(time.Now())
+// Cache entries consist of the version the value was written at, followed by the value itself.
+func encodeCacheEntry(version int64, val []byte) []byte {
+	buf := make([]byte, 8+len(val))
+	binary.BigEndian.PutUint64(buf, uint64(version))
+	copy(buf[8:], val)
+	return buf
+}
 
-	data, err := c.cache.Get(string(key))
+func decodeCacheEntry(data []byte) (int64, []byte, error) {
+	if len(data) < 8 {
+		return 0, nil, fmt.Errorf("[CachingStore] invalid cache entry")
+	}
+	return int64(binary.BigEndian.Uint64(data)), data[8:], nil
+}
 
-	if err != nil {
-		cacheMisses.With("store_operation", "get").Add(1)
-		switch err {
-		case bigcache.ErrEntryNotFound:
-			break
-		default:
-			// Since, there is no provision of passing error in the interface
-			// we would directly access source and only log the error
-			cacheErrors.With("cache_operation", "get").Add(1)
-			c.logger.Error(fmt.Sprintf("[ReadOnlyCachingStore] error while getting key: %s from cache, error: %v", string(key), err.Error()))
+// cachingStoreSnapshot is a read-only snapshot of a specific version of a CachingStore, reads
+// go through the cache first, and fall back to a snapshot of the underlying store.
+type cachingStoreSnapshot struct {
+	store    *CachingStore
+	snapshot Snapshot
+	version  int64
+}
+
+func (s *cachingStoreSnapshot) Get(key []byte) []byte {
+	val, _ := s.get(key, "get")
+	return val
+}
+
+func (s *cachingStoreSnapshot) Has(key []byte) bool {
+	val, _ := s.get(key, "has")
+	return val != nil
+}
+
+func (s *cachingStoreSnapshot) get(key []byte, op string) (val []byte, err error) {
+	defer func(begin time.Time) {
+		elapsed := float64(time.Since(begin).Nanoseconds()) / math.Pow10(6)
+		lvs := []string{"error", fmt.Sprint(err != nil), "isCacheHit", fmt.Sprint(err == nil)}
+		if op == "has" {
+			hasDuration.With(lvs...).Observe(elapsed)
+		} else {
+			getDuration.With(lvs...).Observe(elapsed)
 		}
+	}(time.Now())
 
-		data = c.VersionedKVStore.Get(key)
-		if data == nil {
-			return nil
+	var data []byte
+	data, err = s.store.cache.Get(string(key))
+	if err == nil {
+		var entryVersion int64
+		entryVersion, val, err = decodeCacheEntry(data)
+		if err == nil && entryVersion <= s.version {
+			cacheHits.With("store_operation", op).Add(1)
+			return val, nil
 		}
-		setErr := c.cache.Set(string(key), data)
-		if setErr != nil {
-			cacheErrors.With("cache_operation", "set").Add(1)
-			c.logger.Error(fmt.Sprintf("[ReadOnlyCachingStore] error while setting key: %s in cache, error: %v", string(key), setErr.Error()))
+		if err == nil {
+			// the cached value was written after the snapshot was created
+			err = bigcache.ErrEntryNotFound
 		}
-	} else {
-		cacheHits.With("store_operation", "get").Add(1)
 	}
 
-	return data
-}
+	cacheMisses.With("store_operation", op).Add(1)
+	if err != bigcache.ErrEntryNotFound {
+		// Since, there is no provision of passing error in the interface
+		// we would directly access source and only log the error
+		cacheErrors.With("cache_operation", "get").Add(1)
+		s.store.logger.Error(fmt.Sprintf("[CachingStore] error while getting key: %s from cache, error: %v", string(key), err.Error()))
+	}
 
-func (c *ReadOnlyCachingStore) SaveVersion() ([]byte, int64, error) {
-	return nil, 0, errors.New("[ReadOnlyCachingStore] SaveVersion() not implemented")
+	val = s.snapshot.Get(key)
+	if val != nil {
+		s.store.cacheSnapshotValue(key, val, s.version)
+	}
+	return val, err
 }
 
-func (c *ReadOnlyCachingStore) Prune() error {
-	return errors.New("[ReadOnlyCachingStore] Prune() not implemented")
+// Range bypasses the cache, since the cache can't be efficiently searched by prefix.
+func (s *cachingStoreSnapshot) Range(prefix []byte) plugin.RangeData {
+	return s.snapshot.Range(prefix)
 }
 
-// Implements Snapshot interface
-func (c *ReadOnlyCachingStore) Release() {
-	// noop
+func (s *cachingStoreSnapshot) Release() {
+	s.snapshot.Release()
 }
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -8,6 +9,7 @@ import (
 	"github.com/allegro/bigcache"
 	"github.com/diademnetwork/go-diadem/plugin"
 	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tendermint/libs/db"
 )
 
 type MockStore struct {
@@ -94,18 +96,21 @@ func TestCachingStore(t *testing.T) {
 	cachingStore.Set([]byte("key1"), []byte("value3"))
 	storedValue := mockStore.Get([]byte("key1"))
 	assert.Equal(t, "value3", string(storedValue), "cachingstore need to set correct value to backing store")
-	cachedValue, err = cachingStore.cache.Get("key1")
+	cacheEntry, err := cachingStore.cache.Get("key1")
+	require.Nil(t, err)
+	entryVersion, cachedValue, err := decodeCacheEntry(cacheEntry)
 	require.Nil(t, err)
 	assert.Equal(t, "value3", string(cachedValue), "cachingStore need to set correct value in the cache")
+	assert.Equal(t, int64(1), entryVersion, "cachingStore need to tag the cached value with the next version")
 
 	cachingStore.Delete([]byte("key1"))
 	storedValue = mockStore.Get([]byte("key1"))
 	assert.Equal(t, true, storedValue == nil, "cachingStore need to delete value from underlying storage")
-	cachedValue, err = cachingStore.cache.Get("key1")
+	_, err = cachingStore.cache.Get("key1")
 	require.EqualError(t, err, bigcache.ErrEntryNotFound.Error())
 }
 
-func TestReadOnlyCachingStore(t *testing.T) {
+func TestCachingStoreSnapshotReadsFromCache(t *testing.T) {
 	defaultConfig := DefaultCachingStoreConfig()
 	defaultConfig.CachingEnabled = true
 
@@ -114,21 +119,104 @@ func TestReadOnlyCachingStore(t *testing.T) {
 	cachingStore, err := NewCachingStore(mockStore, defaultConfig)
 	require.NoError(t, err)
 
-	readOnlyCachingStore := NewReadOnlyCachingStore(cachingStore)
+	snapshot := cachingStore.GetSnapshot()
+	defer snapshot.Release()
 
 	mockStore.Set([]byte("key1"), []byte("value1"))
-	cachedValue := readOnlyCachingStore.Get([]byte("key1"))
-	assert.Equal(t, "value1", string(cachedValue), "readOnlyCachingStore need to fetch key correctly from backing store")
+	cachedValue := snapshot.Get([]byte("key1"))
+	assert.Equal(t, "value1", string(cachedValue), "snapshot need to fetch key correctly from backing store")
 
 	mockStore.Set([]byte("key1"), []byte("value2"))
-	cachedValue = readOnlyCachingStore.Get([]byte("key1"))
-	assert.Equal(t, "value1", string(cachedValue), "readOnlyCachingStore need to fetch key from cache and not backing store")
+	cachedValue = snapshot.Get([]byte("key1"))
+	assert.Equal(t, "value1", string(cachedValue), "snapshot need to fetch key from cache and not backing store")
+}
+
+func TestCachingStoreSnapshotVersions(t *testing.T) {
+	defaultConfig := DefaultCachingStoreConfig()
+	defaultConfig.CachingEnabled = true
+
+	iavlStore, err := NewIAVLStore(dbm.NewMemDB(), 0, 0)
+	require.NoError(t, err)
+	cachingStore, err := NewCachingStore(iavlStore, defaultConfig)
+	require.NoError(t, err)
+
+	cachingStore.Set(key1, val1)
+	cachingStore.Set(key2, val2)
+	_, _, err = cachingStore.SaveVersion()
+	require.NoError(t, err)
+
+	snap1 := cachingStore.GetSnapshot()
+	defer snap1.Release()
+	require.Equal(t, val1, snap1.Get(key1))
+
+	// uncommitted writes shouldn't be visible to any snapshots
+	cachingStore.Set(key1, val3)
+	cachingStore.Set(key3, val3)
+	cachingStore.Delete(key2)
+	snap1b := cachingStore.GetSnapshot()
+	defer snap1b.Release()
+	for _, snap := range []Snapshot{snap1, snap1b} {
+		require.Equal(t, val1, snap.Get(key1))
+		require.Equal(t, val2, snap.Get(key2))
+		require.False(t, snap.Has(key3))
+	}
+
+	_, _, err = cachingStore.SaveVersion()
+	require.NoError(t, err)
+
+	snap2 := cachingStore.GetSnapshot()
+	defer snap2.Release()
+	require.Equal(t, val3, snap2.Get(key1))
+	require.Nil(t, snap2.Get(key2))
+	require.True(t, snap2.Has(key3))
+
+	// snapshots of the previous version shouldn't see the newly committed writes either
+	require.Equal(t, val1, snap1.Get(key1))
+	require.Equal(t, val2, snap1.Get(key2))
+	require.False(t, snap1.Has(key3))
+
+	// reading a key from a snapshot after it has been deleted shouldn't bring it back into the cache
+	cachingStore.Delete(key1)
+	require.Equal(t, val3, snap2.Get(key1))
+	_, _, err = cachingStore.SaveVersion()
+	require.NoError(t, err)
+	snap3 := cachingStore.GetSnapshot()
+	defer snap3.Release()
+	require.Nil(t, snap3.Get(key1))
+	require.Equal(t, val3, snap2.Get(key1))
+}
+
+// brokenHistoricalStore can't load any of its saved versions.
+type brokenHistoricalStore struct {
+	*MockStore
+	version int64
+}
+
+func (s *brokenHistoricalStore) SaveVersion() ([]byte, int64, error) {
+	s.version++
+	return nil, s.version, nil
+}
+
+func (s *brokenHistoricalStore) GetSnapshotAt(version int64) (Snapshot, error) {
+	return nil, fmt.Errorf("version %d not found", version)
+}
+
+func TestCachingStoreSnapshotErrors(t *testing.T) {
+	defaultConfig := DefaultCachingStoreConfig()
+	defaultConfig.CachingEnabled = true
+
+	cachingStore, err := NewCachingStore(&brokenHistoricalStore{MockStore: NewMockStore()}, defaultConfig)
+	require.NoError(t, err)
+	cachingStore.Set(key1, val1)
+	_, version, err := cachingStore.SaveVersion()
+	require.NoError(t, err)
 
-	require.Panics(t, func() {
-		readOnlyCachingStore.Set([]byte("key1"), []byte("value1"))
-	})
+	_, err = cachingStore.GetSnapshotAt(version)
+	require.Error(t, err)
 
-	require.Panics(t, func() {
-		readOnlyCachingStore.Delete([]byte("key1"))
-	})
+	// falls back to the snapshot of the underlying store instead of panicking
+	var snap Snapshot
+	require.NotPanics(t, func() { snap = cachingStore.GetSnapshot() })
+	defer snap.Release()
+	require.Equal(t, val1, snap.Get(key1))
 }
@@ -12,6 +12,9 @@ type AppStoreConfig struct {
 	PruneInterval int64
 	// Number of versions to prune at a time.
 	PruneBatchSize int64
+	// If the average time it takes to commit a block exceeds this number of milliseconds the
+	// background pruning will slow down. If zero the background pruning won't be throttled.
+	PruneTargetCommitLatency int64
 	// DB backend to use for storing a materialized view of the latest persistent app state
 	// possible values are "none" | "goleveldb". Defaults to "none", which means the
 	// values are stored in app.db
@@ -28,16 +31,17 @@ type AppStoreConfig struct {
 
 func DefaultConfig() *AppStoreConfig {
 	return &AppStoreConfig{
-		Version:              1,
-		CompactOnLoad:        false,
-		MaxVersions:          0,
-		PruneInterval:        0,
-		PruneBatchSize:       50,
-		LatestStateDBBackend: "goleveldb",
-		LatestStateDBName:    "app_state",
-		NodeDBVersion:        NodeDBV1,
-		NodeCacheSize:        10000,
-		SnapshotVersion:      MultiReaderIAVLStoreSnapshotV1,
+		Version:                  1,
+		CompactOnLoad:            false,
+		MaxVersions:              0,
+		PruneInterval:            0,
+		PruneBatchSize:           50,
+		PruneTargetCommitLatency: 0,
+		LatestStateDBBackend:     "goleveldb",
+		LatestStateDBName:        "app_state",
+		NodeDBVersion:            NodeDBV1,
+		NodeCacheSize:            10000,
+		SnapshotVersion:          MultiReaderIAVLStoreSnapshotV1,
 	}
 }
 
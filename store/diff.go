@@ -0,0 +1,197 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pkg/errors"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+// StateDiffEntry describes a key whose value differs between two versions of the app state.
+// OldValue is nil if the key was added, NewValue is nil if the key was deleted.
+type StateDiffEntry struct {
+	Key      []byte
+	OldValue []byte
+	NewValue []byte
+}
+
+// StateDiffSource identifies a specific version of the IAVL tree stored in an app store.
+type StateDiffSource struct {
+	nodeDB   dbm.DB
+	getValue func(key []byte) []byte
+	version  int64
+}
+
+// NewStateDiffSource returns the tree at the given version in the given store, the store must be
+// an IAVLStore or a MultiReaderIAVLStore.
+func NewStateDiffSource(s VersionedKVStore, version int64) (*StateDiffSource, error) {
+	switch st := s.(type) {
+	case *IAVLStore:
+		if !st.tree.VersionExists(version) {
+			return nil, fmt.Errorf("tree version %d doesn't exist", version)
+		}
+		return &StateDiffSource{nodeDB: st.nodeDB, version: version}, nil
+	case *MultiReaderIAVLStore:
+		// valueDB only contains the values of the latest saved tree
+		if version != st.Version() {
+			return nil, fmt.Errorf(
+				"only the latest version (%d) of MultiReaderIAVLStore can be compared", st.Version(),
+			)
+		}
+		return &StateDiffSource{nodeDB: st.nodeDB, getValue: st.getValue, version: version}, nil
+	default:
+		return nil, fmt.Errorf("can't compare state stored in %T", s)
+	}
+}
+
+// DiffStates walks the IAVL trees of two versions of the app state and calls fn for every key
+// that was added, deleted, or changed between the old & new versions, in key order.
+// Sub-trees that are identical in both versions are skipped, so comparing two closely related
+// versions only requires loading the tree nodes that actually differ.
+func DiffStates(oldSrc, newSrc *StateDiffSource, fn func(entry StateDiffEntry) error) error {
+	oldCur, err := newStateDiffCursor(oldSrc)
+	if err != nil {
+		return errors.Wrap(err, "failed to load old tree")
+	}
+	newCur, err := newStateDiffCursor(newSrc)
+	if err != nil {
+		return errors.Wrap(err, "failed to load new tree")
+	}
+
+	for {
+		oldTop, newTop := oldCur.peek(), newCur.peek()
+		if oldTop == nil && newTop == nil {
+			return nil
+		}
+
+		if oldTop != nil && newTop != nil {
+			if bytes.Equal(oldTop.hash, newTop.hash) {
+				oldCur.pop()
+				newCur.pop()
+				continue
+			}
+			// keep expanding the taller sub-tree until both cursors point at leaf nodes, or at
+			// identical sub-trees
+			if !oldTop.node.isLeaf() || !newTop.node.isLeaf() {
+				if oldTop.node.height >= newTop.node.height {
+					err = oldCur.expand()
+				} else {
+					err = newCur.expand()
+				}
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		// one of the trees has run out of keys, or both cursors point at leaf nodes
+		if oldTop != nil && !oldTop.node.isLeaf() {
+			if err := oldCur.expand(); err != nil {
+				return err
+			}
+			continue
+		}
+		if newTop != nil && !newTop.node.isLeaf() {
+			if err := newCur.expand(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var entry StateDiffEntry
+		cmp := 0
+		if oldTop == nil {
+			cmp = 1
+		} else if newTop == nil {
+			cmp = -1
+		} else {
+			cmp = bytes.Compare(oldTop.node.key, newTop.node.key)
+		}
+		switch {
+		case cmp < 0:
+			entry = StateDiffEntry{Key: oldTop.node.key, OldValue: oldTop.node.value}
+			oldCur.pop()
+		case cmp > 0:
+			entry = StateDiffEntry{Key: newTop.node.key, NewValue: newTop.node.value}
+			newCur.pop()
+		default:
+			oldCur.pop()
+			newCur.pop()
+			// the leaf hash also depends on the version the key was last written at, so the
+			// value may be the same even though the hashes differ
+			if bytes.Equal(oldTop.node.value, newTop.node.value) {
+				continue
+			}
+			entry = StateDiffEntry{
+				Key:      newTop.node.key,
+				OldValue: oldTop.node.value,
+				NewValue: newTop.node.value,
+			}
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+type stateDiffNode struct {
+	hash []byte
+	node *iavlNode
+}
+
+// stateDiffCursor walks the nodes of an IAVL tree in key order, the top of the stack is always
+// the next sub-tree that should be visited.
+type stateDiffCursor struct {
+	src   *StateDiffSource
+	stack []*stateDiffNode
+}
+
+func newStateDiffCursor(src *StateDiffSource) (*stateDiffCursor, error) {
+	rootHash := src.nodeDB.Get(iavlRootKey(src.version))
+	if rootHash == nil {
+		return nil, fmt.Errorf("tree version %d doesn't exist", src.version)
+	}
+	c := &stateDiffCursor{src: src}
+	if len(rootHash) > 0 { // empty tree
+		if err := c.push(rootHash); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *stateDiffCursor) peek() *stateDiffNode {
+	if len(c.stack) == 0 {
+		return nil
+	}
+	return c.stack[len(c.stack)-1]
+}
+
+func (c *stateDiffCursor) pop() {
+	c.stack = c.stack[:len(c.stack)-1]
+}
+
+func (c *stateDiffCursor) push(hash []byte) error {
+	buf := c.src.nodeDB.Get(iavlNodeKey(hash))
+	if buf == nil {
+		return fmt.Errorf("node %X not found", hash)
+	}
+	node, err := decodeIAVLNode(buf, c.src.getValue)
+	if err != nil {
+		return errors.Wrapf(err, "failed to decode node %X", hash)
+	}
+	c.stack = append(c.stack, &stateDiffNode{hash: hash, node: node})
+	return nil
+}
+
+// expand replaces the inner node at the top of the stack with its children.
+func (c *stateDiffCursor) expand() error {
+	top := c.peek()
+	c.pop()
+	if err := c.push(top.node.rightHash); err != nil {
+		return err
+	}
+	return c.push(top.node.leftHash)
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+func collectStateDiff(t *testing.T, oldSrc, newSrc *StateDiffSource) []StateDiffEntry {
+	var entries []StateDiffEntry
+	require.NoError(t, DiffStates(oldSrc, newSrc, func(entry StateDiffEntry) error {
+		entries = append(entries, entry)
+		return nil
+	}))
+	return entries
+}
+
+func TestDiffStatesBetweenVersions(t *testing.T) {
+	s, err := NewIAVLStore(dbm.NewMemDB(), 0, 0)
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		s.Set([]byte(fmt.Sprintf("key%03d", i)), []byte(fmt.Sprintf("val%d", i)))
+	}
+	_, _, err = s.SaveVersion()
+	require.NoError(t, err)
+
+	s.Set([]byte("key010"), []byte("changed"))
+	s.Set([]byte("key050"), []byte("val50")) // same value, different version
+	s.Delete([]byte("key020"))
+	s.Set([]byte("key1000"), []byte("added"))
+	_, _, err = s.SaveVersion()
+	require.NoError(t, err)
+
+	v1, err := NewStateDiffSource(s, 1)
+	require.NoError(t, err)
+	v2, err := NewStateDiffSource(s, 2)
+	require.NoError(t, err)
+
+	require.Equal(t, []StateDiffEntry{
+		{Key: []byte("key010"), OldValue: []byte("val10"), NewValue: []byte("changed")},
+		{Key: []byte("key020"), OldValue: []byte("val20")},
+		{Key: []byte("key1000"), NewValue: []byte("added")},
+	}, collectStateDiff(t, v1, v2))
+
+	// reversing the order of the versions should flip added & deleted keys
+	require.Equal(t, []StateDiffEntry{
+		{Key: []byte("key010"), OldValue: []byte("changed"), NewValue: []byte("val10")},
+		{Key: []byte("key020"), NewValue: []byte("val20")},
+		{Key: []byte("key1000"), OldValue: []byte("added")},
+	}, collectStateDiff(t, v2, v1))
+
+	require.Empty(t, collectStateDiff(t, v2, v2))
+
+	_, err = NewStateDiffSource(s, 3)
+	require.Error(t, err)
+}
+
+func TestDiffStatesBetweenDBs(t *testing.T) {
+	s1, err := NewIAVLStore(dbm.NewMemDB(), 0, 0)
+	require.NoError(t, err)
+	s2, err := NewIAVLStore(dbm.NewMemDB(), 0, 0)
+	require.NoError(t, err)
+
+	// empty trees
+	_, _, err = s1.SaveVersion()
+	require.NoError(t, err)
+	_, _, err = s2.SaveVersion()
+	require.NoError(t, err)
+
+	src1, err := NewStateDiffSource(s1, 1)
+	require.NoError(t, err)
+	src2, err := NewStateDiffSource(s2, 1)
+	require.NoError(t, err)
+	require.Empty(t, collectStateDiff(t, src1, src2))
+
+	for _, s := range []*IAVLStore{s1, s2} {
+		s.Set(key1, val1)
+		s.Set(key2, val2)
+	}
+	s2.Set(key3, val3)
+	_, _, err = s1.SaveVersion()
+	require.NoError(t, err)
+	_, _, err = s2.SaveVersion()
+	require.NoError(t, err)
+
+	src1, err = NewStateDiffSource(s1, 2)
+	require.NoError(t, err)
+	src2, err = NewStateDiffSource(s2, 2)
+	require.NoError(t, err)
+	require.Equal(t, []StateDiffEntry{
+		{Key: key3, NewValue: val3},
+	}, collectStateDiff(t, src1, src2))
+}
@@ -1,13 +1,19 @@
 package store
 
 import (
+	"bytes"
 	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/gogo/protobuf/proto"
 	diadem "github.com/diademnetwork/go-diadem"
 	"github.com/diademnetwork/go-diadem/plugin/types"
 	"github.com/diademnetwork/go-diadem/util"
+	"github.com/pkg/errors"
 	dbm "github.com/tendermint/tendermint/libs/db"
 )
 
@@ -17,12 +23,57 @@ const (
 	contractIDBlockHeightKeyPrefix byte = 3
 	pluginNameTopicKeyPrefix       byte = 4
 	lastContractIDKeyPrefix             = 5
+	txHashKeyPrefix                byte = 6
+	indexStartKeyPrefix            byte = 7
 )
 
 type EventFilter struct {
 	FromBlock uint64
 	ToBlock   uint64
 	Contract  string
+	// Only match events that have this topic.
+	Topic string
+	// Only match events whose caller has this address.
+	Origin string
+	// Only match events emitted by the tx with this hash.
+	TxHash []byte
+	// Max number of events to return, zero means no limit.
+	Limit uint64
+	// Position of the first event to return, used to fetch the next page of events.
+	Cursor *EventCursor
+}
+
+// EventCursor identifies the position of an event in the event store.
+type EventCursor struct {
+	BlockHeight uint64
+	EventIndex  uint16
+}
+
+func (c *EventCursor) String() string {
+	return fmt.Sprintf("%d.%d", c.BlockHeight, c.EventIndex)
+}
+
+// ParseEventCursor parses a cursor previously returned by EventCursor.String().
+func ParseEventCursor(s string) (*EventCursor, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid event cursor %s", s)
+	}
+	height, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid event cursor %s", s)
+	}
+	index, err := strconv.ParseUint(parts[1], 10, 16)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid event cursor %s", s)
+	}
+	return &EventCursor{BlockHeight: height, EventIndex: uint16(index)}, nil
+}
+
+type EventFilterResult struct {
+	Events []*types.EventData
+	// Position of the next matching event, nil if there are no more matching events.
+	NextCursor *EventCursor
 }
 
 type EventStore interface {
@@ -31,8 +82,8 @@ type EventStore interface {
 	SaveEvent(contractID uint64, blockHeight uint64, eventIndex uint16, eventData *types.EventData) error
 	// BatchSaveEvents save series of events
 	BatchSaveEvents(events []*types.EventData) error
-	// FilterEvents filters events that match the given filter
-	FilterEvents(filter EventFilter) ([]*types.EventData, error)
+	// FilterEvents returns the events that match the given filter, in the order they were emitted
+	FilterEvents(filter EventFilter) (*EventFilterResult, error)
 	// ContractID mapping
 	GetContractID(pluginName string) uint64
 }
@@ -40,6 +91,8 @@ type EventStore interface {
 type KVEventStore struct {
 	dbm.DB
 	sync.Mutex
+	// Set once the height from which the topic & tx hash indices are complete has been persisted.
+	indexStartSaved bool
 }
 
 var _ EventStore = &KVEventStore{}
@@ -53,23 +106,25 @@ func (s *KVEventStore) SaveEvent(contractID uint64, blockHeight uint64, eventInd
 	if err != nil {
 		return err
 	}
+	s.saveIndexStart(blockHeight)
 	s.Set(prefixBlockHeightEventIndex(blockHeight, eventIndex), data)
 	s.Set(prefixContractIDBlockHightEventIndex(contractID, blockHeight, eventIndex), data)
+	for _, key := range secondaryIndexKeys(eventData, blockHeight, eventIndex) {
+		s.Set(key, data)
+	}
 	return nil
 }
 
 func (s *KVEventStore) BatchSaveEvents(events []*types.EventData) error {
 	// assume eevents is already sorted by event index
+	if len(events) > 0 {
+		s.saveIndexStart(events[0].BlockHeight)
+	}
 	batch := s.NewBatch()
 	for i, event := range events {
 		// resolve contractID
 		// Go contract uses plugin name, EVM contract uses address
-		var contractID uint64
-		contractName := event.PluginName
-		if contractName == "" {
-			contractName = diadem.UnmarshalAddressPB(event.Address).String()
-		}
-		contractID = s.GetContractID(contractName)
+		contractID := s.GetContractID(eventContractName(event))
 
 		data, err := proto.Marshal(event)
 		if err != nil {
@@ -78,43 +133,190 @@ func (s *KVEventStore) BatchSaveEvents(events []*types.EventData) error {
 		eventIndex := uint16(i)
 		batch.Set(prefixBlockHeightEventIndex(event.BlockHeight, eventIndex), data)
 		batch.Set(prefixContractIDBlockHightEventIndex(contractID, event.BlockHeight, eventIndex), data)
+		for _, key := range secondaryIndexKeys(event, event.BlockHeight, eventIndex) {
+			batch.Set(key, data)
+		}
 	}
 	batch.Write()
 	return nil
 }
 
-func (s *KVEventStore) FilterEvents(filter EventFilter) ([]*types.EventData, error) {
-	var events []*types.EventData
-	if filter.Contract != "" {
-		contractID := s.GetContractID(filter.Contract)
-		// Interator uses [start, end) so make sure we increase end inclusively
-		start := prefixContractIDBlockHight(contractID, filter.FromBlock)
-		end := prefixContractIDBlockHight(contractID, filter.ToBlock+1)
-		itr := s.Iterator(start, end)
-		defer itr.Close()
-		for ; itr.Valid(); itr.Next() {
-			var ed types.EventData
-			if err := proto.Unmarshal(itr.Value(), &ed); err != nil {
+func (s *KVEventStore) FilterEvents(filter EventFilter) (*EventFilterResult, error) {
+	// Iterator uses [start, end) so make sure we increase end inclusively
+	fromBlock, fromIndex := filter.FromBlock, uint16(0)
+	if filter.Cursor != nil && filter.Cursor.BlockHeight >= fromBlock {
+		fromBlock, fromIndex = filter.Cursor.BlockHeight, filter.Cursor.EventIndex
+	}
+	result := &EventFilterResult{}
+
+	useSecondaryIndex := len(filter.TxHash) > 0 || (filter.Contract != "" && filter.Topic != "")
+	if useSecondaryIndex {
+		// Events saved before the topic & tx hash indices were introduced aren't in those indices,
+		// so the blocks below the index start height have to be scanned instead.
+		if indexStart := s.indexStart(); fromBlock < indexStart {
+			toBlock := filter.ToBlock
+			if indexStart-1 < toBlock {
+				toBlock = indexStart - 1
+			}
+			start, end := s.primaryIndexRange(filter.Contract, fromBlock, fromIndex, toBlock)
+			done, err := s.filterEventsInRange(start, end, &filter, false, result)
+			if err != nil {
 				return nil, err
 			}
-			events = append(events, &ed)
+			if done || indexStart > filter.ToBlock {
+				return result, nil
+			}
+			fromBlock, fromIndex = indexStart, 0
 		}
-	} else {
-		// Interator uses [start, end) so make sure we increase end inclusively
-		start := prefixBlockHeightEventIndex(filter.FromBlock, 0)
-		end := prefixBlockHeightEventIndex(filter.ToBlock+1, 0)
-		itr := s.Iterator(start, end)
-		defer itr.Close()
-		for ; itr.Valid(); itr.Next() {
-			var ed types.EventData
-			if err := proto.Unmarshal(itr.Value(), &ed); err != nil {
-				return nil, err
+	}
+
+	var start, end []byte
+	switch {
+	case len(filter.TxHash) > 0:
+		// txs are only included in a single block, so there's no need to bother with the
+		// block range when looking up events by tx hash
+		start = prefixTxHash(filter.TxHash)
+		end = prefixRangeEnd(start)
+	case filter.Contract != "" && filter.Topic != "":
+		start = prefixPluginNameTopicBlockHeightEventIndex(filter.Contract, filter.Topic, fromBlock, fromIndex)
+		end = prefixPluginNameTopicBlockHeightEventIndex(filter.Contract, filter.Topic, filter.ToBlock+1, 0)
+	default:
+		start, end = s.primaryIndexRange(filter.Contract, fromBlock, fromIndex, filter.ToBlock)
+	}
+
+	// the tx hash index isn't partitioned by contract, so events emitted by other contracts have
+	// to be skipped while iterating
+	matchContract := len(filter.TxHash) > 0 && filter.Contract != ""
+	if _, err := s.filterEventsInRange(start, end, &filter, matchContract, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// primaryIndexRange returns the range of keys of the events emitted by the given contract (or all
+// contracts if the contract is empty) from the given position up to (and including) toBlock.
+func (s *KVEventStore) primaryIndexRange(contract string, fromBlock uint64, fromIndex uint16, toBlock uint64) ([]byte, []byte) {
+	if contract != "" {
+		contractID := s.GetContractID(contract)
+		return prefixContractIDBlockHightEventIndex(contractID, fromBlock, fromIndex),
+			prefixContractIDBlockHight(contractID, toBlock+1)
+	}
+	return prefixBlockHeightEventIndex(fromBlock, fromIndex), prefixBlockHeightEventIndex(toBlock+1, 0)
+}
+
+// filterEventsInRange appends the events in the given key range that match the filter to the
+// result, and returns true if the filter limit has been reached.
+func (s *KVEventStore) filterEventsInRange(
+	start, end []byte, filter *EventFilter, matchContract bool, result *EventFilterResult,
+) (bool, error) {
+	itr := s.Iterator(start, end)
+	defer itr.Close()
+	for ; itr.Valid(); itr.Next() {
+		blockHeight, eventIndex, err := parseEventKeySuffix(itr.Key())
+		if err != nil {
+			return false, err
+		}
+		if blockHeight < filter.FromBlock || blockHeight > filter.ToBlock {
+			continue
+		}
+		if filter.Cursor != nil && (blockHeight < filter.Cursor.BlockHeight ||
+			(blockHeight == filter.Cursor.BlockHeight && eventIndex < filter.Cursor.EventIndex)) {
+			continue
+		}
+		var ed types.EventData
+		if err := proto.Unmarshal(itr.Value(), &ed); err != nil {
+			return false, err
+		}
+		if matchContract && eventContractName(&ed) != filter.Contract {
+			continue
+		}
+		if !eventMatchesFilter(&ed, filter) {
+			continue
+		}
+		if filter.Limit > 0 && uint64(len(result.Events)) >= filter.Limit {
+			result.NextCursor = &EventCursor{BlockHeight: blockHeight, EventIndex: eventIndex}
+			return true, nil
+		}
+		result.Events = append(result.Events, &ed)
+	}
+	return false, nil
+}
+
+// saveIndexStart persists the height of the first block whose events are saved to the topic & tx
+// hash indices, unless it has already been persisted.
+func (s *KVEventStore) saveIndexStart(blockHeight uint64) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.indexStartSaved {
+		return
+	}
+	if len(s.Get(prefixIndexStart())) == 0 {
+		s.Set(prefixIndexStart(), uint64ToBytes(blockHeight))
+	}
+	s.indexStartSaved = true
+}
+
+// indexStart returns the height from which the topic & tx hash indices contain all the events, if
+// no events have been saved to the indices yet then none of the existing events are indexed.
+func (s *KVEventStore) indexStart() uint64 {
+	data := s.Get(prefixIndexStart())
+	if len(data) == 0 {
+		return math.MaxUint64
+	}
+	return bytesToUint64(data)
+}
+
+func eventMatchesFilter(ed *types.EventData, filter *EventFilter) bool {
+	if len(filter.TxHash) > 0 && !bytes.Equal(ed.TxHash, filter.TxHash) {
+		return false
+	}
+	if filter.Origin != "" && (ed.Caller == nil || diadem.UnmarshalAddressPB(ed.Caller).String() != filter.Origin) {
+		return false
+	}
+	if filter.Topic != "" {
+		for _, topic := range ed.Topics {
+			if topic == filter.Topic {
+				return true
 			}
-			events = append(events, &ed)
 		}
+		return false
 	}
+	return true
+}
 
-	return events, nil
+// eventContractName returns the name the event store uses to identify the contract that emitted
+// the given event. Go contracts are identified by plugin name, EVM contracts by address.
+func eventContractName(event *types.EventData) string {
+	if event.PluginName != "" || event.Address == nil {
+		return event.PluginName
+	}
+	return diadem.UnmarshalAddressPB(event.Address).String()
+}
+
+// secondaryIndexKeys returns the keys of the topic & tx hash indices the given event should be
+// stored under.
+func secondaryIndexKeys(event *types.EventData, blockHeight uint64, eventIndex uint16) [][]byte {
+	var keys [][]byte
+	if contractName := eventContractName(event); contractName != "" {
+		for _, topic := range event.Topics {
+			keys = append(keys, prefixPluginNameTopicBlockHeightEventIndex(contractName, topic, blockHeight, eventIndex))
+		}
+	}
+	if len(event.TxHash) > 0 {
+		keys = append(keys, util.PrefixKey(prefixTxHash(event.TxHash), uint64ToBytes(blockHeight), uint16ToBytes(eventIndex)))
+	}
+	return keys
+}
+
+// parseEventKeySuffix extracts the block height & event index all event keys end with.
+func parseEventKeySuffix(key []byte) (uint64, uint16, error) {
+	// block height (8 bytes) + separator (1 byte) + event index (2 bytes)
+	if len(key) < 11 {
+		return 0, 0, fmt.Errorf("invalid event key %X", key)
+	}
+	suffix := key[len(key)-11:]
+	return binary.BigEndian.Uint64(suffix[:8]), binary.BigEndian.Uint16(suffix[9:]), nil
 }
 
 func (s *KVEventStore) GetContractID(pluginName string) uint64 {
@@ -157,6 +359,18 @@ func prefixPluginNameTopic(pluginName string, topic string) []byte {
 	return util.PrefixKey([]byte{pluginNameTopicKeyPrefix}, []byte(pluginName), []byte(topic))
 }
 
+func prefixPluginNameTopicBlockHeightEventIndex(pluginName string, topic string, blockHeight uint64, eventIndex uint16) []byte {
+	return util.PrefixKey(prefixPluginNameTopic(pluginName, topic), uint64ToBytes(blockHeight), uint16ToBytes(eventIndex))
+}
+
+func prefixTxHash(txHash []byte) []byte {
+	return util.PrefixKey([]byte{txHashKeyPrefix}, txHash)
+}
+
+func prefixIndexStart() []byte {
+	return util.PrefixKey([]byte{indexStartKeyPrefix}, []byte("index-start"))
+}
+
 func prefixLastContractID() []byte {
 	return util.PrefixKey([]byte{lastContractIDKeyPrefix}, []byte("contract-id"))
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/gogo/protobuf/proto"
+	diadem "github.com/diademnetwork/go-diadem"
 	"github.com/diademnetwork/go-diadem/plugin/types"
 	"github.com/stretchr/testify/require"
 	dbm "github.com/tendermint/tendermint/libs/db"
@@ -97,8 +98,9 @@ func TestEventStoreFilterMemDB(t *testing.T) {
 		ToBlock:   1,
 		Contract:  "plugin1",
 	}
-	events, err := eventStore.FilterEvents(filter1)
+	result, err := eventStore.FilterEvents(filter1)
 	require.Nil(t, err)
+	events := result.Events
 	require.Equal(t, len(eventData1), len(events), "expect the same length")
 	for i, e := range events {
 		require.True(t, proto.Equal(eventData1[i], e))
@@ -109,8 +111,9 @@ func TestEventStoreFilterMemDB(t *testing.T) {
 		ToBlock:   2,
 		Contract:  "plugin1",
 	}
-	events, err = eventStore.FilterEvents(filter2)
+	result, err = eventStore.FilterEvents(filter2)
 	require.Nil(t, err)
+	events = result.Events
 	require.Equal(t, len(eventData2), len(events), "expect the same length")
 	for i, e := range events {
 		require.True(t, proto.Equal(eventData2[i], e))
@@ -121,8 +124,9 @@ func TestEventStoreFilterMemDB(t *testing.T) {
 		ToBlock:   2,
 		Contract:  "plugin1",
 	}
-	events, err = eventStore.FilterEvents(filter3)
+	result, err = eventStore.FilterEvents(filter3)
 	require.Nil(t, err)
+	events = result.Events
 	require.Equal(t, len(eventData1)+len(eventData2), len(events), "expect the same length")
 	var allEventData []*types.EventData
 	allEventData = append(allEventData, eventData1...)
@@ -179,8 +183,9 @@ func TestEventStoreFilterMultiplePluginsMemDB(t *testing.T) {
 		ToBlock:   10,
 		Contract:  "plugin1",
 	}
-	events, err := eventStore.FilterEvents(filter1)
+	result, err := eventStore.FilterEvents(filter1)
 	require.Nil(t, err)
+	events := result.Events
 	require.Equal(t, len(eventData1), len(events), "expect the same length")
 	for i, e := range events {
 		require.True(t, proto.Equal(eventData1[i], e))
@@ -191,8 +196,9 @@ func TestEventStoreFilterMultiplePluginsMemDB(t *testing.T) {
 		ToBlock:   10,
 		Contract:  "plugin2",
 	}
-	events, err = eventStore.FilterEvents(filter2)
+	result, err = eventStore.FilterEvents(filter2)
 	require.Nil(t, err)
+	events = result.Events
 	require.Equal(t, len(eventData2), len(events), "expect the same length")
 	for i, e := range events {
 		require.True(t, proto.Equal(eventData2[i], e))
@@ -214,8 +220,9 @@ func TestEventStoreFilterMultiplePluginsMemDB(t *testing.T) {
 		ToBlock:   15,
 		Contract:  "plugin1",
 	}
-	events, err = eventStore.FilterEvents(filter3)
+	result, err = eventStore.FilterEvents(filter3)
 	require.Nil(t, err)
+	events = result.Events
 	require.Equal(t, len(eventData1), len(events), "expect the same length")
 	for i, e := range events {
 		require.True(t, proto.Equal(eventData1[i], e))
@@ -225,6 +232,184 @@ func TestEventStoreFilterMultiplePluginsMemDB(t *testing.T) {
 		FromBlock: 1,
 		ToBlock:   15,
 	}
-	events, err = eventStore.FilterEvents(filter4)
+	_, err = eventStore.FilterEvents(filter4)
 	require.Nil(t, err)
 }
+
+func TestEventStoreFilterTopicOriginTxHashMemDB(t *testing.T) {
+	memdb := dbm.NewMemDB()
+	var eventStore EventStore = NewKVEventStore(memdb)
+
+	caller1 := diadem.MustParseAddress("default:0xb16a379ec18d4093666f8f38b11a3071c920207d")
+	caller2 := diadem.MustParseAddress("default:0x5cecd1f7261e1f4c684e297be3edf03b825e01c4")
+	var events []*types.EventData
+	for i := 1; i <= 10; i++ {
+		caller := caller1
+		topic := "even"
+		if i%2 == 1 {
+			caller = caller2
+			topic = "odd"
+		}
+		events = append(events, &types.EventData{
+			PluginName:       "plugin1",
+			Caller:           caller.MarshalPB(),
+			Topics:           []string{"all", topic},
+			BlockHeight:      uint64(i),
+			TransactionIndex: 0,
+			TxHash:           []byte(fmt.Sprintf("tx%d", i)),
+			EncodedBody:      []byte(fmt.Sprintf("event-%d", i)),
+		})
+	}
+	// event from another contract with the same topics & tx hash
+	events = append(events, &types.EventData{
+		PluginName:  "plugin2",
+		Caller:      caller1.MarshalPB(),
+		Topics:      []string{"all", "even"},
+		BlockHeight: 10,
+		TxHash:      []byte("tx10"),
+		EncodedBody: []byte("event2-10"),
+	})
+	require.NoError(t, eventStore.BatchSaveEvents(events))
+
+	result, err := eventStore.FilterEvents(EventFilter{
+		FromBlock: 1,
+		ToBlock:   10,
+		Contract:  "plugin1",
+		Topic:     "odd",
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Events, 5)
+	require.Nil(t, result.NextCursor)
+	for i, e := range result.Events {
+		require.True(t, proto.Equal(events[i*2], e))
+	}
+
+	result, err = eventStore.FilterEvents(EventFilter{
+		FromBlock: 1,
+		ToBlock:   10,
+		Topic:     "even",
+		Origin:    caller1.String(),
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Events, 6)
+
+	result, err = eventStore.FilterEvents(EventFilter{
+		FromBlock: 1,
+		ToBlock:   10,
+		Origin:    caller2.String(),
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Events, 5)
+
+	result, err = eventStore.FilterEvents(EventFilter{
+		FromBlock: 1,
+		ToBlock:   10,
+		TxHash:    []byte("tx10"),
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Events, 2)
+
+	result, err = eventStore.FilterEvents(EventFilter{
+		FromBlock: 1,
+		ToBlock:   10,
+		Contract:  "plugin2",
+		TxHash:    []byte("tx10"),
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Events, 1)
+	require.True(t, proto.Equal(events[10], result.Events[0]))
+
+	// page through all the events of plugin1 that have the "all" topic
+	filter := EventFilter{
+		FromBlock: 1,
+		ToBlock:   10,
+		Contract:  "plugin1",
+		Topic:     "all",
+		Limit:     3,
+	}
+	var paged []*types.EventData
+	for pages := 0; ; pages++ {
+		require.True(t, pages < 4, "too many pages")
+		result, err = eventStore.FilterEvents(filter)
+		require.NoError(t, err)
+		require.True(t, len(result.Events) <= 3)
+		paged = append(paged, result.Events...)
+		if result.NextCursor == nil {
+			break
+		}
+		cursor, err := ParseEventCursor(result.NextCursor.String())
+		require.NoError(t, err)
+		require.Equal(t, result.NextCursor, cursor)
+		filter.Cursor = cursor
+	}
+	require.Len(t, paged, 10)
+	for i, e := range paged {
+		require.True(t, proto.Equal(events[i], e))
+	}
+
+	_, err = ParseEventCursor("10")
+	require.Error(t, err)
+	_, err = ParseEventCursor("10.abc")
+	require.Error(t, err)
+}
+
+func TestEventStoreFilterUnindexedEventsMemDB(t *testing.T) {
+	memdb := dbm.NewMemDB()
+	eventStore := NewKVEventStore(memdb)
+
+	var events []*types.EventData
+	for i := 1; i <= 6; i++ {
+		events = append(events, &types.EventData{
+			PluginName:  "plugin1",
+			Topics:      []string{"all"},
+			BlockHeight: uint64(i),
+			TxHash:      []byte(fmt.Sprintf("tx%d", i)),
+			EncodedBody: []byte(fmt.Sprintf("event-%d", i)),
+		})
+	}
+	// events saved before the topic & tx hash indices were introduced only exist in the block
+	// height & contract indices
+	contractID := eventStore.GetContractID("plugin1")
+	for _, event := range events[:3] {
+		data, err := proto.Marshal(event)
+		require.NoError(t, err)
+		memdb.Set(prefixBlockHeightEventIndex(event.BlockHeight, 0), data)
+		memdb.Set(prefixContractIDBlockHightEventIndex(contractID, event.BlockHeight, 0), data)
+	}
+
+	// none of the existing events are indexed until new events are saved
+	result, err := eventStore.FilterEvents(EventFilter{FromBlock: 1, ToBlock: 6, Contract: "plugin1", Topic: "all"})
+	require.NoError(t, err)
+	require.Len(t, result.Events, 3)
+
+	for _, event := range events[3:] {
+		require.NoError(t, eventStore.BatchSaveEvents([]*types.EventData{event}))
+	}
+
+	result, err = eventStore.FilterEvents(EventFilter{FromBlock: 1, ToBlock: 6, Contract: "plugin1", Topic: "all"})
+	require.NoError(t, err)
+	require.Len(t, result.Events, 6)
+	for i, e := range result.Events {
+		require.True(t, proto.Equal(events[i], e))
+	}
+
+	result, err = eventStore.FilterEvents(EventFilter{FromBlock: 1, ToBlock: 6, TxHash: []byte("tx2")})
+	require.NoError(t, err)
+	require.Len(t, result.Events, 1)
+	require.True(t, proto.Equal(events[1], result.Events[0]))
+
+	// paging should carry on from the unindexed events to the indexed ones
+	filter := EventFilter{FromBlock: 1, ToBlock: 6, Contract: "plugin1", Topic: "all", Limit: 2}
+	var paged []*types.EventData
+	for pages := 0; ; pages++ {
+		require.True(t, pages < 4, "too many pages")
+		result, err = eventStore.FilterEvents(filter)
+		require.NoError(t, err)
+		paged = append(paged, result.Events...)
+		if result.NextCursor == nil {
+			break
+		}
+		filter.Cursor = result.NextCursor
+	}
+	require.Len(t, paged, 6)
+}
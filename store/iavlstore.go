@@ -36,6 +36,7 @@ func init() {
 type IAVLStore struct {
 	tree        *iavl.MutableTree
 	maxVersions int64 // maximum number of versions to keep when pruning
+	nodeDB      dbm.DB
 }
 
 func (s *IAVLStore) Delete(key []byte) {
@@ -144,6 +145,62 @@ func (s *IAVLStore) Prune() error {
 	return nil
 }
 
+// ExportSnapshot writes a state snapshot of the given tree version to the specified directory,
+// the snapshot can be loaded into a fresh app.db via ImportStateSnapshot.
+func (s *IAVLStore) ExportSnapshot(version int64, dir string, chunkSize int) (*StateSnapshotManifest, error) {
+	if !s.tree.VersionExists(version) {
+		return nil, fmt.Errorf("tree version %d doesn't exist", version)
+	}
+	return exportStateSnapshot(s.nodeDB, nil, version, dir, chunkSize)
+}
+
+// GetWithProof returns the value of the given key in the last saved version of the tree, along
+// with a Merkle proof for the value (or its absence).
+func (s *IAVLStore) GetWithProof(key []byte) (*ValueProof, error) {
+	version := s.tree.Version()
+	if version == 0 {
+		return nil, errors.New("no saved tree versions")
+	}
+	value, proof, err := s.tree.GetVersionedWithProof(key, version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to generate proof at tree version %d", version)
+	}
+	return &ValueProof{
+		Version: version,
+		Key:     key,
+		Value:   value,
+		Proof:   proof,
+	}, nil
+}
+
+// GetSnapshotAt returns a read-only snapshot of a previously saved version of the tree.
+// If maxVersions is non-zero only the last maxVersions versions of the tree are available.
+func (s *IAVLStore) GetSnapshotAt(version int64) (Snapshot, error) {
+	latestVer := s.Version()
+	if version < 1 {
+		return nil, fmt.Errorf("invalid height %d", version)
+	}
+	if version > latestVer {
+		return nil, fmt.Errorf("state at height %d doesn't exist yet, latest height is %d", version, latestVer)
+	}
+	if (s.maxVersions != 0) && (version <= latestVer-s.maxVersions) {
+		return nil, fmt.Errorf(
+			"state at height %d has been pruned, oldest available height is %d",
+			version, latestVer-s.maxVersions+1,
+		)
+	}
+	if !s.tree.VersionExists(version) {
+		return nil, fmt.Errorf("state at height %d has been pruned", version)
+	}
+	tree, err := s.tree.GetImmutable(version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load immutable tree for version %d", version)
+	}
+	return &multiReaderIAVLStoreTreeSnapshot{
+		ImmutableTree: tree,
+	}, nil
+}
+
 func (s *IAVLStore) GetSnapshot() Snapshot {
 	// This isn't an actual snapshot obviously, and never will be, but lets pretend...
 	return &iavlStoreSnapshot{
@@ -171,6 +228,7 @@ func NewIAVLStore(db dbm.DB, maxVersions, targetVersion int64) (*IAVLStore, erro
 	return &IAVLStore{
 		tree:        tree,
 		maxVersions: maxVersions,
+		nodeDB:      db,
 	}, nil
 }
 
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"fmt"
 	"log"
 	"os"
 
@@ -129,3 +130,17 @@ func (s *LogStore) Prune() error {
 func (s *LogStore) GetSnapshot() Snapshot {
 	return s.store.GetSnapshot()
 }
+
+func (s *LogStore) GetSnapshotAt(version int64) (Snapshot, error) {
+	if hs, ok := s.store.(HistoricalStore); ok {
+		return hs.GetSnapshotAt(version)
+	}
+	return nil, fmt.Errorf("[LogStore] underlying store doesn't support historical state")
+}
+
+func (s *LogStore) GetWithProof(key []byte) (*ValueProof, error) {
+	if ps, ok := s.store.(ProvableStore); ok {
+		return ps.GetWithProof(key)
+	}
+	return nil, fmt.Errorf("[LogStore] underlying store doesn't support proofs")
+}
@@ -138,6 +138,53 @@ func (s *MultiReaderIAVLStore) GetSnapshot() Snapshot {
 	}
 }
 
+// GetSnapshotAt returns a read-only snapshot of the latest saved version of the tree, previous
+// versions aren't available because the valueDB only contains the values of the latest version.
+func (s *MultiReaderIAVLStore) GetSnapshotAt(version int64) (Snapshot, error) {
+	tree := (*iavl.ImmutableTree)(atomic.LoadPointer(&s.lastSavedTree))
+	if version != tree.Version() {
+		return nil, fmt.Errorf(
+			"state at height %d is not available, AppStore v2 (MultiReaderIAVLStore) doesn't keep "+
+				"previous versions, only the latest state (height %d) can be queried",
+			version, tree.Version(),
+		)
+	}
+	return &multiReaderIAVLStoreTreeSnapshot{
+		ImmutableTree: tree,
+	}, nil
+}
+
+// ExportSnapshot writes a state snapshot of the given tree version to the specified directory.
+// Since the valueDB only contains the values of the latest saved tree only the latest version can
+// be exported.
+func (s *MultiReaderIAVLStore) ExportSnapshot(version int64, dir string, chunkSize int) (*StateSnapshotManifest, error) {
+	if version != s.Version() {
+		return nil, fmt.Errorf(
+			"only the latest version (%d) can be exported from MultiReaderIAVLStore", s.Version(),
+		)
+	}
+	return exportStateSnapshot(s.nodeDB, s.getValue, version, dir, chunkSize)
+}
+
+// GetWithProof returns the value of the given key in the last saved version of the tree, along
+// with a Merkle proof for the value (or its absence).
+func (s *MultiReaderIAVLStore) GetWithProof(key []byte) (*ValueProof, error) {
+	tree := (*iavl.ImmutableTree)(atomic.LoadPointer(&s.lastSavedTree))
+	if tree.Version() == 0 {
+		return nil, errors.New("no saved tree versions")
+	}
+	value, proof, err := tree.GetWithProof(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to generate proof at tree version %d", tree.Version())
+	}
+	return &ValueProof{
+		Version: tree.Version(),
+		Key:     key,
+		Value:   value,
+		Proof:   proof,
+	}, nil
+}
+
 func (s *MultiReaderIAVLStore) getValue(key []byte) []byte {
 	// TODO: In theory the IAVL tree shouldn't try to load any key in s.valueBatch,
 	//       but need to test what happens when Delete, Set, Delete, Set is called for the same
@@ -212,6 +259,7 @@ func NewMultiReaderIAVLStore(nodeDB dbm.DB, valueDB db.DBWrapper, cfg *AppStoreC
 	s.IAVLStore = IAVLStore{
 		tree:        tree,
 		maxVersions: maxVersions,
+		nodeDB:      nodeDB,
 	}
 
 	if err := s.setLastSavedTreeToVersion(treeVer); err != nil {
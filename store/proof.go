@@ -0,0 +1,63 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/tendermint/iavl"
+)
+
+// ValueProof contains the value (if any) stored under a key in the app store at a specific version,
+// and a Merkle proof that can be used to verify the value (or its absence) against the root hash
+// of the app store at that version.
+type ValueProof struct {
+	// Version of the app store the proof was generated for, this is also the height of the block
+	// whose state was proven.
+	Version int64 `json:"version"`
+	// Key the proof was generated for.
+	Key []byte `json:"key"`
+	// Value is nil if the key doesn't exist in the store.
+	Value []byte           `json:"value"`
+	Proof *iavl.RangeProof `json:"proof"`
+}
+
+// ProvableStore is implemented by stores that can generate Merkle proofs for the values they contain.
+type ProvableStore interface {
+	// GetWithProof returns the value of the given key in the last saved version of the store,
+	// along with a proof for the value (or an absence proof if the key doesn't exist).
+	GetWithProof(key []byte) (*ValueProof, error)
+}
+
+// VerifyValueProof checks that the given proof was generated for the given key, and that the value
+// in the proof (or its absence) is proven by the proof against the given app hash.
+//
+// The app hash must be obtained from a trusted source, the state of the app store at version N is
+// committed to the chain in the AppHash field of the header of the block at height N + 1.
+func VerifyValueProof(appHash []byte, key []byte, proof *ValueProof) error {
+	if proof == nil || proof.Proof == nil {
+		return errors.New("missing proof")
+	}
+	if !bytes.Equal(key, proof.Key) {
+		return fmt.Errorf("proof is for key %X, expected %X", proof.Key, key)
+	}
+	if err := proof.Proof.Verify(appHash); err != nil {
+		return errors.Wrap(err, "failed to verify proof root")
+	}
+	if proof.Value == nil {
+		if err := proof.Proof.VerifyAbsence(key); err != nil {
+			return errors.Wrap(err, "failed to verify absence of key")
+		}
+		return nil
+	}
+	if err := proof.Proof.VerifyItem(key, proof.Value); err != nil {
+		return errors.Wrap(err, "failed to verify value")
+	}
+	return nil
+}
+
+var _ ProvableStore = &IAVLStore{}
+var _ ProvableStore = &MultiReaderIAVLStore{}
+var _ ProvableStore = &PruningIAVLStore{}
+var _ ProvableStore = &CachingStore{}
+var _ ProvableStore = &LogStore{}
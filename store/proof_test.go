@@ -0,0 +1,53 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+func TestIAVLStoreGetWithProof(t *testing.T) {
+	s, err := NewIAVLStore(dbm.NewMemDB(), 0, 0)
+	require.NoError(t, err)
+
+	_, err = s.GetWithProof(key1)
+	require.Error(t, err, "proofs shouldn't be available until a version is saved")
+
+	s.Set(key1, val1)
+	s.Set(key2, val2)
+	appHash, _, err := s.SaveVersion()
+	require.NoError(t, err)
+
+	// uncommitted changes shouldn't affect the proofs
+	s.Set(key1, val3)
+	s.Set(key3, val3)
+
+	proof, err := s.GetWithProof(key1)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), proof.Version)
+	require.Equal(t, val1, proof.Value)
+	require.NoError(t, VerifyValueProof(appHash, key1, proof))
+
+	// absence proof
+	proof, err = s.GetWithProof(key3)
+	require.NoError(t, err)
+	require.Nil(t, proof.Value)
+	require.NoError(t, VerifyValueProof(appHash, key3, proof))
+
+	// tampered value
+	proof, err = s.GetWithProof(key2)
+	require.NoError(t, err)
+	proof.Value = val3
+	require.Error(t, VerifyValueProof(appHash, key2, proof))
+
+	// proof for a different key
+	proof, err = s.GetWithProof(key2)
+	require.NoError(t, err)
+	require.Error(t, VerifyValueProof(appHash, key1, proof))
+
+	// wrong app hash
+	newAppHash, _, err := s.SaveVersion()
+	require.NoError(t, err)
+	require.Error(t, VerifyValueProof(newAppHash, key2, proof))
+}
@@ -2,6 +2,8 @@ package store
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"time"
@@ -19,6 +21,12 @@ import (
 var (
 	pruneDuration         metrics.Histogram
 	deleteVersionDuration metrics.Histogram
+	pruneOldestVersion    metrics.Gauge
+	pruneVersionsPruned   metrics.Counter
+	pruneBytesReclaimed   metrics.Gauge
+	pruneETA              metrics.Gauge
+	pruneBatchSize        metrics.Gauge
+	pruneAvgCommitLatency metrics.Gauge
 )
 
 func init() {
@@ -39,19 +47,107 @@ func init() {
 			Name:      "delete_version_duration",
 			Help:      "How long it took to delete a single version from the IAVL store (in seconds)",
 		}, []string{"error"})
+	pruneOldestVersion = kitprometheus.NewGaugeFrom(
+		stdprometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "oldest_version",
+			Help:      "Oldest IAVL tree version that hasn't been pruned yet",
+		}, nil)
+	pruneVersionsPruned = kitprometheus.NewCounterFrom(
+		stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "versions_pruned",
+			Help:      "Number of IAVL tree versions pruned since the node started",
+		}, nil)
+	pruneBytesReclaimed = kitprometheus.NewGaugeFrom(
+		stdprometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "bytes_reclaimed",
+			Help:      "Decrease in the size of app.db on disk since the node started (in bytes)",
+		}, nil)
+	pruneETA = kitprometheus.NewGaugeFrom(
+		stdprometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "eta",
+			Help:      "Estimated time until all the old versions are pruned (in seconds)",
+		}, nil)
+	pruneBatchSize = kitprometheus.NewGaugeFrom(
+		stdprometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "batch_size",
+			Help:      "Number of versions the pruner will delete in the next cycle",
+		}, nil)
+	pruneAvgCommitLatency = kitprometheus.NewGaugeFrom(
+		stdprometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "avg_commit_latency",
+			Help:      "Moving average of the time it takes to save a new version of the store (in seconds)",
+		}, nil)
+}
+
+const (
+	// Weight given to the latest sample when updating the moving average of the commit latency.
+	commitLatencyWeight = 0.2
+	// Minimum amount of time between measurements of the DB size on disk.
+	dbSizeCheckInterval = time.Minute
+)
+
+// PruningStatus describes the progress of the background pruning of old app store versions.
+type PruningStatus struct {
+	// Oldest version that hasn't been pruned yet.
+	OldestVersion int64 `json:"oldestVersion"`
+	LatestVersion int64 `json:"latestVersion"`
+	// Newest version that will be pruned (given the current latest version).
+	TargetVersion int64 `json:"targetVersion"`
+	// Number of versions pruned since the node started.
+	VersionsPruned uint64 `json:"versionsPruned"`
+	// Decrease in the size of the DB on disk since the node started, this is only an approximation
+	// since the space taken up by deleted keys is only reclaimed when the DB is compacted.
+	// Zero if the DB location on disk is unknown.
+	BytesReclaimed int64 `json:"bytesReclaimed"`
+	// Estimated time until all the versions up to TargetVersion are pruned, -1 if unknown.
+	ETASeconds int64 `json:"etaSeconds"`
+	// Number of versions that will be pruned in the next cycle, may be lower than the configured
+	// batch size if the pruner is being throttled.
+	BatchSize int64 `json:"batchSize"`
+	// Moving average of the time taken to save a new version of the store.
+	AvgCommitLatencyMs int64 `json:"avgCommitLatencyMs"`
+	Throttled          bool  `json:"throttled"`
+}
+
+// PruningStatusReporter is implemented by stores that prune old versions in the background.
+type PruningStatusReporter interface {
+	PruningStatus() (*PruningStatus, error)
 }
 
 type PruningIAVLStoreConfig struct {
 	MaxVersions int64 // maximum number of versions to keep when pruning
 	BatchSize   int64 // maximum number of versions to delete in each cycle
 	Interval    time.Duration
-	Logger      *diadem.Logger
+	// If the average time taken to save a new version exceeds this limit the pruner will reduce
+	// the number of versions it deletes in each cycle, and if that's not enough it'll skip cycles
+	// until the commit latency drops. If zero the pruner won't be throttled.
+	TargetCommitLatency time.Duration
+	// Location of the DB on disk, used to estimate how much disk space has been reclaimed by
+	// pruning, may be left empty.
+	DBPath string
+	Logger *diadem.Logger
 }
 
 // PruningIAVLStore is a specialized IAVLStore that has a background thread that periodically prunes
 // old versions. It should only be used to prune old clusters, on new clusters nodes will delete
 // a version each time they save a new one, so the background thread, and all the extra locking
 // is unnecessary.
+//
+// Since the background thread blocks commits while it's deleting versions it throttles itself
+// based on how long it takes to save new versions, so pruning a large backlog of old versions
+// doesn't slow down block production too much.
 type PruningIAVLStore struct {
 	store       *IAVLStore
 	mutex       *sync.RWMutex
@@ -60,6 +156,17 @@ type PruningIAVLStore struct {
 	batchSize   int64
 	batchCount  uint64
 	logger      *diadem.Logger
+
+	targetCommitLatency time.Duration
+	avgCommitLatency    time.Duration
+	curBatchSize        int64
+	throttled           bool
+	versionsPruned      uint64
+	startTime           time.Time
+	dbPath              string
+	initialDBSize       int64
+	bytesReclaimed      int64
+	lastDBSizeCheck     time.Time
 }
 
 // NewPruningIAVLStore creates a new PruningIAVLStore.
@@ -83,6 +190,11 @@ func NewPruningIAVLStore(db dbm.DB, cfg PruningIAVLStoreConfig) (*PruningIAVLSto
 		maxVersions: maxVersions,
 		batchSize:   cfg.BatchSize,
 		logger:      cfg.Logger,
+
+		targetCommitLatency: cfg.TargetCommitLatency,
+		curBatchSize:        cfg.BatchSize,
+		startTime:           time.Now(),
+		dbPath:              cfg.DBPath,
 	}
 
 	if s.logger == nil {
@@ -102,6 +214,15 @@ func NewPruningIAVLStore(db dbm.DB, cfg PruningIAVLStoreConfig) (*PruningIAVLSto
 			}
 		}
 		s.oldestVer = oldestVer
+		pruneOldestVersion.Set(float64(oldestVer))
+
+		if s.dbPath != "" {
+			if s.initialDBSize, err = dirSize(s.dbPath); err != nil {
+				s.logger.Error("Failed to compute app store size", "path", s.dbPath, "err", err)
+				s.dbPath = ""
+			}
+			s.lastDBSizeCheck = time.Now()
+		}
 
 		go s.loopWithInterval(s.prune, cfg.Interval)
 	}
@@ -159,6 +280,8 @@ func (s *PruningIAVLStore) Version() int64 {
 }
 
 func (s *PruningIAVLStore) SaveVersion() ([]byte, int64, error) {
+	// the time spent waiting for the pruner to release the lock counts towards the commit latency
+	begin := time.Now()
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -166,9 +289,51 @@ func (s *PruningIAVLStore) SaveVersion() ([]byte, int64, error) {
 	if err == nil && s.oldestVer == 0 {
 		s.oldestVer = ver
 	}
+	s.updateCommitLatency(time.Since(begin))
 	return hash, ver, err
 }
 
+func (s *PruningIAVLStore) updateCommitLatency(latency time.Duration) {
+	if s.avgCommitLatency == 0 {
+		s.avgCommitLatency = latency
+	} else {
+		s.avgCommitLatency = time.Duration(
+			commitLatencyWeight*float64(latency) + (1-commitLatencyWeight)*float64(s.avgCommitLatency),
+		)
+	}
+	pruneAvgCommitLatency.Set(s.avgCommitLatency.Seconds())
+}
+
+// PruningStatus returns the current progress of the background pruning.
+func (s *PruningIAVLStore) PruningStatus() (*PruningStatus, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	latestVer := s.store.Version()
+	etaSecs := int64(-1)
+	if eta, ok := s.eta(latestVer); ok {
+		etaSecs = int64(eta.Seconds())
+	}
+	return &PruningStatus{
+		OldestVersion:      s.oldestVer,
+		LatestVersion:      latestVer,
+		TargetVersion:      s.targetVersion(latestVer),
+		VersionsPruned:     s.versionsPruned,
+		BytesReclaimed:     s.bytesReclaimed,
+		ETASeconds:         etaSecs,
+		BatchSize:          s.curBatchSize,
+		AvgCommitLatencyMs: int64(s.avgCommitLatency / time.Millisecond),
+		Throttled:          s.throttled,
+	}, nil
+}
+
+func (s *PruningIAVLStore) GetWithProof(key []byte) (*ValueProof, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.store.GetWithProof(key)
+}
+
 func (s *PruningIAVLStore) Prune() error {
 	// pruning is done in the goroutine, so do nothing here
 	return nil
@@ -181,6 +346,18 @@ func (s *PruningIAVLStore) GetSnapshot() Snapshot {
 	}
 }
 
+func (s *PruningIAVLStore) GetSnapshotAt(version int64) (Snapshot, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if version < s.oldestVer {
+		return nil, fmt.Errorf(
+			"state at height %d has been pruned, oldest available height is %d", version, s.oldestVer,
+		)
+	}
+	return s.store.GetSnapshotAt(version)
+}
+
 func (s *PruningIAVLStore) prune() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -192,25 +369,29 @@ func (s *PruningIAVLStore) prune() error {
 	}(time.Now())
 
 	latestVer := s.store.Version()
-	endVer := latestVer - s.maxVersions
+	endVer := s.targetVersion(latestVer)
 
 	if (s.oldestVer == 0) || (s.oldestVer > endVer) {
 		return nil // nothing to prune yet
 	}
 
-	if (endVer - s.oldestVer) > s.batchSize {
-		endVer = s.oldestVer + s.batchSize
+	if !s.throttle() {
+		return nil // give the node a chance to catch up
 	}
 
-	if endVer > (latestVer - 2) {
-		endVer = latestVer - 2
+	if (endVer - s.oldestVer) > s.curBatchSize {
+		endVer = s.oldestVer + s.curBatchSize
 	}
 
+	defer s.updateProgress(latestVer)
+
 	for i := s.oldestVer; i <= endVer; i++ {
 		if s.store.tree.VersionExists(i) {
 			if err = s.deleteVersion(i); err != nil {
 				return errors.Wrapf(err, "failed to delete tree version %d", i)
 			}
+			s.versionsPruned++
+			pruneVersionsPruned.Add(1)
 		}
 		s.oldestVer++
 	}
@@ -219,6 +400,103 @@ func (s *PruningIAVLStore) prune() error {
 	return nil
 }
 
+// targetVersion returns the newest version that should be pruned.
+func (s *PruningIAVLStore) targetVersion(latestVer int64) int64 {
+	endVer := latestVer - s.maxVersions
+	if endVer > (latestVer - 2) {
+		endVer = latestVer - 2
+	}
+	return endVer
+}
+
+// throttle adjusts the number of versions that will be pruned in the next cycle based on the
+// recent commit latency, returns false if the next cycle should be skipped.
+func (s *PruningIAVLStore) throttle() bool {
+	defer func() {
+		pruneBatchSize.Set(float64(s.curBatchSize))
+	}()
+
+	if s.targetCommitLatency == 0 {
+		s.curBatchSize = s.batchSize
+		return true
+	}
+
+	if s.avgCommitLatency > s.targetCommitLatency {
+		if s.curBatchSize <= 1 {
+			if !s.throttled {
+				s.logger.Info(
+					"Pausing app store pruning due to high commit latency",
+					"latency", s.avgCommitLatency, "target", s.targetCommitLatency,
+				)
+			}
+			s.throttled = true
+			return false
+		}
+		s.curBatchSize = s.curBatchSize / 2
+	} else if s.avgCommitLatency < s.targetCommitLatency/2 && s.curBatchSize < s.batchSize {
+		s.curBatchSize = s.curBatchSize * 2
+		if s.curBatchSize > s.batchSize {
+			s.curBatchSize = s.batchSize
+		}
+	}
+	if s.throttled {
+		s.logger.Info("Resuming app store pruning", "batchSize", s.curBatchSize)
+	}
+	s.throttled = false
+	return true
+}
+
+func (s *PruningIAVLStore) updateProgress(latestVer int64) {
+	pruneOldestVersion.Set(float64(s.oldestVer))
+	if eta, ok := s.eta(latestVer); ok {
+		pruneETA.Set(eta.Seconds())
+	}
+
+	if s.dbPath == "" || time.Since(s.lastDBSizeCheck) < dbSizeCheckInterval {
+		return
+	}
+	s.lastDBSizeCheck = time.Now()
+	size, err := dirSize(s.dbPath)
+	if err != nil {
+		s.logger.Error("Failed to compute app store size", "path", s.dbPath, "err", err)
+		return
+	}
+	s.bytesReclaimed = s.initialDBSize - size
+	if s.bytesReclaimed < 0 {
+		s.bytesReclaimed = 0
+	}
+	pruneBytesReclaimed.Set(float64(s.bytesReclaimed))
+}
+
+// eta estimates how long it will take to prune all the versions up to the target version, based on
+// the average pruning rate since the node started. Returns false if the rate isn't known yet.
+func (s *PruningIAVLStore) eta(latestVer int64) (time.Duration, bool) {
+	remaining := s.targetVersion(latestVer) - s.oldestVer + 1
+	if remaining <= 0 || s.oldestVer == 0 {
+		return 0, true
+	}
+	if s.versionsPruned == 0 {
+		return 0, false
+	}
+	elapsed := time.Since(s.startTime)
+	return time.Duration(float64(elapsed) / float64(s.versionsPruned) * float64(remaining)), true
+}
+
+// dirSize returns the total size of all the files in the given directory (and its sub-directories).
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
 func (s *PruningIAVLStore) deleteVersion(ver int64) error {
 	var err error
 	defer func(begin time.Time) {
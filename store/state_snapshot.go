@@ -0,0 +1,447 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	amino "github.com/tendermint/go-amino"
+	"github.com/tendermint/tendermint/crypto/tmhash"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+const (
+	// DefaultStateSnapshotChunkSize is the default number of IAVL tree nodes stored in each chunk
+	// of a state snapshot.
+	DefaultStateSnapshotChunkSize = 100000
+
+	stateSnapshotManifestFile = "manifest.json"
+	stateSnapshotFormat       = 1
+)
+
+// Prefixes used by the IAVL NodeDB to store tree nodes & roots in the underlying DB.
+var (
+	iavlNodeKeyPrefix = []byte("n")
+	iavlRootKeyPrefix = []byte("r")
+)
+
+// StateSnapshotChunk describes a single chunk of a state snapshot.
+type StateSnapshotChunk struct {
+	// Name of the chunk file, relative to the snapshot directory
+	File string `json:"file"`
+	// Hex-encoded SHA-256 hash of the chunk file
+	Hash string `json:"hash"`
+	// Number of tree nodes in the chunk
+	NumNodes int `json:"numNodes"`
+}
+
+// StateSnapshotManifest describes a state snapshot of the app store at a particular version.
+// The snapshot consists of the manifest and a set of chunk files, each chunk contains a subset of
+// the IAVL tree nodes reachable from the root of the tree at the snapshot version.
+type StateSnapshotManifest struct {
+	Format int `json:"format"`
+	// Version of the app store the snapshot was taken at, this matches the block height.
+	Version int64 `json:"version"`
+	// Hex-encoded root hash of the IAVL tree, which should match the AppHash returned by
+	// Application.Commit for the block at the snapshot height.
+	RootHash string               `json:"rootHash"`
+	Chunks   []StateSnapshotChunk `json:"chunks"`
+}
+
+// iavlNode contains the fields of an IAVL tree node as they're serialized to the NodeDB.
+type iavlNode struct {
+	height    int8
+	size      int64
+	version   int64
+	key       []byte
+	value     []byte
+	leftHash  []byte
+	rightHash []byte
+}
+
+func (n *iavlNode) isLeaf() bool {
+	return n.height == 0
+}
+
+// decodeIAVLNode decodes a node loaded from the NodeDB, if the leaf values are stored outside the
+// NodeDB getValue will be used to look up the value of any leaf node.
+func decodeIAVLNode(buf []byte, getValue func(key []byte) []byte) (*iavlNode, error) {
+	node := &iavlNode{}
+
+	height, n, err := amino.DecodeInt8(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode node height")
+	}
+	buf = buf[n:]
+	node.height = height
+
+	if node.size, n, err = amino.DecodeVarint(buf); err != nil {
+		return nil, errors.Wrap(err, "failed to decode node size")
+	}
+	buf = buf[n:]
+
+	if node.version, n, err = amino.DecodeVarint(buf); err != nil {
+		return nil, errors.Wrap(err, "failed to decode node version")
+	}
+	buf = buf[n:]
+
+	if node.key, n, err = amino.DecodeByteSlice(buf); err != nil {
+		return nil, errors.Wrap(err, "failed to decode node key")
+	}
+	buf = buf[n:]
+
+	if node.isLeaf() {
+		if len(buf) == 0 && getValue != nil {
+			node.value = getValue(node.key)
+			return node, nil
+		}
+		if node.value, _, err = amino.DecodeByteSlice(buf); err != nil {
+			return nil, errors.Wrap(err, "failed to decode node value")
+		}
+		return node, nil
+	}
+
+	if node.leftHash, n, err = amino.DecodeByteSlice(buf); err != nil {
+		return nil, errors.Wrap(err, "failed to decode left node hash")
+	}
+	buf = buf[n:]
+
+	if node.rightHash, _, err = amino.DecodeByteSlice(buf); err != nil {
+		return nil, errors.Wrap(err, "failed to decode right node hash")
+	}
+	return node, nil
+}
+
+// encode serializes the node in the same format the IAVL NodeDB uses, leaf values are always
+// stored inline.
+func (n *iavlNode) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := amino.EncodeInt8(&buf, n.height); err != nil {
+		return nil, err
+	}
+	if err := amino.EncodeVarint(&buf, n.size); err != nil {
+		return nil, err
+	}
+	if err := amino.EncodeVarint(&buf, n.version); err != nil {
+		return nil, err
+	}
+	if err := amino.EncodeByteSlice(&buf, n.key); err != nil {
+		return nil, err
+	}
+	if n.isLeaf() {
+		if err := amino.EncodeByteSlice(&buf, n.value); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := amino.EncodeByteSlice(&buf, n.leftHash); err != nil {
+			return nil, err
+		}
+		if err := amino.EncodeByteSlice(&buf, n.rightHash); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// hash computes the node hash the same way the IAVL tree does.
+func (n *iavlNode) hash() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := amino.EncodeInt8(&buf, n.height); err != nil {
+		return nil, err
+	}
+	if err := amino.EncodeVarint(&buf, n.size); err != nil {
+		return nil, err
+	}
+	if err := amino.EncodeVarint(&buf, n.version); err != nil {
+		return nil, err
+	}
+	if n.isLeaf() {
+		if err := amino.EncodeByteSlice(&buf, n.key); err != nil {
+			return nil, err
+		}
+		if err := amino.EncodeByteSlice(&buf, tmhash.Sum(n.value)); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := amino.EncodeByteSlice(&buf, n.leftHash); err != nil {
+			return nil, err
+		}
+		if err := amino.EncodeByteSlice(&buf, n.rightHash); err != nil {
+			return nil, err
+		}
+	}
+	return tmhash.Sum(buf.Bytes()), nil
+}
+
+func iavlNodeKey(hash []byte) []byte {
+	return append(append([]byte{}, iavlNodeKeyPrefix...), hash...)
+}
+
+func iavlRootKey(version int64) []byte {
+	key := make([]byte, len(iavlRootKeyPrefix)+8)
+	copy(key, iavlRootKeyPrefix)
+	binary.BigEndian.PutUint64(key[len(iavlRootKeyPrefix):], uint64(version))
+	return key
+}
+
+// stateSnapshotWriter splits the exported tree nodes into chunk files.
+type stateSnapshotWriter struct {
+	dir       string
+	chunkSize int
+	manifest  *StateSnapshotManifest
+	file      *os.File
+	writer    *bufio.Writer
+	hasher    hash.Hash
+	numNodes  int
+}
+
+func (w *stateSnapshotWriter) writeNode(nodeBytes []byte) error {
+	if w.file == nil {
+		if err := w.openChunk(); err != nil {
+			return err
+		}
+	}
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(lenBuf, uint64(len(nodeBytes)))
+	if _, err := w.writer.Write(lenBuf[:n]); err != nil {
+		return err
+	}
+	if _, err := w.writer.Write(nodeBytes); err != nil {
+		return err
+	}
+	w.numNodes++
+	if w.numNodes >= w.chunkSize {
+		return w.closeChunk()
+	}
+	return nil
+}
+
+func (w *stateSnapshotWriter) openChunk() error {
+	name := fmt.Sprintf("chunk-%06d.bin", len(w.manifest.Chunks))
+	file, err := os.Create(filepath.Join(w.dir, name))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create chunk file %s", name)
+	}
+	w.file = file
+	w.hasher = sha256.New()
+	w.writer = bufio.NewWriter(io.MultiWriter(file, w.hasher))
+	w.numNodes = 0
+	w.manifest.Chunks = append(w.manifest.Chunks, StateSnapshotChunk{
+		File: name,
+	})
+	return nil
+}
+
+func (w *stateSnapshotWriter) closeChunk() error {
+	if w.file == nil {
+		return nil
+	}
+	if err := w.writer.Flush(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	chunk := &w.manifest.Chunks[len(w.manifest.Chunks)-1]
+	chunk.Hash = hex.EncodeToString(w.hasher.Sum(nil))
+	chunk.NumNodes = w.numNodes
+	w.file = nil
+	return nil
+}
+
+// exportStateSnapshot writes out all the IAVL tree nodes reachable from the root of the tree at
+// the given version to a set of chunk files in the given directory.
+func exportStateSnapshot(
+	nodeDB dbm.DB, getValue func(key []byte) []byte, version int64, dir string, chunkSize int,
+) (*StateSnapshotManifest, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultStateSnapshotChunkSize
+	}
+
+	rootHash := nodeDB.Get(iavlRootKey(version))
+	if rootHash == nil {
+		return nil, fmt.Errorf("tree version %d doesn't exist", version)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create snapshot dir %s", dir)
+	}
+
+	manifest := &StateSnapshotManifest{
+		Format:   stateSnapshotFormat,
+		Version:  version,
+		RootHash: hex.EncodeToString(rootHash),
+		Chunks:   []StateSnapshotChunk{},
+	}
+	w := &stateSnapshotWriter{
+		dir:       dir,
+		chunkSize: chunkSize,
+		manifest:  manifest,
+	}
+
+	var walk func(hash []byte) error
+	walk = func(hash []byte) error {
+		buf := nodeDB.Get(iavlNodeKey(hash))
+		if buf == nil {
+			return fmt.Errorf("node %X not found", hash)
+		}
+		node, err := decodeIAVLNode(buf, getValue)
+		if err != nil {
+			return errors.Wrapf(err, "failed to decode node %X", hash)
+		}
+		nodeBytes, err := node.encode()
+		if err != nil {
+			return err
+		}
+		if err := w.writeNode(nodeBytes); err != nil {
+			return err
+		}
+		if node.isLeaf() {
+			return nil
+		}
+		if err := walk(node.leftHash); err != nil {
+			return err
+		}
+		return walk(node.rightHash)
+	}
+
+	if len(rootHash) > 0 {
+		if err := walk(rootHash); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.closeChunk(); err != nil {
+		return nil, err
+	}
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, stateSnapshotManifestFile), manifestBytes, 0644); err != nil {
+		return nil, errors.Wrap(err, "failed to write snapshot manifest")
+	}
+	return manifest, nil
+}
+
+// ReadStateSnapshotManifest loads the manifest of the state snapshot in the given directory.
+func ReadStateSnapshotManifest(dir string) (*StateSnapshotManifest, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(dir, stateSnapshotManifestFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read snapshot manifest")
+	}
+	var manifest StateSnapshotManifest
+	if err := json.Unmarshal(buf, &manifest); err != nil {
+		return nil, errors.Wrap(err, "failed to parse snapshot manifest")
+	}
+	if manifest.Format != stateSnapshotFormat {
+		return nil, fmt.Errorf("unsupported snapshot format %d", manifest.Format)
+	}
+	return &manifest, nil
+}
+
+// ImportStateSnapshot loads the state snapshot in the given directory into an empty app.db.
+// The hash of every chunk is checked against the manifest, the hash of every tree node is
+// recomputed from its contents, and the root hash of the resulting tree is checked against
+// trustedAppHash, which should be the AppHash committed to the chain for the snapshot height.
+// Nothing is written to the DB unless the snapshot is found to be valid and complete.
+func ImportStateSnapshot(nodeDB dbm.DB, dir string, trustedAppHash []byte) (*StateSnapshotManifest, error) {
+	manifest, err := ReadStateSnapshotManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	rootHash, err := hex.DecodeString(manifest.RootHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid root hash in snapshot manifest")
+	}
+	if !bytes.Equal(rootHash, trustedAppHash) {
+		return nil, fmt.Errorf(
+			"snapshot root hash %X doesn't match app hash %X", rootHash, trustedAppHash,
+		)
+	}
+
+	it := dbm.IteratePrefix(nodeDB, iavlRootKeyPrefix)
+	hasRoots := it.Valid()
+	it.Close()
+	if hasRoots {
+		return nil, errors.New("can't import snapshot into a non-empty DB")
+	}
+
+	batch := nodeDB.NewBatch()
+	seen := map[string]bool{}
+	referenced := map[string]bool{}
+
+	for _, chunk := range manifest.Chunks {
+		buf, err := ioutil.ReadFile(filepath.Join(dir, chunk.File))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read chunk %s", chunk.File)
+		}
+		chunkHash := sha256.Sum256(buf)
+		if hex.EncodeToString(chunkHash[:]) != chunk.Hash {
+			return nil, fmt.Errorf("hash mismatch in chunk %s", chunk.File)
+		}
+
+		numNodes := 0
+		for len(buf) > 0 {
+			size, n := binary.Uvarint(buf)
+			if n <= 0 || uint64(len(buf)-n) < size {
+				return nil, fmt.Errorf("malformed chunk %s", chunk.File)
+			}
+			nodeBytes := buf[n : n+int(size)]
+			buf = buf[n+int(size):]
+
+			node, err := decodeIAVLNode(nodeBytes, nil)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to decode node in chunk %s", chunk.File)
+			}
+			hash, err := node.hash()
+			if err != nil {
+				return nil, err
+			}
+			if !node.isLeaf() {
+				referenced[string(node.leftHash)] = true
+				referenced[string(node.rightHash)] = true
+			}
+			seen[string(hash)] = true
+			batch.Set(iavlNodeKey(hash), nodeBytes)
+			numNodes++
+		}
+		if numNodes != chunk.NumNodes {
+			return nil, fmt.Errorf(
+				"chunk %s contains %d nodes, expected %d", chunk.File, numNodes, chunk.NumNodes,
+			)
+		}
+	}
+
+	if len(rootHash) > 0 && !seen[string(rootHash)] {
+		return nil, errors.New("snapshot doesn't contain the root node")
+	}
+	for hash := range referenced {
+		if !seen[hash] {
+			return nil, fmt.Errorf("snapshot is missing node %X", []byte(hash))
+		}
+	}
+
+	batch.Set(iavlRootKey(manifest.Version), rootHash)
+	batch.Write()
+	return manifest, nil
+}
+
+// StateSnapshotExporter is implemented by app stores that can export state snapshots.
+type StateSnapshotExporter interface {
+	ExportSnapshot(version int64, dir string, chunkSize int) (*StateSnapshotManifest, error)
+}
+
+var _ StateSnapshotExporter = &IAVLStore{}
+var _ StateSnapshotExporter = &MultiReaderIAVLStore{}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+func TestStateSnapshotExportImport(t *testing.T) {
+	srcDB := dbm.NewMemDB()
+	srcStore, err := NewIAVLStore(srcDB, 0, 0)
+	require.NoError(t, err)
+
+	for v := 0; v < 5; v++ {
+		for i := 0; i < 50; i++ {
+			srcStore.Set([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d-%d", v, i)))
+		}
+		srcStore.Delete([]byte(fmt.Sprintf("key%d", v)))
+		_, _, err = srcStore.SaveVersion()
+		require.NoError(t, err)
+	}
+
+	dir, err := ioutil.TempDir("", "state-snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// chunk size is deliberately small to force the export to span multiple chunks
+	manifest, err := srcStore.ExportSnapshot(srcStore.Version(), dir, 16)
+	require.NoError(t, err)
+	require.Equal(t, srcStore.Version(), manifest.Version)
+	require.True(t, len(manifest.Chunks) > 1)
+
+	// import must be rejected if the root hash doesn't match the trusted app hash
+	_, err = ImportStateSnapshot(dbm.NewMemDB(), dir, []byte("bad hash"))
+	require.Error(t, err)
+
+	destDB := dbm.NewMemDB()
+	_, err = ImportStateSnapshot(destDB, dir, srcStore.Hash())
+	require.NoError(t, err)
+
+	destStore, err := NewIAVLStore(destDB, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, srcStore.Version(), destStore.Version())
+	require.Equal(t, srcStore.Hash(), destStore.Hash())
+	require.Equal(t, srcStore.Range(nil), destStore.Range(nil))
+
+	// importing into a non-empty DB must fail
+	_, err = ImportStateSnapshot(destDB, dir, srcStore.Hash())
+	require.Error(t, err)
+
+	// the new tree must be usable for subsequent versions
+	destStore.Set([]byte("key0"), []byte("newvalue"))
+	srcStore.Set([]byte("key0"), []byte("newvalue"))
+	_, _, err = destStore.SaveVersion()
+	require.NoError(t, err)
+	_, _, err = srcStore.SaveVersion()
+	require.NoError(t, err)
+	require.Equal(t, srcStore.Hash(), destStore.Hash())
+}
+
+func TestStateSnapshotImportCorruptChunk(t *testing.T) {
+	srcStore, err := NewIAVLStore(dbm.NewMemDB(), 0, 0)
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		srcStore.Set([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i)))
+	}
+	_, _, err = srcStore.SaveVersion()
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "state-snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	manifest, err := srcStore.ExportSnapshot(srcStore.Version(), dir, 8)
+	require.NoError(t, err)
+
+	chunkPath := filepath.Join(dir, manifest.Chunks[0].File)
+	chunk, err := ioutil.ReadFile(chunkPath)
+	require.NoError(t, err)
+	chunk[len(chunk)-1] ^= 0xFF
+	require.NoError(t, ioutil.WriteFile(chunkPath, chunk, 0644))
+
+	destDB := dbm.NewMemDB()
+	_, err = ImportStateSnapshot(destDB, dir, srcStore.Hash())
+	require.Error(t, err)
+	// nothing should've been written to the DB
+	_, err = NewIAVLStore(destDB, 0, 0)
+	require.NoError(t, err)
+	it := destDB.Iterator(nil, nil)
+	require.False(t, it.Valid())
+	it.Close()
+}
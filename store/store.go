@@ -56,6 +56,14 @@ type VersionedKVStore interface {
 	GetSnapshot() Snapshot
 }
 
+// HistoricalStore is implemented by versioned stores that can provide read-only snapshots of
+// previously saved versions.
+type HistoricalStore interface {
+	// GetSnapshotAt returns a read-only snapshot of the store at the given version, an error will be
+	// returned if the version hasn't been saved yet, or if it has already been pruned.
+	GetSnapshotAt(version int64) (Snapshot, error)
+}
+
 type cacheItem struct {
 	Value   []byte
 	Deleted bool
@@ -708,6 +708,65 @@ func TestPruningIAVLStoreKeepsAllVersionsIfMaxVersionsIsZero(t *testing.T) {
 	require.Equal(t, uint64(0), store.batchCount)
 }
 
+func TestPruningIAVLStoreThrottling(t *testing.T) {
+	cfg := PruningIAVLStoreConfig{
+		MaxVersions:         2,
+		BatchSize:           8,
+		Interval:            1 * time.Hour,
+		TargetCommitLatency: 100 * time.Millisecond,
+	}
+	store, err := NewPruningIAVLStore(dbm.NewMemDB(), cfg)
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		store.Set(key1, []byte{byte(i)})
+		_, _, err := store.SaveVersion()
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(1), store.oldestVer)
+
+	// batch size should be halved on each cycle while the commit latency is too high
+	for _, expected := range []struct {
+		batchSize int64
+		oldestVer int64
+	}{
+		{batchSize: 4, oldestVer: 6},
+		{batchSize: 2, oldestVer: 9},
+		{batchSize: 1, oldestVer: 11},
+	} {
+		store.avgCommitLatency = 200 * time.Millisecond
+		require.NoError(t, store.prune())
+		require.Equal(t, expected.batchSize, store.curBatchSize)
+		require.Equal(t, expected.oldestVer, store.oldestVer)
+		require.False(t, store.throttled)
+	}
+
+	// pruning should be paused once the batch size can't be reduced any further
+	require.NoError(t, store.prune())
+	require.Equal(t, int64(11), store.oldestVer)
+	require.True(t, store.throttled)
+
+	status, err := store.PruningStatus()
+	require.NoError(t, err)
+	require.True(t, status.Throttled)
+	require.Equal(t, int64(200), status.AvgCommitLatencyMs)
+
+	// and resumed with a bigger batch size once the commit latency drops
+	store.avgCommitLatency = 10 * time.Millisecond
+	require.NoError(t, store.prune())
+	require.Equal(t, int64(2), store.curBatchSize)
+	require.Equal(t, int64(14), store.oldestVer)
+	require.False(t, store.throttled)
+
+	status, err = store.PruningStatus()
+	require.NoError(t, err)
+	require.Equal(t, int64(14), status.OldestVersion)
+	require.Equal(t, int64(20), status.LatestVersion)
+	require.Equal(t, int64(18), status.TargetVersion)
+	require.Equal(t, uint64(13), status.VersionsPruned)
+	require.True(t, status.ETASeconds >= 0)
+}
+
 func TestIAVLStoreKeepsAllVersionsIfMaxVersionsIsZero(t *testing.T) {
 	store, err := NewIAVLStore(dbm.NewMemDB(), 0, 0)
 	require.NoError(t, err)
@@ -793,3 +852,38 @@ func TestSwitchFromIAVLStoreToPruningIAVLStore(t *testing.T) {
 
 	require.Equal(t, (store3.Version()-cfg.MaxVersions)+1, store3.oldestVer)
 }
+
+func TestIAVLStoreGetSnapshotAt(t *testing.T) {
+	store, err := NewIAVLStore(dbm.NewMemDB(), 3, 0)
+	require.NoError(t, err)
+
+	values := [][]byte{val1, val2, val3, val1, val2}
+	for _, val := range values {
+		store.Set(key1, val)
+		_, _, err := store.SaveVersion()
+		require.NoError(t, err)
+		require.NoError(t, store.Prune())
+	}
+	require.Equal(t, int64(5), store.Version())
+
+	for ver := int64(3); ver <= 5; ver++ {
+		snap, err := store.GetSnapshotAt(ver)
+		require.NoError(t, err)
+		require.Equal(t, values[ver-1], snap.Get(key1))
+		require.True(t, snap.Has(key1))
+		snap.Release()
+	}
+
+	// uncommitted changes shouldn't be visible in snapshots
+	store.Set(key1, val3)
+	snap, err := store.GetSnapshotAt(5)
+	require.NoError(t, err)
+	require.Equal(t, val2, snap.Get(key1))
+
+	_, err = store.GetSnapshotAt(2)
+	require.EqualError(t, err, "state at height 2 has been pruned, oldest available height is 3")
+	_, err = store.GetSnapshotAt(6)
+	require.Error(t, err)
+	_, err = store.GetSnapshotAt(0)
+	require.Error(t, err)
+}
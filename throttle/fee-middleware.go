@@ -0,0 +1,220 @@
+package throttle
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/go-diadem/plugin/contractpb"
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/auth"
+	"github.com/diademnetwork/diademchain/builtin/plugins/coin"
+	"github.com/diademnetwork/diademchain/evm"
+	"github.com/diademnetwork/diademchain/vm"
+	"github.com/pkg/errors"
+)
+
+// TxFeeConfig determines the fees charged for txs, all fees are denominated in the smallest unit
+// of DiademCoin (10^-18 DIADEM).
+type TxFeeConfig struct {
+	Enabled bool
+	// Flat fees charged for deploy txs, call txs, and all other types of txs
+	DeployTxFee uint64
+	CallTxFee   uint64
+	OtherTxFee  uint64
+	// Fee charged per byte of the tx payload, EVM txs are charged for the gas they use instead
+	FeePerByte uint64
+	// Fee charged per unit of gas used by EVM txs
+	GasPrice uint64
+}
+
+func DefaultTxFeeConfig() *TxFeeConfig {
+	return &TxFeeConfig{
+		Enabled: false,
+	}
+}
+
+// Clone returns a deep clone of the config.
+func (c *TxFeeConfig) Clone() *TxFeeConfig {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	return &clone
+}
+
+// NewTxFeeMiddleware returns middleware that charges the fee for each tx in DiademCoin, and
+// transfers the collected fees to the fee collector. Fees are only charged once the tx:fees
+// feature is enabled.
+//
+// The fee collector is currently the DPOS contract, the collected fees accumulate in its DiademCoin
+// balance, they're not added to the reward pool or otherwise distributed yet.
+//
+// The fee is paid by the origin of the tx, unless another account agreed to pay it (see
+// auth.FeePayerTxMiddleware), in which case the tx is rejected if its fee could exceed the max fee
+// the fee payer agreed to. Flat & payload size based fees are charged before the tx is executed,
+// gas based fees can only be charged once an EVM tx has been executed, so they're not charged
+// in CheckTx (since EVM txs aren't executed in CheckTx).
+//
+// Txs that fail don't change the state, so they aren't charged any fees. To prevent txs that are
+// bound to fail from being sent for free, txs are rejected up front unless the payer can cover the
+// max fee they can be charged, which for Ethereum txs includes the fee for their gas limit.
+func NewTxFeeMiddleware(
+	cfg *TxFeeConfig,
+	createCoinCtx func(state diademchain.State) (contractpb.Context, error),
+	getFeeCollector func(state diademchain.State) (diadem.Address, error),
+) diademchain.TxMiddlewareFunc {
+	return diademchain.TxMiddlewareFunc(func(
+		state diademchain.State,
+		txBytes []byte,
+		next diademchain.TxHandlerFunc,
+		isCheckTx bool,
+	) (res diademchain.TxHandlerResult, err error) {
+		if !state.FeatureEnabled(diademchain.TxFeesFeature, false) {
+			return next(state, txBytes, isCheckTx)
+		}
+
+		payer := auth.FeePayer(state.Context())
+		if payer.IsEmpty() {
+			payer = auth.Origin(state.Context())
+		}
+		if payer.IsEmpty() {
+			return res, errors.New("throttle: transaction has no origin [tx-fee]")
+		}
+
+		var nonceTx auth.NonceTx
+		if err := proto.Unmarshal(txBytes, &nonceTx); err != nil {
+			return res, errors.Wrap(err, "throttle: unwrap nonce Tx")
+		}
+
+		var tx diademchain.Transaction
+		if err := proto.Unmarshal(nonceTx.Inner, &tx); err != nil {
+			return res, errors.New("throttle: unmarshal tx")
+		}
+
+		isEVMTx, err := isEVMTx(&tx)
+		if err != nil {
+			return res, err
+		}
+
+		// Ethereum txs must be signed with a gas price that covers the price gas is charged at
+		ethTx := auth.EthTxFromContext(state.Context())
+		if ethTx != nil && ethTx.GasPrice != nil {
+			if ethTx.GasPrice.Cmp(new(big.Int).SetUint64(cfg.GasPrice)) < 0 {
+				return res, errors.Errorf("tx gas price %v is lower than the gas price %v", ethTx.GasPrice, cfg.GasPrice)
+			}
+		}
+
+		chargeFee := func(fee *big.Int) error {
+			coinCtx, err := createCoinCtx(state)
+			if err != nil {
+				return errors.Wrap(err, "failed to create DiademCoin contract context")
+			}
+			collector, err := getFeeCollector(state)
+			if err != nil {
+				return errors.Wrap(err, "failed to resolve fee collector")
+			}
+			return coin.ChargeFee(coinCtx, payer, collector, &diadem.BigUInt{Int: fee})
+		}
+
+		fee := new(big.Int).SetUint64(txFee(cfg, &tx, isEVMTx))
+		maxFee := new(big.Int).Set(fee)
+		if ethTx != nil && isEVMTx {
+			maxFee.Add(maxFee, new(big.Int).Mul(
+				new(big.Int).SetUint64(ethTx.GasLimit),
+				new(big.Int).SetUint64(cfg.GasPrice),
+			))
+		}
+		payerMaxFee, hasFeePayer := auth.FeePayerMaxFee(state.Context())
+		if hasFeePayer && maxFee.Cmp(new(big.Int).SetUint64(payerMaxFee)) > 0 {
+			return res, errors.Errorf("tx fee %v exceeds the max fee %v agreed to by the fee payer", maxFee, payerMaxFee)
+		}
+		if maxFee.Sign() > 0 {
+			coinCtx, err := createCoinCtx(state)
+			if err != nil {
+				return res, errors.Wrap(err, "failed to create DiademCoin contract context")
+			}
+			balance, err := coin.BalanceOf(coinCtx, payer)
+			if err != nil {
+				return res, err
+			}
+			if balance.Cmp(&diadem.BigUInt{Int: maxFee}) < 0 {
+				return res, errors.Errorf("balance of %s is too low to pay max fee %v", payer.String(), maxFee)
+			}
+		}
+
+		if fee.Sign() > 0 {
+			if err := chargeFee(fee); err != nil {
+				return res, err
+			}
+		}
+
+		if !isEVMTx || isCheckTx || cfg.GasPrice == 0 {
+			return next(state, txBytes, isCheckTx)
+		}
+
+		gasMeter := &evm.GasMeter{}
+		ctx := context.WithValue(state.Context(), evm.ContextKeyGasMeter, gasMeter)
+		r, err := next(state.WithContext(ctx), txBytes, isCheckTx)
+		if err != nil {
+			return r, err
+		}
+		if gasMeter.Used() > 0 {
+			gasFee := new(big.Int).Mul(
+				new(big.Int).SetUint64(gasMeter.Used()),
+				new(big.Int).SetUint64(cfg.GasPrice),
+			)
+			// the gas used by txs that don't have a gas limit is only known after they're executed
+			if totalFee := new(big.Int).Add(fee, gasFee); hasFeePayer && totalFee.Cmp(new(big.Int).SetUint64(payerMaxFee)) > 0 {
+				return r, errors.Errorf("tx fee %v exceeds the max fee %v agreed to by the fee payer", totalFee, payerMaxFee)
+			}
+			if err := chargeFee(gasFee); err != nil {
+				return r, err
+			}
+		}
+		return r, nil
+	})
+}
+
+// txFee returns the fee charged for the given tx before it's executed.
+func txFee(cfg *TxFeeConfig, tx *diademchain.Transaction, isEVMTx bool) uint64 {
+	var fee uint64
+	switch tx.Id {
+	case deployId:
+		fee = cfg.DeployTxFee
+	case callId:
+		fee = cfg.CallTxFee
+	default:
+		fee = cfg.OtherTxFee
+	}
+	if !isEVMTx {
+		fee += cfg.FeePerByte * uint64(len(tx.Data))
+	}
+	return fee
+}
+
+func isEVMTx(tx *diademchain.Transaction) (bool, error) {
+	if tx.Id != deployId && tx.Id != callId {
+		return false, nil
+	}
+
+	var msg vm.MessageTx
+	if err := proto.Unmarshal(tx.Data, &msg); err != nil {
+		return false, errors.Wrapf(err, "unmarshal message tx %v", tx.Data)
+	}
+
+	if tx.Id == deployId {
+		var deployTx vm.DeployTx
+		if err := proto.Unmarshal(msg.Data, &deployTx); err != nil {
+			return false, errors.Wrapf(err, "unmarshal deploy tx %v", msg.Data)
+		}
+		return deployTx.VmType == vm.VMType_EVM, nil
+	}
+
+	var callTx vm.CallTx
+	if err := proto.Unmarshal(msg.Data, &callTx); err != nil {
+		return false, errors.Wrapf(err, "unmarshal call tx %v", msg.Data)
+	}
+	return callTx.VmType == vm.VMType_EVM, nil
+}
@@ -0,0 +1,124 @@
+package throttle
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/diademnetwork/go-diadem"
+	godiademplugin "github.com/diademnetwork/go-diadem/plugin"
+	"github.com/diademnetwork/go-diadem/plugin/contractpb"
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/auth"
+	"github.com/diademnetwork/diademchain/builtin/plugins/coin"
+	"github.com/diademnetwork/diademchain/evm"
+	"github.com/diademnetwork/diademchain/store"
+	"github.com/diademnetwork/diademchain/vm"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+func TestTxFeeMiddleware(t *testing.T) {
+	state := diademchain.NewStoreState(context.Background(), store.NewMemStore(), abci.Header{}, nil, nil)
+
+	fakeCtx := godiademplugin.CreateFakeContext(addr1, addr1)
+	coinAddr := fakeCtx.CreateContract(coin.Contract)
+	coinCtx := contractpb.WrapPluginContext(fakeCtx.WithAddress(coinAddr))
+	coinContract := &coin.Coin{}
+	require.NoError(t, coinContract.Init(coinCtx, &coin.InitRequest{
+		Accounts: []*coin.InitialAccount{
+			{Owner: origin.MarshalPB(), Balance: 1},
+		},
+	}))
+
+	feeCollector := diadem.MustParseAddress("chain:0x3a4aF42a17AAD6Dbc6d21c162989d0f701074055")
+	sponsor := diadem.MustParseAddress("chain:0x7fe2ff7961e1f4c684e297be3edf03b825e01c4d")
+
+	cfg := &TxFeeConfig{
+		Enabled:     true,
+		DeployTxFee: 1000,
+		CallTxFee:   100,
+		FeePerByte:  1,
+		GasPrice:    2,
+	}
+	tmx := NewTxFeeMiddleware(
+		cfg,
+		func(state diademchain.State) (contractpb.Context, error) {
+			return coinCtx, nil
+		},
+		func(state diademchain.State) (diadem.Address, error) {
+			return feeCollector, nil
+		},
+	)
+	next := func(state diademchain.State, txBytes []byte, isCheckTx bool) (diademchain.TxHandlerResult, error) {
+		if gasMeter := evm.GasMeterFromContext(state.Context()); gasMeter != nil {
+			gasMeter.Consume(50)
+		}
+		return diademchain.TxHandlerResult{}, nil
+	}
+	balanceOf := func(addr diadem.Address) int64 {
+		resp, err := coinContract.BalanceOf(coinCtx, &coin.BalanceOfRequest{Owner: addr.MarshalPB()})
+		require.NoError(t, err)
+		return resp.Balance.Value.Int64()
+	}
+
+	ctx := context.WithValue(state.Context(), auth.ContextKeyOrigin, origin)
+
+	// No fees should be charged until the feature is enabled
+	txSigned := mockSignedTx(t, uint64(1), callId, vm.VMType_EVM, contract)
+	_, err := tmx.ProcessTx(state.WithContext(ctx), txSigned.Inner, next, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), balanceOf(feeCollector))
+	state.SetFeature(diademchain.TxFeesFeature, true)
+
+	// Only the flat fee should be charged for EVM txs in CheckTx, since they're not executed
+	_, err = tmx.ProcessTx(state.WithContext(ctx), txSigned.Inner, next, true)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), balanceOf(feeCollector))
+
+	// The flat fee + the fee for the gas used should be charged in DeliverTx
+	_, err = tmx.ProcessTx(state.WithContext(ctx), txSigned.Inner, next, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(100+100+50*2), balanceOf(feeCollector))
+
+	// Non-EVM txs should be charged for the size of the payload instead of gas
+	txSigned = mockSignedTx(t, uint64(2), deployId, vm.VMType_PLUGIN, contract)
+	_, err = tmx.ProcessTx(state.WithContext(ctx), txSigned.Inner, next, false)
+	require.NoError(t, err)
+	require.True(t, balanceOf(feeCollector) > int64(100+100+50*2+1000))
+
+	// Fees should be charged to the fee payer instead of the origin, if there is one
+	collected := balanceOf(feeCollector)
+	sponsorCtx := context.WithValue(ctx, auth.ContextKeyFeePayer, sponsor)
+	txSigned = mockSignedTx(t, uint64(3), callId, vm.VMType_EVM, contract)
+	_, err = tmx.ProcessTx(state.WithContext(sponsorCtx), txSigned.Inner, next, false)
+	require.Error(t, err)
+	require.Equal(t, collected, balanceOf(feeCollector))
+
+	require.NoError(t, coin.ChargeFee(coinCtx, origin, sponsor, diadem.NewBigUIntFromInt(1000)))
+	_, err = tmx.ProcessTx(state.WithContext(sponsorCtx), txSigned.Inner, next, false)
+	require.NoError(t, err)
+	require.Equal(t, collected+100+50*2, balanceOf(feeCollector))
+	require.Equal(t, int64(1000-100-50*2), balanceOf(sponsor))
+
+	// Ethereum txs should be rejected up front if the payer can't cover the fee for their gas limit
+	collected = balanceOf(feeCollector)
+	ethTxCtx := context.WithValue(sponsorCtx, auth.ContextKeyEthTx, &auth.EthTxInfo{
+		GasLimit: 1000,
+		GasPrice: big.NewInt(2),
+	})
+	_, err = tmx.ProcessTx(state.WithContext(ethTxCtx), txSigned.Inner, next, true)
+	require.Error(t, err)
+	require.Equal(t, collected, balanceOf(feeCollector))
+
+	// The fee payer should never be charged more than the max fee it agreed to pay
+	maxFeeCtx := context.WithValue(sponsorCtx, auth.ContextKeyFeePayerMaxFee, uint64(50))
+	_, err = tmx.ProcessTx(state.WithContext(maxFeeCtx), txSigned.Inner, next, false)
+	require.Error(t, err)
+	require.Equal(t, collected, balanceOf(feeCollector))
+
+	// The gas fee of txs without a gas limit only exceeds the max fee once the tx is executed
+	maxFeeCtx = context.WithValue(sponsorCtx, auth.ContextKeyFeePayerMaxFee, uint64(150))
+	_, err = tmx.ProcessTx(state.WithContext(maxFeeCtx), txSigned.Inner, next, false)
+	require.Error(t, err)
+}
@@ -4,7 +4,10 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/diademnetwork/go-diadem"
 	"github.com/diademnetwork/go-diadem/auth"
+	"github.com/diademnetwork/go-diadem/plugin/contractpb"
 	"github.com/diademnetwork/go-diadem/types"
+	"github.com/diademnetwork/go-diadem/vm"
+	"github.com/diademnetwork/diademchain"
 	lauth "github.com/diademnetwork/diademchain/auth"
 	"github.com/pkg/errors"
 )
@@ -56,35 +59,77 @@ type OriginValidator struct {
 	allowedDeployers []diadem.Address
 	deployValidation bool
 	callValidation   bool
+	// Used to determine the sender of RLP encoded Ethereum txs, if nil such txs are rejected.
+	authCfg *lauth.Config
+	// Used to look up the account the sender of an RLP encoded Ethereum tx is mapped to.
+	createAddressMapperCtx func(state diademchain.State) (contractpb.Context, error)
 }
 
-func NewOriginValidator(period uint64, allowedDeployers []diadem.Address, deployValidation, callValidation bool) OriginValidator {
+func NewOriginValidator(
+	period uint64, allowedDeployers []diadem.Address, deployValidation, callValidation bool,
+	authCfg *lauth.Config, createAddressMapperCtx func(state diademchain.State) (contractpb.Context, error),
+) OriginValidator {
 	dv := OriginValidator{
 		period:           period,
 		alreadyCalled:    make([][]callTx, period),
 		allowedDeployers: allowedDeployers,
 		deployValidation: deployValidation,
 		callValidation:   callValidation,
+		authCfg:          authCfg,
+
+		createAddressMapperCtx: createAddressMapperCtx,
 	}
 	return dv
 }
 
-func (dv *OriginValidator) ValidateOrigin(txBytes []byte, chainId string, currentBlockHeight int64) error {
+func (dv *OriginValidator) ValidateOrigin(state diademchain.State, txBytes []byte) error {
 	if !dv.deployValidation && !dv.callValidation {
 		return nil
 	}
 
-	var txSigned auth.SignedTx
-	if err := proto.Unmarshal(txBytes, &txSigned); err != nil {
-		return err
-	}
-	origin, err := lauth.GetOrigin(txSigned, chainId)
-	if err != nil {
-		return err
+	chainId := state.Block().ChainID
+	currentBlockHeight := state.Block().Height
+
+	var origin diadem.Address
+	var nonceTxBytes []byte
+	isEthTx := lauth.IsEthRLPTx(txBytes)
+	if isEthTx {
+		if dv.authCfg == nil {
+			return errors.New("Ethereum txs are not supported")
+		}
+		// the sender is recovered from the signature of the tx
+		var err error
+		nonceTxBytes, err = lauth.UnwrapTx(txBytes, chainId, dv.authCfg)
+		if err != nil {
+			return err
+		}
+	} else {
+		var txSigned auth.SignedTx
+		if err := proto.Unmarshal(txBytes, &txSigned); err != nil {
+			return err
+		}
+		// txs signed by a rotated key are attributed to the account the key controls
+		var err error
+		origin, err = lauth.ResolveOrigin(state, txSigned)
+		if err != nil {
+			return err
+		}
+		// txs signed by a session key are attributed to the account that granted it, whether the
+		// session key can be used to sign the tx is checked by the signature verification middleware
+		if state.FeatureEnabled(diademchain.AuthSessionKeysFeature, false) {
+			sessionKey, err := lauth.GetSessionKey(state, txSigned.PublicKey)
+			if err != nil {
+				return err
+			}
+			if sessionKey != nil {
+				origin = diadem.UnmarshalAddressPB(sessionKey.Owner)
+			}
+		}
+		nonceTxBytes = txSigned.Inner
 	}
 
 	var txNonce auth.NonceTx
-	if err := proto.Unmarshal(txSigned.Inner, &txNonce); err != nil {
+	if err := proto.Unmarshal(nonceTxBytes, &txNonce); err != nil {
 		return err
 	}
 
@@ -93,11 +138,30 @@ func (dv *OriginValidator) ValidateOrigin(txBytes []byte, chainId string, curren
 		return err
 	}
 
+	if isEthTx {
+		var msg vm.MessageTx
+		if err := proto.Unmarshal(txTransaction.Data, &msg); err != nil {
+			return err
+		}
+		// the sender is mapped to an account on this chain the same way the signature
+		// verification middleware maps it
+		var err error
+		origin, err = lauth.ResolveAccountAddress(
+			diadem.UnmarshalAddressPB(msg.From), state, dv.authCfg, dv.createAddressMapperCtx,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
 	switch txTransaction.Id {
 	case callId:
 		return dv.validateCaller(origin, txNonce.Sequence, uint64(currentBlockHeight))
 	case deployId:
 		return dv.validateDeployer(origin)
+	case rotateKeyId, grantSessionKeyId, revokeSessionKeyId:
+		// key rotation, session key grants & revocations aren't subject to the deploy & call limits
+		return nil
 	default:
 		return errors.Errorf("unrecognised transaction id %v", txTransaction.Id)
 	}
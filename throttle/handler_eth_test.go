@@ -0,0 +1,92 @@
+// +build evm
+
+package throttle
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	etypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/diademnetwork/go-diadem"
+	amtypes "github.com/diademnetwork/go-diadem/builtin/types/address_mapper"
+	godiademplugin "github.com/diademnetwork/go-diadem/plugin"
+	"github.com/diademnetwork/go-diadem/plugin/contractpb"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/auth"
+	"github.com/diademnetwork/diademchain/builtin/plugins/address_mapper"
+	"github.com/diademnetwork/diademchain/store"
+)
+
+func TestOriginValidatorEthRLPTx(t *testing.T) {
+	ethKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	ethAddr := diadem.Address{ChainID: "eth", Local: crypto.PubkeyToAddress(ethKey.PublicKey).Bytes()}
+
+	deployTx, err := etypes.SignTx(
+		etypes.NewContractCreation(0, big.NewInt(0), 100000, big.NewInt(0), []byte("code")),
+		etypes.NewEIP155Signer(auth.EthChainID("default")), ethKey,
+	)
+	require.NoError(t, err)
+	deployTxBytes, err := rlp.EncodeToBytes(deployTx)
+	require.NoError(t, err)
+
+	callTx, err := etypes.SignTx(
+		etypes.NewTransaction(1, common.BytesToAddress(addr2.Local), big.NewInt(0), 100000, big.NewInt(0), nil),
+		etypes.NewEIP155Signer(auth.EthChainID("default")), ethKey,
+	)
+	require.NoError(t, err)
+	callTxBytes, err := rlp.EncodeToBytes(callTx)
+	require.NoError(t, err)
+
+	authCfg := &auth.Config{
+		Chains: map[string]auth.ChainConfig{
+			"default": {TxType: auth.DiademSignedTxType, AccountType: auth.NativeAccountType},
+			"eth":     {TxType: auth.EthereumSignedTxType, AccountType: auth.MappedAccountType},
+		},
+	}
+
+	state := diademchain.NewStoreState(nil, store.NewMemStore(), abci.Header{ChainID: "default", Height: 1}, nil, nil)
+	state.SetFeature(diademchain.AuthSigTxFeaturePrefix+"default", true)
+	state.SetFeature(diademchain.AuthSigTxFeaturePrefix+"eth", true)
+
+	mappedAddr := diadem.MustParseAddress("default:0x5cecd1f7261e1f4c684e297be3edf03b825e01c4")
+	fakeCtx := godiademplugin.CreateFakeContext(mappedAddr, mappedAddr)
+	amAddr := fakeCtx.CreateContract(address_mapper.Contract)
+	amCtx := contractpb.WrapPluginContext(fakeCtx.WithAddress(amAddr))
+	createAddressMapperCtx := func(state diademchain.State) (contractpb.Context, error) {
+		return amCtx, nil
+	}
+	am := &address_mapper.AddressMapper{}
+	require.NoError(t, am.Init(amCtx, &address_mapper.InitRequest{}))
+
+	// Ethereum txs can't be validated without the auth config
+	handler := NewOriginValidator(period, allowedDeplolyers, true, true, nil, nil)
+	require.Error(t, handler.ValidateOrigin(state, callTxBytes))
+
+	// the sender of the tx is recovered from the signature, and must be mapped to an account
+	handler = NewOriginValidator(period, append(allowedDeplolyers, ethAddr), true, true, authCfg, createAddressMapperCtx)
+	handler.Reset(1)
+	require.Error(t, handler.ValidateOrigin(state, callTxBytes))
+
+	sig, err := address_mapper.SignIdentityMapping(mappedAddr, ethAddr, ethKey)
+	require.NoError(t, err)
+	require.NoError(t, am.AddIdentityMapping(amCtx, &amtypes.AddressMapperAddIdentityMappingRequest{
+		From:      mappedAddr.MarshalPB(),
+		To:        ethAddr.MarshalPB(),
+		Signature: sig,
+	}))
+
+	// the deployer whitelist applies to the account the sender is mapped to
+	require.Error(t, handler.ValidateOrigin(state, deployTxBytes))
+	require.NoError(t, handler.ValidateOrigin(state, callTxBytes))
+
+	handler = NewOriginValidator(period, append(allowedDeplolyers, mappedAddr), true, true, authCfg, createAddressMapperCtx)
+	handler.Reset(1)
+	require.NoError(t, handler.ValidateOrigin(state, deployTxBytes))
+}
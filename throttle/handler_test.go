@@ -3,8 +3,17 @@ package throttle
 import (
 	"testing"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/go-diadem/auth"
+	"github.com/diademnetwork/go-diadem/types"
 	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/diademnetwork/diademchain"
+	lauth "github.com/diademnetwork/diademchain/auth"
+	"github.com/diademnetwork/diademchain/store"
 )
 
 const (
@@ -20,7 +29,7 @@ var (
 )
 
 func TestDeployValidator(t *testing.T) {
-	handler := NewOriginValidator(period, allowedDeplolyers, true, true)
+	handler := NewOriginValidator(period, allowedDeplolyers, true, true, nil, nil)
 	nonce2 := uint64(1)
 	nonce3 := uint64(1)
 	nonce4 := uint64(1)
@@ -128,3 +137,79 @@ func TestDeployValidator(t *testing.T) {
 	require.NoError(t, handler.validateCaller(addr4, nonce4, height))
 	nonce4++
 }
+
+func TestOriginValidatorRotatedKey(t *testing.T) {
+	state := diademchain.NewStoreState(nil, store.NewMemStore(), abci.Header{ChainID: "default", Height: 1}, nil, nil)
+	state.SetFeature(diademchain.AuthKeyRotationFeature, true)
+
+	pub0, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	pub1, priv1, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	account := diadem.Address{ChainID: "default", Local: diadem.LocalAddressFromPublicKey(pub0)}
+
+	handler := NewOriginValidator(period, []diadem.Address{account}, true, true, nil, nil)
+	handler.Reset(1)
+
+	// the deployer is only allowed once the new key has been rotated into the whitelisted account
+	deployTx := mockOriginSignedTx(t, priv1, deployId)
+	require.Error(t, handler.ValidateOrigin(state, deployTx))
+	require.NoError(t, lauth.RotateKey(state, account, &lauth.RotateKeyTx{
+		NewPublicKey:    pub1,
+		NewKeySignature: ed25519.Sign(priv1, lauth.RotateKeyMessage(account)),
+	}, nil))
+	require.NoError(t, handler.ValidateOrigin(state, deployTx))
+
+	// key rotation txs are passed through, unknown txs are rejected
+	require.NoError(t, handler.ValidateOrigin(state, mockOriginSignedTx(t, priv1, rotateKeyId)))
+	require.Error(t, handler.ValidateOrigin(state, mockOriginSignedTx(t, priv1, migrationId)))
+	require.Error(t, handler.ValidateOrigin(state, mockOriginSignedTx(t, priv1, 7)))
+}
+
+func TestOriginValidatorSessionKey(t *testing.T) {
+	state := diademchain.NewStoreState(nil, store.NewMemStore(), abci.Header{ChainID: "default", Height: 1}, nil, nil)
+	state.SetFeature(diademchain.AuthSessionKeysFeature, true)
+
+	ownerPubKey, ownerPrivKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	sessionPubKey, sessionPrivKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	owner := diadem.Address{ChainID: "default", Local: diadem.LocalAddressFromPublicKey(ownerPubKey)}
+
+	handler := NewOriginValidator(period, allowedDeplolyers, true, true, nil, nil)
+	handler.Reset(1)
+
+	// grant & revoke txs are passed through
+	require.NoError(t, handler.ValidateOrigin(state, mockOriginSignedTx(t, ownerPrivKey, grantSessionKeyId)))
+	require.NoError(t, handler.ValidateOrigin(state, mockOriginSignedTx(t, ownerPrivKey, revokeSessionKeyId)))
+
+	require.NoError(t, lauth.GrantSessionKey(state, owner, &lauth.GrantSessionKeyTx{
+		PublicKey:    sessionPubKey,
+		KeySignature: ed25519.Sign(sessionPrivKey, lauth.SessionKeyMessage(owner)),
+		Contracts:    []*types.Address{addr2.MarshalPB()},
+		MaxTxCount:   10,
+		ExpiryHeight: 100,
+	}, 0))
+
+	// calls signed by the session key count towards the call limit of the owner
+	require.NoError(t, handler.ValidateOrigin(state, mockOriginSignedTx(t, ownerPrivKey, callId)))
+	sessionTx, err := proto.Marshal(&auth.NonceTx{Inner: mockOriginTx(t, callId), Sequence: 2})
+	require.NoError(t, err)
+	sessionSignedTx, err := proto.Marshal(auth.SignTx(auth.NewEd25519Signer(sessionPrivKey), sessionTx))
+	require.NoError(t, err)
+	require.Error(t, handler.ValidateOrigin(state, sessionSignedTx))
+}
+
+func mockOriginTx(t *testing.T, id uint32) []byte {
+	tx, err := proto.Marshal(&types.Transaction{Id: id, Data: []byte("data")})
+	require.NoError(t, err)
+	return tx
+}
+
+func mockOriginSignedTx(t *testing.T, privKey ed25519.PrivateKey, id uint32) []byte {
+	nonceTx, err := proto.Marshal(&auth.NonceTx{Inner: mockOriginTx(t, id), Sequence: 1})
+	require.NoError(t, err)
+	signedTx, err := proto.Marshal(auth.SignTx(auth.NewEd25519Signer(privKey), nonceTx))
+	require.NoError(t, err)
+	return signedTx
+}
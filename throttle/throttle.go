@@ -19,9 +19,12 @@ import (
 )
 
 const (
-	deployId    = uint32(1)
-	callId      = uint32(2)
-	migrationId = uint32(3)
+	deployId           = uint32(1)
+	callId             = uint32(2)
+	migrationId        = uint32(3)
+	rotateKeyId        = uint32(4)
+	grantSessionKeyId  = uint32(5)
+	revokeSessionKeyId = uint32(6)
 )
 
 type Throttle struct {
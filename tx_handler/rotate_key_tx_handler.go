@@ -0,0 +1,55 @@
+package tx_handler
+
+import (
+	"fmt"
+
+	proto "github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+
+	diadem "github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/auth"
+	"github.com/diademnetwork/diademchain/vm"
+)
+
+// RotateKeyTxHandler handles RotateKeyTx(s), which replace the key that controls the sender's
+// account without changing the account address.
+type RotateKeyTxHandler struct {
+	// Used to check that the new key doesn't already hold any coins, may be nil.
+	HasBalance auth.HasBalanceFunc
+}
+
+func (h *RotateKeyTxHandler) ProcessTx(
+	state diademchain.State,
+	txBytes []byte,
+	isCheckTx bool,
+) (diademchain.TxHandlerResult, error) {
+	var r diademchain.TxHandlerResult
+
+	if !state.FeatureEnabled(diademchain.AuthKeyRotationFeature, false) {
+		return r, fmt.Errorf("RotateKeyTx feature hasn't been enabled")
+	}
+
+	var msg vm.MessageTx
+	err := proto.Unmarshal(txBytes, &msg)
+	if err != nil {
+		return r, err
+	}
+
+	origin := auth.Origin(state.Context())
+	caller := diadem.UnmarshalAddressPB(msg.From)
+
+	if caller.Compare(origin) != 0 {
+		return r, fmt.Errorf("Origin doesn't match caller: - %v != %v", origin, caller)
+	}
+
+	var tx auth.RotateKeyTx
+	if err := proto.Unmarshal(msg.Data, &tx); err != nil {
+		return r, errors.Wrap(err, "failed to unmarshal RotateKeyTx")
+	}
+
+	if err := auth.RotateKey(state, origin, &tx, h.HasBalance); err != nil {
+		return r, errors.Wrapf(err, "failed to rotate key of account %s", origin.String())
+	}
+	return r, nil
+}
@@ -0,0 +1,99 @@
+package tx_handler
+
+import (
+	"context"
+	"testing"
+
+	proto "github.com/gogo/protobuf/proto"
+	"github.com/diademnetwork/go-diadem"
+	lauth "github.com/diademnetwork/go-diadem/auth"
+	"github.com/diademnetwork/go-diadem/vm"
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/auth"
+	"github.com/diademnetwork/diademchain/store"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestRotateKeyTxHandler(t *testing.T) {
+	state := diademchain.NewStoreState(nil, store.NewMemStore(), abci.Header{ChainID: "default"}, nil, nil)
+
+	pub0, priv0, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	pub1, priv1, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	pub2, priv2, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	account := diadem.Address{ChainID: "default", Local: diadem.LocalAddressFromPublicKey(pub0)}
+	ctx := context.WithValue(state.Context(), auth.ContextKeyOrigin, account)
+	s := state.WithContext(ctx)
+
+	var fundedAccount diadem.Address
+	handler := &RotateKeyTxHandler{
+		HasBalance: func(_ diademchain.State, addr diadem.Address) (bool, error) {
+			return addr.Compare(fundedAccount) == 0, nil
+		},
+	}
+	rotateTx1 := mockRotateKeyTx(t, account, pub1, priv1)
+
+	// Expect an error if key rotation is not enabled
+	_, err = handler.ProcessTx(s, rotateTx1, false)
+	require.Error(t, err)
+
+	state.SetFeature(diademchain.AuthKeyRotationFeature, true)
+
+	// The new key must sign the account address
+	_, err = handler.ProcessTx(s, mockRotateKeyTx(t, account, pub1, priv2), false)
+	require.Error(t, err)
+
+	_, err = handler.ProcessTx(s, rotateTx1, false)
+	require.NoError(t, err)
+	require.Equal(t, []byte(pub1), auth.AccountKey(state, account))
+
+	// Txs signed by the new key are attributed to the account, txs signed by the old key are rejected
+	origin, err := auth.ResolveOrigin(state, mockSignedTx(priv1))
+	require.NoError(t, err)
+	require.Equal(t, account.String(), origin.String())
+	_, err = auth.ResolveOrigin(state, mockSignedTx(priv0))
+	require.Error(t, err)
+
+	// Keys can't be reused
+	_, err = handler.ProcessTx(s, mockRotateKeyTx(t, account, pub0, priv0), false)
+	require.Error(t, err)
+
+	// Keys whose address already holds coins can't be used either
+	fundedAccount = diadem.Address{ChainID: "default", Local: diadem.LocalAddressFromPublicKey(pub2)}
+	_, err = handler.ProcessTx(s, mockRotateKeyTx(t, account, pub2, priv2), false)
+	require.Error(t, err)
+	fundedAccount = diadem.Address{}
+
+	_, err = handler.ProcessTx(s, mockRotateKeyTx(t, account, pub2, priv2), false)
+	require.NoError(t, err)
+	origin, err = auth.ResolveOrigin(state, mockSignedTx(priv2))
+	require.NoError(t, err)
+	require.Equal(t, account.String(), origin.String())
+	_, err = auth.ResolveOrigin(state, mockSignedTx(priv1))
+	require.Error(t, err)
+}
+
+func mockRotateKeyTx(t *testing.T, account diadem.Address, newPubKey ed25519.PublicKey, signingKey ed25519.PrivateKey) []byte {
+	rotateKeyTx, err := proto.Marshal(&auth.RotateKeyTx{
+		NewPublicKey:    newPubKey,
+		NewKeySignature: ed25519.Sign(signingKey, auth.RotateKeyMessage(account)),
+	})
+	require.NoError(t, err)
+
+	messageTx, err := proto.Marshal(&vm.MessageTx{
+		Data: rotateKeyTx,
+		To:   account.MarshalPB(),
+		From: account.MarshalPB(),
+	})
+	require.NoError(t, err)
+	return messageTx
+}
+
+func mockSignedTx(privKey ed25519.PrivateKey) auth.SignedTx {
+	return *lauth.SignTx(lauth.NewEd25519Signer(privKey), []byte("tx"))
+}
@@ -0,0 +1,91 @@
+package tx_handler
+
+import (
+	"fmt"
+
+	proto "github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+
+	diadem "github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/auth"
+	"github.com/diademnetwork/diademchain/vm"
+)
+
+// GrantSessionKeyTxHandler handles GrantSessionKeyTx(s), which grant a session key to the sender's
+// account.
+type GrantSessionKeyTxHandler struct {
+	// Max number of blocks a session key can remain valid for, zero means there's no limit.
+	MaxLifetime int64
+}
+
+func (h *GrantSessionKeyTxHandler) ProcessTx(
+	state diademchain.State,
+	txBytes []byte,
+	isCheckTx bool,
+) (diademchain.TxHandlerResult, error) {
+	var r diademchain.TxHandlerResult
+
+	origin, data, err := unmarshalSessionKeyMessageTx(state, txBytes)
+	if err != nil {
+		return r, err
+	}
+
+	var tx auth.GrantSessionKeyTx
+	if err := proto.Unmarshal(data, &tx); err != nil {
+		return r, errors.Wrap(err, "failed to unmarshal GrantSessionKeyTx")
+	}
+
+	if err := auth.GrantSessionKey(state, origin, &tx, h.MaxLifetime); err != nil {
+		return r, errors.Wrapf(err, "failed to grant session key to account %s", origin.String())
+	}
+	return r, nil
+}
+
+// RevokeSessionKeyTxHandler handles RevokeSessionKeyTx(s), which revoke a session key previously
+// granted to the sender's account.
+type RevokeSessionKeyTxHandler struct {
+}
+
+func (h *RevokeSessionKeyTxHandler) ProcessTx(
+	state diademchain.State,
+	txBytes []byte,
+	isCheckTx bool,
+) (diademchain.TxHandlerResult, error) {
+	var r diademchain.TxHandlerResult
+
+	origin, data, err := unmarshalSessionKeyMessageTx(state, txBytes)
+	if err != nil {
+		return r, err
+	}
+
+	var tx auth.RevokeSessionKeyTx
+	if err := proto.Unmarshal(data, &tx); err != nil {
+		return r, errors.Wrap(err, "failed to unmarshal RevokeSessionKeyTx")
+	}
+
+	if err := auth.RevokeSessionKey(state, origin, &tx); err != nil {
+		return r, errors.Wrapf(err, "failed to revoke session key of account %s", origin.String())
+	}
+	return r, nil
+}
+
+// unmarshalSessionKeyMessageTx returns the origin of the given MessageTx, and its payload.
+func unmarshalSessionKeyMessageTx(state diademchain.State, txBytes []byte) (diadem.Address, []byte, error) {
+	if !state.FeatureEnabled(diademchain.AuthSessionKeysFeature, false) {
+		return diadem.Address{}, nil, fmt.Errorf("session keys feature hasn't been enabled")
+	}
+
+	var msg vm.MessageTx
+	if err := proto.Unmarshal(txBytes, &msg); err != nil {
+		return diadem.Address{}, nil, err
+	}
+
+	origin := auth.Origin(state.Context())
+	caller := diadem.UnmarshalAddressPB(msg.From)
+
+	if caller.Compare(origin) != 0 {
+		return diadem.Address{}, nil, fmt.Errorf("Origin doesn't match caller: - %v != %v", origin, caller)
+	}
+	return origin, msg.Data, nil
+}
@@ -0,0 +1,81 @@
+package tx_handler
+
+import (
+	"context"
+	"testing"
+
+	proto "github.com/gogo/protobuf/proto"
+	"github.com/diademnetwork/go-diadem"
+	"github.com/diademnetwork/go-diadem/types"
+	"github.com/diademnetwork/go-diadem/vm"
+	"github.com/diademnetwork/diademchain"
+	"github.com/diademnetwork/diademchain/auth"
+	"github.com/diademnetwork/diademchain/store"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestSessionKeyTxHandlers(t *testing.T) {
+	state := diademchain.NewStoreState(nil, store.NewMemStore(), abci.Header{ChainID: "default", Height: 5}, nil, nil)
+
+	pub0, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	sessionPub, sessionPriv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	account := diadem.Address{ChainID: "default", Local: diadem.LocalAddressFromPublicKey(pub0)}
+	contract := diadem.MustParseAddress("default:0x9a1aC42a17AAD6Dbc6d21c162989d0f701074044")
+	ctx := context.WithValue(state.Context(), auth.ContextKeyOrigin, account)
+	s := state.WithContext(ctx)
+
+	grantHandler := &GrantSessionKeyTxHandler{}
+	revokeHandler := &RevokeSessionKeyTxHandler{}
+	grantTx := mockSessionKeyMessageTx(t, account, &auth.GrantSessionKeyTx{
+		PublicKey:    sessionPub,
+		KeySignature: ed25519.Sign(sessionPriv, auth.SessionKeyMessage(account)),
+		Contracts:    []*types.Address{contract.MarshalPB()},
+		MaxTxCount:   10,
+		ExpiryHeight: 100,
+	})
+	revokeTx := mockSessionKeyMessageTx(t, account, &auth.RevokeSessionKeyTx{
+		PublicKey: sessionPub,
+	})
+
+	// Expect an error if session keys are not enabled
+	_, err = grantHandler.ProcessTx(s, grantTx, false)
+	require.Error(t, err)
+
+	state.SetFeature(diademchain.AuthSessionKeysFeature, true)
+
+	_, err = grantHandler.ProcessTx(s, grantTx, false)
+	require.NoError(t, err)
+	sessionKey, err := auth.GetSessionKey(state, sessionPub)
+	require.NoError(t, err)
+	require.NotNil(t, sessionKey)
+	require.Equal(t, account.String(), diadem.UnmarshalAddressPB(sessionKey.Owner).String())
+	require.Equal(t, uint64(10), sessionKey.MaxTxCount)
+
+	_, err = revokeHandler.ProcessTx(s, revokeTx, false)
+	require.NoError(t, err)
+	sessionKey, err = auth.GetSessionKey(state, sessionPub)
+	require.NoError(t, err)
+	require.Nil(t, sessionKey)
+
+	// Can't revoke the same key twice
+	_, err = revokeHandler.ProcessTx(s, revokeTx, false)
+	require.Error(t, err)
+}
+
+func mockSessionKeyMessageTx(t *testing.T, account diadem.Address, tx proto.Message) []byte {
+	data, err := proto.Marshal(tx)
+	require.NoError(t, err)
+
+	messageTx, err := proto.Marshal(&vm.MessageTx{
+		Data: data,
+		To:   account.MarshalPB(),
+		From: account.MarshalPB(),
+	})
+	require.NoError(t, err)
+	return messageTx
+}